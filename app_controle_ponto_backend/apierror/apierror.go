@@ -0,0 +1,194 @@
+// Package apierror writes every error response as an RFC 7807 problem detail, with the
+// application/problem+json media type:
+//
+//	{
+//	  "type": "urn:controle-ponto:problem:validation_failed",
+//	  "title": "Validation failed",
+//	  "status": 400,
+//	  "detail": "The request has invalid fields",
+//	  "instance": "/api/register",
+//	  "code": "validation_failed",
+//	  "request_id": "4f9c2a7d1e8b4c3a9d0e6f1b2c3d4e5f",
+//	  "errors": [{"field": "email", "code": "invalid", "message": "must be a valid email address"}]
+//	}
+//
+// code is stable for clients to branch on and is never translated. title, the same for
+// every occurrence of a code, detail, which explains this occurrence, and the field
+// messages are translated to the request locale, so they can be shown to users but not
+// matched on.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/logging"
+	"controle-ponto-api/validation"
+)
+
+// ContentType is the media type of error responses.
+const ContentType = "application/problem+json"
+
+// TypePrefix prefixes the code to form the problem type URI.
+const TypePrefix = "urn:controle-ponto:problem:"
+
+// Codes of errors clients are expected to handle. Errors without a specific code use the
+// default of their status (see CodeFor).
+const (
+	CodeValidation  = "validation_failed"
+	CodeInvalidJSON = "invalid_json"
+
+	CodeTokenMissing = "token_missing"
+	CodeTokenInvalid = "token_invalid"
+
+	CodeInvalidCredentials       = "invalid_credentials"
+	CodeEmailTaken               = "email_taken"
+	CodeEmailNotVerified         = "email_not_verified"
+	CodeAccountLocked            = "account_locked"
+	CodeLoginThrottled           = "login_throttled"
+	CodeRateLimited              = "rate_limited"
+	CodeVerificationTokenInvalid = "verification_token_invalid"
+
+	CodeMFAChallengeInvalid     = "mfa_challenge_invalid"
+	CodeMFACodeInvalid          = "mfa_code_invalid"
+	CodeMFAEnrollmentRequired   = "mfa_enrollment_required"
+	CodeMFAAlreadyEnabled       = "mfa_already_enabled"
+	CodeMFAEnrollmentNotStarted = "mfa_enrollment_not_started"
+
+	CodeIdentityProviderNotConfigured   = "identity_provider_not_configured"
+	CodeIdentityProviderUnavailable     = "identity_provider_unavailable"
+	CodeIdentityProviderDiscoveryFailed = "identity_provider_discovery_failed"
+	CodeEmailDomainTaken                = "email_domain_taken"
+	CodeDomainVerificationFailed        = "domain_verification_failed"
+
+	CodePontoTooClose          = "ponto_too_close"
+	CodeLeaveRequestOverlap    = "leave_request_overlap"
+	CodeLeaveRequestNotPending = "leave_request_not_pending"
+	CodeAttachmentRequired     = "attachment_required"
+	CodeAdjustmentNotPending   = "adjustment_not_pending"
+	CodeDeliveryNotFailed      = "delivery_not_failed"
+	CodeReportNotReady         = "report_not_ready"
+
+	CodeIdempotencyKeyReused     = "idempotency_key_reused"
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+)
+
+var titles = map[string]string{
+	CodeValidation:  "Validation failed",
+	CodeInvalidJSON: "Malformed request body",
+
+	CodeTokenMissing: "Authentication required",
+	CodeTokenInvalid: "Invalid or expired session",
+
+	CodeInvalidCredentials:       "Invalid credentials",
+	CodeEmailTaken:               "Email already registered",
+	CodeEmailNotVerified:         "Email not verified",
+	CodeAccountLocked:            "Account temporarily locked",
+	CodeLoginThrottled:           "Too many login attempts",
+	CodeRateLimited:              "Too many requests",
+	CodeVerificationTokenInvalid: "Invalid or expired link",
+
+	CodeMFAChallengeInvalid:     "Invalid or expired two-factor challenge",
+	CodeMFACodeInvalid:          "Invalid two-factor code",
+	CodeMFAEnrollmentRequired:   "Two-factor authentication required",
+	CodeMFAAlreadyEnabled:       "Two-factor authentication already enabled",
+	CodeMFAEnrollmentNotStarted: "Two-factor enrollment not started",
+
+	CodeIdentityProviderNotConfigured:   "No identity provider configured",
+	CodeIdentityProviderUnavailable:     "Identity provider unavailable",
+	CodeIdentityProviderDiscoveryFailed: "Identity provider discovery failed",
+	CodeEmailDomainTaken:                "Email domain already in use",
+	CodeDomainVerificationFailed:        "Domain ownership not verified",
+
+	CodePontoTooClose:          "Punch too close to another one",
+	CodeLeaveRequestOverlap:    "Overlapping leave request",
+	CodeLeaveRequestNotPending: "Leave request already decided",
+	CodeAttachmentRequired:     "Attachment required",
+	CodeAdjustmentNotPending:   "Adjustment request already decided",
+	CodeDeliveryNotFailed:      "Delivery did not fail",
+	CodeReportNotReady:         "Report not ready",
+
+	CodeIdempotencyKeyReused:     "Idempotency key reused",
+	CodeIdempotencyKeyInProgress: "Request still in progress",
+}
+
+// Problem is an RFC 7807 problem detail, extended with code, request_id and errors.
+type Problem struct {
+	Type string `json:"type" example:"urn:controle-ponto:problem:validation_failed"`
+	// Title summarizes the code, in the request locale.
+	Title  string `json:"title" example:"Validation failed"`
+	Status int    `json:"status" example:"400"`
+	// Detail explains this occurrence, in the request locale.
+	Detail   string `json:"detail,omitempty" example:"The request has invalid fields"`
+	Instance string `json:"instance,omitempty" example:"/api/register"`
+	// Code is stable across locales, for clients to branch on.
+	Code      string                  `json:"code" example:"validation_failed"`
+	RequestID string                  `json:"request_id,omitempty" example:"4f9c2a7d1e8b4c3a9d0e6f1b2c3d4e5f"`
+	Errors    []validation.FieldError `json:"errors,omitempty"`
+}
+
+// CodeFor returns the default code of a status, its snake_case reason phrase
+// ("not_found", "too_many_requests").
+func CodeFor(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// Title returns the English title of code, falling back to the reason phrase of status for
+// codes without one.
+func Title(code string, status int) string {
+	if title, ok := titles[code]; ok {
+		return title
+	}
+	return http.StatusText(status)
+}
+
+// Write responds with a problem of the given status and code. An empty code uses the
+// default of status.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	WriteProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// WriteFields responds 400 with the field errors.
+func WriteFields(w http.ResponseWriter, r *http.Request, fields validation.Errors) {
+	WriteProblem(w, r, Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidation,
+		Detail: "The request has invalid fields",
+		Errors: fields,
+	})
+}
+
+// WriteProblem responds with p, filling in the members derived from the request and the
+// code, and translating title, detail and field messages.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	locale := i18n.FromContext(r.Context())
+	if p.Code == "" {
+		p.Code = CodeFor(p.Status)
+	}
+	if p.Type == "" {
+		p.Type = TypePrefix + p.Code
+	}
+	if p.Title == "" {
+		p.Title = Title(p.Code, p.Status)
+	}
+	p.Title = i18n.T(locale, p.Title)
+	p.Detail = i18n.T(locale, p.Detail)
+	if len(p.Errors) > 0 {
+		p.Errors = validation.Errors(p.Errors).Localize(func(format string, args ...interface{}) string {
+			return i18n.T(locale, format, args...)
+		})
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = logging.RequestID(r.Context())
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
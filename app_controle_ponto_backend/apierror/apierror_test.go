@@ -0,0 +1,50 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/validation"
+)
+
+func TestWriteProblemTranslates(t *testing.T) {
+	tests := []struct {
+		locale                 string
+		title, detail, message string
+	}{
+		{i18n.English, "Punch too close to another one", "Invalid user ID", "must not be negative"},
+		{i18n.PortugueseBR, "Ponto muito próximo de outro", "ID de usuário inválido", "não pode ser negativo"},
+		{i18n.Spanish, "Fichaje demasiado cercano a otro", "ID de usuario no válido", "no puede ser negativo"},
+	}
+	var v validation.Validator
+	v.Check(false, "x", validation.CodeOutOfRange, "must not be negative")
+	fields := v.Err().(validation.Errors)
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/pontos", nil)
+			r = r.WithContext(i18n.WithLocale(r.Context(), tt.locale))
+			rec := httptest.NewRecorder()
+			WriteProblem(rec, r, Problem{
+				Status: http.StatusConflict,
+				Code:   CodePontoTooClose,
+				Detail: "Invalid user ID",
+				Errors: fields,
+			})
+
+			var p Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Title != tt.title || p.Detail != tt.detail {
+				t.Errorf("title %q, detail %q; want %q, %q", p.Title, p.Detail, tt.title, tt.detail)
+			}
+			if len(p.Errors) != 1 || p.Errors[0].Message != tt.message {
+				t.Errorf("errors = %+v, want message %q", p.Errors, tt.message)
+			}
+		})
+	}
+}
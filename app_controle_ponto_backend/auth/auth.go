@@ -0,0 +1,270 @@
+// Package auth issues and validates the API access tokens (JWT).
+//
+// Tokens are signed with the active key and carry its ID in the kid header. Validation
+// looks the key up by kid among every configured key, so a new key can be activated while
+// tokens signed with the previous ones remain valid until they expire.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// ErrInvalidToken is returned, wrapped, for every token that fails validation.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the claims of an access token.
+type Claims struct {
+	UserID int64 `json:"user_id"`
+	// Purpose is empty on access tokens and names the single step a restricted token
+	// authorizes, such as PurposeMFA.
+	Purpose string `json:"purpose,omitempty"`
+	// Version is the user's token version when the token was issued. Bumping the stored
+	// version revokes every token issued before, which the caller checks after Validate.
+	Version int64 `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+// Purposes of restricted tokens.
+const (
+	// PurposeMFA lets a user who passed the password check complete the second factor.
+	PurposeMFA = "mfa"
+	// PurposeMFAEnrollment lets a user who must use a second factor enroll one.
+	PurposeMFAEnrollment = "mfa_enrollment"
+)
+
+// Key is a signing or verification key identified by ID (the kid header).
+type Key struct {
+	ID        string
+	Algorithm string
+	method    jwt.SigningMethod
+	// signKey is nil for keys that only verify tokens.
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// NewHMACKey creates an HS256 key.
+func NewHMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("HMAC secret must not be empty")
+	}
+	return newKey(id, AlgHS256, jwt.SigningMethodHS256, secret, secret)
+}
+
+// HMACKeyID derives a stable key ID from an HMAC secret, for secrets configured without one.
+func HMACKeyID(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "hs256-" + hex.EncodeToString(sum[:6])
+}
+
+// NewRSAKey creates an RS256 key. priv may be nil for a key that only verifies tokens.
+func NewRSAKey(id string, priv *rsa.PrivateKey, pub *rsa.PublicKey) (*Key, error) {
+	if pub == nil && priv != nil {
+		pub = &priv.PublicKey
+	}
+	if pub == nil {
+		return nil, errors.New("RSA key requires a public or private key")
+	}
+	if pub.N.BitLen() < 2048 {
+		return nil, errors.New("RSA key must have at least 2048 bits")
+	}
+	var sign interface{}
+	if priv != nil {
+		sign = priv
+	}
+	return newKey(id, AlgRS256, jwt.SigningMethodRS256, sign, pub)
+}
+
+// NewEd25519Key creates an EdDSA key. priv may be nil for a key that only verifies tokens.
+func NewEd25519Key(id string, priv ed25519.PrivateKey, pub ed25519.PublicKey) (*Key, error) {
+	if pub == nil && priv != nil {
+		pub = priv.Public().(ed25519.PublicKey)
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("Ed25519 key requires a public or private key")
+	}
+	var sign interface{}
+	if priv != nil {
+		sign = priv
+	}
+	return newKey(id, AlgEdDSA, jwt.SigningMethodEdDSA, sign, pub)
+}
+
+func newKey(id, alg string, method jwt.SigningMethod, sign, verify interface{}) (*Key, error) {
+	if id == "" {
+		return nil, errors.New("key ID must not be empty")
+	}
+	return &Key{ID: id, Algorithm: alg, method: method, signKey: sign, verifyKey: verify}, nil
+}
+
+// CanSign reports whether the key has private material.
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// Options are the registered claims set on issued tokens and required on validated ones.
+type Options struct {
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Leeway tolerates clock skew between servers when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+// Issuer issues tokens with the active key and validates tokens signed by any known key.
+type Issuer struct {
+	opts    Options
+	signing *Key
+	keys    map[string]*Key
+	order   []*Key
+	parser  *jwt.Parser
+}
+
+// NewIssuer creates an Issuer that signs with the key signingKeyID, which must be among keys.
+func NewIssuer(opts Options, signingKeyID string, keys ...*Key) (*Issuer, error) {
+	if opts.Issuer == "" || opts.Audience == "" {
+		return nil, errors.New("issuer and audience are required")
+	}
+	if opts.TTL <= 0 {
+		return nil, errors.New("token TTL must be positive")
+	}
+
+	i := &Issuer{opts: opts, keys: make(map[string]*Key, len(keys))}
+	var algs []string
+	for _, k := range keys {
+		if _, dup := i.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate key ID %q", k.ID)
+		}
+		i.keys[k.ID] = k
+		i.order = append(i.order, k)
+		algs = append(algs, k.Algorithm)
+	}
+
+	i.signing = i.keys[signingKeyID]
+	if i.signing == nil {
+		return nil, fmt.Errorf("signing key %q not found", signingKeyID)
+	}
+	if !i.signing.CanSign() {
+		return nil, fmt.Errorf("signing key %q has no private key", signingKeyID)
+	}
+
+	i.parser = jwt.NewParser(
+		jwt.WithValidMethods(algs),
+		jwt.WithIssuer(opts.Issuer),
+		jwt.WithAudience(opts.Audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opts.Leeway),
+	)
+	return i, nil
+}
+
+// Issue creates a signed access token for the user, carrying the user's current token
+// version.
+func (i *Issuer) Issue(userID, version int64) (string, *Claims, error) {
+	return i.issue(userID, version, "", i.opts.TTL)
+}
+
+// IssuePurpose creates a token that is only accepted by ValidatePurpose with the same
+// purpose, never as an access token.
+func (i *Issuer) IssuePurpose(userID, version int64, purpose string, ttl time.Duration) (string, *Claims, error) {
+	if purpose == "" {
+		return "", nil, errors.New("purpose must not be empty")
+	}
+	return i.issue(userID, version, purpose, ttl)
+}
+
+func (i *Issuer) issue(userID, version int64, purpose string, ttl time.Duration) (string, *Claims, error) {
+	now := time.Now()
+	jti, err := newTokenID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims := &Claims{
+		UserID:  userID,
+		Purpose: purpose,
+		Version: version,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.opts.Issuer,
+			Subject:   strconv.FormatInt(userID, 10),
+			Audience:  jwt.ClaimStrings{i.opts.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+	}
+
+	token := jwt.NewWithClaims(i.signing.method, claims)
+	token.Header["kid"] = i.signing.ID
+	signed, err := token.SignedString(i.signing.signKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// Validate parses an access token, checking the signature against the key named by kid,
+// the algorithm expected for that key and the exp, nbf, iat, iss, aud and jti claims.
+func (i *Issuer) Validate(tokenString string) (*Claims, error) {
+	return i.validate(tokenString, "")
+}
+
+// ValidatePurpose is Validate for tokens created by IssuePurpose with the given purpose.
+func (i *Issuer) ValidatePurpose(tokenString, purpose string) (*Claims, error) {
+	if purpose == "" {
+		return nil, errors.New("purpose must not be empty")
+	}
+	return i.validate(tokenString, purpose)
+}
+
+func (i *Issuer) validate(tokenString, purpose string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := i.parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := i.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		// Each key is bound to one algorithm, so an HS256 token can't be verified with an RSA public key
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("algorithm %s not allowed for key %q", token.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.ID == "" || claims.UserID <= 0 || claims.Subject != strconv.FormatInt(claims.UserID, 10) {
+		return nil, fmt.Errorf("%w: missing or inconsistent jti, sub or user_id", ErrInvalidToken)
+	}
+	if claims.Purpose != purpose {
+		return nil, fmt.Errorf("%w: token purpose %q not accepted here", ErrInvalidToken, claims.Purpose)
+	}
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var opcoesTeste = Options{Issuer: "controle-ponto", Audience: "controle-ponto-api", TTL: time.Hour}
+
+// chavesTeste cria uma chave HS256 e uma EdDSA, com os IDs "antiga" e "nova".
+func chavesTeste(t *testing.T) (antiga, nova *Key) {
+	t.Helper()
+	antiga, err := NewHMACKey("antiga", []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nova, err = NewEd25519Key("nova", priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return antiga, nova
+}
+
+// assinar gera um token com claims válidas para o usuário 5, alteradas por ajustar, e o
+// assina com method e key colocando kid no cabeçalho.
+func assinar(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, ajustar func(*Claims)) string {
+	t.Helper()
+	now := time.Now()
+	claims := &Claims{
+		UserID: 5,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    opcoesTeste.Issuer,
+			Subject:   strconv.Itoa(5),
+			Audience:  jwt.ClaimStrings{opcoesTeste.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        "jti-de-teste",
+		},
+	}
+	if ajustar != nil {
+		ajustar(claims)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyRotation(t *testing.T) {
+	antiga, nova := chavesTeste(t)
+	antes, err := NewIssuer(opcoesTeste, "antiga", antiga)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// During the rotation the new key signs and the old one still verifies
+	durante, err := NewIssuer(opcoesTeste, "nova", antiga, nova)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Once the old tokens have expired the old key is removed
+	depois, err := NewIssuer(opcoesTeste, "nova", nova)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenAntigo, _, err := antes.Issue(5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenNovo, _, err := durante.Issue(5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		issuer *Issuer
+		token  string
+		valid  bool
+	}{
+		{"old token during rotation", durante, tokenAntigo, true},
+		{"new token during rotation", durante, tokenNovo, true},
+		{"new token after rotation", depois, tokenNovo, true},
+		{"old token after its key is removed", depois, tokenAntigo, false},
+		{"new token on an instance not yet rotated", antes, tokenNovo, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.issuer.Validate(tt.token)
+			if tt.valid && (err != nil || claims.UserID != 5) {
+				t.Fatalf("Validate() = %+v, %v; want user 5", claims, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Validate() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	antiga, nova := chavesTeste(t)
+	issuer, err := NewIssuer(opcoesTeste, "nova", antiga, nova)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publica := []byte(nova.verifyKey.(ed25519.PublicKey))
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{
+			name:  "valid",
+			token: assinar(t, jwt.SigningMethodEdDSA, "nova", nova.signKey, nil),
+			valid: true,
+		},
+		{
+			// HS256 is accepted for the old key, but not with the kid of the EdDSA key and its
+			// public key as the HMAC secret
+			name:  "algorithm not bound to the kid",
+			token: assinar(t, jwt.SigningMethodHS256, "nova", publica, nil),
+		},
+		{
+			name:  "algorithm of no configured key",
+			token: assinar(t, jwt.SigningMethodHS512, "antiga", antiga.signKey, nil),
+		},
+		{
+			name:  "unknown kid",
+			token: assinar(t, jwt.SigningMethodHS256, "removida", antiga.signKey, nil),
+		},
+		{
+			name:  "missing kid",
+			token: assinar(t, jwt.SigningMethodHS256, "", antiga.signKey, nil),
+		},
+		{
+			name: "expired",
+			token: assinar(t, jwt.SigningMethodEdDSA, "nova", nova.signKey, func(c *Claims) {
+				c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Hour))
+				c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+			}),
+		},
+		{
+			name: "without exp",
+			token: assinar(t, jwt.SigningMethodEdDSA, "nova", nova.signKey, func(c *Claims) {
+				c.ExpiresAt = nil
+			}),
+		},
+		{
+			name: "other audience",
+			token: assinar(t, jwt.SigningMethodEdDSA, "nova", nova.signKey, func(c *Claims) {
+				c.Audience = jwt.ClaimStrings{"outra-api"}
+			}),
+		},
+		{
+			name: "subject of another user",
+			token: assinar(t, jwt.SigningMethodEdDSA, "nova", nova.signKey, func(c *Claims) {
+				c.Subject = "6"
+			}),
+		},
+		{
+			name: "restricted token",
+			token: assinar(t, jwt.SigningMethodEdDSA, "nova", nova.signKey, func(c *Claims) {
+				c.Purpose = PurposeMFA
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := issuer.Validate(tt.token)
+			if tt.valid && err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Validate() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestValidatePurpose(t *testing.T) {
+	_, nova := chavesTeste(t)
+	issuer, err := NewIssuer(opcoesTeste, "nova", nova)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restrito, _, err := issuer.IssuePurpose(5, 0, PurposeMFA, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := issuer.ValidatePurpose(restrito, PurposeMFA); err != nil {
+		t.Errorf("ValidatePurpose(%s) error = %v", PurposeMFA, err)
+	}
+	if _, err := issuer.ValidatePurpose(restrito, PurposeMFAEnrollment); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidatePurpose(%s) error = %v, want ErrInvalidToken", PurposeMFAEnrollment, err)
+	}
+}
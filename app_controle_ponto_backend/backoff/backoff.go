@@ -0,0 +1,19 @@
+// Package backoff calcula a espera entre as tentativas de uma operação que falhou, como a
+// execução de uma tarefa ou a entrega de um webhook.
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Espera retorna o intervalo antes da próxima tentativa depois de tentativa falhas: base
+// depois da primeira, dobrando a cada falha até maxima. Soma até 10% de variação, para que
+// as operações que falharam juntas não tentem de novo todas ao mesmo tempo.
+func Espera(tentativa int, base, maxima time.Duration) time.Duration {
+	espera := min(base, maxima)
+	for i := 1; i < tentativa && espera < maxima; i++ {
+		espera = min(espera*2, maxima)
+	}
+	return espera + time.Duration(rand.Int64N(int64(espera)/10+1))
+}
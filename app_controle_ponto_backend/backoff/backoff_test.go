@@ -0,0 +1,34 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEspera(t *testing.T) {
+	tests := []struct {
+		name      string
+		tentativa int
+		base      time.Duration
+		maxima    time.Duration
+		want      time.Duration
+	}{
+		{"first failure", 1, 30 * time.Second, 6 * time.Hour, 30 * time.Second},
+		{"doubles", 4, 30 * time.Second, 6 * time.Hour, 4 * time.Minute},
+		{"capped", 12, 30 * time.Second, 6 * time.Hour, 6 * time.Hour},
+		// Many attempts must not overflow the shift into a negative wait
+		{"far past the cap", 200, time.Hour, 6 * time.Hour, 6 * time.Hour},
+		{"base above the cap", 1, 2 * time.Hour, time.Hour, time.Hour},
+		{"zero attempts", 0, 10 * time.Second, time.Hour, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				got := Espera(tt.tentativa, tt.base, tt.maxima)
+				if got < tt.want || got > tt.want+tt.want/10 {
+					t.Fatalf("Espera(%d) = %s, want between %s and %s", tt.tentativa, got, tt.want, tt.want+tt.want/10)
+				}
+			}
+		})
+	}
+}
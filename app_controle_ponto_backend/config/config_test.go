@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// configProducao devolve uma configuração de produção válida, que cada caso ajusta.
+func configProducao() Config {
+	c := Default()
+	c.Env = EnvProduction
+	c.Server.CORSOrigins = []string{"https://ponto.exemplo.com"}
+	c.Database.URL = "postgres://ponto@db/ponto"
+	c.JWT.Secret = strings.Repeat("s", minJWTSecretLength)
+	c.Mail.Driver = MailDriverSMTP
+	c.Mail.SMTP.Host = "smtp.exemplo.com"
+	c.Metrics.Token = strings.Repeat("m", minMetricsTokenLength)
+	return c
+}
+
+// escreverYAML grava o conteúdo num arquivo de configuração temporário e devolve o caminho.
+func escreverYAML(t *testing.T, conteudo string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(conteudo), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	arquivo := escreverYAML(t, `
+server:
+  port: 8001
+  cors_origins: ["https://yaml.exemplo.com"]
+`)
+	tests := []struct {
+		name     string
+		arquivo  bool
+		envPort  string
+		args     []string
+		wantPort int
+		// wantCORS confirma que o que só o YAML define sobrevive às camadas acima
+		wantCORS string
+	}{
+		{"defaults", false, "", nil, 8080, "*"},
+		{"yaml over defaults", true, "", nil, 8001, "https://yaml.exemplo.com"},
+		{"env over yaml", true, "8002", nil, 8002, "https://yaml.exemplo.com"},
+		{"flag over env", true, "8002", []string{"-port", "8003"}, 8003, "https://yaml.exemplo.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Empty variables are ignored by Load, so this also hides the host's values
+			t.Setenv("APP_ENV", "")
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv("CORS_ORIGINS", "")
+			t.Setenv("PORT", tt.envPort)
+			args := tt.args
+			if tt.arquivo {
+				args = append([]string{"-config", arquivo}, args...)
+			}
+
+			cfg, err := Load(args)
+			if err != nil {
+				t.Fatalf("Load() = %v", err)
+			}
+			if cfg.Server.Port != tt.wantPort {
+				t.Errorf("port = %d, want %d", cfg.Server.Port, tt.wantPort)
+			}
+			if len(cfg.Server.CORSOrigins) != 1 || cfg.Server.CORSOrigins[0] != tt.wantCORS {
+				t.Errorf("cors origins = %v, want [%s]", cfg.Server.CORSOrigins, tt.wantCORS)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownYAMLKey(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	arquivo := escreverYAML(t, `
+server:
+  prot: 8001
+`)
+
+	_, err := Load([]string{"-config", arquivo})
+	if err == nil || !strings.Contains(err.Error(), "field prot not found") {
+		t.Errorf("Load() = %v, want an error about the unknown key prot", err)
+	}
+}
+
+func TestValidateProduction(t *testing.T) {
+	tests := []struct {
+		name    string
+		ajustar func(c *Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing JWT secret", func(c *Config) { c.JWT.Secret = "" }, "JWT secret or signing key file is required in production"},
+		{"short JWT secret", func(c *Config) { c.JWT.Secret = "curto" }, "JWT secret must have at least 32 characters in production"},
+		{"CORS wildcard", func(c *Config) { c.Server.CORSOrigins = []string{"https://ponto.exemplo.com", "*"} }, `CORS origin "*" is not allowed in production`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := configProducao()
+			tt.ajustar(&c)
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    RateLimitRule
+		wantErr string
+	}{
+		{"limited", RateLimitRule{Requests: 20, Per: time.Minute}, ""},
+		{"disabled", RateLimitRule{}, ""},
+		{"zero period", RateLimitRule{Requests: 20}, "rate_limit pontos per must be positive"},
+		{"negative period", RateLimitRule{Requests: 20, Per: -time.Minute}, "rate_limit pontos per must be positive"},
+		{"negative requests", RateLimitRule{Requests: -1, Per: time.Minute}, "rate_limit pontos requests must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := configProducao()
+			c.RateLimit.Pontos = tt.rule
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateProductionMetricsToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		enabled bool
+		token   string
+		wantErr string
+	}{
+		{"token set", EnvProduction, true, strings.Repeat("m", minMetricsTokenLength), ""},
+		{"missing token", EnvProduction, true, "", "metrics token is required in production"},
+		{"short token", EnvProduction, true, "curto", "metrics token must have at least 32 characters"},
+		{"metrics disabled", EnvProduction, false, "", ""},
+		{"development without token", EnvDevelopment, true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := configProducao()
+			c.Env = tt.env
+			c.Metrics.Enabled = tt.enabled
+			c.Metrics.Token = tt.token
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
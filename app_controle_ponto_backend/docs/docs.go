@@ -15,198 +15,239 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/login": {
-            "post": {
-                "description": "Autentica um usuário com email e senha e retorna um token JWT.",
-                "consumes": [
-                    "application/json"
+        "/afastamentos": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Lista as solicitações de afastamento do usuário. Gestores podem listar as da equipe com equipe=true ou de um usuário com user_id.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Afastamentos"
                 ],
-                "summary": "Realiza o login do usuário",
+                "summary": "Lista afastamentos",
                 "parameters": [
                     {
-                        "description": "Credenciais de login (apenas email e password são necessários)",
-                        "name": "credentials",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.User"
-                        }
+                        "type": "string",
+                        "description": "Filtra pelo status (pendente, aprovado, rejeitado, cancelado)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID do usuário consultado",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Lista os afastamentos de toda a equipe",
+                        "name": "equipe",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Afastamento"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid query parameters",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
-                    "401": {
-                        "description": "Invalid credentials",
+                    "403": {
+                        "description": "Permission denied",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/pontos": {
+            },
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Cria um novo registro de ponto com o horário atual para o usuário autenticado.",
+                "description": "Cria uma solicitação de afastamento (férias, atestado, folga...) pendente de aprovação do gestor.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Pontos"
+                    "Afastamentos"
+                ],
+                "summary": "Solicita um afastamento",
+                "parameters": [
+                    {
+                        "description": "Dados da solicitação",
+                        "name": "afastamento",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SolicitacaoAfastamentoPayload"
+                        }
+                    }
                 ],
-                "summary": "Registra um novo ponto",
                 "responses": {
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Ponto"
+                            "$ref": "#/definitions/models.Afastamento"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Overlapping leave request",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/pontos/{data}": {
+        "/afastamentos/tipos": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Lista todos os registros de ponto de um usuário para uma data específica.",
+                "description": "Lista os tipos de afastamento disponíveis para a empresa do usuário, com o tempo creditado por dia.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Pontos"
-                ],
-                "summary": "Lista os pontos por data",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Data no formato YYYY-MM-DD",
-                        "name": "data",
-                        "in": "path",
-                        "required": true
-                    }
+                    "Afastamentos"
                 ],
+                "summary": "Lista os tipos de afastamento",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/models.Ponto"
+                                "$ref": "#/definitions/models.TipoAfastamento"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Invalid date format. Use YYYY-MM-DD",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/pontos/{data}/total-horas": {
-            "get": {
+        "/afastamentos/{id}/anexos": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Calcula o total de horas trabalhadas em um dia com base nos registros de ponto (entrada/saída).",
+                "description": "Registra os metadados de um documento (por exemplo, o atestado médico) já enviado ao armazenamento de arquivos. Apenas o solicitante pode anexar, enquanto o afastamento estiver pendente.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Pontos"
+                    "Afastamentos"
                 ],
-                "summary": "Calcula horas trabalhadas",
+                "summary": "Anexa um documento a um afastamento",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Data no formato YYYY-MM-DD",
-                        "name": "data",
+                        "type": "integer",
+                        "description": "ID do afastamento",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Metadados do anexo",
+                        "name": "anexo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.AnexoAfastamentoPayload"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.AnexoAfastamento"
                         }
                     },
                     "400": {
-                        "description": "Invalid date format. Use YYYY-MM-DD",
+                        "description": "Invalid request body",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Leave request not found or permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Leave request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/pontos/{id}": {
-            "put": {
+        "/afastamentos/{id}/aprovar": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Atualiza o horário de um registro de ponto existente.",
+                "description": "Aprova uma solicitação pendente da equipe do gestor. Os dias aprovados passam a ser abonados no cálculo de horas e no banco de horas.",
                 "consumes": [
                     "application/json"
                 ],
@@ -214,24 +255,23 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Pontos"
+                    "Afastamentos"
                 ],
-                "summary": "Atualiza um registro de ponto",
+                "summary": "Aprova um afastamento",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID do Ponto",
+                        "description": "ID do afastamento",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Novo horário para o registro",
-                        "name": "horario",
+                        "description": "Observação da decisão",
+                        "name": "decisao",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handlers.PontoUpdatePayload"
+                            "$ref": "#/definitions/handlers.DecisaoAfastamentoPayload"
                         }
                     }
                 ],
@@ -239,86 +279,108 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Afastamento"
                         }
                     },
                     "400": {
-                        "description": "Invalid ID format or request body",
+                        "description": "Missing required attachment",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "404": {
-                        "description": "Ponto not found or permission denied",
+                        "description": "Leave request not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Leave request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/afastamentos/{id}/cancelar": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Deleta um registro de ponto existente.",
+                "description": "O solicitante pode cancelar uma solicitação pendente; o gestor também pode cancelar um afastamento já aprovado.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Pontos"
+                    "Afastamentos"
                 ],
-                "summary": "Deleta um registro de ponto",
+                "summary": "Cancela um afastamento",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID do Ponto",
+                        "description": "ID do afastamento",
                         "name": "id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/models.Afastamento"
                         }
                     },
-                    "400": {
-                        "description": "Invalid ID format",
+                    "403": {
+                        "description": "Permission denied",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "404": {
-                        "description": "Ponto not found or permission denied",
+                        "description": "Leave request not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Leave request can't be cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
         },
-        "/register": {
+        "/afastamentos/{id}/rejeitar": {
             "post": {
-                "description": "Cria um novo usuário no sistema com nome, email e senha.",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Rejeita uma solicitação pendente da equipe do gestor.",
                 "consumes": [
                     "application/json"
                 ],
@@ -326,83 +388,3886 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Authentication"
+                    "Afastamentos"
                 ],
-                "summary": "Registra um novo usuário",
+                "summary": "Rejeita um afastamento",
                 "parameters": [
                     {
-                        "description": "Dados do usuário para registro (ID e Horarios podem ser omitidos)",
-                        "name": "user",
+                        "type": "integer",
+                        "description": "ID do afastamento",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Observação da decisão",
+                        "name": "decisao",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.User"
+                            "$ref": "#/definitions/handlers.DecisaoAfastamentoPayload"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Afastamento"
                         }
                     },
-                    "400": {
-                        "description": "Invalid request body",
+                    "403": {
+                        "description": "Permission denied",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Leave request not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Leave request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     },
                     "500": {
-                        "description": "Failed to create user",
+                        "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/apierror.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.PontoUpdatePayload": {
-            "type": "object",
-            "properties": {
-                "horario": {
-                    "type": "string"
-                }
-            }
-        },
-        "models.Ponto": {
-            "type": "object",
-            "properties": {
-                "horario": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "user_id": {
-                    "type": "integer"
-                }
-            }
         },
-        "models.User": {
-            "type": "object",
-            "properties": {
-                "email": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
-                },
-                "nome": {
-                    "type": "string"
-                },
-                "password": {
-                    "description": "omitempty so it's not sent in responses",
+        "/ajustes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lista as solicitações de ajuste do usuário. Gestores podem listar as da equipe com equipe=true ou de um usuário com user_id.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ajustes"
+                ],
+                "summary": "Lista ajustes de ponto",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filtra pelo status (pendente, aprovado, rejeitado)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID do usuário consultado",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Lista os ajustes de toda a equipe",
+                        "name": "equipe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.AjustePonto"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Pede a correção do horário de um ponto do usuário, ou a inclusão de um ponto esquecido quando ponto_id é omitido. O ponto só muda quando o gestor aprova o ajuste.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ajustes"
+                ],
+                "summary": "Solicita o ajuste de um ponto",
+                "parameters": [
+                    {
+                        "description": "Dados da solicitação",
+                        "name": "ajuste",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SolicitacaoAjustePayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.AjustePonto"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ajustes/{id}/aprovar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Aprova uma solicitação pendente da equipe do gestor e aplica o horário pedido ao ponto, ou inclui o ponto que faltou. As violações dos dias afetados são reavaliadas.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ajustes"
+                ],
+                "summary": "Aprova um ajuste de ponto",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do ajuste",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Observação da decisão",
+                        "name": "decisao",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DecisaoAjustePayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AjustePonto"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Adjustment request not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Adjustment request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/ajustes/{id}/rejeitar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Rejeita uma solicitação pendente da equipe do gestor; o ponto não muda.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Ajustes"
+                ],
+                "summary": "Rejeita um ajuste de ponto",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do ajuste",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Observação da decisão",
+                        "name": "decisao",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.DecisaoAjustePayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.AjustePonto"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Adjustment request not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Adjustment request is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/callback": {
+            "get": {
+                "description": "Recebe o código de autorização (exigindo o cookie __Host-oidc_state do navegador que iniciou o login), troca-o pelo ID token, valida o token e cria o usuário no primeiro acesso (pelo email verificado, num domínio verificado da empresa do provedor). Como em /login, quem tem TOTP ou é obrigado a cadastrá-lo recebe um desafio em vez do token. Redireciona ao frontend com a resposta do login no fragmento, ou a devolve em JSON se nenhum frontend estiver configurado. Uma conta existente sem empresa não é vinculada aqui: a resposta traz um token de vínculo (erro=vinculo_necessario\u0026vinculo=... no fragmento, ou 202 em JSON) que o dono confirma em /auth/oidc/vincular depois de entrar com a senha.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Conclui o login pelo provedor de identidade",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Código de autorização",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State gerado em /auth/oidc/iniciar",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespostaLogin"
+                        }
+                    },
+                    "202": {
+                        "description": "Account link required",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "Invalid or expired login session",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Identity provider login failed",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "User is not allowed to sign in with this provider",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/iniciar": {
+            "get": {
+                "description": "Localiza o provedor OpenID Connect pelo domínio verificado do email (ou pela empresa) e redireciona o navegador para a autorização, com PKCE (S256), state e nonce. O hash do state fica no cookie __Host-oidc_state, exigido no callback.",
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Inicia o login pelo provedor de identidade da empresa",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email do usuário; o domínio escolhe o provedor",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Empresa cujo provedor será usado",
+                        "name": "empresa_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Found"
+                    },
+                    "400": {
+                        "description": "email or empresa_id is required",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "No identity provider configured",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Identity provider unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/vincular": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Confirma o vínculo pedido por /auth/oidc/callback para uma conta existente sem empresa. Exige a sessão do próprio dono da conta, obtida entrando com a senha; a conta passa a pertencer à empresa do provedor e mantém a senha local.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Vincula a conta ao provedor de identidade da empresa",
+                "parameters": [
+                    {
+                        "description": "Token de vínculo recebido no callback",
+                        "name": "vinculo",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TokenPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid or expired link token",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Account already belongs to an empresa",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/banco-horas": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Apura dia a dia o tempo trabalhado, a jornada esperada e o tempo abonado por afastamentos aprovados, somando o saldo de horas do período. Os dias são contados no fuso horário da empresa e os dias futuros não são apurados. Os dias já fechados são lidos do saldo gravado, recalculado em segundo plano depois de cada alteração nos pontos ou afastamentos.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Banco de Horas"
+                ],
+                "summary": "Consulta o banco de horas",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Data inicial no formato YYYY-MM-DD (padrão: 29 dias atrás)",
+                        "name": "inicio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data final no formato YYYY-MM-DD (padrão: hoje)",
+                        "name": "fim",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID do usuário consultado (gestores)",
+                        "name": "user_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.BancoDeHorasResposta"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/conta/idioma": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Define o idioma dos emails enviados ao usuário (pt-BR, en ou es). As respostas da API seguem o cabeçalho Accept-Language de cada requisição e, quando ele não indica um idioma suportado, este idioma.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Conta"
+                ],
+                "summary": "Escolhe o idioma dos emails",
+                "parameters": [
+                    {
+                        "description": "Idioma",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.IdiomaPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update language",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/verificacao": {
+            "post": {
+                "description": "Envia um novo link de verificação se o email pertencer a uma conta ainda não verificada. A resposta é a mesma em todos os casos, para não revelar quais emails estão cadastrados.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Reenvia o email de verificação",
+                "parameters": [
+                    {
+                        "description": "Email da conta",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EmailPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/email/verificar": {
+            "post": {
+                "description": "Consome o token enviado por email e marca o email como verificado, liberando o login.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Verifica o email da conta",
+                "parameters": [
+                    {
+                        "description": "Token recebido por email",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TokenPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid or expired token",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/empresa/provedor-identidade": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retorna a configuração OpenID Connect da empresa, sem o client secret. Restrito a administradores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Empresa"
+                ],
+                "summary": "Consulta o provedor de identidade da empresa",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProvedorIdentidade"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "No identity provider configured",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cria ou substitui a configuração OpenID Connect da empresa. O issuer precisa responder ao discovery; os domínios de email não podem ter sido verificados por outra empresa. Cada domínio novo recebe um desafio DNS TXT e só passa a valer no login depois de verificado em /empresa/provedor-identidade/dominios/{dominio}/verificar. Restrito a administradores.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Empresa"
+                ],
+                "summary": "Configura o provedor de identidade da empresa",
+                "parameters": [
+                    {
+                        "description": "Configuração do provedor",
+                        "name": "provedor",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ProvedorIdentidadePayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProvedorIdentidade"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Email domain already used by another empresa",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove a configuração OpenID Connect da empresa. Usuários sem senha local deixam de conseguir entrar. Restrito a administradores.",
+                "tags": [
+                    "Empresa"
+                ],
+                "summary": "Remove o provedor de identidade da empresa",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "No identity provider configured",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/empresa/provedor-identidade/dominios/{dominio}/verificar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Consulta o registro DNS TXT _controle-ponto.{dominio} e, se ele trouxer o valor do desafio do domínio, marca o domínio como verificado. Só domínios verificados direcionam e aceitam logins pelo provedor. Restrito a administradores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Empresa"
+                ],
+                "summary": "Verifica a posse de um domínio do provedor de identidade",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Domínio de email configurado no provedor",
+                        "name": "dominio",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ProvedorIdentidade"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Domain not configured in the identity provider",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Email domain already used by another empresa",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Verification TXT record not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/empresa/regras": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retorna as regras de ponto da empresa do usuário autenticado, ou as regras padrão (CLT) se ele não estiver vinculado a uma empresa.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Empresa"
+                ],
+                "summary": "Consulta as regras da empresa",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Empresa"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Atualiza o intervalo mínimo entre pontos, a política de duplicidade, a carga horária diária, a tolerância de atraso, os papéis obrigados a usar MFA e os limites de jornada, interjornada e intrajornada da empresa. Restrito a administradores.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Empresa"
+                ],
+                "summary": "Atualiza as regras da empresa",
+                "parameters": [
+                    {
+                        "description": "Novas regras da empresa",
+                        "name": "regras",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RegrasEmpresaPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Empresa"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/equipe/membros/{id}/bloqueio-login": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Encerra o bloqueio e a sequência de falhas de login da conta. Restrito aos administradores da empresa do usuário.",
+                "tags": [
+                    "Equipe"
+                ],
+                "summary": "Desbloqueia o login de um usuário",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do usuário",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/equipe/membros/{id}/escala": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Define a entrada prevista (HH:MM, no fuso horário da empresa) usada para apontar atrasos no painel da equipe. Uma entrada vazia remove a escala. Restrito ao gestor do usuário ou a um administrador da empresa.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Equipe"
+                ],
+                "summary": "Atualiza a escala de um membro da equipe",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do usuário",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Nova escala",
+                        "name": "escala",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EscalaPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/equipe/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retorna a situação atual de cada membro da equipe (trabalhando, em_intervalo, ausente, nao_iniciou, encerrado) a partir dos pontos do dia, contado no fuso horário da empresa, o tempo trabalhado até o momento e os atrasos em relação à entrada prevista. Quem ainda não tem pontos no dia e terminou o dia anterior com uma saída está encerrado. Restrito a gestores e administradores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Equipe"
+                ],
+                "summary": "Painel de presença da equipe",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusEquipeResposta"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/eventos/stream": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Mantém uma conexão Server-Sent Events que entrega os eventos ponto.criado, ponto.alterado e ponto.removido da equipe do gestor (escopo=equipe) ou da empresa do administrador (escopo=empresa). Envie o cabeçalho Last-Event-ID para retomar após uma reconexão; se eventos tiverem sido perdidos, um evento reset é enviado e o cliente deve recarregar o estado.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Eventos"
+                ],
+                "summary": "Stream de eventos de ponto",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "equipe ou empresa (padrão: empresa para administradores, equipe para gestores)",
+                        "name": "escopo",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID do último evento recebido",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream de eventos",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Autentica um usuário com email e senha e retorna um token JWT. Se o usuário usa TOTP, ou a empresa o exige para o papel dele, retorna um desafio a ser concluído em /login/mfa ou /login/mfa/cadastro. Falhas seguidas impõem uma espera crescente entre as tentativas e depois bloqueiam a conta temporariamente; o mesmo vale para o IP de origem. O cabeçalho Retry-After informa quando tentar de novo.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Realiza o login do usuário",
+                "parameters": [
+                    {
+                        "description": "Credenciais de login (apenas email e password são necessários)",
+                        "name": "credentials",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespostaLogin"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid credentials",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Email not verified",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "423": {
+                        "description": "Account temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many login attempts",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/mfa": {
+            "post": {
+                "description": "Recebe o desafio devolvido por /login e um código do aplicativo autenticador ou de recuperação, e retorna o token de acesso. Cada desafio aceita poucas tentativas, e os códigos errados contam na mesma sequência de falhas da senha: a conta passa pela mesma espera crescente e pelo mesmo bloqueio temporário.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Conclui o login com o segundo fator",
+                "parameters": [
+                    {
+                        "description": "Desafio e código",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigoMFAPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespostaLogin"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "423": {
+                        "description": "Account temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many login attempts",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/mfa/cadastro": {
+            "post": {
+                "description": "Para usuários cuja empresa exige MFA e que ainda não cadastraram o TOTP. Recebe o desafio devolvido por /login e retorna o segredo e a URI otpauth:// para o QR code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Inicia o cadastro obrigatório do TOTP durante o login",
+                "parameters": [
+                    {
+                        "description": "Desafio (o código é ignorado)",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigoMFAPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CadastroTOTP"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or expired MFA challenge",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/login/mfa/cadastro/confirmar": {
+            "post": {
+                "description": "Confirma o TOTP com um código do aplicativo e retorna o token de acesso e os códigos de recuperação, exibidos só desta vez.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Conclui o cadastro obrigatório do TOTP durante o login",
+                "parameters": [
+                    {
+                        "description": "Desafio e código",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigoMFAPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RespostaLogin"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MFA"
+                ],
+                "summary": "Consulta o segundo fator do usuário",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.StatusMFA"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/codigos-recuperacao": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Exige um código válido e invalida os códigos anteriores. Os códigos errados contam na sequência de falhas do login: a conta passa pela mesma espera crescente e pelo mesmo bloqueio temporário.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MFA"
+                ],
+                "summary": "Gera novos códigos de recuperação",
+                "parameters": [
+                    {
+                        "description": "Código do aplicativo ou de recuperação",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigoMFAPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigosRecuperacao"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "423": {
+                        "description": "Account temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many login attempts",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/totp": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Gera um segredo e a URI otpauth:// para o QR code. O TOTP só passa a ser exigido depois da confirmação.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MFA"
+                ],
+                "summary": "Inicia o cadastro do TOTP",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CadastroTOTP"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Exige um código válido. Não é permitido quando a empresa exige MFA para o papel do usuário. Os códigos errados contam na sequência de falhas do login: a conta passa pela mesma espera crescente e pelo mesmo bloqueio temporário.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MFA"
+                ],
+                "summary": "Desativa o TOTP",
+                "parameters": [
+                    {
+                        "description": "Código do aplicativo ou de recuperação",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigoMFAPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "MFA is mandatory for this user",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "423": {
+                        "description": "Account temporarily locked",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many login attempts",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/mfa/totp/confirmar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Ativa o TOTP com um código do aplicativo e retorna os códigos de recuperação, exibidos só desta vez.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MFA"
+                ],
+                "summary": "Confirma o cadastro do TOTP",
+                "parameters": [
+                    {
+                        "description": "Código do aplicativo",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigoMFAPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.CodigosRecuperacao"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "TOTP is already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cria um novo registro de ponto com o horário atual para o usuário autenticado. Pontos mais próximos de outro que o intervalo mínimo da empresa são rejeitados, mesclados ao existente ou sinalizados, conforme a política configurada.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Registra um novo ponto",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Chave para evitar registros duplicados em reenvios",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ponto mesclado a um registro existente",
+                        "schema": {
+                            "$ref": "#/definitions/models.Ponto"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Ponto"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency-Key conflict or 'ponto' too close to another one",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/importacoes": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Agenda a gravação, em segundo plano, de um lote de pontos dos usuários gerenciados por quem envia. Cada ponto traz um UUID da origem, e reimportar o mesmo UUID não gera duplicidade; pontos próximos demais de outro seguem a política de duplicidade da empresa, como na sincronização offline. Acompanhe a importação pela URL do cabeçalho Location.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Importa pontos de outro sistema",
+                "parameters": [
+                    {
+                        "description": "Pontos a importar",
+                        "name": "importacao",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportacaoPontosPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chave para evitar importações duplicadas em reenvios",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportacaoPontos"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL da importação"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/importacoes/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retorna o status de uma importação pedida pelo usuário autenticado e, quando concluída, o resultado de cada ponto.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Consulta uma importação de pontos",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da importação",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ImportacaoPontos"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Import not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/inconsistencias": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lista os dias do período com quantidade ímpar de pontos, pontos mais próximos que o intervalo mínimo da empresa ou jornada acima do máximo configurado. Os dias são contados no fuso horário da empresa.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Relatório de inconsistências",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Data inicial no formato YYYY-MM-DD (padrão: 29 dias atrás)",
+                        "name": "inicio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data final no formato YYYY-MM-DD (padrão: hoje)",
+                        "name": "fim",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RelatorioInconsistencias"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid date format. Use YYYY-MM-DD",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/sync": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Recebe um lote de pontos capturados sem conexão, com o horário do aparelho, o offset monotônico e um UUID gerado pelo cliente. Reenvios do mesmo UUID não geram duplicidade, e pontos próximos demais de outro seguem a política de duplicidade da empresa. Horários do aparelho anteriores a 2000 ou no futuro, além da tolerância de deriva do relógio, são recusados.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Sincroniza pontos capturados offline",
+                "parameters": [
+                    {
+                        "description": "Lote de pontos capturados offline",
+                        "name": "pontos",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SincronizacaoPayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chave para evitar registros duplicados em reenvios",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.SincronizacaoResposta"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency-Key conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/{data}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lista todos os registros de ponto de um usuário para uma data específica, contada no fuso horário da empresa.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Lista os pontos por data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Data no formato YYYY-MM-DD",
+                        "name": "data",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Ponto"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid date format. Use YYYY-MM-DD",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/{data}/total-horas": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Calcula o total de horas trabalhadas em um dia com base nos registros de ponto (entrada/saída), o saldo em relação à jornada esperada (abonando afastamentos aprovados) e lista as violações de interjornada e intrajornada já apuradas para o dia. O dia é contado no fuso horário da empresa.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Calcula horas trabalhadas",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Data no formato YYYY-MM-DD",
+                        "name": "data",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.TotalHorasResposta"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid date format. Use YYYY-MM-DD",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/pontos/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Atualiza o horário de um registro de ponto existente. Um horário mais próximo de outro ponto que o intervalo mínimo da empresa é sinalizado se a política for sinalizar e rejeitado nas demais, já que uma alteração não tem como ser mesclada.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Atualiza um registro de ponto",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do Ponto",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Novo horário para o registro",
+                        "name": "horario",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PontoUpdatePayload"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chave para evitar registros duplicados em reenvios",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format or request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Ponto not found or permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency-Key conflict or 'ponto' too close to another one",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Deleta um registro de ponto existente.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Pontos"
+                ],
+                "summary": "Deleta um registro de ponto",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do Ponto",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chave para evitar registros duplicados em reenvios",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Ponto not found or permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Idempotency-Key conflict",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/register": {
+            "post": {
+                "description": "Cria um novo usuário no sistema com nome, email e senha, que precisa seguir a política de senhas, e envia o link de verificação do email. O login só é liberado depois da verificação.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Registra um novo usuário",
+                "parameters": [
+                    {
+                        "description": "Dados do usuário para registro (ID e Horarios podem ser omitidos)",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or weak password",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Email already registered",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create user",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/relatorios": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Agenda a geração, em segundo plano, do relatório de banco de horas ou de inconsistências do período em CSV. Gestores exportam o relatório de um usuário da equipe (user_id) ou de toda a equipe (equipe); os usuários incluídos são definidos no pedido. Acompanhe a exportação pela URL do cabeçalho Location.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Relatórios"
+                ],
+                "summary": "Solicita a exportação de um relatório",
+                "parameters": [
+                    {
+                        "description": "Tipo, período e usuários do relatório",
+                        "name": "exportacao",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.ExportacaoRelatorioPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.ExportacaoRelatorio"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL da exportação"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/relatorios/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retorna o status de uma exportação pedida pelo usuário autenticado.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Relatórios"
+                ],
+                "summary": "Consulta uma exportação de relatório",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da exportação",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ExportacaoRelatorio"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Report not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/relatorios/{id}/arquivo": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Retorna o CSV de uma exportação concluída pedida pelo usuário autenticado.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "Relatórios"
+                ],
+                "summary": "Baixa o arquivo de um relatório",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID da exportação",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid ID format",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Report not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Report not ready",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/senha/esqueci": {
+            "post": {
+                "description": "Envia um link de redefinição se o email pertencer a uma conta com senha local (contas que entram pelo provedor de identidade não têm). A resposta é a mesma em todos os casos, para não revelar quais emails estão cadastrados.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Solicita a redefinição de senha",
+                "parameters": [
+                    {
+                        "description": "Email da conta",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.EmailPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted"
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/senha/redefinir": {
+            "post": {
+                "description": "Consome o token enviado por email e grava a nova senha, que precisa seguir a política de senhas e não pode repetir as mais recentes. Todos os tokens de acesso emitidos antes deixam de valer, encerrando as sessões abertas. Como o token chegou ao email da conta, o email também passa a contar como verificado.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Authentication"
+                ],
+                "summary": "Redefine a senha",
+                "parameters": [
+                    {
+                        "description": "Token e nova senha",
+                        "name": "payload",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.RedefinirSenhaPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Invalid or expired token, weak or recently used password",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/violacoes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lista as violações de interjornada e intrajornada registradas no período. Gestores e administradores podem consultar um usuário da equipe com user_id ou toda a equipe com equipe=true.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Violações"
+                ],
+                "summary": "Lista violações de jornada",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Data inicial no formato YYYY-MM-DD (padrão: 29 dias atrás)",
+                        "name": "inicio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Data final no formato YYYY-MM-DD (padrão: hoje)",
+                        "name": "fim",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID do usuário consultado",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Lista as violações de toda a equipe",
+                        "name": "equipe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/regras.Violacao"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query parameters",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lista os webhooks cadastrados na empresa, sem os segredos. Restrito a administradores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Lista os webhooks",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Webhook"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cadastra um endpoint que recebe, via POST, os eventos escolhidos da empresa. Cada entrega é assinada no cabeçalho X-Webhook-Assinatura com HMAC-SHA256 de \"\u003cX-Webhook-Timestamp\u003e.\u003ccorpo\u003e\". O segredo só é devolvido nesta resposta. Restrito a administradores.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Cadastra um webhook",
+                "parameters": [
+                    {
+                        "description": "URL e eventos assinados",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handlers.WebhookPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Remove o webhook e o seu log de entregas. Restrito a administradores.",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Remove um webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/entregas": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lista as entregas mais recentes do webhook com o status, o número de tentativas, o último código HTTP e o último erro. Restrito a administradores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Log de entregas de um webhook",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filtra pelo status (pendente, entregue, falhou)",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.EntregaWebhook"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/entregas/{entrega_id}/reenviar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Devolve à fila uma entrega que esgotou as tentativas, zerando o contador. Restrito a administradores.",
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Reenvia uma entrega que falhou",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "ID da entrega",
+                        "name": "entrega_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Delivery not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Delivery has not failed",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/{id}/testar": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Enfileira um evento webhook.teste para o webhook, útil para validar a assinatura em um servidor local. Restrito a administradores.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Envia um evento de teste",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID do webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Permission denied",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/apierror.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apierror.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is stable across locales, for clients to branch on.",
+                    "type": "string",
+                    "example": "validation_failed"
+                },
+                "detail": {
+                    "description": "Detail explains this occurrence, in the request locale.",
+                    "type": "string",
+                    "example": "The request has invalid fields"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/register"
+                },
+                "request_id": {
+                    "type": "string",
+                    "example": "4f9c2a7d1e8b4c3a9d0e6f1b2c3d4e5f"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 400
+                },
+                "title": {
+                    "description": "Title summarizes the code, in the request locale.",
+                    "type": "string",
+                    "example": "Validation failed"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "urn:controle-ponto:problem:validation_failed"
+                }
+            }
+        },
+        "handlers.AnexoAfastamentoPayload": {
+            "type": "object",
+            "properties": {
+                "nome_arquivo": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "tamanho_bytes": {
+                    "type": "integer"
+                },
+                "tipo_conteudo": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.BancoDeHorasResposta": {
+            "type": "object",
+            "properties": {
+                "abonado_minutos": {
+                    "type": "integer"
+                },
+                "dias": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jornada.Apuracao"
+                    }
+                },
+                "esperado_minutos": {
+                    "type": "integer"
+                },
+                "fim": {
+                    "type": "string"
+                },
+                "inicio": {
+                    "type": "string"
+                },
+                "saldo_minutos": {
+                    "type": "integer"
+                },
+                "trabalhado_minutos": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.CadastroTOTP": {
+            "type": "object",
+            "properties": {
+                "segredo": {
+                    "type": "string"
+                },
+                "uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CodigoMFAPayload": {
+            "type": "object",
+            "properties": {
+                "codigo": {
+                    "description": "Codigo do aplicativo autenticador ou um código de recuperação.",
+                    "type": "string"
+                },
+                "desafio": {
+                    "description": "Desafio recebido no login; não é usado nas rotas autenticadas.",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.CodigosRecuperacao": {
+            "type": "object",
+            "properties": {
+                "codigos_recuperacao": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "handlers.DecisaoAfastamentoPayload": {
+            "type": "object",
+            "properties": {
+                "observacao": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.DecisaoAjustePayload": {
+            "type": "object",
+            "properties": {
+                "observacao": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.EmailPayload": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.EscalaPayload": {
+            "type": "object",
+            "properties": {
+                "entrada_prevista": {
+                    "description": "EntradaPrevista no formato HH:MM, no fuso horário da empresa; vazio remove a escala.",
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ExportacaoRelatorioPayload": {
+            "type": "object",
+            "properties": {
+                "equipe": {
+                    "type": "boolean"
+                },
+                "fim": {
+                    "type": "string"
+                },
+                "inicio": {
+                    "type": "string"
+                },
+                "tipo": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "UserID exporta o relatório de um usuário gerenciado; Equipe, o de todos eles.",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.IdiomaPayload": {
+            "type": "object",
+            "properties": {
+                "idioma": {
+                    "type": "string",
+                    "example": "pt-BR"
+                }
+            }
+        },
+        "handlers.ImportacaoPontos": {
+            "type": "object",
+            "properties": {
+                "concluido_em": {
+                    "type": "string"
+                },
+                "criado_em": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "resultados": {
+                    "description": "Resultados traz o resultado de cada ponto, na ordem do pedido, quando a importação é\nconcluída. Os status são os mesmos da sincronização offline.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SincronizacaoItemResultado"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_pontos": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.ImportacaoPontosPayload": {
+            "type": "object",
+            "properties": {
+                "pontos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.PontoImportadoPayload"
+                    }
+                }
+            }
+        },
+        "handlers.PontoImportadoPayload": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "description": "ClientID é um UUID que identifica o ponto na origem; reimportar o mesmo UUID não gera\nduplicidade.",
+                    "type": "string"
+                },
+                "horario": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.PontoOfflinePayload": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "description": "ClientID é um UUID gerado pelo aparelho e usado como chave de idempotência.",
+                    "type": "string"
+                },
+                "horario_dispositivo": {
+                    "description": "HorarioDispositivo é o horário do relógio do aparelho no momento da captura.",
+                    "type": "string"
+                },
+                "offset_monotonico_ms": {
+                    "description": "OffsetMonotonicoMs é o tempo decorrido, medido pelo relógio monotônico do aparelho,\nentre a captura do ponto e o envio da requisição.",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.PontoUpdatePayload": {
+            "type": "object",
+            "properties": {
+                "horario": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.ProvedorIdentidadePayload": {
+            "type": "object",
+            "properties": {
+                "ativo": {
+                    "type": "boolean"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "client_secret": {
+                    "description": "ClientSecret vazio mantém o atual; clientes públicos não têm secret.",
+                    "type": "string"
+                },
+                "dominios": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "issuer": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RedefinirSenhaPayload": {
+            "type": "object",
+            "properties": {
+                "nova_senha": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RegrasEmpresaPayload": {
+            "type": "object",
+            "properties": {
+                "carga_horaria_diaria_minutos": {
+                    "type": "integer"
+                },
+                "fuso_horario": {
+                    "description": "FusoHorario é o fuso IANA em que os dias são contados; vazio mantém o atual.",
+                    "type": "string"
+                },
+                "interjornada_minima_minutos": {
+                    "type": "integer"
+                },
+                "intervalo_minimo_segundos": {
+                    "type": "integer"
+                },
+                "intrajornada_curta_limite_minutos": {
+                    "type": "integer"
+                },
+                "intrajornada_curta_minima_minutos": {
+                    "type": "integer"
+                },
+                "intrajornada_limite_minutos": {
+                    "type": "integer"
+                },
+                "intrajornada_minima_minutos": {
+                    "type": "integer"
+                },
+                "jornada_maxima_minutos": {
+                    "type": "integer"
+                },
+                "mfa_obrigatoria_papeis": {
+                    "description": "MFAObrigatoriaPapeis lista os papéis (gestor, admin, funcionario) que precisam de TOTP.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "politica_duplicidade": {
+                    "type": "string"
+                },
+                "tolerancia_atraso_minutos": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.RelatorioInconsistencias": {
+            "type": "object",
+            "properties": {
+                "fim": {
+                    "type": "string"
+                },
+                "inconsistencias": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/jornada.Inconsistencia"
+                    }
+                },
+                "inicio": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.RespostaLogin": {
+            "type": "object",
+            "properties": {
+                "codigos_recuperacao": {
+                    "description": "CodigosRecuperacao só aparece ao concluir o cadastro do TOTP.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "desafio": {
+                    "type": "string"
+                },
+                "expira_em": {
+                    "type": "string"
+                },
+                "mfa_cadastro_requerido": {
+                    "type": "boolean"
+                },
+                "mfa_requerido": {
+                    "type": "boolean"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SincronizacaoItemResultado": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "erro": {
+                    "description": "Erro explica por que o ponto foi rejeitado.",
+                    "type": "string"
+                },
+                "erros": {
+                    "description": "Erros são os campos inválidos de um item com status invalido, nomeados a partir do\nlote, como em pontos[3].horario_dispositivo.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                },
+                "ponto": {
+                    "$ref": "#/definitions/models.Ponto"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.SincronizacaoPayload": {
+            "type": "object",
+            "properties": {
+                "pontos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.PontoOfflinePayload"
+                    }
+                }
+            }
+        },
+        "handlers.SincronizacaoResposta": {
+            "type": "object",
+            "properties": {
+                "resultados": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.SincronizacaoItemResultado"
+                    }
+                }
+            }
+        },
+        "handlers.SolicitacaoAfastamentoPayload": {
+            "type": "object",
+            "properties": {
+                "data_fim": {
+                    "type": "string"
+                },
+                "data_inicio": {
+                    "type": "string"
+                },
+                "motivo": {
+                    "type": "string"
+                },
+                "tipo_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.SolicitacaoAjustePayload": {
+            "type": "object",
+            "properties": {
+                "horario": {
+                    "type": "string"
+                },
+                "motivo": {
+                    "type": "string"
+                },
+                "ponto_id": {
+                    "description": "PontoID é o ponto a corrigir; omitido para incluir um ponto que faltou.",
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.StatusEquipeResposta": {
+            "type": "object",
+            "properties": {
+                "atualizado_em": {
+                    "type": "string"
+                },
+                "data": {
+                    "type": "string"
+                },
+                "membros": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handlers.StatusMembroEquipe"
+                    }
+                },
+                "resumo": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "handlers.StatusMFA": {
+            "type": "object",
+            "properties": {
+                "codigos_recuperacao_restantes": {
+                    "type": "integer"
+                },
+                "obrigatoria": {
+                    "description": "Obrigatoria indica que a empresa exige MFA para o papel do usuário.",
+                    "type": "boolean"
+                },
+                "totp_ativo": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "handlers.StatusMembroEquipe": {
+            "type": "object",
+            "properties": {
+                "afastamento": {
+                    "type": "string"
+                },
+                "atrasado": {
+                    "type": "boolean"
+                },
+                "atraso_minutos": {
+                    "type": "integer"
+                },
+                "entrada_prevista": {
+                    "type": "string"
+                },
+                "nome": {
+                    "type": "string"
+                },
+                "situacao": {
+                    "type": "string"
+                },
+                "trabalhado_minutos": {
+                    "type": "integer"
+                },
+                "ultimo_ponto": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.TokenPayload": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "handlers.TotalHorasResposta": {
+            "type": "object",
+            "properties": {
+                "abonado_minutos": {
+                    "type": "integer"
+                },
+                "afastamento": {
+                    "type": "string"
+                },
+                "data": {
+                    "type": "string"
+                },
+                "esperado_minutos": {
+                    "type": "integer"
+                },
+                "saldo_minutos": {
+                    "description": "SaldoMinutos é positivo para horas extras e negativo para horas faltantes.",
+                    "type": "integer"
+                },
+                "total_segundos": {
+                    "type": "string"
+                },
+                "total_trabalhado": {
+                    "type": "string"
+                },
+                "trabalhado_minutos": {
+                    "type": "integer"
+                },
+                "violacoes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/regras.Violacao"
+                    }
+                }
+            }
+        },
+        "handlers.WebhookPayload": {
+            "type": "object",
+            "properties": {
+                "eventos": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "segredo": {
+                    "description": "Segredo usado na assinatura; gerado automaticamente se vazio.",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "jornada.Apuracao": {
+            "type": "object",
+            "properties": {
+                "abonado_minutos": {
+                    "type": "integer"
+                },
+                "afastamento": {
+                    "type": "string"
+                },
+                "data": {
+                    "type": "string"
+                },
+                "esperado_minutos": {
+                    "type": "integer"
+                },
+                "saldo_minutos": {
+                    "description": "SaldoMinutos é positivo para horas extras e negativo para horas faltantes.",
+                    "type": "integer"
+                },
+                "trabalhado_minutos": {
+                    "type": "integer"
+                }
+            }
+        },
+        "jornada.Inconsistencia": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "descricao": {
+                    "type": "string"
+                },
+                "ponto_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tipo": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Afastamento": {
+            "type": "object",
+            "properties": {
+                "anexos": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.AnexoAfastamento"
+                    }
+                },
+                "criado_em": {
+                    "type": "string"
+                },
+                "data_fim": {
+                    "type": "string"
+                },
+                "data_inicio": {
+                    "type": "string"
+                },
+                "decidido_em": {
+                    "type": "string"
+                },
+                "decidido_por": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "motivo": {
+                    "type": "string"
+                },
+                "observacao_decisao": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tipo": {
+                    "$ref": "#/definitions/models.TipoAfastamento"
+                },
+                "tipo_id": {
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AjustePonto": {
+            "type": "object",
+            "properties": {
+                "criado_em": {
+                    "type": "string"
+                },
+                "decidido_em": {
+                    "type": "string"
+                },
+                "decidido_por": {
+                    "type": "integer"
+                },
+                "horario": {
+                    "description": "Horario é o horário pedido para o ponto.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "motivo": {
+                    "type": "string"
+                },
+                "observacao_decisao": {
+                    "type": "string"
+                },
+                "ponto_id": {
+                    "description": "PontoID é o ponto corrigido; nulo quando o ajuste inclui um ponto que faltou.",
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.AnexoAfastamento": {
+            "type": "object",
+            "properties": {
+                "afastamento_id": {
+                    "type": "integer"
+                },
+                "criado_em": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "nome_arquivo": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "tamanho_bytes": {
+                    "type": "integer"
+                },
+                "tipo_conteudo": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DominioVerificacao": {
+            "type": "object",
+            "properties": {
+                "dominio": {
+                    "type": "string",
+                    "example": "empresa.com.br"
+                },
+                "nome": {
+                    "type": "string",
+                    "example": "_controle-ponto.empresa.com.br"
+                },
+                "valor": {
+                    "type": "string",
+                    "example": "controle-ponto-verificacao=3q2-7wEjPk..."
+                },
+                "verificado_em": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Empresa": {
+            "type": "object",
+            "properties": {
+                "carga_horaria_diaria_minutos": {
+                    "description": "CargaHorariaDiariaMinutos é a jornada esperada de segunda a sexta-feira, base do saldo de horas.",
+                    "type": "integer"
+                },
+                "fuso_horario": {
+                    "description": "FusoHorario é o fuso IANA (ex.: America/Sao_Paulo) em que os dias de trabalho são contados.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "interjornada_minima_minutos": {
+                    "description": "InterjornadaMinimaMinutos é o descanso mínimo entre duas jornadas.",
+                    "type": "integer"
+                },
+                "intervalo_minimo_segundos": {
+                    "description": "IntervaloMinimoSegundos é o intervalo mínimo entre dois pontos do mesmo usuário.",
+                    "type": "integer"
+                },
+                "intrajornada_curta_limite_minutos": {
+                    "description": "IntrajornadaCurtaLimiteMinutos é a jornada a partir da qual se exige IntrajornadaCurtaMinimaMinutos de intervalo.",
+                    "type": "integer"
+                },
+                "intrajornada_curta_minima_minutos": {
+                    "type": "integer"
+                },
+                "intrajornada_limite_minutos": {
+                    "description": "IntrajornadaLimiteMinutos é a jornada a partir da qual se exige IntrajornadaMinimaMinutos de intervalo.",
+                    "type": "integer"
+                },
+                "intrajornada_minima_minutos": {
+                    "type": "integer"
+                },
+                "jornada_maxima_minutos": {
+                    "description": "JornadaMaximaMinutos é a duração máxima esperada para a jornada de um dia.",
+                    "type": "integer"
+                },
+                "mfa_obrigatoria_papeis": {
+                    "description": "MFAObrigatoriaPapeis são os papéis que precisam de segundo fator (TOTP) para entrar.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "nome": {
+                    "type": "string"
+                },
+                "politica_duplicidade": {
+                    "description": "PoliticaDuplicidade define o que fazer com pontos mais próximos que o intervalo mínimo.",
+                    "type": "string"
+                },
+                "tolerancia_atraso_minutos": {
+                    "description": "ToleranciaAtrasoMinutos é o tempo após a entrada prevista a partir do qual o ponto conta como atraso.",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.EntregaWebhook": {
+            "type": "object",
+            "properties": {
+                "criado_em": {
+                    "type": "string"
+                },
+                "entregue_em": {
+                    "type": "string"
+                },
+                "evento_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "payload": {
+                    "type": "object"
+                },
+                "proxima_tentativa_em": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tentativas": {
+                    "type": "integer"
+                },
+                "tipo": {
+                    "type": "string"
+                },
+                "ultima_tentativa_em": {
+                    "type": "string"
+                },
+                "ultimo_erro": {
+                    "type": "string"
+                },
+                "ultimo_status_http": {
+                    "type": "integer"
+                },
+                "webhook_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ExportacaoRelatorio": {
+            "type": "object",
+            "properties": {
+                "concluido_em": {
+                    "type": "string"
+                },
+                "criado_em": {
+                    "type": "string"
+                },
+                "fim": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "inicio": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "tipo": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "usuario_ids": {
+                    "description": "UsuarioIDs são os usuários incluídos no relatório, resolvidos no pedido.",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "models.Ponto": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "description": "ClientID é o UUID gerado pelo aplicativo para pontos capturados offline.",
+                    "type": "string"
+                },
+                "deriva_segundos": {
+                    "description": "DerivaSegundos é a diferença estimada entre o relógio do aparelho e o do servidor.",
+                    "type": "integer"
+                },
+                "deriva_suspeita": {
+                    "type": "boolean"
+                },
+                "duplicidade_suspeita": {
+                    "description": "DuplicidadeSuspeita indica que o ponto foi registrado muito perto de outro.",
+                    "type": "boolean"
+                },
+                "horario": {
+                    "type": "string"
+                },
+                "horario_dispositivo": {
+                    "description": "HorarioDispositivo é o horário informado pelo relógio do aparelho.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "recebido_em": {
+                    "description": "RecebidoEm é o horário em que o servidor recebeu o registro.",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.ProvedorIdentidade": {
+            "type": "object",
+            "properties": {
+                "ativo": {
+                    "type": "boolean"
+                },
+                "atualizado_em": {
+                    "type": "string"
+                },
+                "client_id": {
+                    "type": "string"
+                },
+                "criado_em": {
+                    "type": "string"
+                },
+                "dominios": {
+                    "description": "Dominios são os domínios de email atendidos pelo provedor. Só usuários com email\nnesses domínios são aceitos, e o login é direcionado ao provedor pelo domínio, depois\nque a empresa comprovar a posse dele (veja Verificacoes).",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "empresa_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "issuer": {
+                    "type": "string"
+                },
+                "possui_client_secret": {
+                    "description": "PossuiClientSecret indica se há um client secret gravado; ele nunca é devolvido.",
+                    "type": "boolean"
+                },
+                "verificacoes": {
+                    "description": "Verificacoes traz, para cada domínio, o registro DNS TXT que comprova a posse.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DominioVerificacao"
+                    }
+                }
+            }
+        },
+        "models.TipoAfastamento": {
+            "type": "object",
+            "properties": {
+                "codigo": {
+                    "type": "string"
+                },
+                "empresa_id": {
+                    "type": "integer"
+                },
+                "exige_anexo": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "minutos_creditados": {
+                    "description": "MinutosCreditados é o tempo abonado em cada dia do afastamento, limitado à jornada esperada.",
+                    "type": "integer"
+                },
+                "nome": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.User": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "empresa_id": {
+                    "type": "integer"
+                },
+                "gestor_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "idioma": {
+                    "description": "Idioma é o idioma dos emails do usuário (pt-BR, en ou es).",
+                    "type": "string"
+                },
+                "nome": {
+                    "type": "string"
+                },
+                "papel": {
+                    "type": "string"
+                },
+                "password": {
+                    "description": "omitempty so it's not sent in responses",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Webhook": {
+            "type": "object",
+            "properties": {
+                "ativo": {
+                    "type": "boolean"
+                },
+                "criado_em": {
+                    "type": "string"
+                },
+                "empresa_id": {
+                    "type": "integer"
+                },
+                "eventos": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "segredo": {
+                    "description": "Segredo assina as entregas e só é devolvido na criação do webhook.",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "regras.Violacao": {
+            "type": "object",
+            "properties": {
+                "apurado_minutos": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "string"
+                },
+                "descricao": {
+                    "type": "string"
+                },
+                "detectada_em": {
+                    "type": "string"
+                },
+                "exigido_minutos": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "tipo": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "validation.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
                     "type": "string"
                 }
             }
@@ -0,0 +1,176 @@
+// Package eventos implementa o barramento interno que distribui os eventos de ponto
+// aos assinantes em tempo real, guardando um histórico curto para retomadas.
+package eventos
+
+import (
+	"controle-ponto-api/models"
+	"sync"
+	"time"
+)
+
+// Tipos de evento de ponto.
+const (
+	TipoPontoCriado   = "ponto.criado"
+	TipoPontoAlterado = "ponto.alterado"
+	TipoPontoRemovido = "ponto.removido"
+)
+
+// Tipos de evento de afastamento, entregues apenas por webhooks.
+const (
+	TipoAfastamentoSolicitado = "afastamento.solicitado"
+	TipoAfastamentoAprovado   = "afastamento.aprovado"
+	TipoAfastamentoRejeitado  = "afastamento.rejeitado"
+	TipoAfastamentoCancelado  = "afastamento.cancelado"
+)
+
+// Tipos de evento de ajuste de ponto, entregues apenas por webhooks. A aprovação também
+// gera o evento de ponto criado ou alterado.
+const (
+	TipoAjusteSolicitado = "ajuste.solicitado"
+	TipoAjusteAprovado   = "ajuste.aprovado"
+	TipoAjusteRejeitado  = "ajuste.rejeitado"
+)
+
+// Evento é uma alteração nos pontos de um usuário.
+type Evento struct {
+	ID     uint64       `json:"id"`
+	Tipo   string       `json:"tipo"`
+	UserID int64        `json:"user_id"`
+	Ponto  models.Ponto `json:"ponto"`
+	// HorarioAnterior é o horário do ponto antes de uma atualização.
+	HorarioAnterior *time.Time `json:"horario_anterior,omitempty"`
+	OcorridoEm      time.Time  `json:"ocorrido_em"`
+
+	// EmpresaID e GestorID do dono do ponto, usados para filtrar os assinantes.
+	EmpresaID *int64 `json:"-"`
+	GestorID  *int64 `json:"-"`
+}
+
+// Filtro decide se um evento deve ser entregue a uma assinatura.
+type Filtro func(Evento) bool
+
+// tamanhoFila é quantos eventos uma assinatura acumula antes de ser descartada por lentidão.
+const tamanhoFila = 64
+
+// Assinatura recebe os eventos publicados depois da sua criação.
+type Assinatura struct {
+	// C é fechado quando a assinatura é cancelada ou descartada por não acompanhar o ritmo.
+	C <-chan Evento
+
+	c      chan Evento
+	filtro Filtro
+	bus    *Bus
+}
+
+// Cancelar encerra a assinatura. Pode ser chamado mais de uma vez.
+func (a *Assinatura) Cancelar() {
+	a.bus.mu.Lock()
+	defer a.bus.mu.Unlock()
+	a.bus.remover(a)
+}
+
+// Bus distribui os eventos publicados entre as assinaturas e mantém os últimos
+// eventos em memória para que um cliente reconectado retome de onde parou.
+type Bus struct {
+	mu          sync.Mutex
+	ultimoID    uint64
+	historico   []Evento
+	capacidade  int
+	assinaturas map[*Assinatura]struct{}
+	encerrado   bool
+}
+
+// NovoBus cria um barramento que guarda até capacidade eventos para retomadas.
+// Os IDs partem do horário de criação, para que um ID emitido antes de um reinício
+// seja sempre anterior ao histórico atual e não se confunda com eventos novos.
+func NovoBus(capacidade int) *Bus {
+	return &Bus{
+		ultimoID:    uint64(time.Now().UnixMicro()),
+		historico:   make([]Evento, 0, capacidade),
+		capacidade:  capacidade,
+		assinaturas: make(map[*Assinatura]struct{}),
+	}
+}
+
+// Padrao é o barramento usado pela API.
+var Padrao = NovoBus(1024)
+
+// Publicar atribui um ID ao evento e o entrega às assinaturas cujo filtro o aceita.
+// Uma assinatura com a fila cheia é descartada em vez de bloquear quem publica.
+func (b *Bus) Publicar(e Evento) Evento {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.ultimoID++
+	e.ID = b.ultimoID
+	if e.OcorridoEm.IsZero() {
+		e.OcorridoEm = time.Now()
+	}
+
+	if len(b.historico) == b.capacidade {
+		copy(b.historico, b.historico[1:])
+		b.historico = b.historico[:len(b.historico)-1]
+	}
+	b.historico = append(b.historico, e)
+
+	for a := range b.assinaturas {
+		if !a.filtro(e) {
+			continue
+		}
+		select {
+		case a.c <- e:
+		default:
+			b.remover(a)
+		}
+	}
+	return e
+}
+
+// Assinar cria uma assinatura e devolve os eventos do histórico posteriores a desde
+// que passam pelo filtro. completo é falso quando desde é anterior ao histórico
+// guardado e alguns eventos podem ter sido perdidos. desde igual a zero não retoma nada.
+func (b *Bus) Assinar(desde uint64, filtro Filtro) (a *Assinatura, pendentes []Evento, completo bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan Evento, tamanhoFila)
+	a = &Assinatura{C: c, c: c, filtro: filtro, bus: b}
+	if b.encerrado {
+		close(c)
+		return a, nil, true
+	}
+	b.assinaturas[a] = struct{}{}
+
+	if desde == 0 {
+		return a, nil, true
+	}
+
+	completo = desde >= b.ultimoID || (len(b.historico) > 0 && b.historico[0].ID <= desde+1)
+	for _, e := range b.historico {
+		if e.ID > desde && filtro(e) {
+			pendentes = append(pendentes, e)
+		}
+	}
+	return a, pendentes, completo
+}
+
+// Encerrar fecha todas as assinaturas, para que os streams abertos terminem durante o
+// desligamento do servidor. Novas assinaturas já nascem fechadas.
+func (b *Bus) Encerrar() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.encerrado = true
+	for a := range b.assinaturas {
+		b.remover(a)
+	}
+}
+
+// remover tira a assinatura do barramento e fecha o seu canal. Exige b.mu.
+func (b *Bus) remover(a *Assinatura) {
+	if _, ok := b.assinaturas[a]; !ok {
+		return
+	}
+	delete(b.assinaturas, a)
+	close(a.c)
+}
@@ -0,0 +1,135 @@
+package eventos
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// todos é o filtro que aceita qualquer evento.
+func todos(Evento) bool { return true }
+
+// idsDos devolve os IDs dos eventos, na ordem.
+func idsDos(evts []Evento) []uint64 {
+	var ids []uint64
+	for _, e := range evts {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestAssinarRetomada(t *testing.T) {
+	b := NovoBus(4)
+	var publicados []Evento
+	for range 6 {
+		publicados = append(publicados, b.Publicar(Evento{Tipo: TipoPontoCriado}))
+	}
+	// The history keeps the last 4: publicados[2] to publicados[5]
+
+	tests := []struct {
+		name         string
+		desde        uint64
+		want         []Evento
+		wantCompleto bool
+	}{
+		{"no Last-Event-ID", 0, nil, true},
+		{"up to date", publicados[5].ID, nil, true},
+		{"inside the history", publicados[3].ID, publicados[4:], true},
+		{"right before the history", publicados[1].ID, publicados[2:], true},
+		{"history moved past it", publicados[0].ID, publicados[2:], false},
+		{"from before a restart", 1, publicados[2:], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, pendentes, completo := b.Assinar(tt.desde, todos)
+			defer a.Cancelar()
+
+			if got, want := idsDos(pendentes), idsDos(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("pendentes = %v, want %v", got, want)
+			}
+			if completo != tt.wantCompleto {
+				t.Errorf("completo = %v, want %v", completo, tt.wantCompleto)
+			}
+		})
+	}
+}
+
+func TestAssinarFiltro(t *testing.T) {
+	empresa := func(id int64) *int64 { return &id }
+	b := NovoBus(16)
+	primeiro := b.Publicar(Evento{UserID: 1, EmpresaID: empresa(3)})
+	b.Publicar(Evento{UserID: 2, EmpresaID: empresa(4)})
+	terceiro := b.Publicar(Evento{UserID: 3, EmpresaID: empresa(3)})
+
+	daEmpresa := func(e Evento) bool { return e.EmpresaID != nil && *e.EmpresaID == 3 }
+	a, pendentes, _ := b.Assinar(primeiro.ID-1, daEmpresa)
+	defer a.Cancelar()
+
+	// Both the history and new events go through the filter
+	if got, want := idsDos(pendentes), []uint64{primeiro.ID, terceiro.ID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pendentes = %v, want %v", got, want)
+	}
+	b.Publicar(Evento{UserID: 4})
+	novo := b.Publicar(Evento{UserID: 5, EmpresaID: empresa(3)})
+	if e := <-a.C; e.ID != novo.ID {
+		t.Errorf("received event %d, want %d", e.ID, novo.ID)
+	}
+}
+
+func TestPublicarAssinanteLento(t *testing.T) {
+	b := NovoBus(16)
+	lenta, _, _ := b.Assinar(0, todos)
+	rapida, _, _ := b.Assinar(0, todos)
+
+	// publicar publica n eventos, falhando o teste se Publicar bloquear
+	publicar := func(n int) {
+		t.Helper()
+		publicou := make(chan struct{})
+		go func() {
+			for range n {
+				b.Publicar(Evento{Tipo: TipoPontoCriado})
+			}
+			close(publicou)
+		}()
+		select {
+		case <-publicou:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Publicar blocked on a slow subscriber")
+		}
+	}
+
+	// Both queues fill up, but only the fast subscriber keeps reading
+	publicar(tamanhoFila)
+	for range tamanhoFila {
+		<-rapida.C
+	}
+	publicar(1)
+
+	n := 0
+	for range lenta.C {
+		n++
+	}
+	if n != tamanhoFila {
+		t.Errorf("slow subscriber received %d events before being dropped, want %d", n, tamanhoFila)
+	}
+	if _, aberta := <-rapida.C; !aberta {
+		t.Error("fast subscriber was dropped")
+	}
+	// Canceling a dropped subscription is a no-op
+	lenta.Cancelar()
+	rapida.Cancelar()
+}
+
+func TestEncerrar(t *testing.T) {
+	b := NovoBus(16)
+	a, _, _ := b.Assinar(0, todos)
+	b.Encerrar()
+
+	if _, aberta := <-a.C; aberta {
+		t.Error("subscription still open after Encerrar")
+	}
+	depois, _, _ := b.Assinar(0, todos)
+	if _, aberta := <-depois.C; aberta {
+		t.Error("subscription created after Encerrar is open")
+	}
+}
@@ -0,0 +1,746 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"controle-ponto-api/webhooks"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+var sha256Regex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// tamanhoMaximoObservacao limita o motivo da solicitação e a observação da decisão.
+const tamanhoMaximoObservacao = 2000
+
+// SolicitacaoAfastamentoPayload define o corpo da requisição de solicitação de afastamento.
+type SolicitacaoAfastamentoPayload struct {
+	TipoID     int64  `json:"tipo_id"`
+	DataInicio string `json:"data_inicio"`
+	DataFim    string `json:"data_fim"`
+	Motivo     string `json:"motivo"`
+}
+
+// AnexoAfastamentoPayload define os metadados de um anexo enviado pelo usuário.
+func (p SolicitacaoAfastamentoPayload) validar() error {
+	var v validation.Validator
+	v.Check(p.TipoID > 0, "tipo_id", validation.CodeRequired, "is required")
+	inicio, inicioOK := v.Date("data_inicio", p.DataInicio)
+	fim, fimOK := v.Date("data_fim", p.DataFim)
+	if inicioOK && fimOK && v.Check(!fim.Before(inicio), "data_fim", validation.CodeOutOfRange, "must not be before data_inicio") {
+		v.Check(fim.Sub(inicio) < maxDiasPorPeriodo*24*time.Hour, "data_fim", validation.CodeOutOfRange,
+			"the period must be shorter than %d days", maxDiasPorPeriodo)
+	}
+	v.MaxLength("motivo", p.Motivo, tamanhoMaximoObservacao)
+	return v.Err()
+}
+
+type AnexoAfastamentoPayload struct {
+	NomeArquivo  string `json:"nome_arquivo"`
+	TipoConteudo string `json:"tipo_conteudo"`
+	TamanhoBytes int64  `json:"tamanho_bytes"`
+	SHA256       string `json:"sha256"`
+	URL          string `json:"url"`
+}
+
+func (p AnexoAfastamentoPayload) validar() error {
+	var v validation.Validator
+	v.Required("nome_arquivo", p.NomeArquivo)
+	v.Required("tipo_conteudo", p.TipoConteudo)
+	v.Check(p.TamanhoBytes > 0, "tamanho_bytes", validation.CodeOutOfRange, "must be positive")
+	v.Check(sha256Regex.MatchString(p.SHA256), "sha256", validation.CodeInvalid, "must be a hex-encoded SHA-256 digest")
+	return v.Err()
+}
+
+// DecisaoAfastamentoPayload define o corpo das requisições de aprovação e rejeição.
+type DecisaoAfastamentoPayload struct {
+	Observacao string `json:"observacao"`
+}
+
+func (p DecisaoAfastamentoPayload) validar() error {
+	var v validation.Validator
+	v.MaxLength("observacao", p.Observacao, tamanhoMaximoObservacao)
+	return v.Err()
+}
+
+const selectAfastamentoSQL = `
+	SELECT a.id, a.user_id, a.tipo_id, a.data_inicio, a.data_fim, a.motivo, a.status,
+		a.decidido_por, a.decidido_em, a.observacao_decisao, a.criado_em,
+		t.id, t.empresa_id, t.codigo, t.nome, t.minutos_creditados, t.exige_anexo
+	FROM afastamentos a JOIN tipos_afastamento t ON t.id = a.tipo_id`
+
+func scanAfastamento(scanner interface{ Scan(...interface{}) error }) (models.Afastamento, error) {
+	var a models.Afastamento
+	var t models.TipoAfastamento
+	var inicio, fim time.Time
+	var decididoPor, empresaID sql.NullInt64
+	var decididoEm sql.NullTime
+
+	err := scanner.Scan(
+		&a.ID, &a.UserID, &a.TipoID, &inicio, &fim, &a.Motivo, &a.Status,
+		&decididoPor, &decididoEm, &a.ObservacaoDecisao, &a.CriadoEm,
+		&t.ID, &empresaID, &t.Codigo, &t.Nome, &t.MinutosCreditados, &t.ExigeAnexo,
+	)
+	if err != nil {
+		return a, err
+	}
+
+	a.DataInicio = inicio.Format(jornada.FormatoData)
+	a.DataFim = fim.Format(jornada.FormatoData)
+	if decididoPor.Valid {
+		a.DecididoPor = &decididoPor.Int64
+	}
+	if decididoEm.Valid {
+		a.DecididoEm = &decididoEm.Time
+	}
+	if empresaID.Valid {
+		t.EmpresaID = &empresaID.Int64
+	}
+	a.Tipo = &t
+	a.Anexos = []models.AnexoAfastamento{}
+	return a, nil
+}
+
+// carregarAfastamento retorna o afastamento com o tipo e os anexos.
+func carregarAfastamento(ctx context.Context, q queryer, id int64) (models.Afastamento, error) {
+	a, err := scanAfastamento(q.QueryRowContext(ctx, selectAfastamentoSQL+" WHERE a.id = $1", id))
+	if err != nil {
+		return a, err
+	}
+
+	lista := []models.Afastamento{a}
+	if err := carregarAnexos(ctx, q, lista); err != nil {
+		return a, err
+	}
+	return lista[0], nil
+}
+
+// carregarAnexos preenche os anexos dos afastamentos com uma única consulta.
+func carregarAnexos(ctx context.Context, q queryer, afastamentos []models.Afastamento) error {
+	if len(afastamentos) == 0 {
+		return nil
+	}
+
+	indices := make(map[int64]int, len(afastamentos))
+	ids := make([]int64, len(afastamentos))
+	for i, a := range afastamentos {
+		indices[a.ID] = i
+		ids[i] = a.ID
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, afastamento_id, nome_arquivo, tipo_conteudo, tamanho_bytes, sha256, url, criado_em
+		FROM afastamento_anexos WHERE afastamento_id = ANY($1) ORDER BY id`,
+		pq.Array(ids),
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var an models.AnexoAfastamento
+		if err := rows.Scan(&an.ID, &an.AfastamentoID, &an.NomeArquivo, &an.TipoConteudo, &an.TamanhoBytes, &an.SHA256, &an.URL, &an.CriadoEm); err != nil {
+			return err
+		}
+		i := indices[an.AfastamentoID]
+		afastamentos[i].Anexos = append(afastamentos[i].Anexos, an)
+	}
+	return rows.Err()
+}
+
+// abonosDoPeriodo retorna, para cada dia entre inicio e fim coberto por um afastamento
+// aprovado do usuário, o abono correspondente. inicio e fim são a meia-noite no fuso da
+// empresa, no qual as datas do afastamento também são lidas.
+func abonosDoPeriodo(ctx context.Context, q queryer, userID int64, inicio, fim time.Time) (map[string]jornada.Abono, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT a.data_inicio, a.data_fim, t.codigo, t.minutos_creditados
+		FROM afastamentos a JOIN tipos_afastamento t ON t.id = a.tipo_id
+		WHERE a.user_id = $1 AND a.status = $2 AND a.data_inicio <= $3 AND a.data_fim >= $4`,
+		userID, models.AfastamentoAprovado, fim.Format(jornada.FormatoData), inicio.Format(jornada.FormatoData),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	abonos := map[string]jornada.Abono{}
+	for rows.Next() {
+		var de, ate time.Time
+		var codigo string
+		var minutos int
+		if err := rows.Scan(&de, &ate, &codigo, &minutos); err != nil {
+			return nil, err
+		}
+
+		// DATE columns are scanned as midnight UTC; the same dates are read in the empresa time zone
+		fuso := inicio.Location()
+		de = time.Date(de.Year(), de.Month(), de.Day(), 0, 0, 0, 0, fuso)
+		ate = time.Date(ate.Year(), ate.Month(), ate.Day(), 0, 0, 0, 0, fuso)
+
+		abono := jornada.Abono{Tipo: codigo, Creditado: time.Duration(minutos) * time.Minute}
+		for dia := maxTime(de, inicio); !dia.After(fim) && !dia.After(ate); dia = dia.AddDate(0, 0, 1) {
+			abonos[dia.Format(jornada.FormatoData)] = abono
+		}
+	}
+	return abonos, rows.Err()
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// ListarTiposAfastamento godoc
+// @Summary      Lista os tipos de afastamento
+// @Description  Lista os tipos de afastamento disponíveis para a empresa do usuário, com o tempo creditado por dia.
+// @Tags         Afastamentos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   models.TipoAfastamento
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos/tipos [get]
+func ListarTiposAfastamento(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	rows, err := database.DB.QueryContext(r.Context(),
+		`SELECT t.id, t.empresa_id, t.codigo, t.nome, t.minutos_creditados, t.exige_anexo
+		FROM tipos_afastamento t
+		WHERE t.empresa_id IS NULL OR t.empresa_id = (SELECT empresa_id FROM users WHERE id = $1)
+		ORDER BY t.nome`,
+		userID,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'tipos_afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve leave types")
+		return
+	}
+	defer rows.Close()
+
+	tipos := []models.TipoAfastamento{}
+	for rows.Next() {
+		var t models.TipoAfastamento
+		var empresaID sql.NullInt64
+		if err := rows.Scan(&t.ID, &empresaID, &t.Codigo, &t.Nome, &t.MinutosCreditados, &t.ExigeAnexo); err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning 'tipo_afastamento' row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process leave types")
+			return
+		}
+		if empresaID.Valid {
+			t.EmpresaID = &empresaID.Int64
+		}
+		tipos = append(tipos, t)
+	}
+
+	respondWithJSON(w, http.StatusOK, tipos)
+}
+
+// SolicitarAfastamento godoc
+// @Summary      Solicita um afastamento
+// @Description  Cria uma solicitação de afastamento (férias, atestado, folga...) pendente de aprovação do gestor.
+// @Tags         Afastamentos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        afastamento  body      SolicitacaoAfastamentoPayload  true  "Dados da solicitação"
+// @Success      201          {object}  models.Afastamento
+// @Failure      400          {object}  apierror.Problem  "Invalid request body"
+// @Failure      409          {object}  apierror.Problem  "Overlapping leave request"
+// @Failure      500          {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos [post]
+func SolicitarAfastamento(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload SolicitacaoAfastamentoPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+	defer tx.Rollback()
+
+	var tipoDisponivel bool
+	err = tx.QueryRowContext(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM tipos_afastamento
+		WHERE id = $1 AND (empresa_id IS NULL OR empresa_id = (SELECT empresa_id FROM users WHERE id = $2)))`,
+		payload.TipoID, userID,
+	).Scan(&tipoDisponivel)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking 'tipo_afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+	if !tipoDisponivel {
+		var v validation.Validator
+		v.Add("tipo_id", validation.CodeNotAllowed, "is not a leave type available to you")
+		respondWithValidationError(w, r, v.Err())
+		return
+	}
+
+	// Two overlapping requests can't be created at once
+	if err := bloquearUsuario(r.Context(), tx, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error locking user", "user_id", userID, "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+
+	var sobreposto bool
+	err = tx.QueryRowContext(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM afastamentos
+		WHERE user_id = $1 AND status IN ($2, $3) AND data_inicio <= $4 AND data_fim >= $5)`,
+		userID, models.AfastamentoPendente, models.AfastamentoAprovado, payload.DataFim, payload.DataInicio,
+	).Scan(&sobreposto)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking overlapping 'afastamentos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+	if sobreposto {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeLeaveRequestOverlap, "There is already a pending or approved leave request in this period")
+		return
+	}
+
+	var id int64
+	err = tx.QueryRowContext(r.Context(),
+		"INSERT INTO afastamentos (user_id, tipo_id, data_inicio, data_fim, motivo) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		userID, payload.TipoID, payload.DataInicio, payload.DataFim, strings.TrimSpace(payload.Motivo),
+	).Scan(&id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+
+	afastamento, err := carregarAfastamento(r.Context(), tx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+
+	err = webhooks.Enfileirar(r.Context(), tx, userID, eventos.TipoAfastamentoSolicitado, dadosEventoAfastamento{Afastamento: afastamento})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create leave request")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, afastamento)
+}
+
+// ListarAfastamentos godoc
+// @Summary      Lista afastamentos
+// @Description  Lista as solicitações de afastamento do usuário. Gestores podem listar as da equipe com equipe=true ou de um usuário com user_id.
+// @Tags         Afastamentos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        status   query     string  false  "Filtra pelo status (pendente, aprovado, rejeitado, cancelado)"
+// @Param        user_id  query     int     false  "ID do usuário consultado"
+// @Param        equipe   query     bool    false  "Lista os afastamentos de toda a equipe"
+// @Success      200      {array}   models.Afastamento
+// @Failure      400      {object}  apierror.Problem  "Invalid query parameters"
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos [get]
+func ListarAfastamentos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve leave requests")
+		return
+	}
+
+	ids := []int64{userID}
+	switch {
+	case r.URL.Query().Get("equipe") == "true":
+		if !solicitante.ehGestor() {
+			respondWithError(w, r, http.StatusForbidden, "Only managers can list the team's leave requests")
+			return
+		}
+		if ids, err = usuariosGerenciados(r.Context(), database.DB, solicitante); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing managed users", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve leave requests")
+			return
+		}
+	case r.URL.Query().Get("user_id") != "":
+		alvoID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		permitido, err := podeGerenciar(r.Context(), database.DB, solicitante, alvoID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve leave requests")
+			return
+		}
+		if !permitido {
+			respondWithError(w, r, http.StatusForbidden, "You don't have permission to see this user's leave requests")
+			return
+		}
+		ids = []int64{alvoID}
+	}
+
+	query := selectAfastamentoSQL + " WHERE a.user_id = ANY($1)"
+	args := []interface{}{pq.Array(ids)}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND a.status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY a.data_inicio DESC, a.id DESC"
+
+	rows, err := database.DB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'afastamentos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve leave requests")
+		return
+	}
+	defer rows.Close()
+
+	afastamentos := []models.Afastamento{}
+	for rows.Next() {
+		a, err := scanAfastamento(rows)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning 'afastamento' row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process leave requests")
+			return
+		}
+		afastamentos = append(afastamentos, a)
+	}
+
+	if err := carregarAnexos(r.Context(), database.DB, afastamentos); err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'afastamento_anexos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve leave requests")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, afastamentos)
+}
+
+// AdicionarAnexoAfastamento godoc
+// @Summary      Anexa um documento a um afastamento
+// @Description  Registra os metadados de um documento (por exemplo, o atestado médico) já enviado ao armazenamento de arquivos. Apenas o solicitante pode anexar, enquanto o afastamento estiver pendente.
+// @Tags         Afastamentos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id     path      int                      true  "ID do afastamento"
+// @Param        anexo  body      AnexoAfastamentoPayload  true  "Metadados do anexo"
+// @Success      201    {object}  models.AnexoAfastamento
+// @Failure      400    {object}  apierror.Problem  "Invalid request body"
+// @Failure      404    {object}  apierror.Problem  "Leave request not found or permission denied"
+// @Failure      409    {object}  apierror.Problem  "Leave request is not pending"
+// @Failure      500    {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos/{id}/anexos [post]
+func AdicionarAnexoAfastamento(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	afastamentoID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	var payload AnexoAfastamentoPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	payload.SHA256 = strings.ToLower(payload.SHA256)
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	var status string
+	err = database.DB.QueryRowContext(r.Context(), "SELECT status FROM afastamentos WHERE id = $1 AND user_id = $2", afastamentoID, userID).Scan(&status)
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "Leave request not found or you don't have permission to change it")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to add attachment")
+		return
+	}
+	if status != models.AfastamentoPendente {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeLeaveRequestNotPending, "Attachments can only be added to pending leave requests")
+		return
+	}
+
+	anexo := models.AnexoAfastamento{
+		AfastamentoID: afastamentoID,
+		NomeArquivo:   strings.TrimSpace(payload.NomeArquivo),
+		TipoConteudo:  payload.TipoConteudo,
+		TamanhoBytes:  payload.TamanhoBytes,
+		SHA256:        payload.SHA256,
+		URL:           payload.URL,
+	}
+	err = database.DB.QueryRowContext(r.Context(),
+		`INSERT INTO afastamento_anexos (afastamento_id, nome_arquivo, tipo_conteudo, tamanho_bytes, sha256, url)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, criado_em`,
+		anexo.AfastamentoID, anexo.NomeArquivo, anexo.TipoConteudo, anexo.TamanhoBytes, anexo.SHA256, anexo.URL,
+	).Scan(&anexo.ID, &anexo.CriadoEm)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting 'afastamento_anexo'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to add attachment")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, anexo)
+}
+
+// AprovarAfastamento godoc
+// @Summary      Aprova um afastamento
+// @Description  Aprova uma solicitação pendente da equipe do gestor. Os dias aprovados passam a ser abonados no cálculo de horas e no banco de horas.
+// @Tags         Afastamentos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                        true   "ID do afastamento"
+// @Param        decisao  body      DecisaoAfastamentoPayload  false  "Observação da decisão"
+// @Success      200      {object}  models.Afastamento
+// @Failure      400      {object}  apierror.Problem  "Missing required attachment"
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      404      {object}  apierror.Problem  "Leave request not found"
+// @Failure      409      {object}  apierror.Problem  "Leave request is not pending"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos/{id}/aprovar [post]
+func AprovarAfastamento(w http.ResponseWriter, r *http.Request) {
+	decidirAfastamento(w, r, models.AfastamentoAprovado)
+}
+
+// RejeitarAfastamento godoc
+// @Summary      Rejeita um afastamento
+// @Description  Rejeita uma solicitação pendente da equipe do gestor.
+// @Tags         Afastamentos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                        true   "ID do afastamento"
+// @Param        decisao  body      DecisaoAfastamentoPayload  false  "Observação da decisão"
+// @Success      200      {object}  models.Afastamento
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      404      {object}  apierror.Problem  "Leave request not found"
+// @Failure      409      {object}  apierror.Problem  "Leave request is not pending"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos/{id}/rejeitar [post]
+func RejeitarAfastamento(w http.ResponseWriter, r *http.Request) {
+	decidirAfastamento(w, r, models.AfastamentoRejeitado)
+}
+
+// CancelarAfastamento godoc
+// @Summary      Cancela um afastamento
+// @Description  O solicitante pode cancelar uma solicitação pendente; o gestor também pode cancelar um afastamento já aprovado.
+// @Tags         Afastamentos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "ID do afastamento"
+// @Success      200  {object}  models.Afastamento
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "Leave request not found"
+// @Failure      409  {object}  apierror.Problem  "Leave request can't be cancelled"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /afastamentos/{id}/cancelar [post]
+func CancelarAfastamento(w http.ResponseWriter, r *http.Request) {
+	decidirAfastamento(w, r, models.AfastamentoCancelado)
+}
+
+// decidirAfastamento aplica a mudança de status, verificando quem pode fazê-la
+// e a partir de qual status.
+func decidirAfastamento(w http.ResponseWriter, r *http.Request, novoStatus string) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	afastamentoID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	var payload DecisaoAfastamentoPayload
+	if r.ContentLength != 0 {
+		if !decodificarJSON(w, r, &payload) {
+			return
+		}
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+	defer tx.Rollback()
+
+	afastamento, err := carregarAfastamento(r.Context(), tx, afastamentoID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "Leave request not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), tx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+
+	// Managers decide on their team's requests, but never on their own
+	gestor := false
+	if afastamento.UserID != userID {
+		if gestor, err = podeGerenciar(r.Context(), tx, solicitante, afastamento.UserID); err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+			return
+		}
+	}
+
+	statusPermitidos := []string{models.AfastamentoPendente}
+	switch {
+	case novoStatus == models.AfastamentoCancelado && gestor:
+		statusPermitidos = append(statusPermitidos, models.AfastamentoAprovado)
+	case novoStatus == models.AfastamentoCancelado && afastamento.UserID == userID:
+	case !gestor:
+		respondWithError(w, r, http.StatusForbidden, "You don't have permission to decide on this leave request")
+		return
+	}
+
+	permitido := false
+	for _, s := range statusPermitidos {
+		permitido = permitido || afastamento.Status == s
+	}
+	if !permitido {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeLeaveRequestNotPending, i18n.T(i18n.FromContext(r.Context()), "Leave request is %s and can't be changed to %s", afastamento.Status, novoStatus))
+		return
+	}
+
+	if novoStatus == models.AfastamentoAprovado && afastamento.Tipo.ExigeAnexo && len(afastamento.Anexos) == 0 {
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeAttachmentRequired, "This leave type requires an attachment before approval")
+		return
+	}
+
+	// The status checked above is only a read: a concurrent decision must make this one fail
+	res, err := tx.ExecContext(r.Context(),
+		`UPDATE afastamentos SET status = $1, decidido_por = $2, decidido_em = NOW(), observacao_decisao = $3
+		WHERE id = $4 AND status = $5`,
+		novoStatus, userID, strings.TrimSpace(payload.Observacao), afastamentoID, afastamento.Status,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+	if n == 0 {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeLeaveRequestNotPending, "Leave request was changed by another request")
+		return
+	}
+
+	// Approving or cancelling an approved request changes the time bank of its days
+	if novoStatus == models.AfastamentoAprovado || afastamento.Status == models.AfastamentoAprovado {
+		if err := bloquearUsuario(r.Context(), tx, afastamento.UserID); err != nil {
+			slog.ErrorContext(r.Context(), "Error locking user", "user_id", afastamento.UserID, "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+			return
+		}
+		if err := invalidarBancoDeHoras(r.Context(), tx, afastamento.UserID, afastamento.DataInicio, afastamento.DataFim); err != nil {
+			slog.ErrorContext(r.Context(), "Error enqueuing time bank recomputation", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+			return
+		}
+	}
+
+	if afastamento, err = carregarAfastamento(r.Context(), tx, afastamentoID); err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+
+	tipoEvento := map[string]string{
+		models.AfastamentoAprovado:  eventos.TipoAfastamentoAprovado,
+		models.AfastamentoRejeitado: eventos.TipoAfastamentoRejeitado,
+		models.AfastamentoCancelado: eventos.TipoAfastamentoCancelado,
+	}[novoStatus]
+	err = webhooks.Enfileirar(r.Context(), tx, afastamento.UserID, tipoEvento, dadosEventoAfastamento{Afastamento: afastamento})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing 'afastamento'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update leave request")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, afastamento)
+}
@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"controle-ponto-api/webhooks"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// SolicitacaoAjustePayload define o corpo da requisição de ajuste de ponto.
+type SolicitacaoAjustePayload struct {
+	// PontoID é o ponto a corrigir; omitido para incluir um ponto que faltou.
+	PontoID *int64    `json:"ponto_id"`
+	Horario time.Time `json:"horario"`
+	Motivo  string    `json:"motivo"`
+}
+
+func (p SolicitacaoAjustePayload) validar(agora time.Time) error {
+	var v validation.Validator
+	v.Check(p.PontoID == nil || *p.PontoID > 0, "ponto_id", validation.CodeOutOfRange, "must be positive")
+	v.TimeBetween("horario", p.Horario, horarioMinimoPonto, agora.Add(ToleranciaDerivaRelogio))
+	if v.Required("motivo", strings.TrimSpace(p.Motivo)) {
+		v.MaxLength("motivo", p.Motivo, tamanhoMaximoObservacao)
+	}
+	return v.Err()
+}
+
+// DecisaoAjustePayload define o corpo das requisições de aprovação e rejeição de ajustes.
+type DecisaoAjustePayload struct {
+	Observacao string `json:"observacao"`
+}
+
+func (p DecisaoAjustePayload) validar() error {
+	var v validation.Validator
+	v.MaxLength("observacao", p.Observacao, tamanhoMaximoObservacao)
+	return v.Err()
+}
+
+const selectAjusteSQL = `
+	SELECT id, user_id, ponto_id, horario, motivo, status, decidido_por, decidido_em, observacao_decisao, criado_em
+	FROM ajustes_ponto`
+
+// colunasAjuste são as colunas de selectAjusteSQL, para o RETURNING de quem grava um ajuste.
+const colunasAjuste = "id, user_id, ponto_id, horario, motivo, status, decidido_por, decidido_em, observacao_decisao, criado_em"
+
+func scanAjuste(scanner interface{ Scan(...interface{}) error }) (models.AjustePonto, error) {
+	var a models.AjustePonto
+	var pontoID, decididoPor sql.NullInt64
+	var decididoEm sql.NullTime
+
+	err := scanner.Scan(&a.ID, &a.UserID, &pontoID, &a.Horario, &a.Motivo, &a.Status, &decididoPor, &decididoEm, &a.ObservacaoDecisao, &a.CriadoEm)
+	if err != nil {
+		return a, err
+	}
+	if pontoID.Valid {
+		a.PontoID = &pontoID.Int64
+	}
+	if decididoPor.Valid {
+		a.DecididoPor = &decididoPor.Int64
+	}
+	if decididoEm.Valid {
+		a.DecididoEm = &decididoEm.Time
+	}
+	return a, nil
+}
+
+// SolicitarAjuste godoc
+// @Summary      Solicita o ajuste de um ponto
+// @Description  Pede a correção do horário de um ponto do usuário, ou a inclusão de um ponto esquecido quando ponto_id é omitido. O ponto só muda quando o gestor aprova o ajuste.
+// @Tags         Ajustes
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        ajuste  body      SolicitacaoAjustePayload  true  "Dados da solicitação"
+// @Success      201     {object}  models.AjustePonto
+// @Failure      400     {object}  apierror.Problem  "Invalid request body"
+// @Failure      500     {object}  apierror.Problem  "Internal server error"
+// @Router       /ajustes [post]
+func SolicitarAjuste(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload SolicitacaoAjustePayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(time.Now()); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create adjustment request")
+		return
+	}
+	defer tx.Rollback()
+
+	if payload.PontoID != nil {
+		var existe bool
+		err = tx.QueryRowContext(r.Context(),
+			"SELECT EXISTS(SELECT 1 FROM pontos WHERE id = $1 AND user_id = $2)",
+			*payload.PontoID, userID,
+		).Scan(&existe)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking 'ponto'", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to create adjustment request")
+			return
+		}
+		if !existe {
+			var v validation.Validator
+			v.Add("ponto_id", validation.CodeNotAllowed, "is not one of your pontos")
+			respondWithValidationError(w, r, v.Err())
+			return
+		}
+	}
+
+	ajuste, err := scanAjuste(tx.QueryRowContext(r.Context(),
+		"INSERT INTO ajustes_ponto (user_id, ponto_id, horario, motivo) VALUES ($1, $2, $3, $4) RETURNING "+colunasAjuste,
+		userID, payload.PontoID, payload.Horario, strings.TrimSpace(payload.Motivo),
+	))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting 'ajuste'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create adjustment request")
+		return
+	}
+
+	if err := webhooks.Enfileirar(r.Context(), tx, userID, eventos.TipoAjusteSolicitado, dadosEventoAjuste{Ajuste: ajuste}); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create adjustment request")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing 'ajuste'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create adjustment request")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, ajuste)
+}
+
+// ListarAjustes godoc
+// @Summary      Lista ajustes de ponto
+// @Description  Lista as solicitações de ajuste do usuário. Gestores podem listar as da equipe com equipe=true ou de um usuário com user_id.
+// @Tags         Ajustes
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        status   query     string  false  "Filtra pelo status (pendente, aprovado, rejeitado)"
+// @Param        user_id  query     int     false  "ID do usuário consultado"
+// @Param        equipe   query     bool    false  "Lista os ajustes de toda a equipe"
+// @Success      200      {array}   models.AjustePonto
+// @Failure      400      {object}  apierror.Problem  "Invalid query parameters"
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /ajustes [get]
+func ListarAjustes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve adjustment requests")
+		return
+	}
+
+	ids := []int64{userID}
+	switch {
+	case r.URL.Query().Get("equipe") == "true":
+		if !solicitante.ehGestor() {
+			respondWithError(w, r, http.StatusForbidden, "Only managers can list the team's adjustment requests")
+			return
+		}
+		if ids, err = usuariosGerenciados(r.Context(), database.DB, solicitante); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing managed users", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve adjustment requests")
+			return
+		}
+	case r.URL.Query().Get("user_id") != "":
+		alvoID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		permitido, err := podeGerenciar(r.Context(), database.DB, solicitante, alvoID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve adjustment requests")
+			return
+		}
+		if !permitido {
+			respondWithError(w, r, http.StatusForbidden, "You don't have permission to see this user's adjustment requests")
+			return
+		}
+		ids = []int64{alvoID}
+	}
+
+	query := selectAjusteSQL + " WHERE user_id = ANY($1)"
+	args := []interface{}{pq.Array(ids)}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY criado_em DESC, id DESC"
+
+	rows, err := database.DB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'ajustes_ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve adjustment requests")
+		return
+	}
+	defer rows.Close()
+
+	ajustes := []models.AjustePonto{}
+	for rows.Next() {
+		a, err := scanAjuste(rows)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning 'ajuste' row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve adjustment requests")
+			return
+		}
+		ajustes = append(ajustes, a)
+	}
+
+	respondWithJSON(w, http.StatusOK, ajustes)
+}
+
+// AprovarAjuste godoc
+// @Summary      Aprova um ajuste de ponto
+// @Description  Aprova uma solicitação pendente da equipe do gestor e aplica o horário pedido ao ponto, ou inclui o ponto que faltou. As violações dos dias afetados são reavaliadas.
+// @Tags         Ajustes
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                   true   "ID do ajuste"
+// @Param        decisao  body      DecisaoAjustePayload  false  "Observação da decisão"
+// @Success      200      {object}  models.AjustePonto
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      404      {object}  apierror.Problem  "Adjustment request not found"
+// @Failure      409      {object}  apierror.Problem  "Adjustment request is not pending"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /ajustes/{id}/aprovar [post]
+func AprovarAjuste(w http.ResponseWriter, r *http.Request) {
+	decidirAjuste(w, r, models.AjusteAprovado)
+}
+
+// RejeitarAjuste godoc
+// @Summary      Rejeita um ajuste de ponto
+// @Description  Rejeita uma solicitação pendente da equipe do gestor; o ponto não muda.
+// @Tags         Ajustes
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id       path      int                   true   "ID do ajuste"
+// @Param        decisao  body      DecisaoAjustePayload  false  "Observação da decisão"
+// @Success      200      {object}  models.AjustePonto
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      404      {object}  apierror.Problem  "Adjustment request not found"
+// @Failure      409      {object}  apierror.Problem  "Adjustment request is not pending"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /ajustes/{id}/rejeitar [post]
+func RejeitarAjuste(w http.ResponseWriter, r *http.Request) {
+	decidirAjuste(w, r, models.AjusteRejeitado)
+}
+
+// decidirAjuste aprova ou rejeita um ajuste pendente. Só o gestor do solicitante decide,
+// nunca sobre os próprios ajustes.
+func decidirAjuste(w http.ResponseWriter, r *http.Request, novoStatus string) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	ajusteID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	var payload DecisaoAjustePayload
+	if r.ContentLength != 0 {
+		if !decodificarJSON(w, r, &payload) {
+			return
+		}
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+		return
+	}
+	defer tx.Rollback()
+
+	ajuste, err := scanAjuste(tx.QueryRowContext(r.Context(), selectAjusteSQL+" WHERE id = $1", ajusteID))
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "Adjustment request not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'ajuste'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), tx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+		return
+	}
+	gestor := false
+	if ajuste.UserID != userID {
+		if gestor, err = podeGerenciar(r.Context(), tx, solicitante, ajuste.UserID); err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+			return
+		}
+	}
+	if !gestor {
+		respondWithError(w, r, http.StatusForbidden, "You don't have permission to decide on this adjustment request")
+		return
+	}
+
+	if ajuste.Status != models.AjustePendente {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeAdjustmentNotPending, i18n.T(i18n.FromContext(r.Context()), "Adjustment request is %s and can't be changed to %s", ajuste.Status, novoStatus))
+		return
+	}
+
+	// As with leave requests, a concurrent decision must make this one fail
+	ajuste, err = scanAjuste(tx.QueryRowContext(r.Context(),
+		`UPDATE ajustes_ponto SET status = $1, decidido_por = $2, decidido_em = NOW(), observacao_decisao = $3
+		WHERE id = $4 AND status = $5
+		RETURNING `+colunasAjuste,
+		novoStatus, userID, strings.TrimSpace(payload.Observacao), ajusteID, models.AjustePendente,
+	))
+	if err == sql.ErrNoRows {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeAdjustmentNotPending, "Adjustment request was changed by another request")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating 'ajuste'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+		return
+	}
+
+	tipoEvento := eventos.TipoAjusteRejeitado
+	var eventoPonto *eventos.Evento
+	if novoStatus == models.AjusteAprovado {
+		tipoEvento = eventos.TipoAjusteAprovado
+		evento, err := aplicarAjuste(r.Context(), tx, ajuste)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error applying 'ajuste'", "ajuste_id", ajusteID, "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+			return
+		}
+		eventoPonto = &evento
+	}
+
+	if err := webhooks.Enfileirar(r.Context(), tx, ajuste.UserID, tipoEvento, dadosEventoAjuste{Ajuste: ajuste}); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing 'ajuste'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update adjustment request")
+		return
+	}
+
+	if eventoPonto != nil {
+		publicarEventosPonto(r.Context(), ajuste.UserID, *eventoPonto)
+	}
+	respondWithJSON(w, http.StatusOK, ajuste)
+}
+
+// aplicarAjuste grava o horário aprovado no ponto, ou inclui o ponto que faltou, e enfileira
+// a reavaliação das violações e o evento de ponto dos webhooks na transação tx. Retorna o
+// evento a publicar depois do commit.
+func aplicarAjuste(ctx context.Context, tx *sql.Tx, ajuste models.AjustePonto) (eventos.Evento, error) {
+	if err := bloquearUsuario(ctx, tx, ajuste.UserID); err != nil {
+		return eventos.Evento{}, err
+	}
+	empresa, err := carregarEmpresaDoUsuario(ctx, tx, ajuste.UserID)
+	if err != nil {
+		return eventos.Evento{}, err
+	}
+
+	ponto := models.Ponto{UserID: ajuste.UserID, Horario: ajuste.Horario}
+	evento := eventos.Evento{Tipo: eventos.TipoPontoCriado}
+	horarios := []time.Time{ajuste.Horario}
+	if ajuste.PontoID == nil {
+		err = tx.QueryRowContext(ctx,
+			"INSERT INTO pontos(user_id, horario) VALUES($1, $2) RETURNING id",
+			ajuste.UserID, ajuste.Horario,
+		).Scan(&ponto.ID)
+	} else {
+		// A deleted ponto takes its ajustes with it, so the row is still there
+		var horarioAnterior time.Time
+		ponto.ID = strconv.FormatInt(*ajuste.PontoID, 10)
+		err = tx.QueryRowContext(ctx,
+			`UPDATE pontos SET horario = $1 FROM pontos anterior
+			WHERE pontos.id = anterior.id AND pontos.id = $2 AND pontos.user_id = $3
+			RETURNING anterior.horario`,
+			ajuste.Horario, *ajuste.PontoID, ajuste.UserID,
+		).Scan(&horarioAnterior)
+		evento = eventos.Evento{Tipo: eventos.TipoPontoAlterado, HorarioAnterior: &horarioAnterior}
+		horarios = append(horarios, horarioAnterior)
+	}
+	if err != nil {
+		return eventos.Evento{}, err
+	}
+	evento.Ponto = ponto
+
+	if err := enfileirarReavaliacao(ctx, tx, ajuste.UserID, empresa.Fuso(), horarios...); err != nil {
+		return eventos.Evento{}, err
+	}
+	if err := invalidarBancoDeHorasDosPontos(ctx, tx, ajuste.UserID, empresa.Fuso(), horarios...); err != nil {
+		return eventos.Evento{}, err
+	}
+	err = webhooks.Enfileirar(ctx, tx, ajuste.UserID, evento.Tipo, dadosEventoPonto{Ponto: ponto, HorarioAnterior: evento.HorarioAnterior})
+	return evento, err
+}
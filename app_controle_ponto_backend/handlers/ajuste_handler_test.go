@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDecidirAjuste(t *testing.T) {
+	const (
+		gestorID = 4
+		donoID   = 8
+		ajusteID = 5
+		pontoID  = 21
+	)
+	horario := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+	anterior := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	colunas := []string{"id", "user_id", "ponto_id", "horario", "motivo", "status", "decidido_por", "decidido_em", "observacao_decisao", "criado_em"}
+	linha := func(pontoID interface{}, status string) *sqlmock.Rows {
+		return sqlmock.NewRows(colunas).AddRow(ajusteID, donoID, pontoID, horario, "Esqueci de bater", status, nil, nil, "", anterior)
+	}
+	// carregar espera a leitura do ajuste e do perfil de quem decide, um gestor do dono
+	carregar := func(mock sqlmock.Sqlmock, pontoID interface{}, status string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta("FROM ajustes_ponto WHERE id = $1")).WithArgs(ajusteID).WillReturnRows(linha(pontoID, status))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT papel, empresa_id FROM users")).
+			WillReturnRows(sqlmock.NewRows([]string{"papel", "empresa_id"}).AddRow(models.PapelGestor, nil))
+		mock.ExpectQuery(regexp.QuoteMeta("gestor_id = $2")).WithArgs(donoID, gestorID).
+			WillReturnRows(sqlmock.NewRows([]string{"existe"}).AddRow(true))
+	}
+	decidir := func(mock sqlmock.Sqlmock, pontoID interface{}, status string) {
+		mock.ExpectQuery(regexp.QuoteMeta("UPDATE ajustes_ponto SET status = $1")).
+			WithArgs(status, gestorID, "", ajusteID, models.AjustePendente).
+			WillReturnRows(linha(pontoID, status))
+	}
+	// webhook espera o evento tipo na outbox
+	webhook := func(mock sqlmock.Sqlmock, tipo string) {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_entregas")).
+			WithArgs(sqlmock.AnyArg(), tipo, sqlmock.AnyArg(), donoID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	aplicar := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).WithArgs(donoID).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT empresa_id FROM users")).
+			WillReturnRows(sqlmock.NewRows([]string{"empresa_id"}).AddRow(nil))
+	}
+	publicar := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT empresa_id, gestor_id FROM users")).
+			WillReturnRows(sqlmock.NewRows([]string{"empresa_id", "gestor_id"}).AddRow(nil, gestorID))
+	}
+
+	tests := []struct {
+		name            string
+		aprovar         bool
+		esperar         func(mock sqlmock.Sqlmock)
+		status          int
+		eventoPublicado string
+	}{
+		{
+			name:    "approval moves the ponto",
+			aprovar: true,
+			esperar: func(mock sqlmock.Sqlmock) {
+				carregar(mock, pontoID, models.AjustePendente)
+				decidir(mock, pontoID, models.AjusteAprovado)
+				aplicar(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE pontos SET horario = $1")).
+					WithArgs(horario, pontoID, donoID).
+					WillReturnRows(sqlmock.NewRows([]string{"horario"}).AddRow(anterior))
+				// One reevaluation for the new day and one for the old, and the time bank of both
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+				esperarInvalidacaoBancoDeHoras(mock)
+				esperarInvalidacaoBancoDeHoras(mock)
+				webhook(mock, eventos.TipoPontoAlterado)
+				webhook(mock, eventos.TipoAjusteAprovado)
+				mock.ExpectCommit()
+				publicar(mock)
+			},
+			status:          http.StatusOK,
+			eventoPublicado: eventos.TipoPontoAlterado,
+		},
+		{
+			name:    "approval adds a missing ponto",
+			aprovar: true,
+			esperar: func(mock sqlmock.Sqlmock) {
+				carregar(mock, nil, models.AjustePendente)
+				decidir(mock, nil, models.AjusteAprovado)
+				aplicar(mock)
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO pontos")).
+					WithArgs(donoID, horario).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(pontoID))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+				esperarInvalidacaoBancoDeHoras(mock)
+				webhook(mock, eventos.TipoPontoCriado)
+				webhook(mock, eventos.TipoAjusteAprovado)
+				mock.ExpectCommit()
+				publicar(mock)
+			},
+			status:          http.StatusOK,
+			eventoPublicado: eventos.TipoPontoCriado,
+		},
+		{
+			name: "rejection leaves the ponto alone",
+			esperar: func(mock sqlmock.Sqlmock) {
+				carregar(mock, pontoID, models.AjustePendente)
+				decidir(mock, pontoID, models.AjusteRejeitado)
+				webhook(mock, eventos.TipoAjusteRejeitado)
+				mock.ExpectCommit()
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:    "already decided",
+			aprovar: true,
+			esperar: func(mock sqlmock.Sqlmock) {
+				carregar(mock, pontoID, models.AjusteRejeitado)
+				mock.ExpectRollback()
+			},
+			status: http.StatusConflict,
+		},
+		{
+			name:    "decided by a concurrent request",
+			aprovar: true,
+			esperar: func(mock sqlmock.Sqlmock) {
+				carregar(mock, pontoID, models.AjustePendente)
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE ajustes_ponto SET status = $1")).WillReturnRows(sqlmock.NewRows(colunas))
+				mock.ExpectRollback()
+			},
+			status: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			tt.esperar(mock)
+
+			assinatura, _, _ := eventos.Padrao.Assinar(0, func(e eventos.Evento) bool { return e.UserID == donoID })
+			defer assinatura.Cancelar()
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "5")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+			ctx = context.WithValue(ctx, middleware.UserIDKey, int64(gestorID))
+			r := httptest.NewRequest(http.MethodPost, "/api/ajustes/5/aprovar", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			if tt.aprovar {
+				AprovarAjuste(rec, r)
+			} else {
+				RejeitarAjuste(rec, r)
+			}
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			select {
+			case e := <-assinatura.C:
+				if e.Tipo != tt.eventoPublicado || e.Ponto.ID != "21" {
+					t.Errorf("published %s for ponto %s, want %q for ponto 21", e.Tipo, e.Ponto.ID, tt.eventoPublicado)
+				}
+			default:
+				if tt.eventoPublicado != "" {
+					t.Errorf("no event published, want %s", tt.eventoPublicado)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Tipos de evento de auditoria.
+const (
+	AuditoriaContaBloqueada     = "login.conta_bloqueada"
+	AuditoriaContaDesbloqueada  = "login.conta_desbloqueada"
+	AuditoriaIPBloqueado        = "login.ip_bloqueado"
+	AuditoriaContaVinculadaOIDC = "oidc.conta_vinculada"
+)
+
+// EventoAuditoria é um registro de eventos_auditoria. UserID é o usuário afetado e AtorID
+// quem executou a ação, quando não foi o próprio sistema.
+type EventoAuditoria struct {
+	Tipo   string
+	UserID *int64
+	AtorID *int64
+	IP     string
+	Dados  interface{}
+}
+
+// registrarAuditoria grava o evento em eventos_auditoria.
+func registrarAuditoria(ctx context.Context, q queryer, e EventoAuditoria) error {
+	dados := []byte("{}")
+	if e.Dados != nil {
+		var err error
+		if dados, err = json.Marshal(e.Dados); err != nil {
+			return err
+		}
+	}
+	var ip *string
+	if e.IP != "" {
+		ip = &e.IP
+	}
+	_, err := q.ExecContext(ctx,
+		"INSERT INTO eventos_auditoria (tipo, user_id, ator_id, ip, dados) VALUES ($1, $2, $3, $4, $5)",
+		e.Tipo, e.UserID, e.AtorID, ip, string(dados),
+	)
+	return err
+}
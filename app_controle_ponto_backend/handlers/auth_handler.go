@@ -1,112 +1,201 @@
 package handlers
 
 import (
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/auth"
 	"controle-ponto-api/database"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/metrics"
 	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
-	"os"
-	"time"
+	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
-
-type Claims struct {
-	UserID int64 `json:"user_id"`
-	jwt.RegisteredClaims
-}
+// Tokens issues the access tokens returned by Login. It is set in main before the server starts.
+var Tokens *auth.Issuer
 
 // Register godoc
 // @Summary      Registra um novo usuário
-// @Description  Cria um novo usuário no sistema com nome, email e senha.
+// @Description  Cria um novo usuário no sistema com nome, email e senha, que precisa seguir a política de senhas, e envia o link de verificação do email. O login só é liberado depois da verificação.
 // @Tags         Authentication
 // @Accept       json
 // @Produce      json
 // @Param        user  body      models.User  true  "Dados do usuário para registro (ID e Horarios podem ser omitidos)"
 // @Success      201   {object}  map[string]string
-// @Failure      400   {string}  string "Invalid request body"
-// @Failure      500   {string}  string "Failed to create user"
+// @Failure      400   {object}  apierror.Problem "Invalid request body or weak password"
+// @Failure      409   {object}  apierror.Problem "Email already registered"
+// @Failure      500   {object}  apierror.Problem "Failed to create user"
 // @Router       /register [post]
 func Register(w http.ResponseWriter, r *http.Request) {
 	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodificarJSON(w, r, &user) {
+		return
+	}
+	user.Nome = strings.TrimSpace(user.Nome)
+	user.Email = normalizarEmail(user.Email)
+
+	var v validation.Validator
+	if v.Required("nome", user.Nome) {
+		v.MaxLength("nome", user.Nome, tamanhoMaximoNome)
+	}
+	if v.Required("email", user.Email) && v.Email("email", user.Email) {
+		v.MaxLength("email", user.Email, tamanhoMaximoEmail)
+	}
+	if user.Idioma == "" {
+		user.Idioma = i18n.FromContext(r.Context())
+	} else {
+		v.OneOf("idioma", user.Idioma, i18n.Supported...)
+	}
+	if err := validarSenha(&v, "password", user.Password, user.Nome, user.Email); err != nil {
+		slog.ErrorContext(r.Context(), "Error checking password", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+	if err := v.Err(); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to hash password")
 		return
 	}
 
-	_, err = database.DB.Exec("INSERT INTO users (nome, email, password_hash) VALUES ($1, $2, $3)", user.Nome, user.Email, string(hashedPassword))
+	tx, err := database.DB.BeginTx(r.Context(), nil)
 	if err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
+	defer tx.Rollback()
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "User created successfully"})
+	err = tx.QueryRowContext(r.Context(),
+		"INSERT INTO users (nome, email, password_hash, idioma) VALUES ($1, $2, $3, $4) RETURNING id",
+		user.Nome, user.Email, string(hashedPassword), user.Idioma,
+	).Scan(&user.ID)
+	if err == nil {
+		err = registrarHistoricoSenha(r.Context(), tx, user.ID, string(hashedPassword))
+	}
+	if err == nil {
+		err = criarTokenUsuario(r.Context(), tx, user.ID, TokenVerificacaoEmail, validadeVerificacaoEmail)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	// Emails are unique in lower case, so this also catches one that differs only by case
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Table == "users" {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeEmailTaken, "Email already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating user", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{
+		"message": i18n.T(i18n.FromContext(r.Context()), "User created successfully. Check your email to verify the account."),
+	})
 }
 
 // Login godoc
 // @Summary      Realiza o login do usuário
-// @Description  Autentica um usuário com email e senha e retorna um token JWT.
+// @Description  Autentica um usuário com email e senha e retorna um token JWT. Se o usuário usa TOTP, ou a empresa o exige para o papel dele, retorna um desafio a ser concluído em /login/mfa ou /login/mfa/cadastro. Falhas seguidas impõem uma espera crescente entre as tentativas e depois bloqueiam a conta temporariamente; o mesmo vale para o IP de origem. O cabeçalho Retry-After informa quando tentar de novo.
 // @Tags         Authentication
 // @Accept       json
 // @Produce      json
 // @Param        credentials  body      models.User  true  "Credenciais de login (apenas email e password são necessários)"
-// @Success      200          {object}  map[string]string
-// @Failure      400          {string}  string "Invalid request body"
-// @Failure      401          {string}  string "Invalid credentials"
-// @Failure      500          {string}  string "Internal server error"
+// @Success      200          {object}  RespostaLogin
+// @Failure      400          {object}  apierror.Problem "Invalid request body"
+// @Failure      401          {object}  apierror.Problem "Invalid credentials"
+// @Failure      403          {object}  apierror.Problem "Email not verified"
+// @Failure      423          {object}  apierror.Problem "Account temporarily locked"
+// @Failure      429          {object}  apierror.Problem "Too many login attempts"
+// @Failure      500          {object}  apierror.Problem "Internal server error"
 // @Router       /login [post]
 func Login(w http.ResponseWriter, r *http.Request) {
 	var creds models.User
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodificarJSON(w, r, &creds) {
+		return
+	}
+	var v validation.Validator
+	v.Required("email", creds.Email)
+	v.Required("password", creds.Password)
+	if err := v.Err(); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	var user models.User
-	var hashedPassword string
-	err := database.DB.QueryRow("SELECT id, nome, email, password_hash FROM users WHERE email = $1", creds.Email).Scan(&user.ID, &user.Nome, &user.Email, &hashedPassword)
+	email := normalizarEmail(creds.Email)
+	ip := ipCliente(r)
+	tentativa, status, espera, err := reservarTentativaLogin(r.Context(), email, ip)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-			return
-		}
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Error checking login attempts", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
 		return
 	}
-
-	if err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(creds.Password)); err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+	if status != 0 {
+		recusarTentativaLogin(w, r, status, espera)
 		return
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		UserID: user.ID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	var user models.User
+	var hashedPassword string
+	var emailVerificado bool
+	// LOWER also matches accounts created before emails were stored in lower case
+	err = database.DB.QueryRowContext(r.Context(), "SELECT id, nome, email, password_hash, email_verificado_em IS NOT NULL FROM users WHERE LOWER(email) = $1", email).Scan(&user.ID, &user.Nome, &user.Email, &hashedPassword, &emailVerificado)
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
-	if err != nil {
-		http.Error(w, "Failed to create token", http.StatusInternalServerError)
+	// Unknown emails and accounts without a password are compared against a dummy hash,
+	// so every failure takes as long as a wrong password
+	var userID *int64
+	var hash []byte
+	if err == nil && hashedPassword != "" {
+		userID = &user.ID
+		hash = []byte(hashedPassword)
+	} else if hash, err = hashFicticio(); err != nil {
+		slog.ErrorContext(r.Context(), "Error generating dummy password hash", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(creds.Password)) != nil || userID == nil {
+		if err := registrarFalhaLogin(r.Context(), tentativa, userID); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording failed login", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		metrics.LoginFailures.WithLabelValues(metrics.LoginInvalidCredentials).Inc()
+		respondWithProblem(w, r, http.StatusUnauthorized, apierror.CodeInvalidCredentials, "Invalid credentials")
+		return
+	}
+	// Checked after the password so it doesn't reveal which emails are registered
+	if !emailVerificado {
+		if err := limparFalhasLogin(r.Context(), tentativa); err != nil {
+			slog.ErrorContext(r.Context(), "Error clearing failed logins", "error", err)
+		}
+		respondWithProblem(w, r, http.StatusForbidden, apierror.CodeEmailNotVerified, "Email not verified")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": tokenString,
-	})
-}
\ No newline at end of file
+	concluirLogin(w, r, user.ID, tentativa)
+}
+
+// JWKS publica as chaves públicas usadas para validar os tokens emitidos pela API, para
+// que outros serviços os verifiquem sem compartilhar um segredo. Chaves HMAC não aparecem.
+// Fica fora de /api, no caminho padrão /.well-known/jwks.json.
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	// Short enough that a rotated key shows up quickly for the services caching the set
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, Tokens.JWKS())
+}
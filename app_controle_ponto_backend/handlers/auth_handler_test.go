@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"controle-ponto-api/apierror"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+// esperarReservaLogin faz reservarTentativaLogin liberar a primeira tentativa do email.
+func esperarReservaLogin(mock sqlmock.Sqlmock, email string) {
+	esperarReservaLoginComFalhas(mock, email, 1)
+}
+
+// esperarReservaLoginComFalhas faz reservarTentativaLogin liberar uma tentativa que
+// completa falhas na sequência do email.
+func esperarReservaLoginComFalhas(mock sqlmock.Sqlmock, email string, falhas int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM falhas_login")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bloqueios_login")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM falhas_login")).WillReturnRows(sqlmock.NewRows([]string{"restante"}))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO bloqueios_login")).WithArgs(email, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"falhas"}).AddRow(falhas))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO falhas_login")).WithArgs(email, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+func TestLoginEmailDesconhecido(t *testing.T) {
+	const email = "ninguem@exemplo.com"
+	mock := mockDB(t)
+	esperarReservaLogin(mock, email)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE LOWER(email) = $1")).WithArgs(email).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nome", "email", "password_hash", "verificado"}))
+	// The password is still compared, against the dummy hash, and counts as a failure
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM falhas_login WHERE ip = $1")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+
+	rec := httptest.NewRecorder()
+	Login(rec, httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(`{"email": "Ninguem@Exemplo.com", "password": "qualquer senha"}`)))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d; body %s", rec.Code, http.StatusUnauthorized, rec.Body)
+	}
+	if hash, err := hashFicticio(); err != nil || len(hash) == 0 {
+		t.Errorf("hashFicticio() = %q, %v", hash, err)
+	}
+}
+
+func TestRegisterEmailExistente(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectBegin()
+	// The account was created as maria@exemplo.com; the unique index on LOWER(email) refuses the variant
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users")).WithArgs("Maria", "maria@exemplo.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(&pq.Error{Code: "23505", Table: "users", Constraint: "idx_users_email_lower"})
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	Register(rec, httptest.NewRequest(http.MethodPost, "/api/register", strings.NewReader(`{"nome": "Maria", "email": "Maria@Exemplo.com", "password": "cavalo bateria grampo correto"}`)))
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d; body %s", rec.Code, http.StatusConflict, rec.Body)
+	}
+	if !strings.Contains(rec.Body.String(), apierror.CodeEmailTaken) {
+		t.Errorf("body = %s, want code %s", rec.Body, apierror.CodeEmailTaken)
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/models"
+	"database/sql"
+)
+
+// perfil reúne os dados usados para decidir o que um usuário pode acessar.
+type perfil struct {
+	ID        int64
+	Papel     string
+	EmpresaID sql.NullInt64
+}
+
+func (p perfil) ehGestor() bool {
+	return p.Papel == models.PapelGestor || p.Papel == models.PapelAdmin
+}
+
+func (p perfil) ehAdmin() bool {
+	return p.Papel == models.PapelAdmin && p.EmpresaID.Valid
+}
+
+// carregarPerfil lê o papel e a empresa do usuário.
+func carregarPerfil(ctx context.Context, q queryer, userID int64) (perfil, error) {
+	p := perfil{ID: userID}
+	err := q.QueryRowContext(ctx, "SELECT papel, empresa_id FROM users WHERE id = $1", userID).Scan(&p.Papel, &p.EmpresaID)
+	return p, err
+}
+
+// podeGerenciar informa se p pode consultar e alterar os dados do usuário alvoID.
+// Administradores gerenciam todos os usuários da própria empresa; gestores,
+// os usuários da sua equipe (users.gestor_id).
+func podeGerenciar(ctx context.Context, q queryer, p perfil, alvoID int64) (bool, error) {
+	if p.ID == alvoID {
+		return true, nil
+	}
+	if !p.ehGestor() {
+		return false, nil
+	}
+
+	var gerenciado bool
+	var err error
+	if p.ehAdmin() {
+		err = q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND empresa_id = $2)", alvoID, p.EmpresaID.Int64).Scan(&gerenciado)
+	} else {
+		err = q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND gestor_id = $2)", alvoID, p.ID).Scan(&gerenciado)
+	}
+	return gerenciado, err
+}
+
+// usuariosGerenciados retorna os IDs dos usuários gerenciados por p, incluindo o próprio.
+func usuariosGerenciados(ctx context.Context, q queryer, p perfil) ([]int64, error) {
+	var rows *sql.Rows
+	var err error
+	switch {
+	case p.ehAdmin():
+		rows, err = q.QueryContext(ctx, "SELECT id FROM users WHERE empresa_id = $1 OR id = $2 ORDER BY id", p.EmpresaID.Int64, p.ID)
+	case p.ehGestor():
+		rows, err = q.QueryContext(ctx, "SELECT id FROM users WHERE gestor_id = $1 OR id = $1 ORDER BY id", p.ID)
+	default:
+		return []int64{p.ID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/tracing"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BancoDeHorasResposta é o extrato do banco de horas de um usuário no período.
+type BancoDeHorasResposta struct {
+	UserID            int64              `json:"user_id"`
+	Inicio            string             `json:"inicio"`
+	Fim               string             `json:"fim"`
+	TrabalhadoMinutos int                `json:"trabalhado_minutos"`
+	EsperadoMinutos   int                `json:"esperado_minutos"`
+	AbonadoMinutos    int                `json:"abonado_minutos"`
+	SaldoMinutos      int                `json:"saldo_minutos"`
+	Dias              []jornada.Apuracao `json:"dias"`
+}
+
+// apurarPeriodo fecha cada dia entre inicio e fim (inclusivo), meia-noite no fuso da
+// empresa, abonando os dias de afastamento aprovado conforme o tempo creditado pelo tipo.
+func apurarPeriodo(ctx context.Context, q queryer, userID int64, empresa models.Empresa, inicio, fim time.Time) ([]jornada.Apuracao, error) {
+	ctx, span := tracing.Start(ctx, "apurarPeriodo", trace.WithAttributes(
+		attribute.Int64("user_id", userID),
+		attribute.String("inicio", inicio.Format(jornada.FormatoData)),
+		attribute.String("fim", fim.Format(jornada.FormatoData)),
+	))
+	defer span.End()
+
+	pontos, err := listarPontosDoPeriodo(ctx, q, userID, inicio, fim)
+	if err != nil {
+		return nil, err
+	}
+	abonos, err := abonosDoPeriodo(ctx, q, userID, inicio, fim)
+	if err != nil {
+		return nil, err
+	}
+
+	_, porDia := jornada.AgruparPorDia(pontos, empresa.Fuso())
+	cargaDiaria := time.Duration(empresa.CargaHorariaDiariaMinutos) * time.Minute
+
+	apuracoes := []jornada.Apuracao{}
+	for dia := inicio; !dia.After(fim); dia = dia.AddDate(0, 0, 1) {
+		data := dia.Format(jornada.FormatoData)
+
+		var abono *jornada.Abono
+		if a, ok := abonos[data]; ok {
+			abono = &a
+		}
+		apuracoes = append(apuracoes, jornada.Apurar(dia, porDia[data], cargaDiaria, abono))
+	}
+	return apuracoes, nil
+}
+
+// JobRecalcularBancoDeHoras é o tipo da tarefa que recalcula o banco de horas gravado de um período.
+const JobRecalcularBancoDeHoras = "banco_horas.recalcular"
+
+// recalculoBancoDeHorasPayload identifica o período a recalcular, em datas no fuso da empresa.
+type recalculoBancoDeHorasPayload struct {
+	UserID int64  `json:"user_id"`
+	Inicio string `json:"inicio"`
+	Fim    string `json:"fim"`
+}
+
+// agendarRecalculoBancoDeHoras agenda o recálculo do banco de horas gravado dos dias entre
+// inicio e fim (inclusivo). Pedidos seguidos do mesmo período geram uma só tarefa.
+func agendarRecalculoBancoDeHoras(ctx context.Context, q jobs.Executor, userID int64, inicio, fim string) error {
+	return jobs.Enfileirar(ctx, q, JobRecalcularBancoDeHoras, recalculoBancoDeHorasPayload{UserID: userID, Inicio: inicio, Fim: fim}, jobs.Opcoes{
+		ChaveUnica: fmt.Sprintf("%d:%s:%s", userID, inicio, fim),
+	})
+}
+
+// invalidarBancoDeHoras descarta o banco de horas gravado dos dias entre inicio e fim e
+// agenda o recálculo. Deve ser chamada na transação da alteração que o desatualiza, para
+// que nenhuma consulta leia o dia antigo depois do commit.
+func invalidarBancoDeHoras(ctx context.Context, q jobs.Executor, userID int64, inicio, fim string) error {
+	if _, err := q.ExecContext(ctx,
+		"DELETE FROM banco_horas_dias WHERE user_id = $1 AND data BETWEEN $2 AND $3",
+		userID, inicio, fim,
+	); err != nil {
+		return err
+	}
+	return agendarRecalculoBancoDeHoras(ctx, q, userID, inicio, fim)
+}
+
+// invalidarBancoDeHorasDosPontos invalida o banco de horas dos dias dos horários informados,
+// contados no fuso da empresa.
+func invalidarBancoDeHorasDosPontos(ctx context.Context, q jobs.Executor, userID int64, fuso *time.Location, horarios ...time.Time) error {
+	for _, h := range horarios {
+		data := h.In(fuso).Format(jornada.FormatoData)
+		if err := invalidarBancoDeHoras(ctx, q, userID, data, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apuracoesGravadas lê o banco de horas gravado dos dias entre inicio e fim, ignorando os
+// dias calculados com outra carga horária ou outro fuso da empresa.
+func apuracoesGravadas(ctx context.Context, q queryer, userID int64, empresa models.Empresa, inicio, fim time.Time) ([]jornada.Apuracao, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT data, trabalhado_minutos, esperado_minutos, abonado_minutos, saldo_minutos, afastamento
+		FROM banco_horas_dias
+		WHERE user_id = $1 AND data BETWEEN $2 AND $3 AND carga_horaria_diaria_minutos = $4 AND fuso_horario = $5
+		ORDER BY data ASC`,
+		userID, inicio.Format(jornada.FormatoData), fim.Format(jornada.FormatoData), empresa.CargaHorariaDiariaMinutos, empresa.Fuso().String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apuracoes := []jornada.Apuracao{}
+	for rows.Next() {
+		var a jornada.Apuracao
+		var data time.Time
+		if err := rows.Scan(&data, &a.TrabalhadoMinutos, &a.EsperadoMinutos, &a.AbonadoMinutos, &a.SaldoMinutos, &a.Afastamento); err != nil {
+			return nil, err
+		}
+		a.Data = data.Format(jornada.FormatoData)
+		apuracoes = append(apuracoes, a)
+	}
+	return apuracoes, rows.Err()
+}
+
+// consultarApuracoes fecha os dias entre inicio e fim, que não pode passar de hoje. Os dias
+// passados vêm do banco de horas gravado; se faltar algum, o período é apurado na hora e o
+// recálculo é agendado para as próximas consultas. Hoje, ainda aberto, é sempre apurado na hora.
+func consultarApuracoes(ctx context.Context, userID int64, empresa models.Empresa, inicio, fim time.Time) ([]jornada.Apuracao, error) {
+	hoje := inicioDoDia(time.Now(), empresa.Fuso())
+	ontem := hoje.AddDate(0, 0, -1)
+	fimPassado := fim
+	if fimPassado.After(ontem) {
+		fimPassado = ontem
+	}
+
+	apuracoes := []jornada.Apuracao{}
+	if !fimPassado.Before(inicio) {
+		gravadas, err := apuracoesGravadas(ctx, database.DB, userID, empresa, inicio, fimPassado)
+		if err != nil {
+			return nil, err
+		}
+
+		dias := 0
+		for dia := inicio; !dia.After(fimPassado); dia = dia.AddDate(0, 0, 1) {
+			dias++
+		}
+		if len(gravadas) == dias {
+			apuracoes = gravadas
+		} else {
+			if apuracoes, err = apurarPeriodo(ctx, database.DB, userID, empresa, inicio, fimPassado); err != nil {
+				return nil, err
+			}
+			err = agendarRecalculoBancoDeHoras(ctx, database.DB, userID, inicio.Format(jornada.FormatoData), fimPassado.Format(jornada.FormatoData))
+			if err != nil {
+				// The answer is already computed; the next query just won't find it stored
+				slog.WarnContext(ctx, "Error enqueuing time bank recomputation", "user_id", userID, "error", err)
+			}
+		}
+	}
+
+	if fim.Equal(hoje) && !hoje.Before(inicio) {
+		doDia, err := apurarPeriodo(ctx, database.DB, userID, empresa, hoje, hoje)
+		if err != nil {
+			return nil, err
+		}
+		apuracoes = append(apuracoes, doDia...)
+	}
+	return apuracoes, nil
+}
+
+// RecalcularBancoDeHorasJob executa a tarefa JobRecalcularBancoDeHoras, gravando os dias
+// fechados do período. Hoje, ainda aberto, não é gravado.
+func RecalcularBancoDeHorasJob(ctx context.Context, job jobs.Job) error {
+	var payload recalculoBancoDeHorasPayload
+	if err := job.Decodificar(&payload); err != nil {
+		return err
+	}
+	empresa, err := carregarEmpresaDoUsuario(ctx, database.DB, payload.UserID)
+	if err != nil {
+		return err
+	}
+	fuso := empresa.Fuso()
+	inicio, err := time.ParseInLocation(jornada.FormatoData, payload.Inicio, fuso)
+	if err != nil {
+		return err
+	}
+	fim, err := time.ParseInLocation(jornada.FormatoData, payload.Fim, fuso)
+	if err != nil {
+		return err
+	}
+	if ontem := inicioDoDia(time.Now(), fuso).AddDate(0, 0, -1); fim.After(ontem) {
+		fim = ontem
+	}
+	if fim.Before(inicio) {
+		return nil
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Changes to the user's records wait for the recomputation, so none of them can be
+	// stored stale after the change has discarded the old days
+	if err := bloquearUsuario(ctx, tx, payload.UserID); err != nil {
+		return err
+	}
+	apuracoes, err := apurarPeriodo(ctx, tx, payload.UserID, empresa, inicio, fim)
+	if err != nil {
+		return err
+	}
+	for _, a := range apuracoes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO banco_horas_dias (user_id, data, trabalhado_minutos, esperado_minutos, abonado_minutos, saldo_minutos,
+				afastamento, carga_horaria_diaria_minutos, fuso_horario)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (user_id, data) DO UPDATE
+				SET trabalhado_minutos = EXCLUDED.trabalhado_minutos,
+					esperado_minutos = EXCLUDED.esperado_minutos,
+					abonado_minutos = EXCLUDED.abonado_minutos,
+					saldo_minutos = EXCLUDED.saldo_minutos,
+					afastamento = EXCLUDED.afastamento,
+					carga_horaria_diaria_minutos = EXCLUDED.carga_horaria_diaria_minutos,
+					fuso_horario = EXCLUDED.fuso_horario,
+					calculado_em = NOW()`,
+			payload.UserID, a.Data, a.TrabalhadoMinutos, a.EsperadoMinutos, a.AbonadoMinutos, a.SaldoMinutos,
+			a.Afastamento, empresa.CargaHorariaDiariaMinutos, fuso.String(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ConsultarBancoDeHoras godoc
+// @Summary      Consulta o banco de horas
+// @Description  Apura dia a dia o tempo trabalhado, a jornada esperada e o tempo abonado por afastamentos aprovados, somando o saldo de horas do período. Os dias são contados no fuso horário da empresa e os dias futuros não são apurados. Os dias já fechados são lidos do saldo gravado, recalculado em segundo plano depois de cada alteração nos pontos ou afastamentos.
+// @Tags         Banco de Horas
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        inicio   query     string  false  "Data inicial no formato YYYY-MM-DD (padrão: 29 dias atrás)"
+// @Param        fim      query     string  false  "Data final no formato YYYY-MM-DD (padrão: hoje)"
+// @Param        user_id  query     int     false  "ID do usuário consultado (gestores)"
+// @Success      200      {object}  BancoDeHorasResposta
+// @Failure      400      {object}  apierror.Problem  "Invalid query parameters"
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /banco-horas [get]
+func ConsultarBancoDeHoras(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	alvoID := userID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		var err error
+		if alvoID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+
+		solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to calculate time bank")
+			return
+		}
+		permitido, err := podeGerenciar(r.Context(), database.DB, solicitante, alvoID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to calculate time bank")
+			return
+		}
+		if !permitido {
+			respondWithError(w, r, http.StatusForbidden, "You don't have permission to see this user's time bank")
+			return
+		}
+	}
+
+	// Days are counted in the time zone of the user whose time bank this is
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, alvoID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to calculate time bank")
+		return
+	}
+
+	inicio, fim, err := parsePeriodo(r, empresa.Fuso())
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if hoje := inicioDoDia(time.Now(), empresa.Fuso()); fim.After(hoje) {
+		fim = hoje
+	}
+
+	resposta := BancoDeHorasResposta{
+		UserID: alvoID,
+		Inicio: inicio.Format(jornada.FormatoData),
+		Fim:    fim.Format(jornada.FormatoData),
+		Dias:   []jornada.Apuracao{},
+	}
+
+	if !fim.Before(inicio) {
+		if resposta.Dias, err = consultarApuracoes(r.Context(), alvoID, empresa, inicio, fim); err != nil {
+			slog.ErrorContext(r.Context(), "Error calculating time bank", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to calculate time bank")
+			return
+		}
+	}
+
+	for _, dia := range resposta.Dias {
+		resposta.TrabalhadoMinutos += dia.TrabalhadoMinutos
+		resposta.EsperadoMinutos += dia.EsperadoMinutos
+		resposta.AbonadoMinutos += dia.AbonadoMinutos
+		resposta.SaldoMinutos += dia.SaldoMinutos
+	}
+
+	respondWithJSON(w, http.StatusOK, resposta)
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/middleware"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// esperarApuracaoNaHora espera a leitura dos pontos e dos afastamentos do período, com
+// um dia de 8 horas em 4 de março de 2024.
+func esperarApuracaoNaHora(mock sqlmock.Sqlmock, userID int64) {
+	sp, _ := time.LoadLocation("America/Sao_Paulo")
+	dia := time.Date(2024, 3, 4, 0, 0, 0, 0, sp)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM pontos WHERE user_id = $1 AND horario >= $2")).
+		WithArgs(userID, dia, dia.AddDate(0, 0, 2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "horario"}).
+			AddRow(1, userID, dia.Add(8*time.Hour)).AddRow(2, userID, dia.Add(12*time.Hour)).
+			AddRow(3, userID, dia.Add(13*time.Hour)).AddRow(4, userID, dia.Add(17*time.Hour)))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM afastamentos a JOIN tipos_afastamento t")).
+		WillReturnRows(sqlmock.NewRows([]string{"data_inicio", "data_fim", "codigo", "minutos_creditados"}))
+}
+
+func TestConsultarBancoDeHoras(t *testing.T) {
+	const userID = 8
+	colunas := []string{"data", "trabalhado_minutos", "esperado_minutos", "abonado_minutos", "saldo_minutos", "afastamento"}
+
+	tests := []struct {
+		name string
+		// gravados são os dias já guardados em banco_horas_dias
+		gravados [][]driver.Value
+		// naHora diz se o período é apurado na requisição, com o recálculo agendado
+		naHora    bool
+		wantSaldo int
+	}{
+		{
+			name: "every day stored",
+			gravados: [][]driver.Value{
+				{time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), 500, 480, 0, 20, ""},
+				{time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), 0, 480, 480, 0, "ferias"},
+			},
+			wantSaldo: 20,
+		},
+		{
+			name: "a day missing",
+			gravados: [][]driver.Value{
+				{time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), 0, 480, 480, 0, "ferias"},
+			},
+			naHora:    true,
+			wantSaldo: -480,
+		},
+		{
+			name:      "nothing stored",
+			naHora:    true,
+			wantSaldo: -480,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarEmpresa(mock, "rejeitar")
+			gravados := sqlmock.NewRows(colunas)
+			for _, g := range tt.gravados {
+				gravados.AddRow(g...)
+			}
+			// Only days computed with the empresa's current settings count
+			mock.ExpectQuery(regexp.QuoteMeta("FROM banco_horas_dias")).
+				WithArgs(userID, "2024-03-04", "2024-03-05", 480, "America/Sao_Paulo").
+				WillReturnRows(gravados)
+			if tt.naHora {
+				esperarApuracaoNaHora(mock, userID)
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).
+					WithArgs(JobRecalcularBancoDeHoras, `{"user_id":8,"inicio":"2024-03-04","fim":"2024-03-05"}`, "8:2024-03-04:2024-03-05", 5, nil).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, int64(userID))
+			r := httptest.NewRequest(http.MethodGet, "/api/banco-horas?inicio=2024-03-04&fim=2024-03-05", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			ConsultarBancoDeHoras(rec, r)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, http.StatusOK, rec.Body)
+			}
+			var resposta BancoDeHorasResposta
+			if err := json.Unmarshal(rec.Body.Bytes(), &resposta); err != nil {
+				t.Fatal(err)
+			}
+			if len(resposta.Dias) != 2 || resposta.Dias[0].Data != "2024-03-04" || resposta.SaldoMinutos != tt.wantSaldo {
+				t.Errorf("resposta = %+v, want 2 days with saldo %d", resposta, tt.wantSaldo)
+			}
+		})
+	}
+}
+
+func TestRecalcularBancoDeHorasJob(t *testing.T) {
+	const userID = 8
+	mock := mockDB(t)
+	esperarEmpresa(mock, "rejeitar")
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+	esperarApuracaoNaHora(mock, userID)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO banco_horas_dias")).
+		WithArgs(userID, "2024-03-04", 480, 480, 0, 0, "", 480, "America/Sao_Paulo").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO banco_horas_dias")).
+		WithArgs(userID, "2024-03-05", 0, 480, 0, -480, "", 480, "America/Sao_Paulo").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := jobs.Job{ID: 1, Tipo: JobRecalcularBancoDeHoras, Payload: []byte(`{"user_id":8,"inicio":"2024-03-04","fim":"2024-03-05"}`), Tentativa: 1}
+	if err := RecalcularBancoDeHorasJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecalcularBancoDeHorasJobHoje(t *testing.T) {
+	mock := mockDB(t)
+	esperarEmpresa(mock, "rejeitar")
+
+	// Today is still open, so there's nothing to store
+	sp, _ := time.LoadLocation("America/Sao_Paulo")
+	hoje := time.Now().In(sp).Format("2006-01-02")
+	job := jobs.Job{ID: 1, Tipo: JobRecalcularBancoDeHoras, Payload: []byte(`{"user_id":8,"inicio":"` + hoje + `","fim":"` + hoje + `"}`), Tentativa: 1}
+	if err := RecalcularBancoDeHorasJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/metrics"
+	"controle-ponto-api/middleware"
+	"database/sql"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LimitesTentativasLogin configura a proteção do login contra força bruta.
+type LimitesTentativasLogin struct {
+	// MaxFalhas falhas consecutivas bloqueiam a conta por DuracaoBloqueio.
+	MaxFalhas       int
+	DuracaoBloqueio time.Duration
+	// JanelaFalhas é por quanto tempo uma falha conta, para a conta e para o IP.
+	JanelaFalhas time.Duration
+	// MaxFalhasIP falhas de um mesmo IP dentro da janela bloqueiam o IP.
+	MaxFalhasIP int
+	// Depois de cada falha a conta espera AtrasoBase, dobrado a cada falha consecutiva
+	// até AtrasoMax, antes da próxima tentativa.
+	AtrasoBase time.Duration
+	AtrasoMax  time.Duration
+}
+
+// LimitesLogin é definido no main.
+var LimitesLogin = LimitesTentativasLogin{
+	MaxFalhas:       5,
+	DuracaoBloqueio: 15 * time.Minute,
+	JanelaFalhas:    15 * time.Minute,
+	MaxFalhasIP:     50,
+	AtrasoBase:      time.Second,
+	AtrasoMax:       30 * time.Second,
+}
+
+// hashFicticio é comparado com a senha quando o email não existe ou não tem senha, para
+// que a resposta leve o mesmo tempo de uma senha errada. É gerado na primeira chamada.
+var hashFicticio = sync.OnceValues(func() ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte("senha-ficticia"), bcrypt.DefaultCost)
+})
+
+// ipCliente retorna o IP de origem da requisição, já descontados os proxies confiáveis
+// pelo middleware ClientIP.
+func ipCliente(r *http.Request) string {
+	return middleware.RemoteIP(r)
+}
+
+// normalizarEmail retorna o email como ele é gravado e procurado: sem espaços nas pontas e
+// em minúsculas. Também é a chave das falhas de login.
+func normalizarEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// atrasoLogin é a espera exigida depois de falhas consecutivas.
+func atrasoLogin(falhas int) time.Duration {
+	atraso := LimitesLogin.AtrasoBase
+	for i := 1; i < falhas && atraso < LimitesLogin.AtrasoMax; i++ {
+		atraso *= 2
+	}
+	return min(atraso, LimitesLogin.AtrasoMax)
+}
+
+// tentativaLogin é uma tentativa de login reservada por reservarTentativaLogin: ela já
+// conta como falha para o email e para o IP até ser concluída.
+type tentativaLogin struct {
+	email string
+	ip    string
+	// falhas é a sequência de falhas do email, incluindo esta tentativa.
+	falhas int
+	// falhaID é a linha de falhas_login que conta a tentativa para o IP.
+	falhaID int64
+}
+
+// reservarTentativaLogin decide, antes de conferir a senha ou o segundo fator, se a
+// tentativa pode seguir e, se puder, já a conta como falha. Assim requisições simultâneas
+// não passam todas pela verificação antes que alguma falha seja gravada. Retorna status 0
+// quando a tentativa pode seguir, ou o status da recusa e quanto tempo falta para a
+// próxima: 429 se o IP passou do limite ou a conta ainda não cumpriu a espera desde a
+// última falha, 423 se a conta está bloqueada. Como o controle é por email, emails que não
+// existem se comportam como os cadastrados. A tentativa termina com registrarFalhaLogin,
+// liberarTentativaLogin ou limparFalhasLogin.
+func reservarTentativaLogin(ctx context.Context, email, ip string) (tentativaLogin, int, time.Duration, error) {
+	t := tentativaLogin{email: email, ip: ip}
+	janela := LimitesLogin.JanelaFalhas.Seconds()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return t, 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Failures and expired lockouts that no longer count are cleaned up along the way
+	_, err = tx.ExecContext(ctx, "DELETE FROM falhas_login WHERE criado_em < NOW() - make_interval(secs => $1)", janela)
+	if err == nil {
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM bloqueios_login WHERE ultima_falha_em < NOW() - make_interval(secs => $1)
+			AND (bloqueado_ate IS NULL OR bloqueado_ate < NOW())`,
+			janela,
+		)
+	}
+	// Attempts from the same IP are serialized, so their count can't be raced
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('falhas_login:' || $1))", ip)
+	}
+	if err != nil {
+		return t, 0, 0, err
+	}
+
+	// The IP is blocked while its MaxFalhasIP-th most recent failure is inside the window
+	var restanteIP float64
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXTRACT(EPOCH FROM criado_em + make_interval(secs => $2) - NOW())::float8 FROM falhas_login
+		WHERE ip = $1 AND criado_em > NOW() - make_interval(secs => $2)
+		ORDER BY criado_em DESC OFFSET $3 LIMIT 1`,
+		ip, janela, LimitesLogin.MaxFalhasIP-1,
+	).Scan(&restanteIP)
+	if err == nil {
+		return t, http.StatusTooManyRequests, segundos(restanteIP), nil
+	}
+	if err != sql.ErrNoRows {
+		return t, 0, 0, err
+	}
+
+	// The upsert locks the email's row, so concurrent attempts see each other's reservation.
+	// A streak restarts after an expired lockout or when the last failure left the window;
+	// the update is skipped while the account is locked or still has to wait
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO bloqueios_login (email, falhas, ultima_falha_em) VALUES ($1, 1, NOW())
+		ON CONFLICT (email) DO UPDATE SET
+			falhas = CASE
+				WHEN bloqueios_login.bloqueado_ate IS NOT NULL OR bloqueios_login.ultima_falha_em < NOW() - make_interval(secs => $2) THEN 1
+				ELSE bloqueios_login.falhas + 1
+			END,
+			ultima_falha_em = NOW(),
+			bloqueado_ate = NULL
+		WHERE CASE
+			WHEN bloqueios_login.bloqueado_ate IS NOT NULL THEN bloqueios_login.bloqueado_ate <= NOW()
+			ELSE bloqueios_login.falhas = 0
+				OR bloqueios_login.ultima_falha_em <= NOW() - make_interval(secs => LEAST($3 * power(2, bloqueios_login.falhas - 1), $4))
+		END
+		RETURNING falhas`,
+		email, janela, LimitesLogin.AtrasoBase.Seconds(), LimitesLogin.AtrasoMax.Seconds(),
+	).Scan(&t.falhas)
+	if err == sql.ErrNoRows {
+		status, espera, err := motivoRecusaLogin(ctx, tx, email)
+		return t, status, espera, err
+	}
+	if err != nil {
+		return t, 0, 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, "INSERT INTO falhas_login (email, ip) VALUES ($1, $2) RETURNING id", email, ip).Scan(&t.falhaID)
+	if err != nil {
+		return t, 0, 0, err
+	}
+	return t, 0, 0, tx.Commit()
+}
+
+// motivoRecusaLogin explica por que reservarTentativaLogin recusou a tentativa do email.
+func motivoRecusaLogin(ctx context.Context, tx *sql.Tx, email string) (int, time.Duration, error) {
+	var falhas int
+	var bloqueio sql.NullFloat64
+	var desdeFalha float64
+	err := tx.QueryRowContext(ctx,
+		`SELECT falhas, EXTRACT(EPOCH FROM bloqueado_ate - NOW())::float8, EXTRACT(EPOCH FROM NOW() - ultima_falha_em)::float8
+		FROM bloqueios_login WHERE email = $1`,
+		email,
+	).Scan(&falhas, &bloqueio, &desdeFalha)
+	if err != nil {
+		return 0, 0, err
+	}
+	if bloqueio.Valid {
+		return http.StatusLocked, max(segundos(bloqueio.Float64), 0), nil
+	}
+	return http.StatusTooManyRequests, max(atrasoLogin(falhas)-segundos(desdeFalha), 0), nil
+}
+
+// registrarFalhaLogin encerra a tentativa como falha, bloqueando a conta ou o IP quando
+// chegam ao limite. userID é nil quando o email não está cadastrado.
+func registrarFalhaLogin(ctx context.Context, t tentativaLogin, userID *int64) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if t.falhas >= LimitesLogin.MaxFalhas {
+		var ate time.Time
+		err = tx.QueryRowContext(ctx,
+			"UPDATE bloqueios_login SET bloqueado_ate = NOW() + make_interval(secs => $2) WHERE email = $1 RETURNING bloqueado_ate",
+			t.email, LimitesLogin.DuracaoBloqueio.Seconds(),
+		).Scan(&ate)
+		if err == nil {
+			err = registrarAuditoria(ctx, tx, EventoAuditoria{
+				Tipo:   AuditoriaContaBloqueada,
+				UserID: userID,
+				IP:     t.ip,
+				Dados:  map[string]interface{}{"email": t.email, "falhas": t.falhas, "bloqueado_ate": ate},
+			})
+		}
+		if err != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "Login locked", "email", t.email, "until", ate, "failures", t.falhas, "ip", t.ip)
+	}
+
+	var falhasIP int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM falhas_login WHERE ip = $1 AND criado_em > NOW() - make_interval(secs => $2)",
+		t.ip, LimitesLogin.JanelaFalhas.Seconds(),
+	).Scan(&falhasIP)
+	if err == nil && falhasIP == LimitesLogin.MaxFalhasIP {
+		err = registrarAuditoria(ctx, tx, EventoAuditoria{
+			Tipo:  AuditoriaIPBloqueado,
+			IP:    t.ip,
+			Dados: map[string]interface{}{"falhas": falhasIP},
+		})
+		if err == nil {
+			slog.WarnContext(ctx, "Login blocked for IP", "ip", t.ip, "failures", falhasIP)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// liberarTentativaLogin desfaz a reserva de uma tentativa correta que ainda depende do
+// segundo fator: a sequência de falhas anterior continua valendo.
+func liberarTentativaLogin(ctx context.Context, t tentativaLogin) error {
+	_, err := database.DB.ExecContext(ctx,
+		`WITH falha AS (DELETE FROM falhas_login WHERE id = $2)
+		UPDATE bloqueios_login SET falhas = GREATEST(falhas - 1, 0) WHERE email = $1`,
+		t.email, t.falhaID,
+	)
+	return err
+}
+
+// limparFalhasLogin encerra a sequência de falhas do email depois de um login completo.
+func limparFalhasLogin(ctx context.Context, t tentativaLogin) error {
+	_, err := database.DB.ExecContext(ctx,
+		`WITH falha AS (DELETE FROM falhas_login WHERE id = $2)
+		DELETE FROM bloqueios_login WHERE email = $1`,
+		t.email, t.falhaID,
+	)
+	return err
+}
+
+// recusarTentativaLogin responde a uma tentativa barrada por reservarTentativaLogin.
+func recusarTentativaLogin(w http.ResponseWriter, r *http.Request, status int, espera time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(espera.Seconds()))))
+	if status == http.StatusLocked {
+		metrics.LoginFailures.WithLabelValues(metrics.LoginLocked).Inc()
+		respondWithProblem(w, r, status, apierror.CodeAccountLocked, "Account temporarily locked")
+		return
+	}
+	metrics.LoginFailures.WithLabelValues(metrics.LoginThrottled).Inc()
+	respondWithProblem(w, r, status, apierror.CodeLoginThrottled, "Too many login attempts")
+}
+
+func segundos(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// DesbloquearLogin godoc
+// @Summary      Desbloqueia o login de um usuário
+// @Description  Encerra o bloqueio e a sequência de falhas de login da conta. Restrito aos administradores da empresa do usuário.
+// @Tags         Equipe
+// @Security     ApiKeyAuth
+// @Param        id  path  int  true  "ID do usuário"
+// @Success      204
+// @Failure      400  {object}  apierror.Problem  "Invalid user ID"
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "User not found"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /equipe/membros/{id}/bloqueio-login [delete]
+func DesbloquearLogin(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	alvoID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to unlock login")
+		return
+	}
+	permitido := solicitante.ehAdmin()
+	if permitido {
+		if permitido, err = podeGerenciar(r.Context(), database.DB, solicitante, alvoID); err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to unlock login")
+			return
+		}
+	}
+	if !permitido {
+		respondWithError(w, r, http.StatusForbidden, "You don't have permission to unlock this user's login")
+		return
+	}
+
+	var email string
+	if err := database.DB.QueryRowContext(r.Context(), "SELECT email FROM users WHERE id = $1", alvoID).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			respondWithError(w, r, http.StatusNotFound, "User not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error loading user", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to unlock login")
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to unlock login")
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(r.Context(), "DELETE FROM bloqueios_login WHERE email = $1", normalizarEmail(email))
+	if err == nil {
+		err = registrarAuditoria(r.Context(), tx, EventoAuditoria{
+			Tipo:   AuditoriaContaDesbloqueada,
+			UserID: &alvoID,
+			AtorID: &userID,
+			IP:     ipCliente(r),
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error unlocking login", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to unlock login")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
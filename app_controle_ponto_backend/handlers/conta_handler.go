@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/mailer"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/password"
+	"controle-ponto-api/validation"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Finalidades dos tokens enviados por email.
+const (
+	TokenVerificacaoEmail = "verificacao_email"
+	TokenRedefinicaoSenha = "redefinicao_senha"
+)
+
+const (
+	// validadeVerificacaoEmail e validadeRedefinicaoSenha são os prazos de uso dos tokens.
+	validadeVerificacaoEmail = 24 * time.Hour
+	validadeRedefinicaoSenha = time.Hour
+)
+
+// JobEnviarTokenEmail é o tipo da tarefa que gera e envia por email um token de tokens_usuario.
+const JobEnviarTokenEmail = "email.token"
+
+// Mailer envia os emails de verificação e de redefinição de senha. AppURL é a URL do
+// frontend usada nos links. Ambos são definidos no main.
+var (
+	Mailer mailer.Sender
+	AppURL string
+)
+
+// PoliticaSenha define as regras das novas senhas e HistoricoSenhas quantas senhas
+// anteriores não podem ser reutilizadas na redefinição. Ambos são definidos no main.
+var (
+	PoliticaSenha   = &password.Policy{MinLength: 8}
+	HistoricoSenhas int
+)
+
+// EmailPayload define o corpo das requisições que só informam um email.
+type EmailPayload struct {
+	Email string `json:"email"`
+}
+
+// TokenPayload define o corpo da verificação de email.
+type TokenPayload struct {
+	Token string `json:"token"`
+}
+
+// RedefinirSenhaPayload define o corpo da redefinição de senha.
+type RedefinirSenhaPayload struct {
+	Token     string `json:"token"`
+	NovaSenha string `json:"nova_senha"`
+}
+
+// IdiomaPayload define o corpo da escolha do idioma dos emails.
+type IdiomaPayload struct {
+	Idioma string `json:"idioma" example:"pt-BR"`
+}
+
+const (
+	// tamanhoMaximoNome e tamanhoMaximoEmail acompanham as colunas de users.
+	tamanhoMaximoNome  = 255
+	tamanhoMaximoEmail = 255
+)
+
+// Códigos dos erros de campo das senhas.
+const (
+	codigoSenhaFraca       = "weak_password"
+	codigoSenhaReutilizada = "reused_password"
+)
+
+func (p EmailPayload) validar() error {
+	var v validation.Validator
+	if v.Required("email", p.Email) {
+		v.Email("email", strings.TrimSpace(p.Email))
+	}
+	return v.Err()
+}
+
+func (p TokenPayload) validar() error {
+	var v validation.Validator
+	v.Required("token", p.Token)
+	return v.Err()
+}
+
+func (p IdiomaPayload) validar() error {
+	var v validation.Validator
+	if v.Required("idioma", p.Idioma) {
+		v.OneOf("idioma", p.Idioma, i18n.Supported...)
+	}
+	return v.Err()
+}
+
+func (p RedefinirSenhaPayload) validar() error {
+	var v validation.Validator
+	v.Required("token", p.Token)
+	v.Required("nova_senha", p.NovaSenha)
+	return v.Err()
+}
+
+// validarSenha aplica a PoliticaSenha e, se a senha for recusada, registra o motivo em v
+// como erro do campo. dadosUsuario são o nome e o email, que não podem compor a senha. O
+// erro retornado só indica falhas internas, como na leitura da lista de senhas vazadas.
+func validarSenha(v *validation.Validator, campo, senha string, dadosUsuario ...string) error {
+	err := PoliticaSenha.Check(senha, dadosUsuario...)
+	var recusa *password.RejectedError
+	if !errors.As(err, &recusa) {
+		return err
+	}
+	v.Add(campo, codigoSenhaFraca, recusa.Reason, recusa.Args...)
+	return nil
+}
+
+// senhaReutilizada informa se a senha é a atual do usuário ou uma das HistoricoSenhas
+// mais recentes.
+func senhaReutilizada(ctx context.Context, tx *sql.Tx, userID int64, senha, hashAtual string) (bool, error) {
+	if HistoricoSenhas == 0 {
+		return false, nil
+	}
+	hashes := []string{hashAtual}
+	rows, err := tx.QueryContext(ctx,
+		"SELECT password_hash FROM historico_senhas WHERE user_id = $1 ORDER BY criado_em DESC, id DESC LIMIT $2",
+		userID, HistoricoSenhas,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return false, err
+		}
+		hashes = append(hashes, h)
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	for _, h := range hashes {
+		if h != "" && bcrypt.CompareHashAndPassword([]byte(h), []byte(senha)) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// registrarHistoricoSenha guarda o hash da nova senha e descarta os que passaram do
+// tamanho do histórico.
+func registrarHistoricoSenha(ctx context.Context, tx *sql.Tx, userID int64, hash string) error {
+	if HistoricoSenhas > 0 {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO historico_senhas (user_id, password_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx,
+		`DELETE FROM historico_senhas WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM historico_senhas WHERE user_id = $1 ORDER BY criado_em DESC, id DESC LIMIT $2
+		)`,
+		userID, HistoricoSenhas,
+	)
+	return err
+}
+
+// criarTokenUsuario registra um token e enfileira o envio por email na transação tx. O
+// token em si só é gerado pela tarefa, então nunca fica gravado em claro.
+func criarTokenUsuario(ctx context.Context, tx *sql.Tx, userID int64, finalidade string, validade time.Duration) error {
+	// Only the latest link stays valid
+	_, err := tx.ExecContext(ctx,
+		"UPDATE tokens_usuario SET usado_em = NOW() WHERE user_id = $1 AND finalidade = $2 AND usado_em IS NULL",
+		userID, finalidade,
+	)
+	if err != nil {
+		return err
+	}
+
+	var tokenID int64
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO tokens_usuario (user_id, finalidade, expira_em) VALUES ($1, $2, $3) RETURNING id",
+		userID, finalidade, time.Now().Add(validade),
+	).Scan(&tokenID)
+	if err != nil {
+		return err
+	}
+	return jobs.Enfileirar(ctx, tx, JobEnviarTokenEmail, map[string]int64{"token_id": tokenID}, jobs.Opcoes{})
+}
+
+// consumirTokenUsuario marca o token como usado e retorna o dono. Retorna sql.ErrNoRows se o
+// token não existir, já tiver sido usado ou estiver expirado.
+func consumirTokenUsuario(ctx context.Context, tx *sql.Tx, token, finalidade string) (int64, error) {
+	var userID int64
+	err := tx.QueryRowContext(ctx,
+		`UPDATE tokens_usuario SET usado_em = NOW()
+		WHERE token_hash = $1 AND finalidade = $2 AND usado_em IS NULL AND expira_em > NOW()
+		RETURNING user_id`,
+		hashToken(token), finalidade,
+	).Scan(&userID)
+	return userID, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
+
+// EnviarTokenEmailJob gera o token, grava seu hash e envia o email. Uma nova tentativa gera
+// outro token, invalidando o link de um envio anterior que possa ter chegado.
+func EnviarTokenEmailJob(ctx context.Context, job jobs.Job) error {
+	var payload struct {
+		TokenID int64 `json:"token_id"`
+	}
+	if err := job.Decodificar(&payload); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var finalidade, nome, email, idioma string
+	var expiraEm time.Time
+	err = tx.QueryRowContext(ctx,
+		`SELECT t.finalidade, t.expira_em, u.nome, u.email, COALESCE(u.idioma, '')
+		FROM tokens_usuario t JOIN users u ON u.id = t.user_id
+		WHERE t.id = $1 AND t.usado_em IS NULL AND t.expira_em > NOW()
+		FOR UPDATE OF t`,
+		payload.TokenID,
+	).Scan(&finalidade, &expiraEm, &nome, &email, &idioma)
+	if err == sql.ErrNoRows {
+		// Used, superseded or expired before it was sent: nothing to do
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if _, err := tx.ExecContext(ctx, "UPDATE tokens_usuario SET token_hash = $1 WHERE id = $2", hashToken(token), payload.TokenID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if !i18n.IsSupported(idioma) {
+		idioma = i18n.Default
+	}
+	return Mailer.Send(ctx, montarEmailToken(idioma, finalidade, nome, email, token, expiraEm))
+}
+
+// montarEmailToken escreve o email no idioma do usuário, com o link (ou só o token, sem
+// AppURL configurada).
+func montarEmailToken(idioma, finalidade, nome, email, token string, expiraEm time.Time) mailer.Message {
+	caminho, assunto, texto := "/verificar-email", "Confirm your email",
+		"Confirm your email to start using Controle de Ponto."
+	if finalidade == TokenRedefinicaoSenha {
+		caminho, assunto, texto = "/redefinir-senha", "Password reset",
+			"We received a request to reset your password. If it wasn't you, ignore this email."
+	}
+
+	acao := i18n.T(idioma, "Token: %s", token)
+	if AppURL != "" {
+		link := strings.TrimSuffix(AppURL, "/") + caminho + "?" + url.Values{"token": {token}}.Encode()
+		acao = i18n.T(idioma, "Open: %s", link)
+	}
+	corpo := fmt.Sprintf("%s\n\n%s\n\n%s\n\n%s\n",
+		i18n.T(idioma, "Hello, %s.", nome),
+		i18n.T(idioma, texto),
+		acao,
+		i18n.T(idioma, "Valid until %s (UTC).", i18n.FormatDateTime(idioma, expiraEm.UTC())),
+	)
+	return mailer.Message{To: email, Subject: i18n.T(idioma, assunto), Body: corpo}
+}
+
+// SolicitarVerificacaoEmail godoc
+// @Summary      Reenvia o email de verificação
+// @Description  Envia um novo link de verificação se o email pertencer a uma conta ainda não verificada. A resposta é a mesma em todos os casos, para não revelar quais emails estão cadastrados.
+// @Tags         Authentication
+// @Accept       json
+// @Param        payload  body  EmailPayload  true  "Email da conta"
+// @Success      202
+// @Failure      400  {object}  apierror.Problem  "Invalid request body"
+// @Router       /email/verificacao [post]
+func SolicitarVerificacaoEmail(w http.ResponseWriter, r *http.Request) {
+	solicitarToken(w, r, TokenVerificacaoEmail, "email_verificado_em IS NULL", validadeVerificacaoEmail)
+}
+
+// SolicitarRedefinicaoSenha godoc
+// @Summary      Solicita a redefinição de senha
+// @Description  Envia um link de redefinição se o email pertencer a uma conta com senha local (contas que entram pelo provedor de identidade não têm). A resposta é a mesma em todos os casos, para não revelar quais emails estão cadastrados.
+// @Tags         Authentication
+// @Accept       json
+// @Param        payload  body  EmailPayload  true  "Email da conta"
+// @Success      202
+// @Failure      400  {object}  apierror.Problem  "Invalid request body"
+// @Router       /senha/esqueci [post]
+func SolicitarRedefinicaoSenha(w http.ResponseWriter, r *http.Request) {
+	solicitarToken(w, r, TokenRedefinicaoSenha, "password_hash <> ''", validadeRedefinicaoSenha)
+}
+
+// solicitarToken cria e envia o token para o usuário com o email informado, se ele atender
+// à condição.
+func solicitarToken(w http.ResponseWriter, r *http.Request, finalidade, condicao string, validade time.Duration) {
+	var payload EmailPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(r.Context(),
+		"SELECT id FROM users WHERE LOWER(email) = $1 AND "+condicao+" FOR UPDATE",
+		normalizarEmail(payload.Email),
+	).Scan(&userID)
+	if err == nil {
+		err = criarTokenUsuario(r.Context(), tx, userID, finalidade, validade)
+		if err == nil {
+			err = tx.Commit()
+		}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Error creating token", "finalidade", finalidade, "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerificarEmail godoc
+// @Summary      Verifica o email da conta
+// @Description  Consome o token enviado por email e marca o email como verificado, liberando o login.
+// @Tags         Authentication
+// @Accept       json
+// @Param        payload  body  TokenPayload  true  "Token recebido por email"
+// @Success      204
+// @Failure      400  {object}  apierror.Problem  "Invalid or expired token"
+// @Router       /email/verificar [post]
+func VerificarEmail(w http.ResponseWriter, r *http.Request) {
+	var payload TokenPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	userID, err := consumirTokenUsuario(r.Context(), tx, payload.Token, TokenVerificacaoEmail)
+	if err == sql.ErrNoRows {
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeVerificationTokenInvalid, "Invalid or expired token")
+		return
+	}
+	if err == nil {
+		_, err = tx.ExecContext(r.Context(), "UPDATE users SET email_verificado_em = COALESCE(email_verificado_em, NOW()) WHERE id = $1", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying email", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RedefinirSenha godoc
+// @Summary      Redefine a senha
+// @Description  Consome o token enviado por email e grava a nova senha, que precisa seguir a política de senhas e não pode repetir as mais recentes. Todos os tokens de acesso emitidos antes deixam de valer, encerrando as sessões abertas. Como o token chegou ao email da conta, o email também passa a contar como verificado.
+// @Tags         Authentication
+// @Accept       json
+// @Param        payload  body  RedefinirSenhaPayload  true  "Token e nova senha"
+// @Success      204
+// @Failure      400  {object}  apierror.Problem  "Invalid or expired token, weak or recently used password"
+// @Router       /senha/redefinir [post]
+func RedefinirSenha(w http.ResponseWriter, r *http.Request) {
+	var payload RedefinirSenhaPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	defer tx.Rollback()
+
+	// A recusa da senha desfaz o consumo do token, então o mesmo link serve para tentar de novo
+	userID, err := consumirTokenUsuario(r.Context(), tx, payload.Token, TokenRedefinicaoSenha)
+	if err == sql.ErrNoRows {
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeVerificationTokenInvalid, "Invalid or expired token")
+		return
+	}
+	var nome, email, hashAtual string
+	if err == nil {
+		err = tx.QueryRowContext(r.Context(), "SELECT nome, email, password_hash FROM users WHERE id = $1", userID).Scan(&nome, &email, &hashAtual)
+	}
+	var v validation.Validator
+	if err == nil {
+		err = validarSenha(&v, "nova_senha", payload.NovaSenha, nome, email)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting password", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if err := v.Err(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	reutilizada, err := senhaReutilizada(r.Context(), tx, userID, payload.NovaSenha, hashAtual)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking password history", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if reutilizada {
+		v.Add("nova_senha", codigoSenhaReutilizada, "was used recently; choose another one")
+		respondWithValidationError(w, r, v.Err())
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(payload.NovaSenha), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid password")
+		return
+	}
+
+	_, err = tx.ExecContext(r.Context(),
+		`UPDATE users SET password_hash = $1, email_verificado_em = COALESCE(email_verificado_em, NOW()),
+			token_versao = token_versao + 1
+		WHERE id = $2`,
+		string(hash), userID,
+	)
+	if err == nil {
+		err = registrarHistoricoSenha(r.Context(), tx, userID, string(hash))
+	}
+	// Whoever knew the old password may be mid-login, waiting on the second factor
+	if err == nil {
+		_, err = tx.ExecContext(r.Context(), "DELETE FROM mfa_desafios WHERE user_id = $1", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resetting password", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AtualizarIdioma godoc
+// @Summary      Escolhe o idioma dos emails
+// @Description  Define o idioma dos emails enviados ao usuário (pt-BR, en ou es). As respostas da API seguem o cabeçalho Accept-Language de cada requisição e, quando ele não indica um idioma suportado, este idioma.
+// @Tags         Conta
+// @Security     ApiKeyAuth
+// @Accept       json
+// @Param        payload  body  IdiomaPayload  true  "Idioma"
+// @Success      204
+// @Failure      400  {object}  apierror.Problem  "Invalid request body"
+// @Failure      500  {object}  apierror.Problem  "Failed to update language"
+// @Router       /conta/idioma [put]
+func AtualizarIdioma(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload IdiomaPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	if _, err := database.DB.ExecContext(r.Context(), "UPDATE users SET idioma = $1 WHERE id = $2", payload.Idioma, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating language", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update language")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IdiomaDoUsuario retorna o idioma escolhido pelo usuário, ou "" se ele não escolheu
+// nenhum. É usado por middleware.UserLocale.
+func IdiomaDoUsuario(ctx context.Context, userID int64) (string, error) {
+	var idioma string
+	err := database.DB.QueryRowContext(ctx, "SELECT COALESCE(idioma, '') FROM users WHERE id = $1", userID).Scan(&idioma)
+	return idioma, err
+}
+
+// VersaoTokenDoUsuario retorna a versão atual dos tokens do usuário. Tokens emitidos com
+// uma versão anterior foram revogados. É usado por middleware.JwtAuthentication.
+func VersaoTokenDoUsuario(ctx context.Context, userID int64) (int64, error) {
+	var versao int64
+	err := database.DB.QueryRowContext(ctx, "SELECT token_versao FROM users WHERE id = $1", userID).Scan(&versao)
+	return versao, err
+}
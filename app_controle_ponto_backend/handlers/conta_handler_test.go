@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"controle-ponto-api/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashToken(t *testing.T) {
+	sum := sha256.Sum256([]byte("abc123"))
+	want := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name, token string
+	}{
+		{"plain", "abc123"},
+		// Tokens pasted from an email often carry a line break
+		{"surrounding whitespace", " abc123\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashToken(tt.token); got != want {
+				t.Errorf("hashToken(%q) = %s, want %s", tt.token, got, want)
+			}
+		})
+	}
+	if hashToken("abc124") == want {
+		t.Error("different tokens have the same hash")
+	}
+}
+
+// consumirTokenSQL é o UPDATE de consumirTokenUsuario. As condições garantem que o token só
+// vale uma vez e só até expirar, então o teste exige cada uma delas.
+var consumirTokenSQL = regexp.QuoteMeta("UPDATE tokens_usuario SET usado_em = NOW()") +
+	`\s+` + regexp.QuoteMeta("WHERE token_hash = $1 AND finalidade = $2 AND usado_em IS NULL AND expira_em > NOW()")
+
+func TestRedefinirSenha(t *testing.T) {
+	const token = "token-do-email"
+
+	tests := []struct {
+		name      string
+		novaSenha string
+		// consumido diz se o token existe, não foi usado e não expirou
+		consumido bool
+		status    int
+		esperar   func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:      "valid token revokes the sessions",
+			novaSenha: "uma senha bem longa",
+			consumido: true,
+			status:    http.StatusNoContent,
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT nome, email, password_hash FROM users")).
+					WillReturnRows(sqlmock.NewRows([]string{"nome", "email", "password_hash"}).AddRow("Ana", "ana@exemplo.com", ""))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET password_hash = $1")+`[^;]*`+regexp.QuoteMeta("token_versao = token_versao + 1")).
+					WithArgs(sqlmock.AnyArg(), 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM historico_senhas")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM mfa_desafios WHERE user_id = $1")).
+					WithArgs(7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name:      "used, expired or unknown token",
+			novaSenha: "uma senha bem longa",
+			status:    http.StatusBadRequest,
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectRollback()
+			},
+		},
+		{
+			// The rollback keeps the token, so the same link can be used again
+			name:      "weak password keeps the token",
+			novaSenha: "curta",
+			consumido: true,
+			status:    http.StatusBadRequest,
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT nome, email, password_hash FROM users")).
+					WillReturnRows(sqlmock.NewRows([]string{"nome", "email", "password_hash"}).AddRow("Ana", "ana@exemplo.com", ""))
+				mock.ExpectRollback()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			linhas := sqlmock.NewRows([]string{"user_id"})
+			if tt.consumido {
+				linhas.AddRow(7)
+			}
+			// Only the hash is stored, so the lookup must never use the token itself
+			mock.ExpectQuery(consumirTokenSQL).
+				WithArgs(hashToken(token), TokenRedefinicaoSenha).
+				WillReturnRows(linhas)
+			tt.esperar(mock)
+
+			body := `{"token": "` + token + `", "nova_senha": "` + tt.novaSenha + `"}`
+			rec := httptest.NewRecorder()
+			RedefinirSenha(rec, httptest.NewRequest(http.MethodPost, "/api/senha/redefinir", strings.NewReader(body)))
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestVerificarEmailUsoUnico(t *testing.T) {
+	const token = "token-do-email"
+	mock := mockDB(t)
+
+	// First use consumes the token
+	mock.ExpectBegin()
+	mock.ExpectQuery(consumirTokenSQL).
+		WithArgs(hashToken(token), TokenVerificacaoEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(7))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verificado_em")).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// The second finds it already used
+	mock.ExpectBegin()
+	mock.ExpectQuery(consumirTokenSQL).
+		WithArgs(hashToken(token), TokenVerificacaoEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	mock.ExpectRollback()
+
+	for _, status := range []int{http.StatusNoContent, http.StatusBadRequest} {
+		rec := httptest.NewRecorder()
+		VerificarEmail(rec, httptest.NewRequest(http.MethodPost, "/api/email/verificar", strings.NewReader(`{"token": "`+token+`"}`)))
+		if rec.Code != status {
+			t.Errorf("status = %d, want %d; body %s", rec.Code, status, rec.Body)
+		}
+	}
+}
+
+func TestSolicitarRedefinicaoSenhaNormalizaEmail(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE LOWER(email) = $1")).
+		WithArgs("ana@exemplo.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	rec := httptest.NewRecorder()
+	SolicitarRedefinicaoSenha(rec, httptest.NewRequest(http.MethodPost, "/api/senha/esqueci", strings.NewReader(`{"email": " Ana@Exemplo.COM "}`)))
+	// Unknown emails get the same answer as known ones
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("status = %d, want %d; body %s", rec.Code, http.StatusAccepted, rec.Body)
+	}
+}
+
+func TestSenhaReutilizada(t *testing.T) {
+	hash := func(senha string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(senha), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h)
+	}
+	atual := hash("senha atual longa")
+	// The query returns only the latest HistoricoSenhas hashes, newest first
+	historico := []string{hash("penultima senha longa"), hash("antepenultima senha longa")}
+
+	tests := []struct {
+		name      string
+		historico int
+		senha     string
+		hashAtual string
+		want      bool
+	}{
+		{name: "current password", historico: 2, senha: "senha atual longa", hashAtual: atual, want: true},
+		{name: "previous password", historico: 2, senha: "penultima senha longa", hashAtual: atual, want: true},
+		{name: "oldest kept password", historico: 2, senha: "antepenultima senha longa", hashAtual: atual, want: true},
+		{name: "new password", historico: 2, senha: "senha nunca usada", hashAtual: atual},
+		// Users who only sign in through OIDC have no current hash
+		{name: "no current password", historico: 2, senha: "senha nunca usada"},
+		{name: "history disabled", senha: "senha atual longa", hashAtual: atual},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anterior := HistoricoSenhas
+			HistoricoSenhas = tt.historico
+			t.Cleanup(func() { HistoricoSenhas = anterior })
+
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			if tt.historico > 0 {
+				linhas := sqlmock.NewRows([]string{"password_hash"})
+				for _, h := range historico {
+					linhas.AddRow(h)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT password_hash FROM historico_senhas WHERE user_id = $1")).
+					WithArgs(7, tt.historico).
+					WillReturnRows(linhas)
+			}
+			mock.ExpectRollback()
+
+			tx, err := database.DB.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+			got, err := senhaReutilizada(context.Background(), tx, 7, tt.senha, tt.hashAtual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("senhaReutilizada(%q) = %v, want %v", tt.senha, got, tt.want)
+			}
+		})
+	}
+}
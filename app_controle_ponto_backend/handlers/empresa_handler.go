@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"controle-ponto-api/database"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RegrasEmpresaPayload define o corpo da requisição de atualização das regras da empresa.
+type RegrasEmpresaPayload struct {
+	IntervaloMinimoSegundos        int    `json:"intervalo_minimo_segundos"`
+	PoliticaDuplicidade            string `json:"politica_duplicidade"`
+	JornadaMaximaMinutos           int    `json:"jornada_maxima_minutos"`
+	InterjornadaMinimaMinutos      int    `json:"interjornada_minima_minutos"`
+	IntrajornadaLimiteMinutos      int    `json:"intrajornada_limite_minutos"`
+	IntrajornadaMinimaMinutos      int    `json:"intrajornada_minima_minutos"`
+	IntrajornadaCurtaLimiteMinutos int    `json:"intrajornada_curta_limite_minutos"`
+	IntrajornadaCurtaMinimaMinutos int    `json:"intrajornada_curta_minima_minutos"`
+	CargaHorariaDiariaMinutos      int    `json:"carga_horaria_diaria_minutos"`
+	ToleranciaAtrasoMinutos        int    `json:"tolerancia_atraso_minutos"`
+	// MFAObrigatoriaPapeis lista os papéis (gestor, admin, funcionario) que precisam de TOTP.
+	MFAObrigatoriaPapeis []string `json:"mfa_obrigatoria_papeis"`
+	// FusoHorario é o fuso IANA em que os dias são contados; vazio mantém o atual.
+	FusoHorario string `json:"fuso_horario"`
+}
+
+func (p RegrasEmpresaPayload) validar() error {
+	var v validation.Validator
+	v.OneOf("politica_duplicidade", p.PoliticaDuplicidade,
+		models.PoliticaDuplicidadeRejeitar, models.PoliticaDuplicidadeMesclar, models.PoliticaDuplicidadeSinalizar)
+
+	valores := []struct {
+		campo string
+		valor int
+	}{
+		{"intervalo_minimo_segundos", p.IntervaloMinimoSegundos},
+		{"jornada_maxima_minutos", p.JornadaMaximaMinutos},
+		{"interjornada_minima_minutos", p.InterjornadaMinimaMinutos},
+		{"intrajornada_limite_minutos", p.IntrajornadaLimiteMinutos},
+		{"intrajornada_minima_minutos", p.IntrajornadaMinimaMinutos},
+		{"intrajornada_curta_limite_minutos", p.IntrajornadaCurtaLimiteMinutos},
+		{"intrajornada_curta_minima_minutos", p.IntrajornadaCurtaMinimaMinutos},
+		{"carga_horaria_diaria_minutos", p.CargaHorariaDiariaMinutos},
+		{"tolerancia_atraso_minutos", p.ToleranciaAtrasoMinutos},
+	}
+	for _, c := range valores {
+		v.Check(c.valor >= 0, c.campo, validation.CodeOutOfRange, "must not be negative")
+	}
+	if p.FusoHorario != "" {
+		_, err := time.LoadLocation(p.FusoHorario)
+		v.Check(err == nil && p.FusoHorario != "Local", "fuso_horario", validation.CodeInvalid, "must be an IANA time zone")
+	}
+	for i, papel := range p.MFAObrigatoriaPapeis {
+		v.OneOf(fmt.Sprintf("mfa_obrigatoria_papeis[%d]", i), papel, models.PapelFuncionario, models.PapelGestor, models.PapelAdmin)
+	}
+	return v.Err()
+}
+
+// papeisOuVazio evita gravar NULL quando a lista é omitida.
+func papeisOuVazio(papeis []string) []string {
+	if papeis == nil {
+		return []string{}
+	}
+	return papeis
+}
+
+// ObterRegrasEmpresa godoc
+// @Summary      Consulta as regras da empresa
+// @Description  Retorna as regras de ponto da empresa do usuário autenticado, ou as regras padrão (CLT) se ele não estiver vinculado a uma empresa.
+// @Tags         Empresa
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.Empresa
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /empresa/regras [get]
+func ObterRegrasEmpresa(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'empresa' rules")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, empresa)
+}
+
+// AtualizarRegrasEmpresa godoc
+// @Summary      Atualiza as regras da empresa
+// @Description  Atualiza o intervalo mínimo entre pontos, a política de duplicidade, a carga horária diária, a tolerância de atraso, os papéis obrigados a usar MFA e os limites de jornada, interjornada e intrajornada da empresa. Restrito a administradores.
+// @Tags         Empresa
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        regras  body      RegrasEmpresaPayload  true  "Novas regras da empresa"
+// @Success      200     {object}  models.Empresa
+// @Failure      400     {object}  apierror.Problem  "Invalid request body"
+// @Failure      403     {object}  apierror.Problem  "Permission denied"
+// @Failure      500     {object}  apierror.Problem  "Internal server error"
+// @Router       /empresa/regras [put]
+func AtualizarRegrasEmpresa(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'empresa' rules")
+		return
+	}
+	if !solicitante.ehAdmin() {
+		respondWithError(w, r, http.StatusForbidden, "Only administrators can change the 'empresa' rules")
+		return
+	}
+
+	var payload RegrasEmpresaPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	_, err = database.DB.ExecContext(r.Context(),
+		`UPDATE empresas SET intervalo_minimo_segundos = $1, politica_duplicidade = $2, jornada_maxima_minutos = $3,
+			interjornada_minima_minutos = $4, intrajornada_limite_minutos = $5, intrajornada_minima_minutos = $6,
+			intrajornada_curta_limite_minutos = $7, intrajornada_curta_minima_minutos = $8, carga_horaria_diaria_minutos = $9,
+			tolerancia_atraso_minutos = $10, mfa_obrigatoria_papeis = $11, fuso_horario = COALESCE(NULLIF($13, ''), fuso_horario)
+		WHERE id = $12`,
+		payload.IntervaloMinimoSegundos, payload.PoliticaDuplicidade, payload.JornadaMaximaMinutos,
+		payload.InterjornadaMinimaMinutos, payload.IntrajornadaLimiteMinutos, payload.IntrajornadaMinimaMinutos,
+		payload.IntrajornadaCurtaLimiteMinutos, payload.IntrajornadaCurtaMinimaMinutos, payload.CargaHorariaDiariaMinutos,
+		payload.ToleranciaAtrasoMinutos, pq.Array(papeisOuVazio(payload.MFAObrigatoriaPapeis)), solicitante.EmpresaID.Int64,
+		payload.FusoHorario,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'empresa' rules")
+		return
+	}
+
+	empresa, err := carregarEmpresa(r.Context(), database.DB, solicitante.EmpresaID.Int64)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'empresa' rules")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, empresa)
+}
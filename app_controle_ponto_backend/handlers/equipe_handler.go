@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// formatoHora é o formato da entrada prevista na escala do usuário.
+const formatoHora = "15:04"
+
+// EscalaPayload define o corpo da requisição de atualização da escala de um membro da equipe.
+type EscalaPayload struct {
+	// EntradaPrevista no formato HH:MM, no fuso horário da empresa; vazio remove a escala.
+	EntradaPrevista string `json:"entrada_prevista"`
+}
+
+func (p EscalaPayload) validar() error {
+	var v validation.Validator
+	if p.EntradaPrevista != "" {
+		_, err := time.Parse(formatoHora, p.EntradaPrevista)
+		v.Check(err == nil, "entrada_prevista", validation.CodeInvalid, "must use the HH:MM format")
+	}
+	return v.Err()
+}
+
+// StatusMembroEquipe é a situação atual de um membro da equipe.
+type StatusMembroEquipe struct {
+	UserID            int64      `json:"user_id"`
+	Nome              string     `json:"nome"`
+	Situacao          string     `json:"situacao"`
+	UltimoPonto       *time.Time `json:"ultimo_ponto,omitempty"`
+	TrabalhadoMinutos int        `json:"trabalhado_minutos"`
+	EntradaPrevista   string     `json:"entrada_prevista,omitempty"`
+	Atrasado          bool       `json:"atrasado"`
+	AtrasoMinutos     int        `json:"atraso_minutos,omitempty"`
+	Afastamento       string     `json:"afastamento,omitempty"`
+}
+
+// StatusEquipeResposta é o painel de presença da equipe no dia corrente.
+type StatusEquipeResposta struct {
+	Data         string               `json:"data"`
+	AtualizadoEm time.Time            `json:"atualizado_em"`
+	Resumo       map[string]int       `json:"resumo"`
+	Membros      []StatusMembroEquipe `json:"membros"`
+}
+
+// membroEquipe é um membro da equipe lido do banco, antes do cálculo de presença.
+type membroEquipe struct {
+	ID              int64
+	Nome            string
+	EntradaPrevista sql.NullString
+	Afastamento     sql.NullString
+}
+
+// listarMembrosEquipe retorna os usuários gerenciados por p, sem o próprio, com a escala
+// e o afastamento aprovado que cobre o dia, se houver.
+func listarMembrosEquipe(ctx context.Context, q queryer, p perfil, dia time.Time) ([]membroEquipe, error) {
+	filtro, alvo := "u.gestor_id = $1", p.ID
+	if p.ehAdmin() {
+		filtro, alvo = "u.empresa_id = $1", p.EmpresaID.Int64
+	}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT u.id, u.nome, to_char(u.entrada_prevista, 'HH24:MI'), af.codigo
+		FROM users u
+		LEFT JOIN LATERAL (
+			SELECT t.codigo FROM afastamentos a JOIN tipos_afastamento t ON t.id = a.tipo_id
+			WHERE a.user_id = u.id AND a.status = $3 AND a.data_inicio <= $4 AND a.data_fim >= $4
+			ORDER BY a.data_inicio LIMIT 1
+		) af ON true
+		WHERE `+filtro+` AND u.id <> $2
+		ORDER BY u.nome ASC, u.id ASC`,
+		alvo, p.ID, models.AfastamentoAprovado, dia.Format(jornada.FormatoData),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	membros := []membroEquipe{}
+	for rows.Next() {
+		var m membroEquipe
+		if err := rows.Scan(&m.ID, &m.Nome, &m.EntradaPrevista, &m.Afastamento); err != nil {
+			return nil, err
+		}
+		membros = append(membros, m)
+	}
+	return membros, rows.Err()
+}
+
+// pontosPorUsuario carrega em uma única consulta os pontos de todos os usuários entre inicio
+// e fim (inclusivo), meia-noite no fuso da empresa.
+func pontosPorUsuario(ctx context.Context, q queryer, ids []int64, inicio, fim time.Time) (map[int64][]models.Ponto, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, user_id, horario FROM pontos
+		WHERE user_id = ANY($1) AND horario >= $2 AND horario < $3
+		ORDER BY user_id ASC, horario ASC`,
+		pq.Array(ids), inicio, fim.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	porUsuario := make(map[int64][]models.Ponto, len(ids))
+	for rows.Next() {
+		var p models.Ponto
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Horario); err != nil {
+			return nil, err
+		}
+		porUsuario[p.UserID] = append(porUsuario[p.UserID], p)
+	}
+	return porUsuario, rows.Err()
+}
+
+// StatusEquipe godoc
+// @Summary      Painel de presença da equipe
+// @Description  Retorna a situação atual de cada membro da equipe (trabalhando, em_intervalo, ausente, nao_iniciou, encerrado) a partir dos pontos do dia, contado no fuso horário da empresa, o tempo trabalhado até o momento e os atrasos em relação à entrada prevista. Quem ainda não tem pontos no dia e terminou o dia anterior com uma saída está encerrado. Restrito a gestores e administradores.
+// @Tags         Equipe
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  StatusEquipeResposta
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /equipe/status [get]
+func StatusEquipe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve team status")
+		return
+	}
+	if !solicitante.ehGestor() {
+		respondWithError(w, r, http.StatusForbidden, "Only managers can see the team status")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve team status")
+		return
+	}
+
+	fuso := empresa.Fuso()
+	agora := time.Now().UTC()
+	hoje := inicioDoDia(agora, fuso)
+	ontem := hoje.AddDate(0, 0, -1)
+
+	membros, err := listarMembrosEquipe(r.Context(), database.DB, solicitante, hoje)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing team members", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve team status")
+		return
+	}
+
+	ids := make([]int64, len(membros))
+	for i, m := range membros {
+		ids[i] = m.ID
+	}
+	// The previous day tells whether a member without pontos today has finished their last jornada
+	pontos, err := pontosPorUsuario(r.Context(), database.DB, ids, ontem, hoje)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying team 'pontos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve team status")
+		return
+	}
+
+	tolerancia := time.Duration(empresa.ToleranciaAtrasoMinutos) * time.Minute
+	resposta := StatusEquipeResposta{
+		Data:         hoje.Format(jornada.FormatoData),
+		AtualizadoEm: agora,
+		Resumo: map[string]int{
+			jornada.PresencaTrabalhando: 0,
+			jornada.PresencaEmIntervalo: 0,
+			jornada.PresencaAusente:     0,
+			jornada.PresencaNaoIniciou:  0,
+			jornada.PresencaEncerrado:   0,
+		},
+		Membros: make([]StatusMembroEquipe, 0, len(membros)),
+	}
+
+	for _, m := range membros {
+		_, porDia := jornada.AgruparPorDia(pontos[m.ID], fuso)
+		doDia := porDia[hoje.Format(jornada.FormatoData)]
+		anteriores := porDia[ontem.Format(jornada.FormatoData)]
+
+		// A escala vale apenas para os dias com jornada esperada.
+		var entradaPrevista *time.Time
+		if m.EntradaPrevista.Valid && jornada.JornadaEsperada(hoje, time.Minute) > 0 {
+			if h, err := time.Parse(formatoHora, m.EntradaPrevista.String); err == nil {
+				prevista := time.Date(hoje.Year(), hoje.Month(), hoje.Day(), h.Hour(), h.Minute(), 0, 0, fuso)
+				entradaPrevista = &prevista
+			}
+		}
+
+		presenca := jornada.CalcularPresenca(doDia, anteriores, agora, entradaPrevista, tolerancia, m.Afastamento.Valid)
+		status := StatusMembroEquipe{
+			UserID:            m.ID,
+			Nome:              m.Nome,
+			Situacao:          presenca.Situacao,
+			TrabalhadoMinutos: int(presenca.Trabalhado.Minutes()),
+			EntradaPrevista:   m.EntradaPrevista.String,
+			Atrasado:          presenca.Atraso > 0,
+			AtrasoMinutos:     int(presenca.Atraso.Minutes()),
+			Afastamento:       m.Afastamento.String,
+		}
+		if len(doDia) > 0 {
+			status.UltimoPonto = &doDia[len(doDia)-1].Horario
+		} else if len(anteriores) > 0 {
+			status.UltimoPonto = &anteriores[len(anteriores)-1].Horario
+		}
+
+		resposta.Resumo[status.Situacao]++
+		resposta.Membros = append(resposta.Membros, status)
+	}
+
+	respondWithJSON(w, http.StatusOK, resposta)
+}
+
+// AtualizarEscala godoc
+// @Summary      Atualiza a escala de um membro da equipe
+// @Description  Define a entrada prevista (HH:MM, no fuso horário da empresa) usada para apontar atrasos no painel da equipe. Uma entrada vazia remove a escala. Restrito ao gestor do usuário ou a um administrador da empresa.
+// @Tags         Equipe
+// @Accept       json
+// @Security     ApiKeyAuth
+// @Param        id      path  int            true  "ID do usuário"
+// @Param        escala  body  EscalaPayload  true  "Nova escala"
+// @Success      204
+// @Failure      400     {object}  apierror.Problem  "Invalid request body"
+// @Failure      403     {object}  apierror.Problem  "Permission denied"
+// @Failure      500     {object}  apierror.Problem  "Internal server error"
+// @Router       /equipe/membros/{id}/escala [put]
+func AtualizarEscala(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	alvoID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var payload EscalaPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	var entrada sql.NullString
+	if payload.EntradaPrevista != "" {
+		entrada = sql.NullString{String: payload.EntradaPrevista, Valid: true}
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+	permitido := solicitante.ehGestor()
+	if permitido {
+		if permitido, err = podeGerenciar(r.Context(), database.DB, solicitante, alvoID); err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to update schedule")
+			return
+		}
+	}
+	if !permitido {
+		respondWithError(w, r, http.StatusForbidden, "You don't have permission to change this user's schedule")
+		return
+	}
+
+	if _, err := database.DB.ExecContext(r.Context(), "UPDATE users SET entrada_prevista = $1 WHERE id = $2", entrada, alvoID); err != nil {
+		slog.ErrorContext(r.Context(), "Error updating schedule", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update schedule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
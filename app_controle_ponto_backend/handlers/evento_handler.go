@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/middleware"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Escopos do stream de eventos.
+const (
+	EscopoEquipe  = "equipe"
+	EscopoEmpresa = "empresa"
+)
+
+// intervaloHeartbeat é a frequência dos comentários enviados para manter a conexão aberta.
+const intervaloHeartbeat = 15 * time.Second
+
+// prazoEscrita é o prazo de cada escrita no stream. Ele é renovado a cada evento ou
+// heartbeat, substituindo o WriteTimeout do servidor, que encerraria o stream.
+const prazoEscrita = 2 * intervaloHeartbeat
+
+// eventoReset avisa o cliente que eventos foram perdidos e o estado deve ser recarregado.
+const eventoReset = "reset"
+
+// publicarEventosPonto completa os eventos com a empresa e o gestor do usuário e os publica
+// no barramento. Deve ser chamada depois do commit; uma falha é apenas registrada no log.
+func publicarEventosPonto(ctx context.Context, userID int64, evts ...eventos.Evento) {
+	var empresaID, gestorID sql.NullInt64
+	err := database.DB.QueryRowContext(ctx, "SELECT empresa_id, gestor_id FROM users WHERE id = $1", userID).Scan(&empresaID, &gestorID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading user to publish 'ponto' events", "user_id", userID, "error", err)
+		return
+	}
+
+	for _, e := range evts {
+		e.UserID = userID
+		if empresaID.Valid {
+			e.EmpresaID = &empresaID.Int64
+		}
+		if gestorID.Valid {
+			e.GestorID = &gestorID.Int64
+		}
+		eventos.Padrao.Publicar(e)
+	}
+}
+
+// filtroDeEventos monta o filtro das assinaturas de p. Os eventos do próprio gestor não são entregues.
+func filtroDeEventos(p perfil, escopo string) eventos.Filtro {
+	if escopo == EscopoEmpresa {
+		return func(e eventos.Evento) bool {
+			return e.UserID != p.ID && e.EmpresaID != nil && *e.EmpresaID == p.EmpresaID.Int64
+		}
+	}
+	return func(e eventos.Evento) bool {
+		return e.UserID != p.ID && e.GestorID != nil && *e.GestorID == p.ID
+	}
+}
+
+// escreverEvento envia um evento no formato Server-Sent Events.
+func escreverEvento(w http.ResponseWriter, e eventos.Evento) error {
+	dados, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Tipo, dados)
+	return err
+}
+
+// StreamEventos godoc
+// @Summary      Stream de eventos de ponto
+// @Description  Mantém uma conexão Server-Sent Events que entrega os eventos ponto.criado, ponto.alterado e ponto.removido da equipe do gestor (escopo=equipe) ou da empresa do administrador (escopo=empresa). Envie o cabeçalho Last-Event-ID para retomar após uma reconexão; se eventos tiverem sido perdidos, um evento reset é enviado e o cliente deve recarregar o estado.
+// @Tags         Eventos
+// @Produce      text/event-stream
+// @Security     ApiKeyAuth
+// @Param        escopo         query   string  false  "equipe ou empresa (padrão: empresa para administradores, equipe para gestores)"
+// @Param        Last-Event-ID  header  string  false  "ID do último evento recebido"
+// @Success      200  {string}  string  "Stream de eventos"
+// @Failure      400  {object}  apierror.Problem  "Invalid query parameters"
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /eventos/stream [get]
+func StreamEventos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to open event stream")
+		return
+	}
+	if !solicitante.ehGestor() {
+		respondWithError(w, r, http.StatusForbidden, "Only managers can follow the event stream")
+		return
+	}
+
+	escopo := r.URL.Query().Get("escopo")
+	switch escopo {
+	case "":
+		escopo = EscopoEquipe
+		if solicitante.ehAdmin() {
+			escopo = EscopoEmpresa
+		}
+	case EscopoEquipe:
+	case EscopoEmpresa:
+		if !solicitante.ehAdmin() {
+			respondWithError(w, r, http.StatusForbidden, "Only administrators can follow the whole 'empresa'")
+			return
+		}
+	default:
+		respondWithError(w, r, http.StatusBadRequest, "escopo must be one of: equipe, empresa")
+		return
+	}
+
+	var desde uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if desde, err = strconv.ParseUint(v, 10, 64); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	assinatura, pendentes, completo := eventos.Padrao.Assinar(desde, filtroDeEventos(solicitante, escopo))
+	defer assinatura.Cancelar()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc.SetWriteDeadline(time.Now().Add(prazoEscrita))
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !completo {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventoReset)
+	}
+	for _, e := range pendentes {
+		if err := escreverEvento(w, e); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "Error flushing event stream", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(intervaloHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, aberta := <-assinatura.C:
+			if !aberta {
+				// Descartada por lentidão ou desligamento: o cliente reconecta com Last-Event-ID e retoma.
+				return
+			}
+			rc.SetWriteDeadline(time.Now().Add(prazoEscrita))
+			if err := escreverEvento(w, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			rc.SetWriteDeadline(time.Now().Add(prazoEscrita))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
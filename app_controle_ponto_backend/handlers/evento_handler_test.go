@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mensagemSSE é um evento lido do stream.
+type mensagemSSE struct {
+	ID   string
+	Tipo string
+	Data string
+}
+
+// esperarPerfil espera a leitura do papel e da empresa (3) do usuário.
+func esperarPerfil(mock sqlmock.Sqlmock, userID int64, papel string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT papel, empresa_id FROM users")).WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"papel", "empresa_id"}).AddRow(papel, 3))
+}
+
+// abrirStream conecta ao StreamEventos como o usuário informado e devolve o leitor do
+// stream, já assinado no barramento. O stream é fechado ao fim do teste.
+func abrirStream(t *testing.T, userID int64, consulta, lastEventID string) *bufio.Reader {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		StreamEventos(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, userID)))
+	}))
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/api/eventos/stream?"+consulta, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// lerEvento lê o próximo evento do stream, pulando o retry e os heartbeats.
+func lerEvento(t *testing.T, r *bufio.Reader) mensagemSSE {
+	t.Helper()
+	var m mensagemSSE
+	for {
+		linha, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the stream: %v", err)
+		}
+		linha = strings.TrimSuffix(linha, "\n")
+		if linha == "" {
+			if m.Tipo != "" {
+				return m
+			}
+			continue
+		}
+		campo, valor, _ := strings.Cut(linha, ": ")
+		switch campo {
+		case "id":
+			m.ID = valor
+		case "event":
+			m.Tipo = valor
+		case "data":
+			m.Data = valor
+		}
+	}
+}
+
+// eventoDe cria um evento de ponto do usuário, da empresa e do gestor informados, com o
+// ponto identificado por pontoID.
+func eventoDe(userID, empresaID, gestorID int64, pontoID string) eventos.Evento {
+	return eventos.Evento{
+		Tipo:      eventos.TipoPontoCriado,
+		UserID:    userID,
+		Ponto:     models.Ponto{ID: pontoID, UserID: userID},
+		EmpresaID: &empresaID,
+		GestorID:  &gestorID,
+	}
+}
+
+func TestStreamEventosFiltro(t *testing.T) {
+	const solicitanteID = 5
+	tests := []struct {
+		name     string
+		papel    string
+		consulta string
+		// ignorados não podem ser entregues; o stream precisa entregar o entregue em seguida
+		ignorados []eventos.Evento
+		entregue  eventos.Evento
+	}{
+		{
+			name:  "equipe by default for managers",
+			papel: models.PapelGestor,
+			ignorados: []eventos.Evento{
+				eventoDe(8, 3, 6, "outra-equipe"),
+				eventoDe(solicitanteID, 3, 2, "proprio"),
+			},
+			entregue: eventoDe(8, 3, solicitanteID, "equipe"),
+		},
+		{
+			name:      "equipe for administrators",
+			papel:     models.PapelAdmin,
+			consulta:  "escopo=equipe",
+			ignorados: []eventos.Evento{eventoDe(8, 3, 6, "outra-equipe")},
+			entregue:  eventoDe(9, 3, solicitanteID, "equipe"),
+		},
+		{
+			name:  "empresa by default for administrators",
+			papel: models.PapelAdmin,
+			ignorados: []eventos.Evento{
+				eventoDe(8, 4, 6, "outra-empresa"),
+				eventoDe(solicitanteID, 3, 2, "proprio"),
+			},
+			entregue: eventoDe(8, 3, 6, "empresa"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarPerfil(mock, solicitanteID, tt.papel)
+			stream := abrirStream(t, solicitanteID, tt.consulta, "")
+
+			for _, e := range tt.ignorados {
+				eventos.Padrao.Publicar(e)
+			}
+			entregue := eventos.Padrao.Publicar(tt.entregue)
+
+			m := lerEvento(t, stream)
+			if m.ID != strconv.FormatUint(entregue.ID, 10) || m.Tipo != eventos.TipoPontoCriado {
+				t.Errorf("got event %s %s %s, want %d", m.ID, m.Tipo, m.Data, entregue.ID)
+			}
+		})
+	}
+}
+
+func TestStreamEventosRetomada(t *testing.T) {
+	const gestorID = 5
+	var publicados []eventos.Evento
+	for i := range 3 {
+		publicados = append(publicados, eventos.Padrao.Publicar(eventoDe(8, 3, gestorID, strconv.Itoa(i))))
+	}
+
+	mock := mockDB(t)
+	esperarPerfil(mock, gestorID, models.PapelGestor)
+	stream := abrirStream(t, gestorID, "", strconv.FormatUint(publicados[0].ID, 10))
+
+	// Only what came after Last-Event-ID, with no reset
+	for _, want := range publicados[1:] {
+		if m := lerEvento(t, stream); m.ID != strconv.FormatUint(want.ID, 10) {
+			t.Errorf("got event %s %s, want %d", m.ID, m.Tipo, want.ID)
+		}
+	}
+}
+
+func TestStreamEventosReset(t *testing.T) {
+	const gestorID = 5
+	ultimoRecebido := eventos.Padrao.Publicar(eventoDe(8, 3, gestorID, "recebido"))
+	// The API keeps 1024 events; push it, and the one right after it, out of the history
+	for range 1024 {
+		eventos.Padrao.Publicar(eventoDe(8, 3, 6, "outra-equipe"))
+	}
+	pendente := eventos.Padrao.Publicar(eventoDe(8, 3, gestorID, "pendente"))
+
+	mock := mockDB(t)
+	esperarPerfil(mock, gestorID, models.PapelGestor)
+	stream := abrirStream(t, gestorID, "", strconv.FormatUint(ultimoRecebido.ID, 10))
+
+	if m := lerEvento(t, stream); m.Tipo != eventoReset {
+		t.Fatalf("got event %s %s, want %s", m.ID, m.Tipo, eventoReset)
+	}
+	// The history still left is delivered after the reset
+	if m := lerEvento(t, stream); m.ID != strconv.FormatUint(pendente.ID, 10) {
+		t.Errorf("got event %s %s, want %d", m.ID, m.Tipo, pendente.ID)
+	}
+}
+
+func TestStreamEventosRecusado(t *testing.T) {
+	tests := []struct {
+		name        string
+		papel       string
+		consulta    string
+		lastEventID string
+		status      int
+	}{
+		{"employee", models.PapelFuncionario, "", "", http.StatusForbidden},
+		{"empresa for managers", models.PapelGestor, "escopo=empresa", "", http.StatusForbidden},
+		{"unknown escopo", models.PapelAdmin, "escopo=tudo", "", http.StatusBadRequest},
+		{"invalid Last-Event-ID", models.PapelGestor, "", "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarPerfil(mock, 5, tt.papel)
+
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, int64(5))
+			r := httptest.NewRequest(http.MethodGet, "/api/eventos/stream?"+tt.consulta, nil).WithContext(ctx)
+			if tt.lastEventID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			rec := httptest.NewRecorder()
+			StreamEventos(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"controle-ponto-api/auth"
+	"controle-ponto-api/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mockDB troca database.DB por um sqlmock durante o teste. Os comandos são comparados por
+// expressão regular, então as expectativas citam só a parte que os distingue.
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anterior := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = anterior
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	return mock
+}
+
+// esperarInvalidacaoBancoDeHoras espera o descarte do banco de horas gravado de um período e
+// o agendamento do recálculo.
+func esperarInvalidacaoBancoDeHoras(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM banco_horas_dias")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// mockTokens troca Tokens por um emissor HS256 durante o teste.
+func mockTokens(t *testing.T) *auth.Issuer {
+	t.Helper()
+	key, err := auth.NewHMACKey("test", []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := auth.NewIssuer(auth.Options{Issuer: "controle-ponto", Audience: "controle-ponto-api", TTL: time.Hour}, "test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anterior := Tokens
+	Tokens = issuer
+	t.Cleanup(func() { Tokens = anterior })
+	return issuer
+}
+
+// capturar é um sqlmock.Argument que aceita qualquer valor e o guarda, para que o teste use
+// o que o handler gerou (um state, um nonce) nas expectativas seguintes.
+type capturar struct{ valor driver.Value }
+
+func (c *capturar) Match(v driver.Value) bool {
+	c.valor = v
+	return true
+}
+
+func (c *capturar) String() string {
+	s, _ := c.valor.(string)
+	return s
+}
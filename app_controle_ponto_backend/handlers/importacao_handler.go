@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/metrics"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/validation"
+	"controle-ponto-api/webhooks"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// JobImportarPontos é o tipo da tarefa que grava os pontos de uma importação.
+const JobImportarPontos = "pontos.importar"
+
+// maxPontosPorImportacao limita o tamanho de uma importação.
+const maxPontosPorImportacao = 5000
+
+// maxTentativasImportacao é o número de execuções da importação antes de ela ser marcada
+// como falhou.
+const maxTentativasImportacao = 3
+
+// Status de uma importação de pontos.
+const (
+	ImportacaoPendente  = "pendente"
+	ImportacaoConcluida = "concluida"
+	ImportacaoFalhou    = "falhou"
+)
+
+// PontoImportadoPayload é um ponto trazido de outro sistema.
+type PontoImportadoPayload struct {
+	UserID int64 `json:"user_id"`
+	// ClientID é um UUID que identifica o ponto na origem; reimportar o mesmo UUID não gera
+	// duplicidade.
+	ClientID string    `json:"client_id"`
+	Horario  time.Time `json:"horario"`
+}
+
+// ImportacaoPontosPayload define o corpo da requisição de importação de pontos.
+type ImportacaoPontosPayload struct {
+	Pontos []PontoImportadoPayload `json:"pontos"`
+}
+
+func (p ImportacaoPontosPayload) validar(recebidoEm time.Time) error {
+	var v validation.Validator
+	v.Check(len(p.Pontos) > 0, "pontos", validation.CodeRequired, "must not be empty")
+	if !v.Check(len(p.Pontos) <= maxPontosPorImportacao, "pontos", validation.CodeOutOfRange,
+		"must have at most %d items", maxPontosPorImportacao) {
+		return v.Err()
+	}
+	for i, item := range p.Pontos {
+		campo := func(nome string) string {
+			return fmt.Sprintf("pontos[%d].%s", i, nome)
+		}
+		v.Check(item.UserID > 0, campo("user_id"), validation.CodeRequired, "is required")
+		v.Check(uuidRegex.MatchString(item.ClientID), campo("client_id"), validation.CodeInvalid, "must be a valid UUID")
+		v.TimeBetween(campo("horario"), item.Horario, horarioMinimoPonto, recebidoEm.Add(ToleranciaDerivaRelogio))
+	}
+	return v.Err()
+}
+
+// ImportacaoPontos é uma importação de pontos gravada em segundo plano.
+type ImportacaoPontos struct {
+	ID          int64  `json:"id"`
+	UserID      int64  `json:"user_id"`
+	Status      string `json:"status"`
+	TotalPontos int    `json:"total_pontos"`
+	// Resultados traz o resultado de cada ponto, na ordem do pedido, quando a importação é
+	// concluída. Os status são os mesmos da sincronização offline.
+	Resultados  []SincronizacaoItemResultado `json:"resultados,omitempty"`
+	CriadoEm    time.Time                    `json:"criado_em"`
+	ConcluidoEm *time.Time                   `json:"concluido_em,omitempty"`
+}
+
+// importacaoPontosJobPayload identifica a importação gravada pela tarefa JobImportarPontos.
+type importacaoPontosJobPayload struct {
+	ImportacaoID int64 `json:"importacao_id"`
+}
+
+// carregarImportacao lê a importação id, sem os pontos enviados.
+func carregarImportacao(ctx context.Context, q queryer, id int64) (ImportacaoPontos, error) {
+	var i ImportacaoPontos
+	var resultados []byte
+	var concluidoEm sql.NullTime
+	err := q.QueryRowContext(ctx,
+		`SELECT id, user_id, status, total_pontos, resultados, criado_em, concluido_em
+		FROM importacoes_pontos WHERE id = $1`,
+		id,
+	).Scan(&i.ID, &i.UserID, &i.Status, &i.TotalPontos, &resultados, &i.CriadoEm, &concluidoEm)
+	if err != nil {
+		return i, err
+	}
+	if len(resultados) > 0 {
+		if err := json.Unmarshal(resultados, &i.Resultados); err != nil {
+			return i, err
+		}
+	}
+	if concluidoEm.Valid {
+		i.ConcluidoEm = &concluidoEm.Time
+	}
+	return i, nil
+}
+
+// importarPontosDoUsuario grava, em uma transação, os pontos de índices indices, que são
+// todos do usuário, guardando o resultado de cada um em resultados. Retorna os eventos
+// dos pontos criados, a publicar depois do commit.
+func importarPontosDoUsuario(ctx context.Context, userID int64, pontos []PontoImportadoPayload, indices []int, recebidoEm time.Time, resultados []SincronizacaoItemResultado) ([]eventos.Evento, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := bloquearUsuario(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	empresa, err := carregarEmpresaDoUsuario(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var horariosCriados []time.Time
+	var criados []eventos.Evento
+	for _, i := range indices {
+		// Imported like an offline ponto: the client_id dedupes and the empresa's
+		// duplicity policy applies
+		item := PontoOfflinePayload{ClientID: pontos[i].ClientID, HorarioDispositivo: pontos[i].Horario}
+		resultado, err := sincronizarPonto(ctx, tx, userID, item, recebidoEm, empresa)
+		if err != nil {
+			return nil, err
+		}
+		if resultado.Status == SyncStatusCriado {
+			if err := webhooks.Enfileirar(ctx, tx, userID, eventos.TipoPontoCriado, dadosEventoPonto{Ponto: *resultado.Ponto}); err != nil {
+				return nil, err
+			}
+			horariosCriados = append(horariosCriados, resultado.Ponto.Horario)
+			criados = append(criados, eventos.Evento{Tipo: eventos.TipoPontoCriado, Ponto: *resultado.Ponto})
+		}
+		resultados[i] = resultado
+	}
+
+	if err := enfileirarReavaliacao(ctx, tx, userID, empresa.Fuso(), horariosCriados...); err != nil {
+		return nil, err
+	}
+	if err := invalidarBancoDeHorasDosPontos(ctx, tx, userID, empresa.Fuso(), horariosCriados...); err != nil {
+		return nil, err
+	}
+	return criados, tx.Commit()
+}
+
+// importarPontos grava os pontos, um usuário por vez, e retorna o resultado de cada um.
+// Se a tarefa for repetida depois de uma falha, os pontos dos usuários já gravados voltam
+// como duplicado.
+func importarPontos(ctx context.Context, pontos []PontoImportadoPayload) ([]SincronizacaoItemResultado, error) {
+	var usuarios []int64
+	indices := make(map[int64][]int)
+	for i, p := range pontos {
+		if _, ok := indices[p.UserID]; !ok {
+			usuarios = append(usuarios, p.UserID)
+		}
+		indices[p.UserID] = append(indices[p.UserID], i)
+	}
+
+	recebidoEm := time.Now()
+	resultados := make([]SincronizacaoItemResultado, len(pontos))
+	for _, userID := range usuarios {
+		criados, err := importarPontosDoUsuario(ctx, userID, pontos, indices[userID], recebidoEm, resultados)
+		if err != nil {
+			return nil, err
+		}
+		if len(criados) > 0 {
+			metrics.PontosRegistered.WithLabelValues(metrics.OriginImport).Add(float64(len(criados)))
+			publicarEventosPonto(ctx, userID, criados...)
+		}
+	}
+	return resultados, nil
+}
+
+// ImportarPontosJob executa a tarefa JobImportarPontos. Os erros dos resultados saem no
+// idioma de quem pediu a importação. Na última tentativa, uma falha marca a importação
+// como falhou.
+func ImportarPontosJob(ctx context.Context, job jobs.Job) error {
+	var payload importacaoPontosJobPayload
+	if err := job.Decodificar(&payload); err != nil {
+		return err
+	}
+
+	importacao, err := carregarImportacao(ctx, database.DB, payload.ImportacaoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The requester's account was deleted along with its imports
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if importacao.Status != ImportacaoPendente {
+		return nil
+	}
+
+	var corpo []byte
+	if err := database.DB.QueryRowContext(ctx, "SELECT pontos FROM importacoes_pontos WHERE id = $1", importacao.ID).Scan(&corpo); err != nil {
+		return err
+	}
+	var pontos []PontoImportadoPayload
+	if err := json.Unmarshal(corpo, &pontos); err != nil {
+		return err
+	}
+
+	if idioma, err := IdiomaDoUsuario(ctx, importacao.UserID); err == nil && i18n.IsSupported(idioma) {
+		ctx = i18n.WithLocale(ctx, idioma)
+	}
+
+	resultados, err := importarPontos(ctx, pontos)
+	if err != nil {
+		// An attempt interrupted by the shutdown runs again
+		if job.Tentativa >= maxTentativasImportacao && ctx.Err() == nil {
+			_, errStatus := database.DB.ExecContext(ctx,
+				"UPDATE importacoes_pontos SET status = $1, concluido_em = NOW() WHERE id = $2",
+				ImportacaoFalhou, importacao.ID,
+			)
+			if errStatus != nil {
+				slog.ErrorContext(ctx, "Error marking 'pontos' import as failed", "importacao_id", importacao.ID, "error", errStatus)
+			}
+		}
+		return err
+	}
+
+	corpo, err = json.Marshal(resultados)
+	if err != nil {
+		return err
+	}
+	_, err = database.DB.ExecContext(ctx,
+		"UPDATE importacoes_pontos SET status = $1, resultados = $2, concluido_em = NOW() WHERE id = $3",
+		ImportacaoConcluida, string(corpo), importacao.ID,
+	)
+	return err
+}
+
+// SolicitarImportacaoPontos godoc
+// @Summary      Importa pontos de outro sistema
+// @Description  Agenda a gravação, em segundo plano, de um lote de pontos dos usuários gerenciados por quem envia. Cada ponto traz um UUID da origem, e reimportar o mesmo UUID não gera duplicidade; pontos próximos demais de outro seguem a política de duplicidade da empresa, como na sincronização offline. Acompanhe a importação pela URL do cabeçalho Location.
+// @Tags         Pontos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        importacao  body    ImportacaoPontosPayload  true  "Pontos a importar"
+// @Param        Idempotency-Key  header  string  false  "Chave para evitar importações duplicadas em reenvios"
+// @Success      202         {object}  ImportacaoPontos
+// @Header       202         {string}  Location  "URL da importação"
+// @Failure      400         {object}  apierror.Problem  "Invalid request body"
+// @Failure      403         {object}  apierror.Problem  "Permission denied"
+// @Failure      500         {object}  apierror.Problem  "Internal server error"
+// @Router       /pontos/importacoes [post]
+func SolicitarImportacaoPontos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload ImportacaoPontosPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(time.Now()); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+	if !solicitante.ehGestor() {
+		respondWithError(w, r, http.StatusForbidden, "Only managers can import 'pontos'")
+		return
+	}
+
+	ids, err := usuariosGerenciados(r.Context(), database.DB, solicitante)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing managed users", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+	gerenciados := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		gerenciados[id] = true
+	}
+	var v validation.Validator
+	for i, item := range payload.Pontos {
+		v.Check(gerenciados[item.UserID], fmt.Sprintf("pontos[%d].user_id", i), validation.CodeNotAllowed, "is not a user you manage")
+	}
+	if err := v.Err(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	corpo, err := json.Marshal(payload.Pontos)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding 'pontos' import", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(r.Context(),
+		"INSERT INTO importacoes_pontos (user_id, pontos, total_pontos) VALUES ($1, $2, $3) RETURNING id",
+		userID, string(corpo), len(payload.Pontos),
+	).Scan(&id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting 'pontos' import", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+
+	err = jobs.Enfileirar(r.Context(), tx, JobImportarPontos, importacaoPontosJobPayload{ImportacaoID: id}, jobs.Opcoes{MaxTentativas: maxTentativasImportacao})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing 'pontos' import", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+
+	importacao, err := carregarImportacao(r.Context(), tx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'pontos' import", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing 'pontos' import", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to import 'pontos'")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/pontos/importacoes/%d", id))
+	respondWithJSON(w, http.StatusAccepted, importacao)
+}
+
+// ObterImportacaoPontos godoc
+// @Summary      Consulta uma importação de pontos
+// @Description  Retorna o status de uma importação pedida pelo usuário autenticado e, quando concluída, o resultado de cada ponto.
+// @Tags         Pontos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "ID da importação"
+// @Success      200  {object}  ImportacaoPontos
+// @Failure      400  {object}  apierror.Problem  "Invalid ID format"
+// @Failure      404  {object}  apierror.Problem  "Import not found"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /pontos/importacoes/{id} [get]
+func ObterImportacaoPontos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	// Other users' imports don't exist for the requester
+	importacao, err := carregarImportacao(r.Context(), database.DB, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && importacao.UserID != userID) {
+		respondWithError(w, r, http.StatusNotFound, "Import not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'pontos' import", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve import")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, importacao)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const outroClientIDTeste = "9c1d2e3f-4a5b-4c6d-8e7f-0a1b2c3d4e5f"
+
+// esperarImportacao espera a leitura da importação 12, pedida pelo usuário 5.
+func esperarImportacao(mock sqlmock.Sqlmock, status string, total int) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM importacoes_pontos WHERE id = $1")).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "total_pontos", "resultados", "criado_em", "concluido_em"}).
+			AddRow(12, 5, status, total, nil, time.Now(), nil))
+}
+
+func TestSolicitarImportacaoPontos(t *testing.T) {
+	const solicitanteID = 5
+	horario := time.Now().Add(-time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+	ponto := func(userID, clientID string) string {
+		return `{"user_id":` + userID + `,"client_id":"` + clientID + `","horario":"` + horario + `"}`
+	}
+
+	tests := []struct {
+		name   string
+		papel  string
+		pontos []string
+		status int
+		// campo é o campo recusado quando o status é 400
+		campo string
+	}{
+		{
+			name:   "managed users",
+			papel:  models.PapelGestor,
+			pontos: []string{ponto("8", clientIDTeste), ponto("9", outroClientIDTeste)},
+			status: http.StatusAccepted,
+		},
+		{
+			name:   "employee",
+			papel:  models.PapelFuncionario,
+			pontos: []string{ponto("8", clientIDTeste)},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "user outside the team",
+			papel:  models.PapelGestor,
+			pontos: []string{ponto("8", clientIDTeste), ponto("10", outroClientIDTeste)},
+			status: http.StatusBadRequest,
+			campo:  "pontos[1].user_id",
+		},
+		{
+			name:   "client_id is not a UUID",
+			pontos: []string{ponto("8", "ponto-1")},
+			status: http.StatusBadRequest,
+			campo:  "pontos[0].client_id",
+		},
+		{
+			name:   "horario in the future",
+			pontos: []string{`{"user_id":8,"client_id":"` + clientIDTeste + `","horario":"2099-01-01T08:00:00Z"}`},
+			status: http.StatusBadRequest,
+			campo:  "pontos[0].horario",
+		},
+		{
+			name:   "empty",
+			status: http.StatusBadRequest,
+			campo:  "pontos",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			if tt.papel != "" {
+				esperarPerfil(mock, solicitanteID, tt.papel)
+			}
+			if tt.papel == models.PapelGestor {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE gestor_id = $1 OR id = $1")).WithArgs(solicitanteID).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(8).AddRow(9))
+			}
+			if tt.status == http.StatusAccepted {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO importacoes_pontos")).
+					WithArgs(solicitanteID, sqlmock.AnyArg(), len(tt.pontos)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).
+					WithArgs(JobImportarPontos, `{"importacao_id":12}`, nil, 3, nil).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				esperarImportacao(mock, ImportacaoPendente, len(tt.pontos))
+				mock.ExpectCommit()
+			}
+
+			corpo := `{"pontos":[` + strings.Join(tt.pontos, ",") + `]}`
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, int64(solicitanteID))
+			r := httptest.NewRequest(http.MethodPost, "/api/pontos/importacoes", strings.NewReader(corpo)).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			SolicitarImportacaoPontos(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			switch tt.status {
+			case http.StatusAccepted:
+				if got := rec.Header().Get("Location"); got != "/api/pontos/importacoes/12" {
+					t.Errorf("Location = %q, want /api/pontos/importacoes/12", got)
+				}
+			case http.StatusBadRequest:
+				var problema struct {
+					Errors []validation.FieldError `json:"errors"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &problema); err != nil {
+					t.Fatal(err)
+				}
+				if len(problema.Errors) != 1 || problema.Errors[0].Field != tt.campo {
+					t.Errorf("errors = %+v, want one on %s", problema.Errors, tt.campo)
+				}
+			}
+		})
+	}
+}
+
+func TestImportarPontosJob(t *testing.T) {
+	horario := time.Date(2024, 3, 4, 11, 0, 0, 0, time.UTC)
+	mock := mockDB(t)
+	esperarImportacao(mock, ImportacaoPendente, 2)
+	pontos, _ := json.Marshal([]PontoImportadoPayload{
+		{UserID: 8, ClientID: clientIDTeste, Horario: horario},
+		{UserID: 9, ClientID: outroClientIDTeste, Horario: horario},
+	})
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pontos FROM importacoes_pontos")).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows([]string{"pontos"}).AddRow(pontos))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(idioma, '') FROM users")).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"idioma"}).AddRow("es"))
+
+	// One transaction per user; user 8 already has the ponto from an earlier import
+	esperarInicioSincronizacao(mock, 8, models.PoliticaDuplicidadeRejeitar)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM pontos WHERE user_id = $1 AND client_id = $2")).WithArgs(8, clientIDTeste).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "horario", "client_id", "horario_dispositivo", "recebido_em", "deriva_segundos", "deriva_suspeita", "duplicidade_suspeita"}).
+			AddRow(40, 8, horario, clientIDTeste, horario, horario, nil, false, false))
+	mock.ExpectCommit()
+
+	esperarInicioSincronizacao(mock, 9, models.PoliticaDuplicidadeRejeitar)
+	mock.ExpectQuery(regexp.QuoteMeta("FROM pontos WHERE user_id = $1 AND client_id = $2")).WithArgs(9, outroClientIDTeste).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "horario", "client_id", "horario_dispositivo", "recebido_em", "deriva_segundos", "deriva_suspeita", "duplicidade_suspeita"}))
+	mock.ExpectQuery(regexp.QuoteMeta("AND id <> $5")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "horario"}))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO pontos")).
+		WithArgs(9, horario, outroClientIDTeste, horario, sqlmock.AnyArg(), nil, false, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_entregas")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WithArgs(JobReavaliarViolacoes, sqlmock.AnyArg(), sqlmock.AnyArg(), 5, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	esperarInvalidacaoBancoDeHoras(mock)
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT empresa_id, gestor_id FROM users")).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"empresa_id", "gestor_id"}).AddRow(3, 5))
+
+	resultados := &capturar{}
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE importacoes_pontos SET status = $1, resultados = $2")).
+		WithArgs(ImportacaoConcluida, resultados, 12).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	job := jobs.Job{ID: 1, Tipo: JobImportarPontos, Payload: []byte(`{"importacao_id":12}`), Tentativa: 1}
+	if err := ImportarPontosJob(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []SincronizacaoItemResultado
+	if err := json.Unmarshal([]byte(resultados.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	// In the order of the request
+	if len(got) != 2 || got[0].Status != SyncStatusDuplicado || got[0].Ponto.ID != "40" ||
+		got[1].Status != SyncStatusCriado || got[1].Ponto.ID != "42" {
+		t.Errorf("resultados = %+v, want ponto 40 duplicado and ponto 42 criado", got)
+	}
+}
+
+func TestImportarPontosJobFalha(t *testing.T) {
+	tests := []struct {
+		name      string
+		tentativa int
+		// wantFalhou diz se a importação é marcada como falhou
+		wantFalhou bool
+	}{
+		{"retried", 1, false},
+		{"last attempt", maxTentativasImportacao, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarImportacao(mock, ImportacaoPendente, 1)
+			pontos, _ := json.Marshal([]PontoImportadoPayload{{UserID: 8, ClientID: clientIDTeste, Horario: time.Now()}})
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT pontos FROM importacoes_pontos")).
+				WillReturnRows(sqlmock.NewRows([]string{"pontos"}).AddRow(pontos))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(idioma, '') FROM users")).
+				WillReturnRows(sqlmock.NewRows([]string{"idioma"}).AddRow(""))
+			mock.ExpectBegin().WillReturnError(errors.New("connection reset"))
+			if tt.wantFalhou {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE importacoes_pontos SET status = $1, concluido_em = NOW()")).
+					WithArgs(ImportacaoFalhou, 12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			job := jobs.Job{ID: 1, Tipo: JobImportarPontos, Payload: []byte(`{"importacao_id":12}`), Tentativa: tt.tentativa}
+			if err := ImportarPontosJob(context.Background(), job); err == nil {
+				t.Error("ImportarPontosJob() = nil, want the error so the job is retried")
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// maxDiasPorPeriodo limita o tamanho dos períodos aceitos nos relatórios.
+const maxDiasPorPeriodo = 366
+
+// RelatorioInconsistencias é a resposta do relatório de inconsistências.
+type RelatorioInconsistencias struct {
+	Inicio          string                   `json:"inicio"`
+	Fim             string                   `json:"fim"`
+	Inconsistencias []jornada.Inconsistencia `json:"inconsistencias"`
+}
+
+// parsePeriodo lê os parâmetros "inicio" e "fim" (YYYY-MM-DD, ambos inclusivos) como a
+// meia-noite desses dias no fuso da empresa. Sem parâmetros, o período são os últimos 30 dias.
+func parsePeriodo(r *http.Request, fuso *time.Location) (time.Time, time.Time, error) {
+	hoje := inicioDoDia(time.Now(), fuso)
+	inicio := hoje.AddDate(0, 0, -29)
+	fim := hoje
+
+	var err error
+	if v := r.URL.Query().Get("inicio"); v != "" {
+		if inicio, err = time.ParseInLocation(jornada.FormatoData, v, fuso); err != nil {
+			return inicio, fim, errors.New("Invalid 'inicio' date format. Use YYYY-MM-DD")
+		}
+	}
+	if v := r.URL.Query().Get("fim"); v != "" {
+		if fim, err = time.ParseInLocation(jornada.FormatoData, v, fuso); err != nil {
+			return inicio, fim, errors.New("Invalid 'fim' date format. Use YYYY-MM-DD")
+		}
+	}
+
+	if fim.Before(inicio) {
+		return inicio, fim, errors.New("'fim' must not be before 'inicio'")
+	}
+	// Counted in calendar days, since days around a DST change aren't 24 hours long
+	if !fim.Before(inicio.AddDate(0, 0, maxDiasPorPeriodo)) {
+		return inicio, fim, errors.New("Period too long")
+	}
+	return inicio, fim, nil
+}
+
+// listarPontosDoPeriodo retorna os pontos do usuário entre inicio e fim (inclusivo), ordenados por horário.
+func listarPontosDoPeriodo(ctx context.Context, q queryer, userID int64, inicio, fim time.Time) ([]models.Ponto, error) {
+	rows, err := q.QueryContext(ctx,
+		"SELECT id, user_id, horario FROM pontos WHERE user_id = $1 AND horario >= $2 AND horario < $3 ORDER BY horario ASC",
+		userID, inicio, fim.AddDate(0, 0, 1),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pontos := []models.Ponto{}
+	for rows.Next() {
+		var p models.Ponto
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Horario); err != nil {
+			return nil, err
+		}
+		pontos = append(pontos, p)
+	}
+	return pontos, rows.Err()
+}
+
+// ListarInconsistencias godoc
+// @Summary      Relatório de inconsistências
+// @Description  Lista os dias do período com quantidade ímpar de pontos, pontos mais próximos que o intervalo mínimo da empresa ou jornada acima do máximo configurado. Os dias são contados no fuso horário da empresa.
+// @Tags         Pontos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        inicio  query     string  false  "Data inicial no formato YYYY-MM-DD (padrão: 29 dias atrás)"
+// @Param        fim     query     string  false  "Data final no formato YYYY-MM-DD (padrão: hoje)"
+// @Success      200     {object}  RelatorioInconsistencias
+// @Failure      400     {object}  apierror.Problem  "Invalid date format. Use YYYY-MM-DD"
+// @Failure      500     {object}  apierror.Problem  "Internal server error"
+// @Router       /pontos/inconsistencias [get]
+func ListarInconsistencias(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'pontos'")
+		return
+	}
+
+	inicio, fim, err := parsePeriodo(r, empresa.Fuso())
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pontos, err := listarPontosDoPeriodo(r.Context(), database.DB, userID, inicio, fim)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'pontos' for inconsistencies", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'pontos'")
+		return
+	}
+
+	regras := jornada.RegrasDaEmpresa(empresa)
+	relatorio := RelatorioInconsistencias{
+		Inicio:          inicio.Format(jornada.FormatoData),
+		Fim:             fim.Format(jornada.FormatoData),
+		Inconsistencias: []jornada.Inconsistencia{},
+	}
+
+	dias, porDia := jornada.AgruparPorDia(pontos, empresa.Fuso())
+	for _, dia := range dias {
+		relatorio.Inconsistencias = append(relatorio.Inconsistencias, jornada.Inconsistencias(dia, porDia[dia], regras)...)
+	}
+
+	respondWithJSON(w, http.StatusOK, relatorio)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParsePeriodo(t *testing.T) {
+	fuso, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		inicio   string
+		fim      string
+		invalido bool
+	}{
+		// Midnight in São Paulo, not in UTC
+		{name: "period", query: "inicio=2024-03-01&fim=2024-03-31", inicio: "2024-03-01T00:00:00-03:00", fim: "2024-03-31T00:00:00-03:00"},
+		{name: "longest period", query: "inicio=2024-01-01&fim=2024-12-31", inicio: "2024-01-01T00:00:00-03:00", fim: "2024-12-31T00:00:00-03:00"},
+		{name: "too long", query: "inicio=2024-01-01&fim=2025-01-01", invalido: true},
+		{name: "end before start", query: "inicio=2024-03-02&fim=2024-03-01", invalido: true},
+		{name: "invalid date", query: "inicio=01/03/2024", invalido: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inicio, fim, err := parsePeriodo(httptest.NewRequest(http.MethodGet, "/api/banco-horas?"+tt.query, nil), fuso)
+			if tt.invalido {
+				if err == nil {
+					t.Fatalf("parsePeriodo(%s) = %s, %s; want an error", tt.query, inicio, fim)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := inicio.Format(time.RFC3339); got != tt.inicio {
+				t.Errorf("inicio = %s, want %s", got, tt.inicio)
+			}
+			if got := fim.Format(time.RFC3339); got != tt.fim {
+				t.Errorf("fim = %s, want %s", got, tt.fim)
+			}
+		})
+	}
+}
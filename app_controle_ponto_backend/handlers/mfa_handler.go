@@ -0,0 +1,775 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/auth"
+	"controle-ponto-api/database"
+	"controle-ponto-api/metrics"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/totp"
+	"controle-ponto-api/validation"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// duracaoDesafioMFA é o tempo para informar o segundo fator depois da senha.
+	duracaoDesafioMFA = 5 * time.Minute
+	// maxTentativasDesafioMFA limita os códigos testados com um mesmo desafio.
+	maxTentativasDesafioMFA = 5
+	// quantidadeCodigosRecuperacao é o número de códigos gerados por vez.
+	quantidadeCodigosRecuperacao = 10
+	// emissorTOTP identifica a conta no aplicativo autenticador.
+	emissorTOTP = "Controle de Ponto"
+)
+
+var (
+	errCodigoInvalido      = errors.New("invalid second factor code")
+	errMFAJaAtiva          = errors.New("TOTP is already enabled")
+	errCadastroNaoIniciado = errors.New("TOTP enrollment not started")
+)
+
+// RespostaLogin é a resposta de Login. Sem segundo fator, traz o token de acesso; caso
+// contrário, um desafio para /login/mfa ou, se o usuário ainda precisa cadastrar o TOTP,
+// para /login/mfa/cadastro.
+type RespostaLogin struct {
+	Token                string     `json:"token,omitempty"`
+	MFARequerido         bool       `json:"mfa_requerido,omitempty"`
+	MFACadastroRequerido bool       `json:"mfa_cadastro_requerido,omitempty"`
+	Desafio              string     `json:"desafio,omitempty"`
+	ExpiraEm             *time.Time `json:"expira_em,omitempty"`
+	// CodigosRecuperacao só aparece ao concluir o cadastro do TOTP.
+	CodigosRecuperacao []string `json:"codigos_recuperacao,omitempty"`
+}
+
+// CadastroTOTP traz o segredo a ser cadastrado no aplicativo autenticador. URI é o
+// otpauth:// a ser exibido como QR code.
+type CadastroTOTP struct {
+	Segredo string `json:"segredo"`
+	URI     string `json:"uri"`
+}
+
+// StatusMFA descreve o segundo fator do usuário.
+type StatusMFA struct {
+	TOTPAtivo bool `json:"totp_ativo"`
+	// Obrigatoria indica que a empresa exige MFA para o papel do usuário.
+	Obrigatoria                 bool `json:"obrigatoria"`
+	CodigosRecuperacaoRestantes int  `json:"codigos_recuperacao_restantes"`
+}
+
+// CodigoMFAPayload define o corpo das requisições que pedem um código do segundo fator.
+type CodigoMFAPayload struct {
+	// Desafio recebido no login; não é usado nas rotas autenticadas.
+	Desafio string `json:"desafio,omitempty"`
+	// Codigo do aplicativo autenticador ou um código de recuperação.
+	Codigo string `json:"codigo"`
+}
+
+// validar exige o desafio nas rotas de login e o código nas que o conferem.
+func (p CodigoMFAPayload) validar(exigirDesafio, exigirCodigo bool) error {
+	var v validation.Validator
+	if exigirDesafio {
+		v.Required("desafio", p.Desafio)
+	}
+	if exigirCodigo {
+		v.Required("codigo", p.Codigo)
+	}
+	return v.Err()
+}
+
+// CodigosRecuperacao são os códigos de uso único para entrar sem o aplicativo autenticador.
+// Só são exibidos uma vez.
+type CodigosRecuperacao struct {
+	Codigos []string `json:"codigos_recuperacao"`
+}
+
+// carregarStatusMFA informa se o usuário tem TOTP e se a empresa o exige para o papel dele.
+func carregarStatusMFA(ctx context.Context, q queryer, userID int64) (StatusMFA, error) {
+	var s StatusMFA
+	err := q.QueryRowContext(ctx,
+		`SELECT u.totp_ativo, COALESCE(u.papel = ANY(e.mfa_obrigatoria_papeis), FALSE),
+			(SELECT COUNT(*) FROM codigos_recuperacao c WHERE c.user_id = u.id AND c.usado_em IS NULL)
+		FROM users u LEFT JOIN empresas e ON e.id = u.empresa_id
+		WHERE u.id = $1`,
+		userID,
+	).Scan(&s.TOTPAtivo, &s.Obrigatoria, &s.CodigosRecuperacaoRestantes)
+	return s, err
+}
+
+// concluirLogin responde ao login com senha: o token de acesso, ou um desafio quando o
+// usuário tem TOTP ou é obrigado a cadastrá-lo. A sequência de falhas do email só é
+// encerrada com o login completo; enquanto falta o segundo fator ela continua, para que
+// entrar de novo com a senha não zere os códigos errados.
+func concluirLogin(w http.ResponseWriter, r *http.Request, userID int64, tentativa tentativaLogin) {
+	resposta, err := prepararLogin(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing login", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	if resposta.Token != "" {
+		err = limparFalhasLogin(r.Context(), tentativa)
+	} else {
+		err = liberarTentativaLogin(r.Context(), tentativa)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error clearing failed logins", "error", err)
+	}
+	respondWithJSON(w, http.StatusOK, resposta)
+}
+
+// prepararLogin monta a resposta de um login com o primeiro fator já conferido, seja pela
+// senha ou pelo provedor de identidade: o token de acesso, ou um desafio para o segundo fator.
+func prepararLogin(ctx context.Context, userID int64) (RespostaLogin, error) {
+	status, err := carregarStatusMFA(ctx, database.DB, userID)
+	if err != nil {
+		return RespostaLogin{}, err
+	}
+	versao, err := VersaoTokenDoUsuario(ctx, userID)
+	if err != nil {
+		return RespostaLogin{}, err
+	}
+
+	var finalidade string
+	switch {
+	case status.TOTPAtivo:
+		finalidade = auth.PurposeMFA
+	case status.Obrigatoria:
+		finalidade = auth.PurposeMFAEnrollment
+	default:
+		tokenString, _, err := Tokens.Issue(userID, versao)
+		return RespostaLogin{Token: tokenString}, err
+	}
+
+	desafio, claims, err := Tokens.IssuePurpose(userID, versao, finalidade, duracaoDesafioMFA)
+	if err != nil {
+		return RespostaLogin{}, err
+	}
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM mfa_desafios WHERE expira_em < NOW()"); err != nil {
+		return RespostaLogin{}, err
+	}
+	_, err = database.DB.ExecContext(ctx,
+		"INSERT INTO mfa_desafios (jti, user_id, expira_em) VALUES ($1, $2, $3)",
+		claims.ID, userID, claims.ExpiresAt.Time,
+	)
+	if err != nil {
+		return RespostaLogin{}, err
+	}
+
+	expiraEm := claims.ExpiresAt.Time
+	return RespostaLogin{
+		MFARequerido:         finalidade == auth.PurposeMFA,
+		MFACadastroRequerido: finalidade == auth.PurposeMFAEnrollment,
+		Desafio:              desafio,
+		ExpiraEm:             &expiraEm,
+	}, nil
+}
+
+// usarDesafio valida o desafio e registra uma tentativa. Retorna nil se o desafio for
+// inválido, já tiver sido concluído ou esgotado as tentativas.
+func usarDesafio(ctx context.Context, tokenString, finalidade string, contarTentativa bool) (*auth.Claims, error) {
+	claims, err := Tokens.ValidatePurpose(tokenString, finalidade)
+	if err != nil {
+		return nil, nil
+	}
+
+	incremento := 0
+	if contarTentativa {
+		incremento = 1
+	}
+	res, err := database.DB.ExecContext(ctx,
+		`UPDATE mfa_desafios SET tentativas = tentativas + $1
+		WHERE jti = $2 AND user_id = $3 AND expira_em > NOW() AND tentativas < $4`,
+		incremento, claims.ID, claims.UserID, maxTentativasDesafioMFA,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// encerrarDesafio descarta o desafio e a sequência de falhas de login depois do login
+// concluído e emite o token de acesso.
+func encerrarDesafio(ctx context.Context, desafio *auth.Claims) (string, error) {
+	if _, err := database.DB.ExecContext(ctx, "DELETE FROM mfa_desafios WHERE jti = $1", desafio.ID); err != nil {
+		return "", err
+	}
+	_, err := database.DB.ExecContext(ctx,
+		"DELETE FROM bloqueios_login WHERE email = (SELECT LOWER(email) FROM users WHERE id = $1)",
+		desafio.UserID,
+	)
+	if err != nil {
+		return "", err
+	}
+	// The challenge carries the version read at login; a password reset since then
+	// deleted the challenge, so it is still current
+	tokenString, _, err := Tokens.Issue(desafio.UserID, desafio.Version)
+	return tokenString, err
+}
+
+// iniciarCadastroTOTP gera um novo segredo pendente para o usuário. O TOTP só passa a ser
+// exigido depois de confirmarCadastroTOTP.
+func iniciarCadastroTOTP(ctx context.Context, userID int64) (CadastroTOTP, error) {
+	var email string
+	var ativo bool
+	if err := database.DB.QueryRowContext(ctx, "SELECT email, totp_ativo FROM users WHERE id = $1", userID).Scan(&email, &ativo); err != nil {
+		return CadastroTOTP{}, err
+	}
+	if ativo {
+		return CadastroTOTP{}, errMFAJaAtiva
+	}
+
+	segredo, err := totp.GenerateSecret()
+	if err != nil {
+		return CadastroTOTP{}, err
+	}
+	if _, err := database.DB.ExecContext(ctx, "UPDATE users SET totp_segredo = $1, totp_ultimo_passo = 0 WHERE id = $2 AND NOT totp_ativo", segredo, userID); err != nil {
+		return CadastroTOTP{}, err
+	}
+	return CadastroTOTP{Segredo: segredo, URI: totp.ProvisioningURI(emissorTOTP, email, segredo)}, nil
+}
+
+// confirmarCadastroTOTP ativa o TOTP se o código gerado pelo aplicativo estiver correto e
+// retorna os códigos de recuperação.
+func confirmarCadastroTOTP(ctx context.Context, userID int64, codigo string) ([]string, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var segredo sql.NullString
+	var ativo bool
+	var ultimoPasso int64
+	err = tx.QueryRowContext(ctx, "SELECT totp_segredo, totp_ativo, totp_ultimo_passo FROM users WHERE id = $1 FOR UPDATE", userID).
+		Scan(&segredo, &ativo, &ultimoPasso)
+	if err != nil {
+		return nil, err
+	}
+	if ativo {
+		return nil, errMFAJaAtiva
+	}
+	if !segredo.Valid {
+		return nil, errCadastroNaoIniciado
+	}
+
+	passo, ok := totp.Validate(segredo.String, codigo, time.Now(), ultimoPasso)
+	if !ok {
+		return nil, errCodigoInvalido
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_ativo = TRUE, totp_ultimo_passo = $1 WHERE id = $2", passo, userID); err != nil {
+		return nil, err
+	}
+	codigos, err := gerarCodigosRecuperacao(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codigos, tx.Commit()
+}
+
+// verificarSegundoFator aceita um código TOTP ainda não usado ou um código de recuperação,
+// que é consumido.
+func verificarSegundoFator(ctx context.Context, userID int64, codigo string) error {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var segredo sql.NullString
+	var ativo bool
+	var ultimoPasso int64
+	err = tx.QueryRowContext(ctx, "SELECT totp_segredo, totp_ativo, totp_ultimo_passo FROM users WHERE id = $1 FOR UPDATE", userID).
+		Scan(&segredo, &ativo, &ultimoPasso)
+	if err != nil {
+		return err
+	}
+	if !ativo || !segredo.Valid {
+		return errCodigoInvalido
+	}
+
+	if passo, ok := totp.Validate(segredo.String, codigo, time.Now(), ultimoPasso); ok {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET totp_ultimo_passo = $1 WHERE id = $2", passo, userID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE codigos_recuperacao SET usado_em = NOW() WHERE user_id = $1 AND codigo_hash = $2 AND usado_em IS NULL",
+		userID, hashCodigoRecuperacao(codigo),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errCodigoInvalido
+	}
+	return tx.Commit()
+}
+
+// gerarCodigosRecuperacao substitui os códigos de recuperação do usuário. Só o hash é
+// gravado; os códigos têm entropia suficiente para dispensar um hash lento.
+func gerarCodigosRecuperacao(ctx context.Context, tx *sql.Tx, userID int64) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM codigos_recuperacao WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	codificacao := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codigos := make([]string, quantidadeCodigosRecuperacao)
+	for i := range codigos {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(codificacao.EncodeToString(b))[:10]
+		codigos[i] = c[:5] + "-" + c[5:]
+		if _, err := tx.ExecContext(ctx, "INSERT INTO codigos_recuperacao (user_id, codigo_hash) VALUES ($1, $2)", userID, hashCodigoRecuperacao(codigos[i])); err != nil {
+			return nil, err
+		}
+	}
+	return codigos, nil
+}
+
+// hashCodigoRecuperacao ignora maiúsculas, espaços e hífens digitados pelo usuário.
+func hashCodigoRecuperacao(codigo string) string {
+	normalizado := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(codigo)))
+	sum := sha256.Sum256([]byte(normalizado))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerificarLoginMFA godoc
+// @Summary      Conclui o login com o segundo fator
+// @Description  Recebe o desafio devolvido por /login e um código do aplicativo autenticador ou de recuperação, e retorna o token de acesso. Cada desafio aceita poucas tentativas, e os códigos errados contam na mesma sequência de falhas da senha: a conta passa pela mesma espera crescente e pelo mesmo bloqueio temporário.
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      CodigoMFAPayload  true  "Desafio e código"
+// @Success      200      {object}  RespostaLogin
+// @Failure      400      {object}  apierror.Problem  "Invalid request body"
+// @Failure      401      {object}  apierror.Problem  "Invalid code"
+// @Failure      423      {object}  apierror.Problem  "Account temporarily locked"
+// @Failure      429      {object}  apierror.Problem  "Too many login attempts"
+// @Router       /login/mfa [post]
+func VerificarLoginMFA(w http.ResponseWriter, r *http.Request) {
+	var payload CodigoMFAPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(true, true); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	desafio, err := usarDesafio(r.Context(), payload.Desafio, auth.PurposeMFA, true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading MFA challenge", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if desafio == nil {
+		respondWithProblem(w, r, http.StatusUnauthorized, apierror.CodeMFAChallengeInvalid, "Invalid or expired MFA challenge")
+		return
+	}
+
+	// Codes are counted per account, like passwords, since a new challenge only costs the
+	// attacker who already has the password another login
+	var email string
+	err = database.DB.QueryRowContext(r.Context(), "SELECT LOWER(email) FROM users WHERE id = $1", desafio.UserID).Scan(&email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	ip := ipCliente(r)
+	tentativa, status, espera, err := reservarTentativaLogin(r.Context(), email, ip)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login attempts", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if status != 0 {
+		recusarTentativaLogin(w, r, status, espera)
+		return
+	}
+
+	if err := verificarSegundoFator(r.Context(), desafio.UserID, payload.Codigo); err != nil {
+		if err == errCodigoInvalido {
+			if err := registrarFalhaLogin(r.Context(), tentativa, &desafio.UserID); err != nil {
+				slog.ErrorContext(r.Context(), "Error recording failed login", "error", err)
+				respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+			metrics.LoginFailures.WithLabelValues(metrics.LoginInvalidMFACode).Inc()
+			respondWithProblem(w, r, http.StatusUnauthorized, apierror.CodeMFACodeInvalid, "Invalid code")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error verifying second factor", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if err := limparFalhasLogin(r.Context(), tentativa); err != nil {
+		slog.ErrorContext(r.Context(), "Error clearing failed logins", "error", err)
+	}
+	tokenString, err := encerrarDesafio(r.Context(), desafio)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing MFA login", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, RespostaLogin{Token: tokenString})
+}
+
+// IniciarCadastroMFALogin godoc
+// @Summary      Inicia o cadastro obrigatório do TOTP durante o login
+// @Description  Para usuários cuja empresa exige MFA e que ainda não cadastraram o TOTP. Recebe o desafio devolvido por /login e retorna o segredo e a URI otpauth:// para o QR code.
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      CodigoMFAPayload  true  "Desafio (o código é ignorado)"
+// @Success      200      {object}  CadastroTOTP
+// @Failure      401      {object}  apierror.Problem  "Invalid or expired MFA challenge"
+// @Router       /login/mfa/cadastro [post]
+func IniciarCadastroMFALogin(w http.ResponseWriter, r *http.Request) {
+	var payload CodigoMFAPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(true, false); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	desafio, err := usarDesafio(r.Context(), payload.Desafio, auth.PurposeMFAEnrollment, false)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading MFA challenge", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if desafio == nil {
+		respondWithProblem(w, r, http.StatusUnauthorized, apierror.CodeMFAChallengeInvalid, "Invalid or expired MFA challenge")
+		return
+	}
+	responderInicioCadastro(w, r, desafio.UserID)
+}
+
+// ConfirmarCadastroMFALogin godoc
+// @Summary      Conclui o cadastro obrigatório do TOTP durante o login
+// @Description  Confirma o TOTP com um código do aplicativo e retorna o token de acesso e os códigos de recuperação, exibidos só desta vez.
+// @Tags         Authentication
+// @Accept       json
+// @Produce      json
+// @Param        payload  body      CodigoMFAPayload  true  "Desafio e código"
+// @Success      200      {object}  RespostaLogin
+// @Failure      401      {object}  apierror.Problem  "Invalid code"
+// @Router       /login/mfa/cadastro/confirmar [post]
+func ConfirmarCadastroMFALogin(w http.ResponseWriter, r *http.Request) {
+	var payload CodigoMFAPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(true, true); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	desafio, err := usarDesafio(r.Context(), payload.Desafio, auth.PurposeMFAEnrollment, true)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading MFA challenge", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if desafio == nil {
+		respondWithProblem(w, r, http.StatusUnauthorized, apierror.CodeMFAChallengeInvalid, "Invalid or expired MFA challenge")
+		return
+	}
+
+	codigos, ok := confirmarCadastro(w, r, desafio.UserID, payload.Codigo)
+	if !ok {
+		return
+	}
+	tokenString, err := encerrarDesafio(r.Context(), desafio)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing MFA login", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create token")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, RespostaLogin{Token: tokenString, CodigosRecuperacao: codigos})
+}
+
+// ObterStatusMFA godoc
+// @Summary      Consulta o segundo fator do usuário
+// @Tags         MFA
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  StatusMFA
+// @Router       /mfa [get]
+func ObterStatusMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	status, err := carregarStatusMFA(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading MFA status", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve MFA status")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+// IniciarCadastroTOTP godoc
+// @Summary      Inicia o cadastro do TOTP
+// @Description  Gera um segredo e a URI otpauth:// para o QR code. O TOTP só passa a ser exigido depois da confirmação.
+// @Tags         MFA
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  CadastroTOTP
+// @Failure      409  {object}  apierror.Problem  "TOTP is already enabled"
+// @Router       /mfa/totp [post]
+func IniciarCadastroTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+	responderInicioCadastro(w, r, userID)
+}
+
+// ConfirmarCadastroTOTP godoc
+// @Summary      Confirma o cadastro do TOTP
+// @Description  Ativa o TOTP com um código do aplicativo e retorna os códigos de recuperação, exibidos só desta vez.
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        payload  body      CodigoMFAPayload  true  "Código do aplicativo"
+// @Success      200      {object}  CodigosRecuperacao
+// @Failure      400      {object}  apierror.Problem  "Invalid code"
+// @Failure      409      {object}  apierror.Problem  "TOTP is already enabled"
+// @Router       /mfa/totp/confirmar [post]
+func ConfirmarCadastroTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload CodigoMFAPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(false, true); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	codigos, ok := confirmarCadastro(w, r, userID, payload.Codigo)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, CodigosRecuperacao{Codigos: codigos})
+}
+
+// DesativarTOTP godoc
+// @Summary      Desativa o TOTP
+// @Description  Exige um código válido. Não é permitido quando a empresa exige MFA para o papel do usuário. Os códigos errados contam na sequência de falhas do login: a conta passa pela mesma espera crescente e pelo mesmo bloqueio temporário.
+// @Tags         MFA
+// @Accept       json
+// @Security     ApiKeyAuth
+// @Param        payload  body      CodigoMFAPayload  true  "Código do aplicativo ou de recuperação"
+// @Success      204
+// @Failure      400      {object}  apierror.Problem  "Invalid code"
+// @Failure      403      {object}  apierror.Problem  "MFA is mandatory for this user"
+// @Failure      423      {object}  apierror.Problem  "Account temporarily locked"
+// @Failure      429      {object}  apierror.Problem  "Too many login attempts"
+// @Router       /mfa/totp [delete]
+func DesativarTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload CodigoMFAPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(false, true); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	status, err := carregarStatusMFA(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading MFA status", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to disable TOTP")
+		return
+	}
+	if status.Obrigatoria {
+		respondWithProblem(w, r, http.StatusForbidden, apierror.CodeMFAEnrollmentRequired, "MFA is mandatory for this user")
+		return
+	}
+
+	if !verificarCodigo(w, r, userID, payload.Codigo) {
+		return
+	}
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err == nil {
+		defer tx.Rollback()
+		_, err = tx.ExecContext(r.Context(), "UPDATE users SET totp_ativo = FALSE, totp_segredo = NULL, totp_ultimo_passo = 0 WHERE id = $1", userID)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(r.Context(), "DELETE FROM codigos_recuperacao WHERE user_id = $1", userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error disabling TOTP", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to disable TOTP")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegerarCodigosRecuperacao godoc
+// @Summary      Gera novos códigos de recuperação
+// @Description  Exige um código válido e invalida os códigos anteriores. Os códigos errados contam na sequência de falhas do login: a conta passa pela mesma espera crescente e pelo mesmo bloqueio temporário.
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        payload  body      CodigoMFAPayload  true  "Código do aplicativo ou de recuperação"
+// @Success      200      {object}  CodigosRecuperacao
+// @Failure      400      {object}  apierror.Problem  "Invalid code"
+// @Failure      423      {object}  apierror.Problem  "Account temporarily locked"
+// @Failure      429      {object}  apierror.Problem  "Too many login attempts"
+// @Router       /mfa/codigos-recuperacao [post]
+func RegerarCodigosRecuperacao(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload CodigoMFAPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(false, true); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	if !verificarCodigo(w, r, userID, payload.Codigo) {
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	defer tx.Rollback()
+
+	codigos, err := gerarCodigosRecuperacao(r.Context(), tx, userID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating recovery codes", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, CodigosRecuperacao{Codigos: codigos})
+}
+
+func responderInicioCadastro(w http.ResponseWriter, r *http.Request, userID int64) {
+	cadastro, err := iniciarCadastroTOTP(r.Context(), userID)
+	if err == errMFAJaAtiva {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeMFAAlreadyEnabled, "TOTP is already enabled")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting TOTP enrollment", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to start TOTP enrollment")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, cadastro)
+}
+
+func confirmarCadastro(w http.ResponseWriter, r *http.Request, userID int64, codigo string) ([]string, bool) {
+	codigos, err := confirmarCadastroTOTP(r.Context(), userID, codigo)
+	switch {
+	case err == errCodigoInvalido:
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeMFACodeInvalid, "Invalid code")
+	case err == errMFAJaAtiva:
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeMFAAlreadyEnabled, "TOTP is already enabled")
+	case err == errCadastroNaoIniciado:
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeMFAEnrollmentNotStarted, "TOTP enrollment not started")
+	case err != nil:
+		slog.ErrorContext(r.Context(), "Error confirming TOTP enrollment", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to confirm TOTP enrollment")
+	default:
+		return codigos, true
+	}
+	return nil, false
+}
+
+// verificarCodigo confere o código exigido pelas rotas autenticadas que mexem no segundo
+// fator. Os códigos errados contam na sequência de falhas do login da conta, como em
+// VerificarLoginMFA, para que uma sessão roubada não possa testar todos os códigos.
+func verificarCodigo(w http.ResponseWriter, r *http.Request, userID int64, codigo string) bool {
+	var email string
+	err := database.DB.QueryRowContext(r.Context(), "SELECT LOWER(email) FROM users WHERE id = $1", userID).Scan(&email)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	tentativa, status, espera, err := reservarTentativaLogin(r.Context(), email, ipCliente(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login attempts", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	if status != 0 {
+		recusarTentativaLogin(w, r, status, espera)
+		return false
+	}
+
+	err = verificarSegundoFator(r.Context(), userID, codigo)
+	if err == errCodigoInvalido {
+		if err := registrarFalhaLogin(r.Context(), tentativa, &userID); err != nil {
+			slog.ErrorContext(r.Context(), "Error recording failed login", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+			return false
+		}
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeMFACodeInvalid, "Invalid code")
+		return false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying second factor", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+	if err := limparFalhasLogin(r.Context(), tentativa); err != nil {
+		slog.ErrorContext(r.Context(), "Error clearing failed logins", "error", err)
+	}
+	return true
+}
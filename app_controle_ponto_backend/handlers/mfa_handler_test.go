@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/middleware"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRegerarCodigosRecuperacaoCodigoErrado(t *testing.T) {
+	const (
+		userID = 5
+		email  = "maria@exemplo.com"
+	)
+	tests := []struct {
+		name string
+		// falhas é a sequência do email com esta tentativa; 0 quando a conta já está bloqueada
+		falhas int
+		status int
+		code   string
+	}{
+		{"first failure", 1, http.StatusBadRequest, apierror.CodeMFACodeInvalid},
+		{"failure reaching the limit locks the account", LimitesLogin.MaxFalhas, http.StatusBadRequest, apierror.CodeMFACodeInvalid},
+		{"locked account", 0, http.StatusLocked, apierror.CodeAccountLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT LOWER(email) FROM users WHERE id = $1")).WithArgs(userID).
+				WillReturnRows(sqlmock.NewRows([]string{"email"}).AddRow(email))
+			if tt.falhas == 0 {
+				// The code is not even checked while the account is locked
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM falhas_login")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM bloqueios_login")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta("FROM falhas_login")).WillReturnRows(sqlmock.NewRows([]string{"restante"}))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO bloqueios_login")).WillReturnRows(sqlmock.NewRows([]string{"falhas"}))
+				mock.ExpectQuery(regexp.QuoteMeta("FROM bloqueios_login WHERE email = $1")).WithArgs(email).
+					WillReturnRows(sqlmock.NewRows([]string{"falhas", "bloqueio", "desde_falha"}).AddRow(LimitesLogin.MaxFalhas, 600.0, 10.0))
+				mock.ExpectRollback()
+			} else {
+				esperarReservaLoginComFalhas(mock, email, tt.falhas)
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT totp_segredo, totp_ativo, totp_ultimo_passo FROM users")).WithArgs(userID).
+					WillReturnRows(sqlmock.NewRows([]string{"totp_segredo", "totp_ativo", "totp_ultimo_passo"}).AddRow("JBSWY3DPEHPK3PXP", true, 0))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE codigos_recuperacao SET usado_em = NOW()")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+
+				mock.ExpectBegin()
+				if tt.falhas >= LimitesLogin.MaxFalhas {
+					mock.ExpectQuery(regexp.QuoteMeta("UPDATE bloqueios_login SET bloqueado_ate")).WithArgs(email, sqlmock.AnyArg()).
+						WillReturnRows(sqlmock.NewRows([]string{"bloqueado_ate"}).AddRow(time.Now().Add(LimitesLogin.DuracaoBloqueio)))
+					mock.ExpectExec(regexp.QuoteMeta("INSERT INTO eventos_auditoria")).
+						WithArgs(AuditoriaContaBloqueada, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM falhas_login WHERE ip = $1")).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.falhas))
+				mock.ExpectCommit()
+			}
+
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, int64(userID))
+			r := httptest.NewRequest(http.MethodPost, "/api/mfa/codigos-recuperacao", strings.NewReader(`{"codigo":"abcd-efgh"}`)).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			RegerarCodigosRecuperacao(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tt.code) {
+				t.Errorf("body = %s, want code %s", rec.Body, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,808 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/oidc"
+	"controle-ponto-api/validation"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+const (
+	// duracaoSessaoOIDC é o tempo que o usuário tem para concluir o login no provedor.
+	duracaoSessaoOIDC = 10 * time.Minute
+	// duracaoVinculoOIDC é o tempo que o dono de uma conta existente tem para entrar com a
+	// senha e confirmar o vínculo com o provedor.
+	duracaoVinculoOIDC = 30 * time.Minute
+	// prefixoRegistroTXT e prefixoValorTXT formam o registro DNS que comprova a posse de um
+	// domínio: _controle-ponto.<domínio> TXT "controle-ponto-verificacao=<token>".
+	prefixoRegistroTXT = "_controle-ponto."
+	prefixoValorTXT    = "controle-ponto-verificacao="
+	// cookieStateOIDC prende o login ao navegador que o iniciou, guardando o hash do state.
+	// O prefixo __Host- exige Secure e Path=/ e impede que um subdomínio o sobrescreva.
+	cookieStateOIDC = "__Host-oidc_state"
+)
+
+// OIDC é o cliente usado no login por provedor de identidade. OIDCCallbackURL e
+// OIDCFrontendURL são definidas no main.
+var OIDC = oidc.NewClient()
+
+// OIDCCallbackURL é a URL de OIDCCallback registrada como redirect URI nos provedores.
+var OIDCCallbackURL string
+
+// OIDCFrontendURL recebe o navegador ao final do login, com #token=..., #desafio=... ou
+// #erro=... no fragmento. Vazia, OIDCCallback responde em JSON, como Login.
+var OIDCFrontendURL string
+
+// ConsultarTXT consulta os registros TXT de um nome na verificação de domínios.
+var ConsultarTXT = net.DefaultResolver.LookupTXT
+
+// ProvedorIdentidadePayload define o corpo da configuração do provedor de identidade da empresa.
+type ProvedorIdentidadePayload struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	// ClientSecret vazio mantém o atual; clientes públicos não têm secret.
+	ClientSecret string   `json:"client_secret"`
+	Dominios     []string `json:"dominios"`
+	Ativo        *bool    `json:"ativo"`
+}
+
+func (p *ProvedorIdentidadePayload) validar() error {
+	var v validation.Validator
+	p.Issuer = strings.TrimSpace(p.Issuer)
+	if err := oidc.ValidateIssuer(p.Issuer); err != nil {
+		v.Add("issuer", validation.CodeInvalid, "must be an absolute https URL without query or fragment")
+	}
+	v.Required("client_id", p.ClientID)
+	v.Check(len(p.Dominios) > 0, "dominios", validation.CodeRequired, "must not be empty")
+	for i, d := range p.Dominios {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if v.Check(d != "" && !strings.ContainsAny(d, "@/ ") && strings.Contains(d, "."), fmt.Sprintf("dominios[%d]", i), validation.CodeInvalid, "must be an email domain, such as empresa.com.br") {
+			p.Dominios[i] = d
+		}
+	}
+	return v.Err()
+}
+
+// provedorLogin é o provedor carregado para o fluxo de login, com o client secret.
+// Dominios traz só os domínios já verificados.
+type provedorLogin struct {
+	ID        int64
+	EmpresaID int64
+	Dominios  []string
+	oidc.Provider
+}
+
+func carregarProvedorLogin(ctx context.Context, q queryer, where string, arg interface{}) (provedorLogin, error) {
+	var p provedorLogin
+	err := q.QueryRowContext(ctx,
+		`SELECT p.id, p.empresa_id, p.issuer, p.client_id, p.client_secret,
+			ARRAY(SELECT d.dominio FROM dominios_verificacao d
+				WHERE d.empresa_id = p.empresa_id AND d.dominio = ANY(p.dominios) AND d.verificado_em IS NOT NULL)
+		FROM provedores_identidade p WHERE p.ativo AND `+where,
+		arg,
+	).Scan(&p.ID, &p.EmpresaID, &p.Issuer, &p.ClientID, &p.ClientSecret, pq.Array(&p.Dominios))
+	return p, err
+}
+
+// dominioEmail retorna o domínio do email em minúsculas.
+func dominioEmail(email string) string {
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[i+1:]))
+}
+
+// IniciarLoginOIDC godoc
+// @Summary      Inicia o login pelo provedor de identidade da empresa
+// @Description  Localiza o provedor OpenID Connect pelo domínio verificado do email (ou pela empresa) e redireciona o navegador para a autorização, com PKCE (S256), state e nonce. O hash do state fica no cookie __Host-oidc_state, exigido no callback.
+// @Tags         Authentication
+// @Param        email       query     string  false  "Email do usuário; o domínio escolhe o provedor"
+// @Param        empresa_id  query     int     false  "Empresa cujo provedor será usado"
+// @Success      302
+// @Failure      400  {object}  apierror.Problem  "email or empresa_id is required"
+// @Failure      404  {object}  apierror.Problem  "No identity provider configured"
+// @Failure      502  {object}  apierror.Problem  "Identity provider unavailable"
+// @Router       /auth/oidc/iniciar [get]
+func IniciarLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	var provedor provedorLogin
+	var err error
+	if email := r.URL.Query().Get("email"); email != "" {
+		provedor, err = carregarProvedorLogin(r.Context(), database.DB,
+			"$1 = ANY(p.dominios) AND p.empresa_id = (SELECT empresa_id FROM dominios_verificacao WHERE dominio = $1 AND verificado_em IS NOT NULL)",
+			dominioEmail(email),
+		)
+	} else if empresaID, convErr := strconv.ParseInt(r.URL.Query().Get("empresa_id"), 10, 64); convErr == nil {
+		provedor, err = carregarProvedorLogin(r.Context(), database.DB, "p.empresa_id = $1", empresaID)
+	} else {
+		respondWithError(w, r, http.StatusBadRequest, "email or empresa_id is required")
+		return
+	}
+	if err == sql.ErrNoRows {
+		respondWithProblem(w, r, http.StatusNotFound, apierror.CodeIdentityProviderNotConfigured, "No identity provider configured")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading identity provider", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	discovery, err := OIDC.Discover(r.Context(), provedor.Issuer)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error discovering identity provider", "issuer", provedor.Issuer, "error", err)
+		respondWithProblem(w, r, http.StatusBadGateway, apierror.CodeIdentityProviderUnavailable, "Identity provider unavailable")
+		return
+	}
+
+	state, errState := oidc.RandomString(32)
+	nonce, errNonce := oidc.RandomString(32)
+	verifier, challenge, errPKCE := oidc.NewPKCE()
+	if err := errors.Join(errState, errNonce, errPKCE); err != nil {
+		slog.ErrorContext(r.Context(), "Error generating OIDC parameters", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	// Abandoned logins are cleaned up here instead of by a separate job
+	if _, err := database.DB.ExecContext(r.Context(), "DELETE FROM oidc_sessoes WHERE expira_em < NOW()"); err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting expired OIDC sessions", "error", err)
+	}
+	_, err = database.DB.ExecContext(r.Context(),
+		"INSERT INTO oidc_sessoes (state, provedor_id, nonce, code_verifier, expira_em) VALUES ($1, $2, $3, $4, $5)",
+		state, provedor.ID, nonce, verifier, time.Now().Add(duracaoSessaoOIDC),
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving OIDC session", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieStateOIDC,
+		Value:    hashToken(state),
+		Path:     "/",
+		MaxAge:   int(duracaoSessaoOIDC / time.Second),
+		Secure:   true,
+		HttpOnly: true,
+		// Lax still sends it on the top-level redirect back from the provider
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, oidc.AuthCodeURL(discovery, provedor.Provider, OIDCCallbackURL, state, nonce, challenge), http.StatusFound)
+}
+
+// stateDoNavegador diz se o state do callback é o do login iniciado por este navegador.
+// Sem isso, quem iniciasse um login com a própria conta poderia levar outra pessoa a
+// concluí-lo e entrar na conta dele (login CSRF). O cookie é apagado em qualquer caso.
+func stateDoNavegador(w http.ResponseWriter, r *http.Request, state string) bool {
+	http.SetCookie(w, &http.Cookie{Name: cookieStateOIDC, Value: "", Path: "/", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	cookie, err := r.Cookie(cookieStateOIDC)
+	if err != nil || state == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(hashToken(state))) == 1
+}
+
+// OIDCCallback godoc
+// @Summary      Conclui o login pelo provedor de identidade
+// @Description  Recebe o código de autorização (exigindo o cookie __Host-oidc_state do navegador que iniciou o login), troca-o pelo ID token, valida o token e cria o usuário no primeiro acesso (pelo email verificado, num domínio verificado da empresa do provedor). Como em /login, quem tem TOTP ou é obrigado a cadastrá-lo recebe um desafio em vez do token. Redireciona ao frontend com a resposta do login no fragmento, ou a devolve em JSON se nenhum frontend estiver configurado. Uma conta existente sem empresa não é vinculada aqui: a resposta traz um token de vínculo (erro=vinculo_necessario&vinculo=... no fragmento, ou 202 em JSON) que o dono confirma em /auth/oidc/vincular depois de entrar com a senha.
+// @Tags         Authentication
+// @Produce      json
+// @Param        code   query     string  true  "Código de autorização"
+// @Param        state  query     string  true  "State gerado em /auth/oidc/iniciar"
+// @Success      200    {object}  RespostaLogin
+// @Success      202    {object}  map[string]string  "Account link required"
+// @Success      302
+// @Failure      400    {object}  apierror.Problem  "Invalid or expired login session"
+// @Failure      401    {object}  apierror.Problem  "Identity provider login failed"
+// @Failure      403    {object}  apierror.Problem  "User is not allowed to sign in with this provider"
+// @Router       /auth/oidc/callback [get]
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if !stateDoNavegador(w, r, q.Get("state")) {
+		falharLoginOIDC(w, r, http.StatusBadRequest, "sessao_invalida", "Invalid or expired login session")
+		return
+	}
+
+	// The session is single use: deleting it first stops a replayed callback
+	var provedorID int64
+	var nonce, verifier string
+	err := database.DB.QueryRowContext(r.Context(),
+		"DELETE FROM oidc_sessoes WHERE state = $1 AND expira_em > NOW() RETURNING provedor_id, nonce, code_verifier",
+		q.Get("state"),
+	).Scan(&provedorID, &nonce, &verifier)
+	if err == sql.ErrNoRows {
+		falharLoginOIDC(w, r, http.StatusBadRequest, "sessao_invalida", "Invalid or expired login session")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading OIDC session", "error", err)
+		falharLoginOIDC(w, r, http.StatusInternalServerError, "erro_interno", "Failed to complete login")
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		slog.WarnContext(r.Context(), "Identity provider returned error", "error", e, "error_description", q.Get("error_description"))
+		falharLoginOIDC(w, r, http.StatusUnauthorized, "login_recusado", "Identity provider login failed")
+		return
+	}
+
+	provedor, err := carregarProvedorLogin(r.Context(), database.DB, "p.id = $1", provedorID)
+	if err == sql.ErrNoRows {
+		falharLoginOIDC(w, r, http.StatusBadRequest, "provedor_desativado", "Identity provider is no longer active")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading identity provider", "error", err)
+		falharLoginOIDC(w, r, http.StatusInternalServerError, "erro_interno", "Failed to complete login")
+		return
+	}
+
+	claims, err := OIDC.Exchange(r.Context(), provedor.Provider, OIDCCallbackURL, q.Get("code"), verifier, nonce)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing login", "issuer", provedor.Issuer, "error", err)
+		falharLoginOIDC(w, r, http.StatusUnauthorized, "login_recusado", "Identity provider login failed")
+		return
+	}
+
+	// The provider is only trusted for the domains the empresa proved it owns, and only for
+	// emails it says it verified, so it can't sign in users of other empresas
+	email := normalizarEmail(claims.Email)
+	emailVerificado := claims.EmailVerified != nil && *claims.EmailVerified
+	if email == "" || !emailVerificado || !slices.Contains(provedor.Dominios, dominioEmail(email)) {
+		falharLoginOIDC(w, r, http.StatusForbidden, "usuario_nao_permitido", "User is not allowed to sign in with this provider")
+		return
+	}
+
+	userID, err := provisionarUsuarioOIDC(r.Context(), provedor, email, claims.Name)
+	if err == errVinculoNecessario {
+		pedirVinculoOIDC(w, r, userID, provedor.EmpresaID)
+		return
+	}
+	if err == errUsuarioDeOutraEmpresa {
+		falharLoginOIDC(w, r, http.StatusForbidden, "usuario_nao_permitido", "User is not allowed to sign in with this provider")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error provisioning OIDC user", "error", err)
+		falharLoginOIDC(w, r, http.StatusInternalServerError, "erro_interno", "Failed to complete login")
+		return
+	}
+
+	// The provider only stands in for the password: a second factor is still required
+	resposta, err := prepararLogin(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error completing login", "error", err)
+		falharLoginOIDC(w, r, http.StatusInternalServerError, "erro_interno", "Failed to create token")
+		return
+	}
+
+	if OIDCFrontendURL == "" {
+		respondWithJSON(w, http.StatusOK, resposta)
+		return
+	}
+	// The fragment isn't sent to servers, so the token stays out of access logs
+	http.Redirect(w, r, OIDCFrontendURL+"#"+fragmentoLogin(resposta).Encode(), http.StatusFound)
+}
+
+// fragmentoLogin leva a RespostaLogin ao frontend: token=..., ou desafio=... com
+// mfa_requerido ou mfa_cadastro_requerido, para seguir em /login/mfa ou /login/mfa/cadastro.
+func fragmentoLogin(resposta RespostaLogin) url.Values {
+	if resposta.Token != "" {
+		return url.Values{"token": {resposta.Token}}
+	}
+	v := url.Values{"desafio": {resposta.Desafio}}
+	if resposta.MFARequerido {
+		v.Set("mfa_requerido", "true")
+	}
+	if resposta.MFACadastroRequerido {
+		v.Set("mfa_cadastro_requerido", "true")
+	}
+	if resposta.ExpiraEm != nil {
+		v.Set("expira_em", resposta.ExpiraEm.UTC().Format(time.RFC3339))
+	}
+	return v
+}
+
+// falharLoginOIDC devolve o navegador ao frontend com o código do erro, ou responde o erro
+// se nenhum frontend estiver configurado.
+func falharLoginOIDC(w http.ResponseWriter, r *http.Request, code int, erro, message string) {
+	if OIDCFrontendURL == "" {
+		respondWithProblem(w, r, code, erro, message)
+		return
+	}
+	http.Redirect(w, r, OIDCFrontendURL+"#"+url.Values{"erro": {erro}}.Encode(), http.StatusFound)
+}
+
+// pedirVinculoOIDC cria o token de vínculo da conta userID com a empresa e o entrega ao
+// navegador, que o confirma em VincularContaOIDC depois de entrar com a senha.
+func pedirVinculoOIDC(w http.ResponseWriter, r *http.Request, userID, empresaID int64) {
+	token, err := oidc.RandomString(32)
+	if err == nil {
+		// Abandoned links are cleaned up here instead of by a separate job
+		if _, err := database.DB.ExecContext(r.Context(), "DELETE FROM oidc_vinculos WHERE expira_em < NOW()"); err != nil {
+			slog.ErrorContext(r.Context(), "Error deleting expired OIDC account links", "error", err)
+		}
+		_, err = database.DB.ExecContext(r.Context(),
+			"INSERT INTO oidc_vinculos (token_hash, user_id, empresa_id, expira_em) VALUES ($1, $2, $3, $4)",
+			hashToken(token), userID, empresaID, time.Now().Add(duracaoVinculoOIDC),
+		)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating OIDC account link", "error", err)
+		falharLoginOIDC(w, r, http.StatusInternalServerError, "erro_interno", "Failed to complete login")
+		return
+	}
+
+	if OIDCFrontendURL == "" {
+		respondWithJSON(w, http.StatusAccepted, map[string]string{"erro": "vinculo_necessario", "vinculo": token})
+		return
+	}
+	http.Redirect(w, r, OIDCFrontendURL+"#"+url.Values{"erro": {"vinculo_necessario"}, "vinculo": {token}}.Encode(), http.StatusFound)
+}
+
+var (
+	errUsuarioDeOutraEmpresa = errors.New("user belongs to another empresa")
+	errVinculoNecessario     = errors.New("existing account must be linked by its owner")
+)
+
+// provisionarUsuarioOIDC retorna o usuário com o email, criando-o na empresa do provedor no
+// primeiro acesso. Usuários criados assim não têm senha local e só entram pelo provedor. Um
+// usuário de outra empresa é recusado; um sem empresa retorna errVinculoNecessario com o
+// ID, pois só o dono da conta, entrando com a senha, pode vinculá-la ao provedor.
+func provisionarUsuarioOIDC(ctx context.Context, provedor provedorLogin, email, nome string) (int64, error) {
+	if nome == "" {
+		nome = email
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var empresaID sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT id, empresa_id FROM users WHERE LOWER(email) = $1 FOR UPDATE", email).Scan(&userID, &empresaID)
+	switch {
+	case err == sql.ErrNoRows:
+		// ON CONFLICT covers a concurrent first login with the same email
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO users (nome, email, password_hash, empresa_id, email_verificado_em) VALUES ($1, $2, '', $3, NOW())
+			ON CONFLICT ((LOWER(email))) DO UPDATE SET email = EXCLUDED.email
+			RETURNING id, empresa_id`,
+			nome, email, provedor.EmpresaID,
+		).Scan(&userID, &empresaID)
+		if err != nil {
+			return 0, err
+		}
+	case err != nil:
+		return 0, err
+	}
+
+	if empresaID.Valid && empresaID.Int64 != provedor.EmpresaID {
+		return 0, errUsuarioDeOutraEmpresa
+	}
+	if !empresaID.Valid {
+		return userID, errVinculoNecessario
+	}
+	// The provider vouches for the email, which counts as verifying it
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET email_verificado_em = NOW() WHERE id = $1 AND email_verificado_em IS NULL", userID); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// VincularContaOIDC godoc
+// @Summary      Vincula a conta ao provedor de identidade da empresa
+// @Description  Confirma o vínculo pedido por /auth/oidc/callback para uma conta existente sem empresa. Exige a sessão do próprio dono da conta, obtida entrando com a senha; a conta passa a pertencer à empresa do provedor e mantém a senha local.
+// @Tags         Authentication
+// @Accept       json
+// @Security     ApiKeyAuth
+// @Param        vinculo  body  TokenPayload  true  "Token de vínculo recebido no callback"
+// @Success      204
+// @Failure      400  {object}  apierror.Problem  "Invalid or expired link token"
+// @Failure      409  {object}  apierror.Problem  "Account already belongs to an empresa"
+// @Router       /auth/oidc/vincular [post]
+func VincularContaOIDC(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload TokenPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to link account")
+		return
+	}
+	defer tx.Rollback()
+
+	// Only the account the link was issued for can use it
+	var empresaID int64
+	err = tx.QueryRowContext(r.Context(),
+		"DELETE FROM oidc_vinculos WHERE token_hash = $1 AND user_id = $2 AND expira_em > NOW() RETURNING empresa_id",
+		hashToken(payload.Token), userID,
+	).Scan(&empresaID)
+	if err == sql.ErrNoRows {
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeVerificationTokenInvalid, "Invalid or expired link token")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading OIDC account link", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to link account")
+		return
+	}
+
+	res, err := tx.ExecContext(r.Context(),
+		"UPDATE users SET empresa_id = $1, email_verificado_em = COALESCE(email_verificado_em, NOW()) WHERE id = $2 AND empresa_id IS NULL",
+		empresaID, userID,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error linking account", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to link account")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithError(w, r, http.StatusConflict, "Account already belongs to an empresa")
+		return
+	}
+
+	err = registrarAuditoria(r.Context(), tx, EventoAuditoria{
+		Tipo:   AuditoriaContaVinculadaOIDC,
+		UserID: &userID,
+		AtorID: &userID,
+		IP:     ipCliente(r),
+		Dados:  map[string]int64{"empresa_id": empresaID},
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error linking account", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to link account")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ObterProvedorIdentidade godoc
+// @Summary      Consulta o provedor de identidade da empresa
+// @Description  Retorna a configuração OpenID Connect da empresa, sem o client secret. Restrito a administradores.
+// @Tags         Empresa
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.ProvedorIdentidade
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "No identity provider configured"
+// @Router       /empresa/provedor-identidade [get]
+func ObterProvedorIdentidade(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdminProvedor(w, r)
+	if !ok {
+		return
+	}
+
+	provedor, err := carregarProvedorIdentidade(r.Context(), solicitante.EmpresaID.Int64)
+	if err == sql.ErrNoRows {
+		respondWithProblem(w, r, http.StatusNotFound, apierror.CodeIdentityProviderNotConfigured, "No identity provider configured")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading identity provider", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve identity provider")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, provedor)
+}
+
+// ConfigurarProvedorIdentidade godoc
+// @Summary      Configura o provedor de identidade da empresa
+// @Description  Cria ou substitui a configuração OpenID Connect da empresa. O issuer precisa responder ao discovery; os domínios de email não podem ter sido verificados por outra empresa. Cada domínio novo recebe um desafio DNS TXT e só passa a valer no login depois de verificado em /empresa/provedor-identidade/dominios/{dominio}/verificar. Restrito a administradores.
+// @Tags         Empresa
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        provedor  body      ProvedorIdentidadePayload  true  "Configuração do provedor"
+// @Success      200       {object}  models.ProvedorIdentidade
+// @Failure      400       {object}  apierror.Problem  "Invalid request body"
+// @Failure      403       {object}  apierror.Problem  "Permission denied"
+// @Failure      409       {object}  apierror.Problem  "Email domain already used by another empresa"
+// @Router       /empresa/provedor-identidade [put]
+func ConfigurarProvedorIdentidade(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdminProvedor(w, r)
+	if !ok {
+		return
+	}
+
+	var payload ProvedorIdentidadePayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	ativo := payload.Ativo == nil || *payload.Ativo
+
+	var emUso bool
+	err := database.DB.QueryRowContext(r.Context(),
+		"SELECT EXISTS(SELECT 1 FROM dominios_verificacao WHERE dominio = ANY($1) AND empresa_id <> $2 AND verificado_em IS NOT NULL)",
+		pq.Array(payload.Dominios), solicitante.EmpresaID.Int64,
+	).Scan(&emUso)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking identity provider domains", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to save identity provider")
+		return
+	}
+	if emUso {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeEmailDomainTaken, "Email domain already used by another empresa")
+		return
+	}
+
+	// Catch a wrong issuer now rather than on the first employee login
+	if _, err := OIDC.Discover(r.Context(), payload.Issuer); err != nil {
+		slog.WarnContext(r.Context(), "Error discovering identity provider", "issuer", payload.Issuer, "error", err)
+		respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeIdentityProviderDiscoveryFailed, "Identity provider discovery failed")
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to save identity provider")
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(r.Context(),
+		`INSERT INTO provedores_identidade (empresa_id, issuer, client_id, client_secret, dominios, ativo)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (empresa_id) DO UPDATE SET issuer = EXCLUDED.issuer, client_id = EXCLUDED.client_id,
+			client_secret = COALESCE(NULLIF(EXCLUDED.client_secret, ''), provedores_identidade.client_secret),
+			dominios = EXCLUDED.dominios, ativo = EXCLUDED.ativo, atualizado_em = NOW()`,
+		solicitante.EmpresaID.Int64, payload.Issuer, strings.TrimSpace(payload.ClientID), payload.ClientSecret,
+		pq.Array(payload.Dominios), ativo,
+	)
+	if err == nil {
+		err = salvarDesafiosDominio(r.Context(), tx, solicitante.EmpresaID.Int64, payload.Dominios)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving identity provider", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to save identity provider")
+		return
+	}
+
+	provedor, err := carregarProvedorIdentidade(r.Context(), solicitante.EmpresaID.Int64)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading identity provider", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve identity provider")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, provedor)
+}
+
+// salvarDesafiosDominio cria o desafio DNS dos domínios novos e descarta o dos domínios que
+// saíram da configuração, liberando-os para outra empresa. Domínios mantidos conservam o
+// token e a verificação.
+func salvarDesafiosDominio(ctx context.Context, tx *sql.Tx, empresaID int64, dominios []string) error {
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM dominios_verificacao WHERE empresa_id = $1 AND NOT (dominio = ANY($2))",
+		empresaID, pq.Array(dominios),
+	)
+	if err != nil {
+		return err
+	}
+	for _, d := range dominios {
+		token, err := oidc.RandomString(24)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO dominios_verificacao (empresa_id, dominio, token) VALUES ($1, $2, $3) ON CONFLICT (empresa_id, dominio) DO NOTHING",
+			empresaID, d, token,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerificarDominioProvedor godoc
+// @Summary      Verifica a posse de um domínio do provedor de identidade
+// @Description  Consulta o registro DNS TXT _controle-ponto.{dominio} e, se ele trouxer o valor do desafio do domínio, marca o domínio como verificado. Só domínios verificados direcionam e aceitam logins pelo provedor. Restrito a administradores.
+// @Tags         Empresa
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        dominio  path      string  true  "Domínio de email configurado no provedor"
+// @Success      200      {object}  models.ProvedorIdentidade
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      404      {object}  apierror.Problem  "Domain not configured in the identity provider"
+// @Failure      409      {object}  apierror.Problem  "Email domain already used by another empresa"
+// @Failure      422      {object}  apierror.Problem  "Verification TXT record not found"
+// @Router       /empresa/provedor-identidade/dominios/{dominio}/verificar [post]
+func VerificarDominioProvedor(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdminProvedor(w, r)
+	if !ok {
+		return
+	}
+	empresaID := solicitante.EmpresaID.Int64
+	dominio := strings.ToLower(strings.TrimSpace(chi.URLParam(r, "dominio")))
+
+	var token string
+	var verificadoEm sql.NullTime
+	err := database.DB.QueryRowContext(r.Context(),
+		"SELECT token, verificado_em FROM dominios_verificacao WHERE empresa_id = $1 AND dominio = $2",
+		empresaID, dominio,
+	).Scan(&token, &verificadoEm)
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "Domain not configured in the identity provider")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading domain verification", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to verify domain")
+		return
+	}
+
+	if !verificadoEm.Valid {
+		if !registroTXTPublicado(r.Context(), dominio, token) {
+			respondWithProblem(w, r, http.StatusUnprocessableEntity, apierror.CodeDomainVerificationFailed, "Verification TXT record not found")
+			return
+		}
+		// The unique index also stops two empresas verifying the same domain concurrently
+		res, err := database.DB.ExecContext(r.Context(),
+			`UPDATE dominios_verificacao SET verificado_em = NOW()
+			WHERE empresa_id = $1 AND dominio = $2 AND NOT EXISTS (
+				SELECT 1 FROM dominios_verificacao WHERE dominio = $2 AND empresa_id <> $1 AND verificado_em IS NOT NULL)`,
+			empresaID, dominio,
+		)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving domain verification", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to verify domain")
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			respondWithProblem(w, r, http.StatusConflict, apierror.CodeEmailDomainTaken, "Email domain already used by another empresa")
+			return
+		}
+	}
+
+	provedor, err := carregarProvedorIdentidade(r.Context(), empresaID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading identity provider", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve identity provider")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, provedor)
+}
+
+// registroTXTPublicado informa se o DNS do domínio publica o valor do desafio.
+func registroTXTPublicado(ctx context.Context, dominio, token string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	registros, err := ConsultarTXT(ctx, prefixoRegistroTXT+dominio)
+	if err != nil {
+		slog.WarnContext(ctx, "Error looking up domain verification record", "dominio", dominio, "error", err)
+		return false
+	}
+	return slices.Contains(registros, prefixoValorTXT+token)
+}
+
+// RemoverProvedorIdentidade godoc
+// @Summary      Remove o provedor de identidade da empresa
+// @Description  Remove a configuração OpenID Connect da empresa. Usuários sem senha local deixam de conseguir entrar. Restrito a administradores.
+// @Tags         Empresa
+// @Security     ApiKeyAuth
+// @Success      204
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "No identity provider configured"
+// @Router       /empresa/provedor-identidade [delete]
+func RemoverProvedorIdentidade(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdminProvedor(w, r)
+	if !ok {
+		return
+	}
+
+	// The empresa's verified domains are released along with the provider
+	res, err := database.DB.ExecContext(r.Context(),
+		`WITH dominios AS (DELETE FROM dominios_verificacao WHERE empresa_id = $1)
+		DELETE FROM provedores_identidade WHERE empresa_id = $1`,
+		solicitante.EmpresaID.Int64,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting identity provider", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete identity provider")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithProblem(w, r, http.StatusNotFound, apierror.CodeIdentityProviderNotConfigured, "No identity provider configured")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// carregarAdminProvedor lê o perfil do usuário e responde 403 se ele não for administrador.
+func carregarAdminProvedor(w http.ResponseWriter, r *http.Request) (perfil, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return perfil{}, false
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to load user profile")
+		return perfil{}, false
+	}
+	if !solicitante.ehAdmin() {
+		respondWithError(w, r, http.StatusForbidden, "Only administrators can manage the identity provider")
+		return perfil{}, false
+	}
+	return solicitante, true
+}
+
+func carregarProvedorIdentidade(ctx context.Context, empresaID int64) (models.ProvedorIdentidade, error) {
+	var p models.ProvedorIdentidade
+	err := database.DB.QueryRowContext(ctx,
+		`SELECT id, empresa_id, issuer, client_id, dominios, ativo, client_secret <> '', criado_em, atualizado_em
+		FROM provedores_identidade WHERE empresa_id = $1`,
+		empresaID,
+	).Scan(&p.ID, &p.EmpresaID, &p.Issuer, &p.ClientID, pq.Array(&p.Dominios), &p.Ativo, &p.PossuiClientSecret, &p.CriadoEm, &p.AtualizadoEm)
+	if err != nil {
+		return p, err
+	}
+
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT dominio, token, verificado_em FROM dominios_verificacao WHERE empresa_id = $1 ORDER BY dominio",
+		empresaID,
+	)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	p.Verificacoes = []models.DominioVerificacao{}
+	for rows.Next() {
+		var d models.DominioVerificacao
+		var token string
+		if err := rows.Scan(&d.Dominio, &token, &d.VerificadoEm); err != nil {
+			return p, err
+		}
+		d.Nome = prefixoRegistroTXT + d.Dominio
+		d.Valor = prefixoValorTXT + token
+		p.Verificacoes = append(p.Verificacoes, d)
+	}
+	return p, rows.Err()
+}
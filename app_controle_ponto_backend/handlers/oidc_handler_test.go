@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"controle-ponto-api/oidc"
+	"controle-ponto-api/oidc/oidctest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testProvedorID = 3
+	testEmpresaID  = 7
+)
+
+// loginOIDC é um login iniciado em IniciarLoginOIDC e autorizado no provedor.
+type loginOIDC struct {
+	code, state     string
+	cookie          *http.Cookie
+	nonce, verifier *capturar
+}
+
+func configurarOIDC(t *testing.T) *oidctest.Provider {
+	t.Helper()
+	p := oidctest.NewProvider(t)
+	anterior, callback, frontend := OIDC, OIDCCallbackURL, OIDCFrontendURL
+	OIDC, OIDCCallbackURL, OIDCFrontendURL = oidc.NewClient(), "https://api.example/api/auth/oidc/callback", ""
+	t.Cleanup(func() { OIDC, OIDCCallbackURL, OIDCFrontendURL = anterior, callback, frontend })
+	return p
+}
+
+func esperarProvedor(mock sqlmock.Sqlmock, p *oidctest.Provider) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM provedores_identidade p WHERE p.ativo AND")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "empresa_id", "issuer", "client_id", "client_secret", "dominios"}).
+			AddRow(testProvedorID, testEmpresaID, p.URL, p.ClientID, "", "{empresa.com.br}"))
+}
+
+// iniciarLoginOIDC passa por IniciarLoginOIDC e pela autorização no provedor.
+func iniciarLoginOIDC(t *testing.T, mock sqlmock.Sqlmock, p *oidctest.Provider) loginOIDC {
+	t.Helper()
+	l := loginOIDC{nonce: &capturar{}, verifier: &capturar{}}
+	state := &capturar{}
+	esperarProvedor(mock, p)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM oidc_sessoes WHERE expira_em < NOW()")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO oidc_sessoes")).
+		WithArgs(state, testProvedorID, l.nonce, l.verifier, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	IniciarLoginOIDC(rec, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/iniciar?empresa_id=7", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("start status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == cookieStateOIDC {
+			l.cookie = c
+		}
+	}
+	if l.cookie == nil || !l.cookie.Secure || !l.cookie.HttpOnly || l.cookie.Path != "/" || l.cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("state cookie = %+v", l.cookie)
+	}
+	if l.cookie.Value != hashToken(state.String()) {
+		t.Fatal("state cookie doesn't hold the hash of the state")
+	}
+
+	l.code, l.state = p.Authorize(rec.Header().Get("Location"))
+	if l.state != state.String() {
+		t.Fatalf("state sent to the provider = %q, saved %q", l.state, state)
+	}
+	return l
+}
+
+func (l loginOIDC) callback(cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?"+url.Values{"code": {l.code}, "state": {l.state}}.Encode(), nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	OIDCCallback(rec, r)
+	return rec
+}
+
+func (l loginOIDC) esperarSessao(mock sqlmock.Sqlmock, p *oidctest.Provider) {
+	mock.ExpectQuery(regexp.QuoteMeta("DELETE FROM oidc_sessoes WHERE state = $1")).
+		WithArgs(l.state).
+		WillReturnRows(sqlmock.NewRows([]string{"provedor_id", "nonce", "code_verifier"}).
+			AddRow(testProvedorID, l.nonce.String(), l.verifier.String()))
+	esperarProvedor(mock, p)
+}
+
+func TestOIDCCallbackStateCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie func(loginOIDC) *http.Cookie
+	}{
+		{"no cookie", func(loginOIDC) *http.Cookie { return nil }},
+		{"cookie of another login", func(loginOIDC) *http.Cookie {
+			return &http.Cookie{Name: cookieStateOIDC, Value: hashToken("state-of-the-attacker")}
+		}},
+		{"state instead of its hash", func(l loginOIDC) *http.Cookie {
+			return &http.Cookie{Name: cookieStateOIDC, Value: l.state}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := configurarOIDC(t)
+			mock := mockDB(t)
+			l := iniciarLoginOIDC(t, mock, p)
+
+			// The session must not be consumed, nor the code redeemed
+			rec := l.callback(tt.cookie(l))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400; body %s", rec.Code, rec.Body)
+			}
+			var problema struct{ Code string }
+			json.NewDecoder(rec.Body).Decode(&problema)
+			if problema.Code != "sessao_invalida" {
+				t.Errorf("code = %q, want sessao_invalida", problema.Code)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackProvisionamento(t *testing.T) {
+	colunasUsuario := []string{"id", "empresa_id"}
+
+	tests := []struct {
+		name string
+		// email and naoVerif shape the ID token; esperar sets up what the callback runs after the exchange
+		email    string
+		naoVerif bool
+		esperar  func(sqlmock.Sqlmock)
+		status   int
+		code     string
+		userID   int64
+		desafio  bool
+	}{
+		{
+			name:  "first login creates the user in the provider's empresa",
+			email: "Ana@Empresa.com.br",
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id, empresa_id FROM users WHERE LOWER(email) = $1 FOR UPDATE")).
+					WithArgs("ana@empresa.com.br").
+					WillReturnRows(sqlmock.NewRows(colunasUsuario))
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO users")).
+					WithArgs("Ana", "ana@empresa.com.br", testEmpresaID).
+					WillReturnRows(sqlmock.NewRows(colunasUsuario).AddRow(42, testEmpresaID))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verificado_em = NOW()")).WithArgs(42).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				esperarPrepararLogin(mock, false)
+			},
+			status: http.StatusOK,
+			userID: 42,
+		},
+		{
+			name:  "existing user of the empresa signs in",
+			email: "ana@empresa.com.br",
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).WillReturnRows(sqlmock.NewRows(colunasUsuario).AddRow(9, testEmpresaID))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verificado_em = NOW()")).WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+				esperarPrepararLogin(mock, false)
+			},
+			status: http.StatusOK,
+			userID: 9,
+		},
+		{
+			name:  "user with TOTP gets a second factor challenge",
+			email: "ana@empresa.com.br",
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).WillReturnRows(sqlmock.NewRows(colunasUsuario).AddRow(9, testEmpresaID))
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email_verificado_em = NOW()")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+				esperarPrepararLogin(mock, true)
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM mfa_desafios")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO mfa_desafios")).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			status:  http.StatusOK,
+			desafio: true,
+		},
+		{
+			name:  "user of another empresa is refused",
+			email: "ana@empresa.com.br",
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).WillReturnRows(sqlmock.NewRows(colunasUsuario).AddRow(9, 8))
+				mock.ExpectRollback()
+			},
+			status: http.StatusForbidden,
+			code:   "usuario_nao_permitido",
+		},
+		{
+			name:  "account without empresa must be linked by its owner",
+			email: "ana@empresa.com.br",
+			esperar: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE")).WillReturnRows(sqlmock.NewRows(colunasUsuario).AddRow(9, nil))
+				mock.ExpectRollback()
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM oidc_vinculos")).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO oidc_vinculos")).
+					WithArgs(sqlmock.AnyArg(), 9, testEmpresaID, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name:     "unverified email is refused",
+			email:    "ana@empresa.com.br",
+			naoVerif: true,
+			status:   http.StatusForbidden,
+			code:     "usuario_nao_permitido",
+		},
+		{
+			name:   "email outside the verified domains is refused",
+			email:  "ana@outra.com.br",
+			status: http.StatusForbidden,
+			code:   "usuario_nao_permitido",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := configurarOIDC(t)
+			p.Email, p.EmailVerified = tt.email, !tt.naoVerif
+			tokens := mockTokens(t)
+			mock := mockDB(t)
+
+			l := iniciarLoginOIDC(t, mock, p)
+			l.esperarSessao(mock, p)
+			if tt.esperar != nil {
+				tt.esperar(mock)
+			}
+
+			rec := l.callback(l.cookie)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+
+			var resposta struct {
+				RespostaLogin
+				Code string `json:"code"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&resposta); err != nil {
+				t.Fatal(err)
+			}
+			if resposta.Code != tt.code {
+				t.Errorf("code = %q, want %q", resposta.Code, tt.code)
+			}
+			if tt.desafio && (resposta.Token != "" || !resposta.MFARequerido || resposta.Desafio == "") {
+				t.Errorf("response = %+v, want a second factor challenge", resposta.RespostaLogin)
+			}
+			if tt.userID != 0 {
+				claims, err := tokens.Validate(resposta.Token)
+				if err != nil {
+					t.Fatalf("token: %v", err)
+				}
+				if claims.UserID != tt.userID || claims.Version != versaoTokenTeste {
+					t.Errorf("token for user %d version %d, want %d version %d", claims.UserID, claims.Version, tt.userID, versaoTokenTeste)
+				}
+			}
+		})
+	}
+}
+
+// esperarPrepararLogin espera as consultas de prepararLogin para um usuário com a versão de
+// token versaoTokenTeste.
+func esperarPrepararLogin(mock sqlmock.Sqlmock, totpAtivo bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT u.totp_ativo")).
+		WillReturnRows(sqlmock.NewRows([]string{"totp_ativo", "obrigatoria", "codigos"}).AddRow(totpAtivo, false, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT token_versao FROM users")).
+		WillReturnRows(sqlmock.NewRows([]string{"token_versao"}).AddRow(versaoTokenTeste))
+}
+
+const versaoTokenTeste = 3
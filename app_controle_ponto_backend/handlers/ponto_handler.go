@@ -1,12 +1,22 @@
 package handlers
 
 import (
+	"controle-ponto-api/apierror"
 	"controle-ponto-api/database"
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/metrics"
 	"controle-ponto-api/middleware"
 	"controle-ponto-api/models"
+	"controle-ponto-api/regras"
+	"controle-ponto-api/validation"
+	"controle-ponto-api/webhooks"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,8 +26,35 @@ import (
 
 // --- Funções Auxiliares ---
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, map[string]string{"error": message})
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	apierror.Write(w, r, code, "", message)
+}
+
+// respondWithProblem responde o erro com um código específico de apierror, para os casos
+// que os clientes tratam de forma própria.
+func respondWithProblem(w http.ResponseWriter, r *http.Request, code int, problema, message string) {
+	apierror.Write(w, r, code, problema, message)
+}
+
+// respondWithValidationError responde 400 com os erros de cada campo, ou com uma mensagem
+// geral se err não trouxer campos.
+func respondWithValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var campos validation.Errors
+	if errors.As(err, &campos) {
+		apierror.WriteFields(w, r, campos)
+		return
+	}
+	respondWithProblem(w, r, http.StatusBadRequest, apierror.CodeInvalidJSON, "Invalid request body")
+}
+
+// decodificarJSON lê o corpo da requisição em dst. Se ele não for válido, responde 400 e
+// retorna false.
+func decodificarJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := validation.DecodeJSON(r.Body, dst); err != nil {
+		respondWithValidationError(w, r, err)
+		return false
+	}
+	return true
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -28,27 +65,50 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	}
 }
 
+// TotalHorasResposta é a resposta do cálculo de horas trabalhadas em um dia.
+type TotalHorasResposta struct {
+	TotalTrabalhado string `json:"total_trabalhado"`
+	TotalSegundos   string `json:"total_segundos"`
+	// Apuracao traz a jornada esperada, o tempo abonado por afastamento e o saldo do dia.
+	jornada.Apuracao
+	Violacoes []regras.Violacao `json:"violacoes"`
+}
+
 // PontoUpdatePayload define a estrutura para o corpo da requisição de atualização de ponto.
 // Isso é usado apenas para a documentação do Swagger.
 type PontoUpdatePayload struct {
 	Horario time.Time `json:"horario"`
 }
 
+// horarioMinimoPonto é o horário mais antigo aceito para um ponto.
+var horarioMinimoPonto = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// validar recusa horários vazios, absurdamente antigos ou no futuro, tolerando a
+// diferença de relógio aceita na sincronização.
+func (p PontoUpdatePayload) validar(agora time.Time) error {
+	var v validation.Validator
+	v.TimeBetween("horario", p.Horario, horarioMinimoPonto, agora.Add(ToleranciaDerivaRelogio))
+	return v.Err()
+}
+
 // --- Handlers ---
 
 // RegistrarPonto godoc
 // @Summary      Registra um novo ponto
-// @Description  Cria um novo registro de ponto com o horário atual para o usuário autenticado.
+// @Description  Cria um novo registro de ponto com o horário atual para o usuário autenticado. Pontos mais próximos de outro que o intervalo mínimo da empresa são rejeitados, mesclados ao existente ou sinalizados, conforme a política configurada.
 // @Tags         Pontos
 // @Produce      json
 // @Security     ApiKeyAuth
+// @Param        Idempotency-Key  header  string  false  "Chave para evitar registros duplicados em reenvios"
+// @Success      200  {object}  models.Ponto  "Ponto mesclado a um registro existente"
 // @Success      201  {object}  models.Ponto
-// @Failure      500  {string}  string "Internal server error"
+// @Failure      409  {object}  apierror.Problem  "Idempotency-Key conflict or 'ponto' too close to another one"
+// @Failure      500  {object}  apierror.Problem "Internal server error"
 // @Router       /pontos [post]
 func RegistrarPonto(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
 	if !ok {
-		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
 		return
 	}
 
@@ -58,55 +118,130 @@ func RegistrarPonto(w http.ResponseWriter, r *http.Request) {
 		Horario: horarioDoPonto,
 	}
 
-	err := database.DB.QueryRow(
-		"INSERT INTO pontos(user_id, horario) VALUES($1, $2) RETURNING id",
-		userID, horarioDoPonto,
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := bloquearUsuario(r.Context(), tx, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error locking 'pontos' of user", "user_id", userID, "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), tx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+
+	intervaloMinimo := time.Duration(empresa.IntervaloMinimoSegundos) * time.Second
+	proximo, err := pontoProximo(r.Context(), tx, userID, horarioDoPonto, intervaloMinimo, 0)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error looking for close 'pontos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+
+	if proximo != nil {
+		switch empresa.PoliticaDuplicidade {
+		case models.PoliticaDuplicidadeMesclar:
+			respondWithJSON(w, http.StatusOK, proximo)
+			return
+		case models.PoliticaDuplicidadeSinalizar:
+			novoPonto.DuplicidadeSuspeita = true
+		default:
+			respondWithProblem(w, r, http.StatusConflict, apierror.CodePontoTooClose, i18n.T(i18n.FromContext(r.Context()), "'Ponto' too close to the one registered at %s", proximo.Horario.Format(time.RFC3339)))
+			return
+		}
+	}
+
+	err = tx.QueryRowContext(r.Context(),
+		"INSERT INTO pontos(user_id, horario, duplicidade_suspeita) VALUES($1, $2, $3) RETURNING id",
+		userID, horarioDoPonto, novoPonto.DuplicidadeSuspeita,
 	).Scan(&novoPonto.ID)
 
 	if err != nil {
-		log.Printf("Error inserting new 'ponto': %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to register 'ponto'")
+		slog.ErrorContext(r.Context(), "Error inserting new 'ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
 		return
 	}
 
+	if err := enfileirarReavaliacao(r.Context(), tx, userID, empresa.Fuso(), horarioDoPonto); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing 'violacoes' evaluation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+	if err := invalidarBancoDeHorasDosPontos(r.Context(), tx, userID, empresa.Fuso(), horarioDoPonto); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing time bank recomputation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+
+	if err := webhooks.Enfileirar(r.Context(), tx, userID, eventos.TipoPontoCriado, dadosEventoPonto{Ponto: novoPonto}); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing new 'ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to register 'ponto'")
+		return
+	}
+
+	metrics.PontosRegistered.WithLabelValues(metrics.OriginOnline).Inc()
+	publicarEventosPonto(r.Context(), userID, eventos.Evento{Tipo: eventos.TipoPontoCriado, Ponto: novoPonto})
+
 	respondWithJSON(w, http.StatusCreated, novoPonto)
 }
 
 // ListarPontosPorData godoc
 // @Summary      Lista os pontos por data
-// @Description  Lista todos os registros de ponto de um usuário para uma data específica.
+// @Description  Lista todos os registros de ponto de um usuário para uma data específica, contada no fuso horário da empresa.
 // @Tags         Pontos
 // @Produce      json
 // @Security     ApiKeyAuth
 // @Param        data  path      string  true  "Data no formato YYYY-MM-DD"
 // @Success      200   {array}   models.Ponto
-// @Failure      400   {string}  string  "Invalid date format. Use YYYY-MM-DD"
-// @Failure      500   {string}  string  "Internal server error"
+// @Failure      400   {object}  apierror.Problem  "Invalid date format. Use YYYY-MM-DD"
+// @Failure      500   {object}  apierror.Problem  "Internal server error"
 // @Router       /pontos/{data} [get]
 func ListarPontosPorData(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
 	if !ok {
-		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'pontos'")
 		return
 	}
 
 	dataParam := chi.URLParam(r, "data")
-	parsedDate, err := time.Parse("2006-01-02", dataParam)
+	parsedDate, err := time.ParseInLocation(jornada.FormatoData, dataParam, empresa.Fuso())
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+		respondWithError(w, r, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
 		return
 	}
 
 	startOfDay := parsedDate
-	endOfDay := startOfDay.Add(24 * time.Hour)
+	endOfDay := startOfDay.AddDate(0, 0, 1)
 
-	rows, err := database.DB.Query(
+	rows, err := database.DB.QueryContext(r.Context(),
 		"SELECT id, user_id, horario FROM pontos WHERE user_id = $1 AND horario >= $2 AND horario < $3 ORDER BY horario ASC",
 		userID, startOfDay, endOfDay,
 	)
 	if err != nil {
-		log.Printf("Error querying 'pontos' by date: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve 'pontos'")
+		slog.ErrorContext(r.Context(), "Error querying 'pontos' by date", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'pontos'")
 		return
 	}
 	defer rows.Close()
@@ -115,8 +250,8 @@ func ListarPontosPorData(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var p models.Ponto
 		if err := rows.Scan(&p.ID, &p.UserID, &p.Horario); err != nil {
-			log.Printf("Error scanning 'ponto' row: %v", err)
-			respondWithError(w, http.StatusInternalServerError, "Failed to process 'pontos' data")
+			slog.ErrorContext(r.Context(), "Error scanning 'ponto' row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process 'pontos' data")
 			return
 		}
 		pontos = append(pontos, p)
@@ -127,71 +262,91 @@ func ListarPontosPorData(w http.ResponseWriter, r *http.Request) {
 
 // CalcularHorasTrabalhadas godoc
 // @Summary      Calcula horas trabalhadas
-// @Description  Calcula o total de horas trabalhadas em um dia com base nos registros de ponto (entrada/saída).
+// @Description  Calcula o total de horas trabalhadas em um dia com base nos registros de ponto (entrada/saída), o saldo em relação à jornada esperada (abonando afastamentos aprovados) e lista as violações de interjornada e intrajornada já apuradas para o dia. O dia é contado no fuso horário da empresa.
 // @Tags         Pontos
 // @Produce      json
 // @Security     ApiKeyAuth
 // @Param        data  path      string  true  "Data no formato YYYY-MM-DD"
-// @Success      200   {object}  map[string]string
-// @Failure      400   {string}  string  "Invalid date format. Use YYYY-MM-DD"
-// @Failure      500   {string}  string  "Internal server error"
+// @Success      200   {object}  TotalHorasResposta
+// @Failure      400   {object}  apierror.Problem  "Invalid date format. Use YYYY-MM-DD"
+// @Failure      500   {object}  apierror.Problem  "Internal server error"
 // @Router       /pontos/{data}/total-horas [get]
 func CalcularHorasTrabalhadas(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
 	if !ok {
-		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'pontos' for calculation")
 		return
 	}
 
 	dataParam := chi.URLParam(r, "data")
-	parsedDate, err := time.Parse("2006-01-02", dataParam)
+	parsedDate, err := time.ParseInLocation(jornada.FormatoData, dataParam, empresa.Fuso())
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+		respondWithError(w, r, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
 		return
 	}
 
 	startOfDay := parsedDate
-	endOfDay := startOfDay.Add(24 * time.Hour)
+	endOfDay := startOfDay.AddDate(0, 0, 1)
 
-	rows, err := database.DB.Query(
-		"SELECT horario FROM pontos WHERE user_id = $1 AND horario >= $2 AND horario < $3 ORDER BY horario ASC",
+	rows, err := database.DB.QueryContext(r.Context(),
+		"SELECT id, user_id, horario FROM pontos WHERE user_id = $1 AND horario >= $2 AND horario < $3 ORDER BY horario ASC",
 		userID, startOfDay, endOfDay,
 	)
 	if err != nil {
-		log.Printf("Error querying 'pontos' for calculation: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to retrieve 'pontos' for calculation")
+		slog.ErrorContext(r.Context(), "Error querying 'pontos' for calculation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'pontos' for calculation")
 		return
 	}
 	defer rows.Close()
 
-	var horarios []time.Time
+	var pontos []models.Ponto
 	for rows.Next() {
-		var horario time.Time
-		if err := rows.Scan(&horario); err != nil {
-			log.Printf("Error scanning 'horario' for calculation: %v", err)
-			respondWithError(w, http.StatusInternalServerError, "Failed to process 'horarios' for calculation")
+		var p models.Ponto
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Horario); err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning 'horario' for calculation", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process 'horarios' for calculation")
 			return
 		}
-		horarios = append(horarios, horario)
-	}
-
-	var totalDuracao time.Duration
-	if len(horarios)%2 != 0 {
-		horarios = horarios[:len(horarios)-1]
+		pontos = append(pontos, p)
 	}
 
-	for i := 0; i < len(horarios); i += 2 {
-		entrada := horarios[i]
-		saida := horarios[i+1]
-		totalDuracao += saida.Sub(entrada)
-	}
+	totalDuracao := jornada.TotalTrabalhado(pontos)
 
 	totalHoras := int(totalDuracao.Hours())
 	totalMinutos := int(totalDuracao.Minutes()) % 60
 
-	resposta := map[string]string{
-		"total_trabalhado": fmt.Sprintf("%dh %dm", totalHoras, totalMinutos),
-		"total_segundos":   fmt.Sprintf("%.0f", totalDuracao.Seconds()),
+	// 'Violacoes' are evaluated by the job enqueued when the 'pontos' change; a GET only reads them
+	violacoes, err := violacoesDoDia(r.Context(), database.DB, userID, dataParam)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'violacoes'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'violacoes'")
+		return
+	}
+
+	abonos, err := abonosDoPeriodo(r.Context(), database.DB, userID, startOfDay, startOfDay)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying approved 'afastamentos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'afastamentos' for calculation")
+		return
+	}
+	var abono *jornada.Abono
+	if a, ok := abonos[dataParam]; ok {
+		abono = &a
+	}
+	cargaDiaria := time.Duration(empresa.CargaHorariaDiariaMinutos) * time.Minute
+
+	resposta := TotalHorasResposta{
+		TotalTrabalhado: fmt.Sprintf("%dh %dm", totalHoras, totalMinutos),
+		TotalSegundos:   fmt.Sprintf("%.0f", totalDuracao.Seconds()),
+		Apuracao:        jornada.Apurar(startOfDay, pontos, cargaDiaria, abono),
+		Violacoes:       violacoes,
 	}
 
 	respondWithJSON(w, http.StatusOK, resposta)
@@ -199,61 +354,132 @@ func CalcularHorasTrabalhadas(w http.ResponseWriter, r *http.Request) {
 
 // AtualizarPonto godoc
 // @Summary      Atualiza um registro de ponto
-// @Description  Atualiza o horário de um registro de ponto existente.
+// @Description  Atualiza o horário de um registro de ponto existente. Um horário mais próximo de outro ponto que o intervalo mínimo da empresa é sinalizado se a política for sinalizar e rejeitado nas demais, já que uma alteração não tem como ser mesclada.
 // @Tags         Pontos
 // @Accept       json
 // @Produce      json
 // @Security     ApiKeyAuth
 // @Param        id       path      int                  true  "ID do Ponto"
 // @Param        horario  body      PontoUpdatePayload   true  "Novo horário para o registro"
+// @Param        Idempotency-Key  header  string  false  "Chave para evitar registros duplicados em reenvios"
 // @Success      200      {object}  map[string]string
-// @Failure      400      {string}  string  "Invalid ID format or request body"
-// @Failure      404      {string}  string  "Ponto not found or permission denied"
-// @Failure      500      {string}  string  "Internal server error"
+// @Failure      400      {object}  apierror.Problem  "Invalid ID format or request body"
+// @Failure      404      {object}  apierror.Problem  "Ponto not found or permission denied"
+// @Failure      409      {object}  apierror.Problem  "Idempotency-Key conflict or 'ponto' too close to another one"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
 // @Router       /pontos/{id} [put]
 func AtualizarPonto(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
 	if !ok {
-		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
 		return
 	}
 
 	idParam := chi.URLParam(r, "id")
 	pontoID, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid ID format")
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
 
 	var payload PontoUpdatePayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(time.Now()); err != nil {
+		respondWithValidationError(w, r, err)
 		return
 	}
 
-	res, err := database.DB.Exec(
-		"UPDATE pontos SET horario = $1 WHERE id = $2 AND user_id = $3",
-		payload.Horario, pontoID, userID,
-	)
+	tx, err := database.DB.BeginTx(r.Context(), nil)
 	if err != nil {
-		log.Printf("Error updating 'ponto': %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to update 'ponto'")
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := res.RowsAffected()
+	if err := bloquearUsuario(r.Context(), tx, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error locking 'pontos' of user", "user_id", userID, "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), tx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
+		return
+	}
+
+	intervaloMinimo := time.Duration(empresa.IntervaloMinimoSegundos) * time.Second
+	proximo, err := pontoProximo(r.Context(), tx, userID, payload.Horario, intervaloMinimo, pontoID)
 	if err != nil {
-		log.Printf("Error getting rows affected: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to confirm update")
+		slog.ErrorContext(r.Context(), "Error looking for close 'pontos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
 		return
 	}
 
-	if rowsAffected == 0 {
-		respondWithError(w, http.StatusNotFound, "'Ponto' not found or you don't have permission to update it")
+	var duplicidadeSuspeita bool
+	if proximo != nil {
+		// Merging an edited ponto would mean deleting one of the two, so only 'sinalizar' lets it through
+		if empresa.PoliticaDuplicidade != models.PoliticaDuplicidadeSinalizar {
+			respondWithProblem(w, r, http.StatusConflict, apierror.CodePontoTooClose, i18n.T(i18n.FromContext(r.Context()), "'Ponto' too close to the one registered at %s", proximo.Horario.Format(time.RFC3339)))
+			return
+		}
+		duplicidadeSuspeita = true
+	}
+
+	// The joined row keeps the previous horario, whose day also needs its 'violacoes' reevaluated
+	var horarioAnterior time.Time
+	err = tx.QueryRowContext(r.Context(),
+		`UPDATE pontos SET horario = $1, duplicidade_suspeita = $4 FROM pontos anterior
+		WHERE pontos.id = anterior.id AND pontos.id = $2 AND pontos.user_id = $3
+		RETURNING anterior.horario`,
+		payload.Horario, pontoID, userID, duplicidadeSuspeita,
+	).Scan(&horarioAnterior)
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "'Ponto' not found or you don't have permission to update it")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating 'ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Ponto updated successfully"})
+	if err := enfileirarReavaliacao(r.Context(), tx, userID, empresa.Fuso(), horarioAnterior, payload.Horario); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing 'violacoes' evaluation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
+		return
+	}
+	if err := invalidarBancoDeHorasDosPontos(r.Context(), tx, userID, empresa.Fuso(), horarioAnterior, payload.Horario); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing time bank recomputation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
+		return
+	}
+
+	ponto := models.Ponto{ID: idParam, UserID: userID, Horario: payload.Horario, DuplicidadeSuspeita: duplicidadeSuspeita}
+	err = webhooks.Enfileirar(r.Context(), tx, userID, eventos.TipoPontoAlterado, dadosEventoPonto{Ponto: ponto, HorarioAnterior: &horarioAnterior})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing updated 'ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to update 'ponto'")
+		return
+	}
+
+	publicarEventosPonto(r.Context(), userID, eventos.Evento{
+		Tipo:            eventos.TipoPontoAlterado,
+		Ponto:           ponto,
+		HorarioAnterior: &horarioAnterior,
+	})
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": i18n.T(i18n.FromContext(r.Context()), "Ponto updated successfully")})
 }
 
 // DeletarPonto godoc
@@ -263,43 +489,79 @@ func AtualizarPonto(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Security     ApiKeyAuth
 // @Param        id   path      int  true  "ID do Ponto"
+// @Param        Idempotency-Key  header  string  false  "Chave para evitar registros duplicados em reenvios"
 // @Success      204  {string}  string "No Content"
-// @Failure      400  {string}  string  "Invalid ID format"
-// @Failure      404  {string}  string  "Ponto not found or permission denied"
-// @Failure      500  {string}  string  "Internal server error"
+// @Failure      400  {object}  apierror.Problem  "Invalid ID format"
+// @Failure      404  {object}  apierror.Problem  "Ponto not found or permission denied"
+// @Failure      409  {object}  apierror.Problem  "Idempotency-Key conflict"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
 // @Router       /pontos/{id} [delete]
 func DeletarPonto(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
 	if !ok {
-		respondWithError(w, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
 		return
 	}
 
 	idParam := chi.URLParam(r, "id")
 	pontoID, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid ID format")
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
 		return
 	}
+	defer tx.Rollback()
 
-	res, err := database.DB.Exec("DELETE FROM pontos WHERE id = $1 AND user_id = $2", pontoID, userID)
+	var horario time.Time
+	err = tx.QueryRowContext(r.Context(), "DELETE FROM pontos WHERE id = $1 AND user_id = $2 RETURNING horario", pontoID, userID).Scan(&horario)
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "'Ponto' not found or you don't have permission to delete it")
+		return
+	}
 	if err != nil {
-		log.Printf("Error deleting 'ponto': %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to delete 'ponto'")
+		slog.ErrorContext(r.Context(), "Error deleting 'ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
 		return
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), tx, userID)
 	if err != nil {
-		log.Printf("Error getting rows affected: %v", err)
-		respondWithError(w, http.StatusInternalServerError, "Failed to confirm deletion")
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
+		return
+	}
+
+	if err := enfileirarReavaliacao(r.Context(), tx, userID, empresa.Fuso(), horario); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing 'violacoes' evaluation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
+		return
+	}
+	if err := invalidarBancoDeHorasDosPontos(r.Context(), tx, userID, empresa.Fuso(), horario); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing time bank recomputation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
 		return
 	}
 
-	if rowsAffected == 0 {
-		respondWithError(w, http.StatusNotFound, "'Ponto' not found or you don't have permission to delete it")
+	ponto := models.Ponto{ID: idParam, UserID: userID, Horario: horario}
+	if err := webhooks.Enfileirar(r.Context(), tx, userID, eventos.TipoPontoRemovido, dadosEventoPonto{Ponto: ponto}); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing deleted 'ponto'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete 'ponto'")
+		return
+	}
+
+	publicarEventosPonto(r.Context(), userID, eventos.Evento{Tipo: eventos.TipoPontoRemovido, Ponto: ponto})
+
 	w.WriteHeader(http.StatusNoContent)
 }
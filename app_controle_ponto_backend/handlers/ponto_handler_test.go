@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+)
+
+// esperarEmpresa faz o usuário pertencer a uma empresa com a política de duplicidade
+// informada e intervalo mínimo de um minuto.
+func esperarEmpresa(mock sqlmock.Sqlmock, politica string) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT empresa_id FROM users")).
+		WillReturnRows(sqlmock.NewRows([]string{"empresa_id"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM empresas WHERE id = $1")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "nome", "intervalo_minimo_segundos", "politica_duplicidade", "jornada_maxima_minutos",
+			"interjornada_minima_minutos", "intrajornada_limite_minutos", "intrajornada_minima_minutos",
+			"intrajornada_curta_limite_minutos", "intrajornada_curta_minima_minutos", "carga_horaria_diaria_minutos",
+			"tolerancia_atraso_minutos", "mfa_obrigatoria_papeis", "fuso_horario",
+		}).AddRow(3, "Empresa", 60, politica, 600, 660, 360, 60, 240, 15, 480, 10, "{}", "America/Sao_Paulo"))
+}
+
+func TestAtualizarPontoPontoProximo(t *testing.T) {
+	const (
+		userID  = 8
+		pontoID = 21
+	)
+	horario := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	anterior := horario.Add(-2 * time.Hour)
+
+	tests := []struct {
+		name     string
+		politica string
+		// proximo diz se há outro ponto a menos de um minuto do novo horário
+		proximo bool
+		status  int
+		// sinalizado é o valor gravado em duplicidade_suspeita quando o ponto é alterado
+		sinalizado bool
+	}{
+		{name: "no ponto nearby", politica: models.PoliticaDuplicidadeRejeitar, status: http.StatusOK},
+		{name: "rejected", politica: models.PoliticaDuplicidadeRejeitar, proximo: true, status: http.StatusConflict},
+		// An edit can't be merged without deleting one of the pontos
+		{name: "merge policy rejects edits", politica: models.PoliticaDuplicidadeMesclar, proximo: true, status: http.StatusConflict},
+		{name: "flagged", politica: models.PoliticaDuplicidadeSinalizar, proximo: true, status: http.StatusOK, sinalizado: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+			esperarEmpresa(mock, tt.politica)
+			linhas := sqlmock.NewRows([]string{"id", "user_id", "horario"})
+			if tt.proximo {
+				linhas.AddRow(22, userID, horario.Add(30*time.Second))
+			}
+			// The ponto being edited is never "too close" to itself
+			mock.ExpectQuery(regexp.QuoteMeta("AND id <> $5")).
+				WithArgs(userID, horario.Add(-time.Minute), horario.Add(time.Minute), horario, pontoID).
+				WillReturnRows(linhas)
+			if tt.status == http.StatusOK {
+				mock.ExpectQuery(regexp.QuoteMeta("UPDATE pontos SET horario = $1, duplicidade_suspeita = $4")).
+					WithArgs(horario, pontoID, userID, tt.sinalizado).
+					WillReturnRows(sqlmock.NewRows([]string{"horario"}).AddRow(anterior))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+				esperarInvalidacaoBancoDeHoras(mock)
+				esperarInvalidacaoBancoDeHoras(mock)
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_entregas")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT empresa_id, gestor_id FROM users")).
+					WillReturnRows(sqlmock.NewRows([]string{"empresa_id", "gestor_id"}).AddRow(3, nil))
+			} else {
+				mock.ExpectRollback()
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "21")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+			ctx = context.WithValue(ctx, middleware.UserIDKey, int64(userID))
+			body := `{"horario": "` + horario.Format(time.RFC3339) + `"}`
+			r := httptest.NewRequest(http.MethodPut, "/api/pontos/21", strings.NewReader(body)).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			AtualizarPonto(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/models"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// queryer é satisfeito tanto por *sql.DB quanto por *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// bloquearUsuario serializa, até o fim da transação, as alterações nos registros do
+// usuário, evitando que duas requisições simultâneas escapem das verificações de duplicidade.
+func bloquearUsuario(ctx context.Context, tx *sql.Tx, userID int64) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", userID)
+	return err
+}
+
+// carregarEmpresaDoUsuario retorna as regras da empresa do usuário,
+// ou models.EmpresaPadrao se ele não estiver vinculado a nenhuma empresa.
+func carregarEmpresaDoUsuario(ctx context.Context, q queryer, userID int64) (models.Empresa, error) {
+	var empresaID sql.NullInt64
+	if err := q.QueryRowContext(ctx, "SELECT empresa_id FROM users WHERE id = $1", userID).Scan(&empresaID); err != nil {
+		return models.EmpresaPadrao, err
+	}
+
+	if !empresaID.Valid {
+		return models.EmpresaPadrao, nil
+	}
+	return carregarEmpresa(ctx, q, empresaID.Int64)
+}
+
+// carregarEmpresa retorna a empresa com o ID informado.
+func carregarEmpresa(ctx context.Context, q queryer, empresaID int64) (models.Empresa, error) {
+	var e models.Empresa
+	err := q.QueryRowContext(ctx,
+		`SELECT id, nome, intervalo_minimo_segundos, politica_duplicidade, jornada_maxima_minutos,
+			interjornada_minima_minutos, intrajornada_limite_minutos, intrajornada_minima_minutos,
+			intrajornada_curta_limite_minutos, intrajornada_curta_minima_minutos, carga_horaria_diaria_minutos,
+			tolerancia_atraso_minutos, mfa_obrigatoria_papeis, fuso_horario
+		FROM empresas WHERE id = $1`,
+		empresaID,
+	).Scan(
+		&e.ID, &e.Nome, &e.IntervaloMinimoSegundos, &e.PoliticaDuplicidade, &e.JornadaMaximaMinutos,
+		&e.InterjornadaMinimaMinutos, &e.IntrajornadaLimiteMinutos, &e.IntrajornadaMinimaMinutos,
+		&e.IntrajornadaCurtaLimiteMinutos, &e.IntrajornadaCurtaMinimaMinutos, &e.CargaHorariaDiariaMinutos,
+		&e.ToleranciaAtrasoMinutos, pq.Array(&e.MFAObrigatoriaPapeis), &e.FusoHorario,
+	)
+	return e, err
+}
+
+// pontoProximo busca o ponto do usuário mais próximo de horario dentro do intervalo informado,
+// desconsiderando o ponto exceto, que é o próprio ponto quando ele é alterado e 0 nos novos.
+// Retorna nil se não houver nenhum.
+func pontoProximo(ctx context.Context, q queryer, userID int64, horario time.Time, intervalo time.Duration, exceto int64) (*models.Ponto, error) {
+	if intervalo <= 0 {
+		return nil, nil
+	}
+
+	var p models.Ponto
+	err := q.QueryRowContext(ctx,
+		`SELECT id, user_id, horario FROM pontos
+		WHERE user_id = $1 AND horario > $2 AND horario < $3 AND id <> $5
+		ORDER BY ABS(EXTRACT(EPOCH FROM (horario - $4::timestamptz)))
+		LIMIT 1`,
+		userID, horario.Add(-intervalo), horario.Add(intervalo), horario, exceto,
+	).Scan(&p.ID, &p.UserID, &p.Horario)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// JobGerarRelatorio é o tipo da tarefa que gera o arquivo de uma exportação de relatório.
+const JobGerarRelatorio = "relatorios.gerar"
+
+// maxTentativasRelatorio é o número de execuções da geração antes de a exportação ser
+// marcada como falhou.
+const maxTentativasRelatorio = 3
+
+// ExportacaoRelatorioPayload define o corpo da requisição de exportação de relatório.
+type ExportacaoRelatorioPayload struct {
+	Tipo   string `json:"tipo"`
+	Inicio string `json:"inicio"`
+	Fim    string `json:"fim"`
+	// UserID exporta o relatório de um usuário gerenciado; Equipe, o de todos eles.
+	UserID *int64 `json:"user_id,omitempty"`
+	Equipe bool   `json:"equipe,omitempty"`
+}
+
+func (p ExportacaoRelatorioPayload) validar() error {
+	var v validation.Validator
+	v.OneOf("tipo", p.Tipo, models.RelatorioBancoHoras, models.RelatorioInconsistencias)
+	inicio, inicioOK := v.Date("inicio", p.Inicio)
+	fim, fimOK := v.Date("fim", p.Fim)
+	if inicioOK && fimOK && v.Check(!fim.Before(inicio), "fim", validation.CodeOutOfRange, "must not be before inicio") {
+		v.Check(fim.Before(inicio.AddDate(0, 0, maxDiasPorPeriodo)), "fim", validation.CodeOutOfRange,
+			"the period must be shorter than %d days", maxDiasPorPeriodo)
+	}
+	v.Check(!p.Equipe || p.UserID == nil, "user_id", validation.CodeNotAllowed, "can't be combined with equipe")
+	return v.Err()
+}
+
+// geracaoRelatorioPayload identifica a exportação gerada pela tarefa JobGerarRelatorio.
+type geracaoRelatorioPayload struct {
+	ExportacaoID int64 `json:"exportacao_id"`
+}
+
+// carregarExportacao lê a exportação id, sem o arquivo.
+func carregarExportacao(ctx context.Context, q queryer, id int64) (models.ExportacaoRelatorio, error) {
+	var e models.ExportacaoRelatorio
+	var inicio, fim time.Time
+	var concluidoEm sql.NullTime
+	err := q.QueryRowContext(ctx,
+		`SELECT id, user_id, tipo, inicio, fim, usuario_ids, status, criado_em, concluido_em
+		FROM exportacoes_relatorio WHERE id = $1`,
+		id,
+	).Scan(&e.ID, &e.UserID, &e.Tipo, &inicio, &fim, pq.Array(&e.UsuarioIDs), &e.Status, &e.CriadoEm, &concluidoEm)
+	if err != nil {
+		return e, err
+	}
+	e.Inicio = inicio.Format(jornada.FormatoData)
+	e.Fim = fim.Format(jornada.FormatoData)
+	if concluidoEm.Valid {
+		e.ConcluidoEm = &concluidoEm.Time
+	}
+	return e, nil
+}
+
+// periodoNoFuso lê as datas inicio e fim como a meia-noite desses dias no fuso informado.
+func periodoNoFuso(inicio, fim string, fuso *time.Location) (time.Time, time.Time, error) {
+	i, err := time.ParseInLocation(jornada.FormatoData, inicio, fuso)
+	if err != nil {
+		return i, i, err
+	}
+	f, err := time.ParseInLocation(jornada.FormatoData, fim, fuso)
+	return i, f, err
+}
+
+// escreverBancoDeHoras escreve uma linha por dia apurado de cada usuário. Os dias futuros
+// não são apurados.
+func escreverBancoDeHoras(ctx context.Context, w *csv.Writer, e models.ExportacaoRelatorio) error {
+	if err := w.Write([]string{"user_id", "data", "trabalhado_minutos", "esperado_minutos", "abonado_minutos", "saldo_minutos", "afastamento"}); err != nil {
+		return err
+	}
+	for _, userID := range e.UsuarioIDs {
+		empresa, err := carregarEmpresaDoUsuario(ctx, database.DB, userID)
+		if err != nil {
+			return err
+		}
+		inicio, fim, err := periodoNoFuso(e.Inicio, e.Fim, empresa.Fuso())
+		if err != nil {
+			return err
+		}
+		if hoje := inicioDoDia(time.Now(), empresa.Fuso()); fim.After(hoje) {
+			fim = hoje
+		}
+		if fim.Before(inicio) {
+			continue
+		}
+
+		apuracoes, err := consultarApuracoes(ctx, userID, empresa, inicio, fim)
+		if err != nil {
+			return err
+		}
+		for _, a := range apuracoes {
+			err := w.Write([]string{
+				strconv.FormatInt(userID, 10), a.Data, strconv.Itoa(a.TrabalhadoMinutos), strconv.Itoa(a.EsperadoMinutos),
+				strconv.Itoa(a.AbonadoMinutos), strconv.Itoa(a.SaldoMinutos), a.Afastamento,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// escreverInconsistencias escreve uma linha por inconsistência de cada usuário, como em
+// ListarInconsistencias.
+func escreverInconsistencias(ctx context.Context, w *csv.Writer, e models.ExportacaoRelatorio) error {
+	if err := w.Write([]string{"user_id", "data", "tipo", "descricao"}); err != nil {
+		return err
+	}
+	for _, userID := range e.UsuarioIDs {
+		empresa, err := carregarEmpresaDoUsuario(ctx, database.DB, userID)
+		if err != nil {
+			return err
+		}
+		inicio, fim, err := periodoNoFuso(e.Inicio, e.Fim, empresa.Fuso())
+		if err != nil {
+			return err
+		}
+		pontos, err := listarPontosDoPeriodo(ctx, database.DB, userID, inicio, fim)
+		if err != nil {
+			return err
+		}
+
+		regras := jornada.RegrasDaEmpresa(empresa)
+		dias, porDia := jornada.AgruparPorDia(pontos, empresa.Fuso())
+		for _, dia := range dias {
+			for _, i := range jornada.Inconsistencias(dia, porDia[dia], regras) {
+				if err := w.Write([]string{strconv.FormatInt(userID, 10), i.Data, i.Tipo, i.Descricao}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// gerarRelatorio monta o CSV da exportação.
+func gerarRelatorio(ctx context.Context, e models.ExportacaoRelatorio) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	var err error
+	switch e.Tipo {
+	case models.RelatorioBancoHoras:
+		err = escreverBancoDeHoras(ctx, w, e)
+	case models.RelatorioInconsistencias:
+		err = escreverInconsistencias(ctx, w, e)
+	default:
+		err = fmt.Errorf("unknown report type %q", e.Tipo)
+	}
+	if err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// GerarRelatorioJob executa a tarefa JobGerarRelatorio. Na última tentativa, uma falha
+// marca a exportação como falhou.
+func GerarRelatorioJob(ctx context.Context, job jobs.Job) error {
+	var payload geracaoRelatorioPayload
+	if err := job.Decodificar(&payload); err != nil {
+		return err
+	}
+
+	exportacao, err := carregarExportacao(ctx, database.DB, payload.ExportacaoID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The requester's account was deleted along with its exports
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if exportacao.Status != models.ExportacaoPendente {
+		return nil
+	}
+
+	conteudo, err := gerarRelatorio(ctx, exportacao)
+	if err != nil {
+		// An attempt interrupted by the shutdown runs again
+		if job.Tentativa >= maxTentativasRelatorio && ctx.Err() == nil {
+			_, errStatus := database.DB.ExecContext(ctx,
+				"UPDATE exportacoes_relatorio SET status = $1, concluido_em = NOW() WHERE id = $2",
+				models.ExportacaoFalhou, exportacao.ID,
+			)
+			if errStatus != nil {
+				slog.ErrorContext(ctx, "Error marking report export as failed", "exportacao_id", exportacao.ID, "error", errStatus)
+			}
+		}
+		return err
+	}
+
+	_, err = database.DB.ExecContext(ctx,
+		"UPDATE exportacoes_relatorio SET status = $1, conteudo = $2, concluido_em = NOW() WHERE id = $3",
+		models.ExportacaoConcluida, conteudo, exportacao.ID,
+	)
+	return err
+}
+
+// SolicitarExportacaoRelatorio godoc
+// @Summary      Solicita a exportação de um relatório
+// @Description  Agenda a geração, em segundo plano, do relatório de banco de horas ou de inconsistências do período em CSV. Gestores exportam o relatório de um usuário da equipe (user_id) ou de toda a equipe (equipe); os usuários incluídos são definidos no pedido. Acompanhe a exportação pela URL do cabeçalho Location.
+// @Tags         Relatórios
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        exportacao  body      ExportacaoRelatorioPayload  true  "Tipo, período e usuários do relatório"
+// @Success      202         {object}  models.ExportacaoRelatorio
+// @Header       202         {string}  Location  "URL da exportação"
+// @Failure      400         {object}  apierror.Problem  "Invalid request body"
+// @Failure      403         {object}  apierror.Problem  "Permission denied"
+// @Failure      500         {object}  apierror.Problem  "Internal server error"
+// @Router       /relatorios [post]
+func SolicitarExportacaoRelatorio(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload ExportacaoRelatorioPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+		return
+	}
+
+	ids := []int64{userID}
+	switch {
+	case payload.Equipe:
+		if !solicitante.ehGestor() {
+			respondWithError(w, r, http.StatusForbidden, "Only managers can export the team's reports")
+			return
+		}
+		if ids, err = usuariosGerenciados(r.Context(), database.DB, solicitante); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing managed users", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+			return
+		}
+	case payload.UserID != nil:
+		permitido, err := podeGerenciar(r.Context(), database.DB, solicitante, *payload.UserID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+			return
+		}
+		if !permitido {
+			respondWithError(w, r, http.StatusForbidden, "You don't have permission to export this user's reports")
+			return
+		}
+		ids = []int64{*payload.UserID}
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+		return
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(r.Context(),
+		"INSERT INTO exportacoes_relatorio (user_id, tipo, inicio, fim, usuario_ids) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		userID, payload.Tipo, payload.Inicio, payload.Fim, pq.Array(ids),
+	).Scan(&id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting report export", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+		return
+	}
+
+	err = jobs.Enfileirar(r.Context(), tx, JobGerarRelatorio, geracaoRelatorioPayload{ExportacaoID: id}, jobs.Opcoes{MaxTentativas: maxTentativasRelatorio})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing report generation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+		return
+	}
+
+	exportacao, err := carregarExportacao(r.Context(), tx, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading report export", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing report export", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to request report")
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/relatorios/%d", id))
+	respondWithJSON(w, http.StatusAccepted, exportacao)
+}
+
+// exportacaoDaRequisicao carrega a exportação do parâmetro {id} pedida pelo usuário
+// autenticado. Se não conseguir, responde e retorna false.
+func exportacaoDaRequisicao(w http.ResponseWriter, r *http.Request) (models.ExportacaoRelatorio, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return models.ExportacaoRelatorio{}, false
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return models.ExportacaoRelatorio{}, false
+	}
+
+	// Other users' exports don't exist for the requester
+	exportacao, err := carregarExportacao(r.Context(), database.DB, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && exportacao.UserID != userID) {
+		respondWithError(w, r, http.StatusNotFound, "Report not found")
+		return exportacao, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading report export", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve report")
+		return exportacao, false
+	}
+	return exportacao, true
+}
+
+// ObterExportacaoRelatorio godoc
+// @Summary      Consulta uma exportação de relatório
+// @Description  Retorna o status de uma exportação pedida pelo usuário autenticado.
+// @Tags         Relatórios
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "ID da exportação"
+// @Success      200  {object}  models.ExportacaoRelatorio
+// @Failure      400  {object}  apierror.Problem  "Invalid ID format"
+// @Failure      404  {object}  apierror.Problem  "Report not found"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /relatorios/{id} [get]
+func ObterExportacaoRelatorio(w http.ResponseWriter, r *http.Request) {
+	exportacao, ok := exportacaoDaRequisicao(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, exportacao)
+}
+
+// BaixarExportacaoRelatorio godoc
+// @Summary      Baixa o arquivo de um relatório
+// @Description  Retorna o CSV de uma exportação concluída pedida pelo usuário autenticado.
+// @Tags         Relatórios
+// @Produce      text/csv
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "ID da exportação"
+// @Success      200  {file}    file
+// @Failure      400  {object}  apierror.Problem  "Invalid ID format"
+// @Failure      404  {object}  apierror.Problem  "Report not found"
+// @Failure      409  {object}  apierror.Problem  "Report not ready"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /relatorios/{id}/arquivo [get]
+func BaixarExportacaoRelatorio(w http.ResponseWriter, r *http.Request) {
+	exportacao, ok := exportacaoDaRequisicao(w, r)
+	if !ok {
+		return
+	}
+	if exportacao.Status != models.ExportacaoConcluida {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeReportNotReady, "The report has not been generated")
+		return
+	}
+
+	var conteudo []byte
+	err := database.DB.QueryRowContext(r.Context(), "SELECT conteudo FROM exportacoes_relatorio WHERE id = $1", exportacao.ID).Scan(&conteudo)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading report file", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="relatorio-%s-%s-%s.csv"`, exportacao.Tipo, exportacao.Inicio, exportacao.Fim))
+	w.WriteHeader(http.StatusOK)
+	w.Write(conteudo)
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+)
+
+// colunasExportacao são as colunas lidas por carregarExportacao.
+var colunasExportacao = []string{"id", "user_id", "tipo", "inicio", "fim", "usuario_ids", "status", "criado_em", "concluido_em"}
+
+// esperarExportacao espera a leitura da exportação 12, pedida pelo usuário 5 para os
+// usuários informados, de 4 a 5 de março de 2024.
+func esperarExportacao(mock sqlmock.Sqlmock, tipo, status, usuarioIDs string) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM exportacoes_relatorio WHERE id = $1")).WithArgs(12).
+		WillReturnRows(sqlmock.NewRows(colunasExportacao).AddRow(
+			12, 5, tipo, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			usuarioIDs, status, time.Now(), nil,
+		))
+}
+
+func TestSolicitarExportacaoRelatorio(t *testing.T) {
+	const solicitanteID = 5
+
+	tests := []struct {
+		name  string
+		papel string
+		corpo string
+		// esperarEscopo espera as consultas que resolvem os usuários do relatório
+		esperarEscopo func(mock sqlmock.Sqlmock)
+		status        int
+		// usuarioIDs é o valor gravado em usuario_ids quando o pedido é aceito
+		usuarioIDs string
+	}{
+		{
+			name:       "own report",
+			papel:      models.PapelFuncionario,
+			corpo:      `{"tipo":"inconsistencias","inicio":"2024-03-04","fim":"2024-03-05"}`,
+			status:     http.StatusAccepted,
+			usuarioIDs: "{5}",
+		},
+		{
+			name:  "the team's report",
+			papel: models.PapelGestor,
+			corpo: `{"tipo":"banco_horas","inicio":"2024-03-04","fim":"2024-03-05","equipe":true}`,
+			esperarEscopo: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE gestor_id = $1 OR id = $1")).WithArgs(solicitanteID).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5).AddRow(8).AddRow(9))
+			},
+			status:     http.StatusAccepted,
+			usuarioIDs: "{5,8,9}",
+		},
+		{
+			name:   "the team's report for employees",
+			papel:  models.PapelFuncionario,
+			corpo:  `{"tipo":"banco_horas","inicio":"2024-03-04","fim":"2024-03-05","equipe":true}`,
+			status: http.StatusForbidden,
+		},
+		{
+			name:  "user outside the team",
+			papel: models.PapelGestor,
+			corpo: `{"tipo":"banco_horas","inicio":"2024-03-04","fim":"2024-03-05","user_id":8}`,
+			esperarEscopo: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND gestor_id = $2)")).WithArgs(8, solicitanteID).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name:   "unknown tipo",
+			corpo:  `{"tipo":"violacoes","inicio":"2024-03-04","fim":"2024-03-05"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "period too long",
+			corpo:  `{"tipo":"banco_horas","inicio":"2024-01-01","fim":"2025-01-01"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "equipe and user_id",
+			corpo:  `{"tipo":"banco_horas","inicio":"2024-03-04","fim":"2024-03-05","equipe":true,"user_id":8}`,
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			if tt.papel != "" {
+				esperarPerfil(mock, solicitanteID, tt.papel)
+			}
+			if tt.esperarEscopo != nil {
+				tt.esperarEscopo(mock)
+			}
+			if tt.status == http.StatusAccepted {
+				var payload ExportacaoRelatorioPayload
+				json.Unmarshal([]byte(tt.corpo), &payload)
+
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO exportacoes_relatorio")).
+					WithArgs(solicitanteID, payload.Tipo, "2024-03-04", "2024-03-05", tt.usuarioIDs).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+				// Generated by the queue, failing for good after 3 attempts
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).
+					WithArgs(JobGerarRelatorio, `{"exportacao_id":12}`, nil, 3, nil).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				esperarExportacao(mock, payload.Tipo, models.ExportacaoPendente, tt.usuarioIDs)
+				mock.ExpectCommit()
+			}
+
+			ctx := context.WithValue(context.Background(), middleware.UserIDKey, int64(solicitanteID))
+			r := httptest.NewRequest(http.MethodPost, "/api/relatorios", strings.NewReader(tt.corpo)).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			SolicitarExportacaoRelatorio(rec, r)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusAccepted {
+				if got := rec.Header().Get("Location"); got != "/api/relatorios/12" {
+					t.Errorf("Location = %q, want /api/relatorios/12", got)
+				}
+				var exportacao models.ExportacaoRelatorio
+				if err := json.Unmarshal(rec.Body.Bytes(), &exportacao); err != nil {
+					t.Fatal(err)
+				}
+				if exportacao.ID != 12 || exportacao.Status != models.ExportacaoPendente || exportacao.Inicio != "2024-03-04" {
+					t.Errorf("exportacao = %+v", exportacao)
+				}
+			}
+		})
+	}
+}
+
+func TestGerarRelatorioJob(t *testing.T) {
+	sp, _ := time.LoadLocation("America/Sao_Paulo")
+
+	tests := []struct {
+		name string
+		tipo string
+		// esperarDados espera a leitura dos dados do usuário 8 no período
+		esperarDados func(mock sqlmock.Sqlmock)
+		want         string
+	}{
+		{
+			name: "inconsistencias",
+			tipo: models.RelatorioInconsistencias,
+			esperarDados: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM pontos WHERE user_id = $1 AND horario >= $2")).
+					WithArgs(8, time.Date(2024, 3, 4, 0, 0, 0, 0, sp), time.Date(2024, 3, 6, 0, 0, 0, 0, sp)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "horario"}).AddRow(1, 8, time.Date(2024, 3, 4, 8, 0, 0, 0, sp)))
+			},
+			want: "user_id,data,tipo,descricao\n" +
+				"8,2024-03-04,quantidade_impar,1 pontos registrados; falta uma entrada ou saída\n",
+		},
+		{
+			name: "banco_horas",
+			tipo: models.RelatorioBancoHoras,
+			esperarDados: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("FROM banco_horas_dias")).
+					WillReturnRows(sqlmock.NewRows([]string{"data", "trabalhado_minutos", "esperado_minutos", "abonado_minutos", "saldo_minutos", "afastamento"}).
+						AddRow(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), 500, 480, 0, 20, "").
+						AddRow(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), 0, 480, 480, 0, "ferias"))
+			},
+			want: "user_id,data,trabalhado_minutos,esperado_minutos,abonado_minutos,saldo_minutos,afastamento\n" +
+				"8,2024-03-04,500,480,0,20,\n" +
+				"8,2024-03-05,0,480,480,0,ferias\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarExportacao(mock, tt.tipo, models.ExportacaoPendente, "{8}")
+			esperarEmpresa(mock, "rejeitar")
+			tt.esperarDados(mock)
+			mock.ExpectExec(regexp.QuoteMeta("UPDATE exportacoes_relatorio SET status = $1, conteudo = $2")).
+				WithArgs(models.ExportacaoConcluida, []byte(tt.want), 12).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			job := jobs.Job{ID: 1, Tipo: JobGerarRelatorio, Payload: []byte(`{"exportacao_id":12}`), Tentativa: 1}
+			if err := GerarRelatorioJob(context.Background(), job); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGerarRelatorioJobFalha(t *testing.T) {
+	tests := []struct {
+		name      string
+		tentativa int
+		// wantFalhou diz se a exportação é marcada como falhou
+		wantFalhou bool
+	}{
+		{"retried", 1, false},
+		{"last attempt", maxTentativasRelatorio, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarExportacao(mock, models.RelatorioInconsistencias, models.ExportacaoPendente, "{8}")
+			esperarEmpresa(mock, "rejeitar")
+			mock.ExpectQuery(regexp.QuoteMeta("FROM pontos WHERE user_id = $1")).WillReturnError(errors.New("connection reset"))
+			if tt.wantFalhou {
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE exportacoes_relatorio SET status = $1, concluido_em = NOW()")).
+					WithArgs(models.ExportacaoFalhou, 12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			job := jobs.Job{ID: 1, Tipo: JobGerarRelatorio, Payload: []byte(`{"exportacao_id":12}`), Tentativa: tt.tentativa}
+			if err := GerarRelatorioJob(context.Background(), job); err == nil {
+				t.Error("GerarRelatorioJob() = nil, want the error so the job is retried")
+			}
+		})
+	}
+}
+
+func TestBaixarExportacaoRelatorio(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   int64
+		status   string
+		wantCode int
+	}{
+		{"done", 5, models.ExportacaoConcluida, http.StatusOK},
+		{"still pending", 5, models.ExportacaoPendente, http.StatusConflict},
+		{"failed", 5, models.ExportacaoFalhou, http.StatusConflict},
+		{"another user's", 6, models.ExportacaoConcluida, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarExportacao(mock, models.RelatorioInconsistencias, tt.status, "{5}")
+			if tt.wantCode == http.StatusOK {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT conteudo FROM exportacoes_relatorio")).WithArgs(12).
+					WillReturnRows(sqlmock.NewRows([]string{"conteudo"}).AddRow([]byte("user_id,data,tipo,descricao\n")))
+			}
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", "12")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+			ctx = context.WithValue(ctx, middleware.UserIDKey, tt.userID)
+			r := httptest.NewRequest(http.MethodGet, "/api/relatorios/12/arquivo", nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			BaixarExportacaoRelatorio(rec, r)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode == http.StatusOK {
+				if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+					t.Errorf("Content-Type = %q", got)
+				}
+				if got := rec.Body.String(); got != "user_id,data,tipo,descricao\n" {
+					t.Errorf("body = %q", got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/metrics"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"controle-ponto-api/webhooks"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// ToleranciaDerivaRelogio é a diferença máxima aceita entre o relógio do aparelho
+// e o relógio do servidor antes de o ponto ser marcado como suspeito.
+var ToleranciaDerivaRelogio = 5 * time.Minute
+
+// maxPontosPorSincronizacao limita o tamanho de um lote enviado pelo aplicativo.
+const maxPontosPorSincronizacao = 500
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Status possíveis de cada item de uma sincronização.
+const (
+	SyncStatusCriado    = "criado"
+	SyncStatusDuplicado = "duplicado"
+	SyncStatusMesclado  = "mesclado"
+	SyncStatusRejeitado = "rejeitado"
+	SyncStatusInvalido  = "invalido"
+)
+
+// PontoOfflinePayload é um ponto capturado pelo aplicativo sem conexão.
+type PontoOfflinePayload struct {
+	// ClientID é um UUID gerado pelo aparelho e usado como chave de idempotência.
+	ClientID string `json:"client_id"`
+	// HorarioDispositivo é o horário do relógio do aparelho no momento da captura.
+	HorarioDispositivo time.Time `json:"horario_dispositivo"`
+	// OffsetMonotonicoMs é o tempo decorrido, medido pelo relógio monotônico do aparelho,
+	// entre a captura do ponto e o envio da requisição.
+	OffsetMonotonicoMs *int64 `json:"offset_monotonico_ms,omitempty"`
+}
+
+// SincronizacaoPayload define o corpo da requisição de sincronização em lote.
+type SincronizacaoPayload struct {
+	Pontos []PontoOfflinePayload `json:"pontos"`
+}
+
+// SincronizacaoItemResultado é o resultado do processamento de um ponto do lote.
+type SincronizacaoItemResultado struct {
+	ClientID string        `json:"client_id"`
+	Status   string        `json:"status"`
+	Ponto    *models.Ponto `json:"ponto,omitempty"`
+	// Erro explica por que o ponto foi rejeitado.
+	Erro string `json:"erro,omitempty"`
+	// Erros são os campos inválidos de um item com status invalido, nomeados a partir do
+	// lote, como em pontos[3].horario_dispositivo.
+	Erros []validation.FieldError `json:"erros,omitempty"`
+}
+
+// SincronizacaoResposta é a resposta da sincronização em lote.
+type SincronizacaoResposta struct {
+	Resultados []SincronizacaoItemResultado `json:"resultados"`
+}
+
+// SincronizarPontos godoc
+// @Summary      Sincroniza pontos capturados offline
+// @Description  Recebe um lote de pontos capturados sem conexão, com o horário do aparelho, o offset monotônico e um UUID gerado pelo cliente. Reenvios do mesmo UUID não geram duplicidade, e pontos próximos demais de outro seguem a política de duplicidade da empresa. Horários do aparelho anteriores a 2000 ou no futuro, além da tolerância de deriva do relógio, são recusados.
+// @Tags         Pontos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        pontos  body      SincronizacaoPayload  true  "Lote de pontos capturados offline"
+// @Param        Idempotency-Key  header  string  false  "Chave para evitar registros duplicados em reenvios"
+// @Success      200     {object}  SincronizacaoResposta
+// @Failure      400     {object}  apierror.Problem  "Invalid request body"
+// @Failure      409     {object}  apierror.Problem  "Idempotency-Key conflict"
+// @Failure      500     {object}  apierror.Problem  "Internal server error"
+// @Router       /pontos/sync [post]
+func SincronizarPontos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	var payload SincronizacaoPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+
+	var v validation.Validator
+	v.Check(len(payload.Pontos) > 0, "pontos", validation.CodeRequired, "must not be empty")
+	v.Check(len(payload.Pontos) <= maxPontosPorSincronizacao, "pontos", validation.CodeOutOfRange,
+		"must have at most %d items", maxPontosPorSincronizacao)
+	if err := v.Err(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+
+	tx, err := database.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting transaction", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+		return
+	}
+	defer tx.Rollback()
+
+	if err := bloquearUsuario(r.Context(), tx, userID); err != nil {
+		slog.ErrorContext(r.Context(), "Error locking 'pontos' of user", "user_id", userID, "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), tx, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+		return
+	}
+
+	recebidoEm := time.Now()
+	var horariosCriados []time.Time
+	var criados []eventos.Evento
+	resposta := SincronizacaoResposta{Resultados: make([]SincronizacaoItemResultado, 0, len(payload.Pontos))}
+
+	locale := i18n.FromContext(r.Context())
+	for i, item := range payload.Pontos {
+		if erros := validarPontoOffline(i, item, recebidoEm); erros != nil {
+			resposta.Resultados = append(resposta.Resultados, SincronizacaoItemResultado{
+				ClientID: item.ClientID,
+				Status:   SyncStatusInvalido,
+				Erros: erros.Localize(func(format string, args ...interface{}) string {
+					return i18n.T(locale, format, args...)
+				}),
+			})
+			continue
+		}
+
+		resultado, err := sincronizarPonto(r.Context(), tx, userID, item, recebidoEm, empresa)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error synchronizing 'ponto'", "client_id", item.ClientID, "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+			return
+		}
+		if resultado.Status == SyncStatusCriado {
+			if err := webhooks.Enfileirar(r.Context(), tx, userID, eventos.TipoPontoCriado, dadosEventoPonto{Ponto: *resultado.Ponto}); err != nil {
+				slog.ErrorContext(r.Context(), "Error enqueuing webhook event", "error", err)
+				respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+				return
+			}
+			horariosCriados = append(horariosCriados, resultado.Ponto.Horario)
+			criados = append(criados, eventos.Evento{Tipo: eventos.TipoPontoCriado, Ponto: *resultado.Ponto})
+		}
+		resposta.Resultados = append(resposta.Resultados, resultado)
+	}
+
+	if err := enfileirarReavaliacao(r.Context(), tx, userID, empresa.Fuso(), horariosCriados...); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing 'violacoes' evaluation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+		return
+	}
+	if err := invalidarBancoDeHorasDosPontos(r.Context(), tx, userID, empresa.Fuso(), horariosCriados...); err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing time bank recomputation", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		slog.ErrorContext(r.Context(), "Error committing synchronized 'pontos'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to synchronize 'pontos'")
+		return
+	}
+
+	if len(criados) > 0 {
+		metrics.PontosRegistered.WithLabelValues(metrics.OriginSync).Add(float64(len(criados)))
+		publicarEventosPonto(r.Context(), userID, criados...)
+	}
+
+	respondWithJSON(w, http.StatusOK, resposta)
+}
+
+// validarPontoOffline retorna os campos inválidos do item de índice i do lote, ou nil.
+// O horário do aparelho vira o horário do ponto, por isso tem os mesmos limites de uma
+// alteração de ponto: relógios em 1970 ou em 2099 são recusados, não só sinalizados.
+func validarPontoOffline(i int, item PontoOfflinePayload, recebidoEm time.Time) validation.Errors {
+	campo := func(nome string) string {
+		return fmt.Sprintf("pontos[%d].%s", i, nome)
+	}
+	var v validation.Validator
+	v.Check(uuidRegex.MatchString(item.ClientID), campo("client_id"), validation.CodeInvalid, "must be a valid UUID")
+	v.TimeBetween(campo("horario_dispositivo"), item.HorarioDispositivo, horarioMinimoPonto, recebidoEm.Add(ToleranciaDerivaRelogio))
+	if item.OffsetMonotonicoMs != nil {
+		v.Check(*item.OffsetMonotonicoMs >= 0, campo("offset_monotonico_ms"), validation.CodeOutOfRange, "must not be negative")
+	}
+	erros, _ := v.Err().(validation.Errors)
+	return erros
+}
+
+// calcularDeriva estima a diferença entre o relógio do aparelho e o do servidor.
+// Com o offset monotônico, o horário real da captura é estimado como
+// recebidoEm - offset; sem ele, não há o que estimar, e horários no futuro
+// já foram recusados por validarPontoOffline.
+func calcularDeriva(item PontoOfflinePayload, recebidoEm time.Time) (*int64, bool) {
+	if item.OffsetMonotonicoMs == nil {
+		return nil, false
+	}
+
+	capturaEstimada := recebidoEm.Add(-time.Duration(*item.OffsetMonotonicoMs) * time.Millisecond)
+	deriva := item.HorarioDispositivo.Sub(capturaEstimada)
+	segundos := int64(deriva.Seconds())
+
+	if deriva < 0 {
+		deriva = -deriva
+	}
+	return &segundos, deriva > ToleranciaDerivaRelogio
+}
+
+// sincronizarPonto insere o ponto offline, ou devolve o ponto já existente
+// caso o mesmo client_id já tenha sido sincronizado pelo usuário. Pontos próximos
+// demais de outro seguem a política de duplicidade da empresa.
+func sincronizarPonto(ctx context.Context, tx *sql.Tx, userID int64, item PontoOfflinePayload, recebidoEm time.Time, empresa models.Empresa) (SincronizacaoItemResultado, error) {
+	resultado := SincronizacaoItemResultado{ClientID: item.ClientID}
+
+	existente, err := buscarPontoPorClientID(ctx, tx, userID, item.ClientID)
+	if err != nil && err != sql.ErrNoRows {
+		return resultado, err
+	}
+	if existente != nil {
+		resultado.Status = SyncStatusDuplicado
+		resultado.Ponto = existente
+		return resultado, nil
+	}
+
+	derivaSegundos, suspeita := calcularDeriva(item, recebidoEm)
+
+	ponto := &models.Ponto{
+		UserID:             userID,
+		Horario:            item.HorarioDispositivo,
+		ClientID:           &item.ClientID,
+		HorarioDispositivo: &item.HorarioDispositivo,
+		RecebidoEm:         &recebidoEm,
+		DerivaSegundos:     derivaSegundos,
+		DerivaSuspeita:     suspeita,
+	}
+
+	intervaloMinimo := time.Duration(empresa.IntervaloMinimoSegundos) * time.Second
+	proximo, err := pontoProximo(ctx, tx, userID, ponto.Horario, intervaloMinimo, 0)
+	if err != nil {
+		return resultado, err
+	}
+
+	if proximo != nil {
+		switch empresa.PoliticaDuplicidade {
+		case models.PoliticaDuplicidadeMesclar:
+			resultado.Status = SyncStatusMesclado
+			resultado.Ponto = proximo
+			return resultado, nil
+		case models.PoliticaDuplicidadeSinalizar:
+			ponto.DuplicidadeSuspeita = true
+		default:
+			resultado.Status = SyncStatusRejeitado
+			resultado.Erro = i18n.T(i18n.FromContext(ctx), "'Ponto' too close to the one registered at %s", proximo.Horario.Format(time.RFC3339))
+			return resultado, nil
+		}
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO pontos(user_id, horario, client_id, horario_dispositivo, recebido_em, deriva_segundos, deriva_suspeita, duplicidade_suspeita)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		userID, ponto.Horario, item.ClientID, item.HorarioDispositivo, recebidoEm, derivaSegundos, suspeita, ponto.DuplicidadeSuspeita,
+	).Scan(&ponto.ID)
+	if err != nil {
+		return resultado, err
+	}
+
+	resultado.Status = SyncStatusCriado
+	resultado.Ponto = ponto
+	return resultado, nil
+}
+
+func buscarPontoPorClientID(ctx context.Context, q queryer, userID int64, clientID string) (*models.Ponto, error) {
+	var p models.Ponto
+	var horarioDispositivo, recebidoEm sql.NullTime
+	var derivaSegundos sql.NullInt64
+	var cid string
+
+	err := q.QueryRowContext(ctx,
+		`SELECT id, user_id, horario, client_id, horario_dispositivo, recebido_em, deriva_segundos, deriva_suspeita, duplicidade_suspeita
+		FROM pontos WHERE user_id = $1 AND client_id = $2`,
+		userID, clientID,
+	).Scan(&p.ID, &p.UserID, &p.Horario, &cid, &horarioDispositivo, &recebidoEm, &derivaSegundos, &p.DerivaSuspeita, &p.DuplicidadeSuspeita)
+	if err != nil {
+		return nil, err
+	}
+
+	p.ClientID = &cid
+	if horarioDispositivo.Valid {
+		p.HorarioDispositivo = &horarioDispositivo.Time
+	}
+	if recebidoEm.Valid {
+		p.RecebidoEm = &recebidoEm.Time
+	}
+	if derivaSegundos.Valid {
+		p.DerivaSegundos = &derivaSegundos.Int64
+	}
+	return &p, nil
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const clientIDTeste = "3f2b8c1e-7a4d-4e9b-9c2f-1d5e6a7b8c9d"
+
+// sincronizar envia o lote ao SincronizarPontos como o usuário informado e decodifica a
+// resposta, que precisa ter o status dado.
+func sincronizar(t *testing.T, userID int64, pontos []map[string]interface{}, status int) SincronizacaoResposta {
+	t.Helper()
+	corpo, err := json.Marshal(map[string]interface{}{"pontos": pontos})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), middleware.UserIDKey, userID)
+	r := httptest.NewRequest(http.MethodPost, "/api/pontos/sync", strings.NewReader(string(corpo))).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	SincronizarPontos(rec, r)
+
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d; body %s", rec.Code, status, rec.Body)
+	}
+	var resposta SincronizacaoResposta
+	if status == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resposta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resposta
+}
+
+// esperarInicioSincronizacao espera a transação, o bloqueio do usuário e a empresa dele.
+func esperarInicioSincronizacao(mock sqlmock.Sqlmock, userID int64, politica string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("pg_advisory_xact_lock")).WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 0))
+	esperarEmpresa(mock, politica)
+}
+
+func TestSincronizarPontosItensInvalidos(t *testing.T) {
+	const userID = 8
+	agora := time.Now().UTC()
+	tests := []struct {
+		name  string
+		ponto map[string]interface{}
+		campo string
+		code  string
+	}{
+		{"client_id is not a UUID", map[string]interface{}{"client_id": "ponto-1", "horario_dispositivo": agora}, "client_id", validation.CodeInvalid},
+		{"missing horario_dispositivo", map[string]interface{}{"client_id": clientIDTeste}, "horario_dispositivo", validation.CodeRequired},
+		{"clock in the future", map[string]interface{}{"client_id": clientIDTeste, "horario_dispositivo": time.Date(2099, 1, 1, 8, 0, 0, 0, time.UTC)}, "horario_dispositivo", validation.CodeOutOfRange},
+		{"clock reset to 1970", map[string]interface{}{"client_id": clientIDTeste, "horario_dispositivo": time.Unix(3600, 0).UTC()}, "horario_dispositivo", validation.CodeOutOfRange},
+		{"negative offset", map[string]interface{}{"client_id": clientIDTeste, "horario_dispositivo": agora, "offset_monotonico_ms": -1}, "offset_monotonico_ms", validation.CodeOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			// Invalid items never reach the pontos table
+			esperarInicioSincronizacao(mock, userID, models.PoliticaDuplicidadeRejeitar)
+			mock.ExpectCommit()
+
+			// The item goes second, so the field path must carry its index
+			primeiro := map[string]interface{}{"client_id": "outro", "horario_dispositivo": agora}
+			resposta := sincronizar(t, userID, []map[string]interface{}{primeiro, tt.ponto}, http.StatusOK)
+
+			if len(resposta.Resultados) != 2 {
+				t.Fatalf("got %d results, want 2", len(resposta.Resultados))
+			}
+			got := resposta.Resultados[1]
+			want := "pontos[1]." + tt.campo
+			if got.Status != SyncStatusInvalido || len(got.Erros) != 1 || got.Erros[0].Field != want || got.Erros[0].Code != tt.code {
+				t.Errorf("result = %+v, want %s with one %s error on %s", got, SyncStatusInvalido, tt.code, want)
+			}
+		})
+	}
+}
+
+func TestSincronizarPontos(t *testing.T) {
+	const userID = 8
+	horario := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Millisecond)
+	dezMinutos := int64(10 * time.Minute / time.Millisecond)
+	zero := int64(0)
+
+	tests := []struct {
+		name     string
+		politica string
+		offsetMs *int64
+		// existente diz se o client_id já foi sincronizado; proximo, se há outro ponto a
+		// menos de um minuto
+		existente bool
+		proximo   bool
+		status    string
+		// deriva e duplicidade são gravados em deriva_suspeita e duplicidade_suspeita
+		deriva      bool
+		duplicidade bool
+	}{
+		{name: "created", politica: models.PoliticaDuplicidadeRejeitar, status: SyncStatusCriado},
+		{name: "replayed client_id", politica: models.PoliticaDuplicidadeRejeitar, existente: true, status: SyncStatusDuplicado},
+		{name: "too close and rejected", politica: models.PoliticaDuplicidadeRejeitar, proximo: true, status: SyncStatusRejeitado},
+		{name: "too close and merged", politica: models.PoliticaDuplicidadeMesclar, proximo: true, status: SyncStatusMesclado},
+		{name: "too close and flagged", politica: models.PoliticaDuplicidadeSinalizar, proximo: true, status: SyncStatusCriado, duplicidade: true},
+		{name: "offset matches the clock", politica: models.PoliticaDuplicidadeRejeitar, offsetMs: &dezMinutos, status: SyncStatusCriado},
+		// Sent right after the capture, but the clock says ten minutes ago
+		{name: "clock drift", politica: models.PoliticaDuplicidadeRejeitar, offsetMs: &zero, status: SyncStatusCriado, deriva: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := mockDB(t)
+			esperarInicioSincronizacao(mock, userID, tt.politica)
+
+			existente := sqlmock.NewRows([]string{"id", "user_id", "horario", "client_id", "horario_dispositivo", "recebido_em", "deriva_segundos", "deriva_suspeita", "duplicidade_suspeita"})
+			if tt.existente {
+				existente.AddRow(40, userID, horario, clientIDTeste, horario, horario.Add(time.Minute), nil, false, false)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta("FROM pontos WHERE user_id = $1 AND client_id = $2")).WithArgs(userID, clientIDTeste).WillReturnRows(existente)
+			if !tt.existente {
+				proximo := sqlmock.NewRows([]string{"id", "user_id", "horario"})
+				if tt.proximo {
+					proximo.AddRow(41, userID, horario.Add(20*time.Second))
+				}
+				mock.ExpectQuery(regexp.QuoteMeta("AND id <> $5")).
+					WithArgs(userID, horario.Add(-time.Minute), horario.Add(time.Minute), horario, 0).
+					WillReturnRows(proximo)
+			}
+			if tt.status == SyncStatusCriado {
+				mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO pontos")).
+					WithArgs(userID, horario, clientIDTeste, horario, sqlmock.AnyArg(), sqlmock.AnyArg(), tt.deriva, tt.duplicidade).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(42))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO webhook_entregas")).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jobs")).WillReturnResult(sqlmock.NewResult(0, 1))
+				esperarInvalidacaoBancoDeHoras(mock)
+			}
+			mock.ExpectCommit()
+			if tt.status == SyncStatusCriado {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT empresa_id, gestor_id FROM users")).
+					WillReturnRows(sqlmock.NewRows([]string{"empresa_id", "gestor_id"}).AddRow(3, nil))
+			}
+
+			ponto := map[string]interface{}{"client_id": clientIDTeste, "horario_dispositivo": horario}
+			if tt.offsetMs != nil {
+				ponto["offset_monotonico_ms"] = *tt.offsetMs
+			}
+			resposta := sincronizar(t, userID, []map[string]interface{}{ponto}, http.StatusOK)
+
+			if len(resposta.Resultados) != 1 {
+				t.Fatalf("got %d results, want 1", len(resposta.Resultados))
+			}
+			got := resposta.Resultados[0]
+			if got.Status != tt.status {
+				t.Fatalf("status = %q, want %q; result %+v", got.Status, tt.status, got)
+			}
+			switch tt.status {
+			case SyncStatusCriado:
+				if got.Ponto.ID != "42" || got.Ponto.DerivaSuspeita != tt.deriva || got.Ponto.DuplicidadeSuspeita != tt.duplicidade {
+					t.Errorf("ponto = %+v, want id 42, deriva_suspeita %v, duplicidade_suspeita %v", got.Ponto, tt.deriva, tt.duplicidade)
+				}
+			case SyncStatusDuplicado:
+				if got.Ponto.ID != "40" {
+					t.Errorf("ponto = %+v, want the one already synchronized", got.Ponto)
+				}
+			case SyncStatusMesclado:
+				if got.Ponto.ID != "41" {
+					t.Errorf("ponto = %+v, want the one nearby", got.Ponto)
+				}
+			case SyncStatusRejeitado:
+				if got.Ponto != nil || got.Erro == "" {
+					t.Errorf("result = %+v, want an error and no ponto", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSincronizarPontosLote(t *testing.T) {
+	tests := []struct {
+		name   string
+		pontos int
+	}{
+		{"empty", 0},
+		{"too many", maxPontosPorSincronizacao + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB(t)
+			pontos := make([]map[string]interface{}, tt.pontos)
+			for i := range pontos {
+				pontos[i] = map[string]interface{}{"client_id": clientIDTeste, "horario_dispositivo": time.Now()}
+			}
+			sincronizar(t, 8, pontos, http.StatusBadRequest)
+		})
+	}
+}
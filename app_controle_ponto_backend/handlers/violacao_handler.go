@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"context"
+	"controle-ponto-api/database"
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/regras"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// inicioDoDia trunca o horário para a meia-noite do seu dia no fuso informado.
+func inicioDoDia(horario time.Time, fuso *time.Location) time.Time {
+	ano, mes, dia := horario.In(fuso).Date()
+	return time.Date(ano, mes, dia, 0, 0, 0, 0, fuso)
+}
+
+// avaliarDia aplica o motor de regras às jornadas iniciadas no dia, contado no fuso da
+// empresa, e grava as violações encontradas, removendo as que deixaram de existir. Os pontos
+// vão de dois dias antes, para achar a jornada anterior, ao dia seguinte, onde termina uma
+// jornada que atravessa a meia-noite.
+func avaliarDia(ctx context.Context, q queryer, userID int64, motor *regras.Motor, dia time.Time, fuso *time.Location) ([]regras.Violacao, error) {
+	dia = inicioDoDia(dia, fuso)
+	data := dia.Format(jornada.FormatoData)
+
+	pontos, err := listarPontosDoPeriodo(ctx, q, userID, dia.AddDate(0, 0, -2), dia.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	violacoes := motor.Avaliar(regras.Dia{
+		UserID: userID,
+		Data:   data,
+		Fuso:   fuso,
+		Pontos: pontos,
+	})
+
+	tipos := make([]string, len(violacoes))
+	for i, v := range violacoes {
+		tipos[i] = v.Tipo
+	}
+	if _, err := q.ExecContext(ctx,
+		"DELETE FROM violacoes WHERE user_id = $1 AND data = $2 AND NOT (tipo = ANY($3))",
+		userID, data, pq.Array(tipos),
+	); err != nil {
+		return nil, err
+	}
+
+	for i := range violacoes {
+		v := &violacoes[i]
+		err := q.QueryRowContext(ctx,
+			`INSERT INTO violacoes (user_id, data, tipo, descricao, apurado_minutos, exigido_minutos)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (user_id, data, tipo) DO UPDATE
+				SET descricao = EXCLUDED.descricao,
+					apurado_minutos = EXCLUDED.apurado_minutos,
+					exigido_minutos = EXCLUDED.exigido_minutos
+			RETURNING id, detectada_em`,
+			userID, data, v.Tipo, v.Descricao, v.ApuradoMinutos, v.ExigidoMinutos,
+		).Scan(&v.ID, &v.DetectadaEm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return violacoes, nil
+}
+
+// reavaliarViolacoes reavalia os dias dos horários informados e os dias vizinhos: o ponto
+// pode pertencer a uma jornada iniciada na véspera, e a interjornada do dia seguinte depende
+// dele.
+func reavaliarViolacoes(ctx context.Context, q queryer, userID int64, empresa models.Empresa, horarios ...time.Time) error {
+	motor := regras.NovoMotor(regras.ConfigDaEmpresa(empresa))
+	fuso := empresa.Fuso()
+
+	dias := map[time.Time]bool{}
+	for _, h := range horarios {
+		dia := inicioDoDia(h, fuso)
+		dias[dia.AddDate(0, 0, -1)] = true
+		dias[dia] = true
+		dias[dia.AddDate(0, 0, 1)] = true
+	}
+
+	ordenados := make([]time.Time, 0, len(dias))
+	for dia := range dias {
+		ordenados = append(ordenados, dia)
+	}
+	sort.Slice(ordenados, func(i, j int) bool { return ordenados[i].Before(ordenados[j]) })
+
+	for _, dia := range ordenados {
+		if _, err := avaliarDia(ctx, q, userID, motor, dia, fuso); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// JobReavaliarViolacoes é o tipo da tarefa que reavalia as violações de um dia.
+const JobReavaliarViolacoes = "violacoes.reavaliar"
+
+// reavaliacaoPayload identifica o dia a reavaliar, no fuso da empresa.
+type reavaliacaoPayload struct {
+	UserID int64  `json:"user_id"`
+	Data   string `json:"data"`
+}
+
+// enfileirarReavaliacao agenda, na transação da alteração dos pontos, a reavaliação das
+// violações dos dias dos horários informados, contados no fuso da empresa. Alterações
+// seguidas no mesmo dia geram uma só tarefa.
+func enfileirarReavaliacao(ctx context.Context, q jobs.Executor, userID int64, fuso *time.Location, horarios ...time.Time) error {
+	for _, h := range horarios {
+		data := h.In(fuso).Format(jornada.FormatoData)
+		err := jobs.Enfileirar(ctx, q, JobReavaliarViolacoes, reavaliacaoPayload{UserID: userID, Data: data}, jobs.Opcoes{
+			ChaveUnica: fmt.Sprintf("%d:%s", userID, data),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReavaliarViolacoesJob executa a tarefa JobReavaliarViolacoes.
+func ReavaliarViolacoesJob(ctx context.Context, job jobs.Job) error {
+	var payload reavaliacaoPayload
+	if err := job.Decodificar(&payload); err != nil {
+		return err
+	}
+	empresa, err := carregarEmpresaDoUsuario(ctx, database.DB, payload.UserID)
+	if err != nil {
+		return err
+	}
+	dia, err := time.ParseInLocation(jornada.FormatoData, payload.Data, empresa.Fuso())
+	if err != nil {
+		return err
+	}
+	return reavaliarViolacoes(ctx, database.DB, payload.UserID, empresa, dia)
+}
+
+// violacoesDoDia retorna as violações já apuradas do usuário na data informada.
+func violacoesDoDia(ctx context.Context, q queryer, userID int64, data string) ([]regras.Violacao, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, user_id, tipo, descricao, apurado_minutos, exigido_minutos, detectada_em
+		FROM violacoes WHERE user_id = $1 AND data = $2
+		ORDER BY tipo ASC`,
+		userID, data,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	violacoes := []regras.Violacao{}
+	for rows.Next() {
+		v := regras.Violacao{Data: data}
+		if err := rows.Scan(&v.ID, &v.UserID, &v.Tipo, &v.Descricao, &v.ApuradoMinutos, &v.ExigidoMinutos, &v.DetectadaEm); err != nil {
+			return nil, err
+		}
+		violacoes = append(violacoes, v)
+	}
+	return violacoes, rows.Err()
+}
+
+// ListarViolacoes godoc
+// @Summary      Lista violações de jornada
+// @Description  Lista as violações de interjornada e intrajornada registradas no período. Gestores e administradores podem consultar um usuário da equipe com user_id ou toda a equipe com equipe=true.
+// @Tags         Violações
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        inicio   query     string  false  "Data inicial no formato YYYY-MM-DD (padrão: 29 dias atrás)"
+// @Param        fim      query     string  false  "Data final no formato YYYY-MM-DD (padrão: hoje)"
+// @Param        user_id  query     int     false  "ID do usuário consultado"
+// @Param        equipe   query     bool    false  "Lista as violações de toda a equipe"
+// @Success      200      {array}   regras.Violacao
+// @Failure      400      {object}  apierror.Problem  "Invalid query parameters"
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /violacoes [get]
+func ListarViolacoes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return
+	}
+
+	empresa, err := carregarEmpresaDoUsuario(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading 'empresa' rules", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'violacoes'")
+		return
+	}
+
+	inicio, fim, err := parsePeriodo(r, empresa.Fuso())
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'violacoes'")
+		return
+	}
+
+	ids := []int64{userID}
+	switch {
+	case r.URL.Query().Get("equipe") == "true":
+		if !solicitante.ehGestor() {
+			respondWithError(w, r, http.StatusForbidden, "Only managers can list the team's 'violacoes'")
+			return
+		}
+		if ids, err = usuariosGerenciados(r.Context(), database.DB, solicitante); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing managed users", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'violacoes'")
+			return
+		}
+	case r.URL.Query().Get("user_id") != "":
+		alvoID, err := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		permitido, err := podeGerenciar(r.Context(), database.DB, solicitante, alvoID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking permissions", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'violacoes'")
+			return
+		}
+		if !permitido {
+			respondWithError(w, r, http.StatusForbidden, "You don't have permission to see this user's 'violacoes'")
+			return
+		}
+		ids = []int64{alvoID}
+	}
+
+	rows, err := database.DB.QueryContext(r.Context(),
+		`SELECT id, user_id, data, tipo, descricao, apurado_minutos, exigido_minutos, detectada_em
+		FROM violacoes WHERE user_id = ANY($1) AND data >= $2 AND data <= $3
+		ORDER BY data ASC, user_id ASC, tipo ASC`,
+		pq.Array(ids), inicio.Format(jornada.FormatoData), fim.Format(jornada.FormatoData),
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying 'violacoes'", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve 'violacoes'")
+		return
+	}
+	defer rows.Close()
+
+	violacoes := []regras.Violacao{}
+	for rows.Next() {
+		var v regras.Violacao
+		var data time.Time
+		if err := rows.Scan(&v.ID, &v.UserID, &data, &v.Tipo, &v.Descricao, &v.ApuradoMinutos, &v.ExigidoMinutos, &v.DetectadaEm); err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning 'violacao' row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process 'violacoes' data")
+			return
+		}
+		v.Data = data.Format(jornada.FormatoData)
+		violacoes = append(violacoes, v)
+	}
+
+	respondWithJSON(w, http.StatusOK, violacoes)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInicioDoDia(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		horario time.Time
+		fuso    *time.Location
+		want    string
+	}{
+		{"UTC", time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC), time.UTC, "2024-03-01T00:00:00Z"},
+		// 01:30 UTC is still 22:30 of the previous day in São Paulo
+		{"overnight shift exit", time.Date(2024, 3, 1, 1, 30, 0, 0, time.UTC), saoPaulo, "2024-02-29T00:00:00-03:00"},
+		{"local evening", time.Date(2024, 3, 1, 23, 0, 0, 0, saoPaulo), saoPaulo, "2024-03-01T00:00:00-03:00"},
+		{"local midnight", time.Date(2024, 3, 1, 3, 0, 0, 0, time.UTC), saoPaulo, "2024-03-01T00:00:00-03:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inicioDoDia(tt.horario, tt.fuso).Format(time.RFC3339); got != tt.want {
+				t.Errorf("inicioDoDia = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/database"
+	"controle-ponto-api/middleware"
+	"controle-ponto-api/models"
+	"controle-ponto-api/validation"
+	"controle-ponto-api/webhooks"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/lib/pq"
+)
+
+// maxEntregasListadas limita o log de entregas devolvido por requisição.
+const maxEntregasListadas = 200
+
+// WebhookPayload define o corpo da requisição de cadastro de um webhook.
+type WebhookPayload struct {
+	URL     string   `json:"url"`
+	Eventos []string `json:"eventos"`
+	// Segredo usado na assinatura; gerado automaticamente se vazio.
+	Segredo string `json:"segredo"`
+}
+
+func (p WebhookPayload) validar() error {
+	var v validation.Validator
+	u, err := url.Parse(p.URL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", validation.CodeInvalid, "must be an absolute http or https URL")
+	v.Check(len(p.Eventos) > 0, "eventos", validation.CodeRequired, "must not be empty")
+	for i, e := range p.Eventos {
+		v.OneOf(fmt.Sprintf("eventos[%d]", i), e, webhooks.EventosSuportados...)
+	}
+	v.Check(p.Segredo == "" || len(p.Segredo) >= 16, "segredo", validation.CodeInvalid, "must have at least 16 characters")
+	return v.Err()
+}
+
+// dadosEventoPonto são os dados dos eventos de ponto entregues aos webhooks.
+type dadosEventoPonto struct {
+	Ponto           models.Ponto `json:"ponto"`
+	HorarioAnterior *time.Time   `json:"horario_anterior,omitempty"`
+}
+
+// dadosEventoAfastamento são os dados dos eventos de afastamento entregues aos webhooks.
+type dadosEventoAfastamento struct {
+	Afastamento models.Afastamento `json:"afastamento"`
+}
+
+// dadosEventoAjuste são os dados dos eventos de ajuste de ponto entregues aos webhooks.
+type dadosEventoAjuste struct {
+	Ajuste models.AjustePonto `json:"ajuste"`
+}
+
+// carregarAdmin lê o perfil do usuário e responde 403 se ele não for administrador.
+func carregarAdmin(w http.ResponseWriter, r *http.Request, falha string) (perfil, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not retrieve user ID from context")
+		return perfil{}, false
+	}
+
+	solicitante, err := carregarPerfil(r.Context(), database.DB, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user profile", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, falha)
+		return perfil{}, false
+	}
+	if !solicitante.ehAdmin() {
+		respondWithError(w, r, http.StatusForbidden, "Only administrators can manage webhooks")
+		return perfil{}, false
+	}
+	return solicitante, true
+}
+
+// webhookDaEmpresa lê o ID da rota e verifica se o webhook pertence à empresa do administrador.
+func webhookDaEmpresa(w http.ResponseWriter, r *http.Request, p perfil, falha string) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return 0, false
+	}
+
+	var existe bool
+	err = database.DB.QueryRowContext(r.Context(), "SELECT EXISTS(SELECT 1 FROM webhooks WHERE id = $1 AND empresa_id = $2)", id, p.EmpresaID.Int64).Scan(&existe)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading webhook", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, falha)
+		return 0, false
+	}
+	if !existe {
+		respondWithError(w, r, http.StatusNotFound, "Webhook not found")
+		return 0, false
+	}
+	return id, true
+}
+
+// CriarWebhook godoc
+// @Summary      Cadastra um webhook
+// @Description  Cadastra um endpoint que recebe, via POST, os eventos escolhidos da empresa. Cada entrega é assinada no cabeçalho X-Webhook-Assinatura com HMAC-SHA256 de "<X-Webhook-Timestamp>.<corpo>". O segredo só é devolvido nesta resposta. Restrito a administradores.
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        webhook  body      WebhookPayload  true  "URL e eventos assinados"
+// @Success      201      {object}  models.Webhook
+// @Failure      400      {object}  apierror.Problem  "Invalid request body"
+// @Failure      403      {object}  apierror.Problem  "Permission denied"
+// @Failure      500      {object}  apierror.Problem  "Internal server error"
+// @Router       /webhooks [post]
+func CriarWebhook(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdmin(w, r, "Failed to create webhook")
+	if !ok {
+		return
+	}
+
+	var payload WebhookPayload
+	if !decodificarJSON(w, r, &payload) {
+		return
+	}
+	if err := payload.validar(); err != nil {
+		respondWithValidationError(w, r, err)
+		return
+	}
+	if payload.Segredo == "" {
+		payload.Segredo = webhooks.NovoSegredo()
+	}
+
+	webhook := models.Webhook{
+		EmpresaID: solicitante.EmpresaID.Int64,
+		URL:       payload.URL,
+		Eventos:   payload.Eventos,
+		Ativo:     true,
+		Segredo:   payload.Segredo,
+	}
+	err := database.DB.QueryRowContext(r.Context(),
+		"INSERT INTO webhooks (empresa_id, url, segredo, eventos) VALUES ($1, $2, $3, $4) RETURNING id, criado_em",
+		webhook.EmpresaID, webhook.URL, webhook.Segredo, pq.Array(webhook.Eventos),
+	).Scan(&webhook.ID, &webhook.CriadoEm)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error inserting webhook", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, webhook)
+}
+
+// ListarWebhooks godoc
+// @Summary      Lista os webhooks
+// @Description  Lista os webhooks cadastrados na empresa, sem os segredos. Restrito a administradores.
+// @Tags         Webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   models.Webhook
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /webhooks [get]
+func ListarWebhooks(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdmin(w, r, "Failed to retrieve webhooks")
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.QueryContext(r.Context(),
+		"SELECT id, empresa_id, url, eventos, ativo, criado_em FROM webhooks WHERE empresa_id = $1 ORDER BY id ASC",
+		solicitante.EmpresaID.Int64,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying webhooks", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve webhooks")
+		return
+	}
+	defer rows.Close()
+
+	lista := []models.Webhook{}
+	for rows.Next() {
+		var wh models.Webhook
+		if err := rows.Scan(&wh.ID, &wh.EmpresaID, &wh.URL, pq.Array(&wh.Eventos), &wh.Ativo, &wh.CriadoEm); err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning webhook row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process webhooks")
+			return
+		}
+		lista = append(lista, wh)
+	}
+
+	respondWithJSON(w, http.StatusOK, lista)
+}
+
+// RemoverWebhook godoc
+// @Summary      Remove um webhook
+// @Description  Remove o webhook e o seu log de entregas. Restrito a administradores.
+// @Tags         Webhooks
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "ID do webhook"
+// @Success      204  {string}  string  "No Content"
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "Webhook not found"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /webhooks/{id} [delete]
+func RemoverWebhook(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdmin(w, r, "Failed to delete webhook")
+	if !ok {
+		return
+	}
+	id, ok := webhookDaEmpresa(w, r, solicitante, "Failed to delete webhook")
+	if !ok {
+		return
+	}
+
+	if _, err := database.DB.ExecContext(r.Context(), "DELETE FROM webhooks WHERE id = $1", id); err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting webhook", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestarWebhook godoc
+// @Summary      Envia um evento de teste
+// @Description  Enfileira um evento webhook.teste para o webhook, útil para validar a assinatura em um servidor local. Restrito a administradores.
+// @Tags         Webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path      int  true  "ID do webhook"
+// @Success      202  {object}  map[string]string
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "Webhook not found"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /webhooks/{id}/testar [post]
+func TestarWebhook(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdmin(w, r, "Failed to test webhook")
+	if !ok {
+		return
+	}
+	id, ok := webhookDaEmpresa(w, r, solicitante, "Failed to test webhook")
+	if !ok {
+		return
+	}
+
+	eventoID, err := webhooks.EnfileirarTeste(r.Context(), database.DB, id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error enqueuing webhook test", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to test webhook")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"evento_id": eventoID})
+}
+
+// ListarEntregasWebhook godoc
+// @Summary      Log de entregas de um webhook
+// @Description  Lista as entregas mais recentes do webhook com o status, o número de tentativas, o último código HTTP e o último erro. Restrito a administradores.
+// @Tags         Webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path      int     true   "ID do webhook"
+// @Param        status  query     string  false  "Filtra pelo status (pendente, entregue, falhou)"
+// @Success      200     {array}   models.EntregaWebhook
+// @Failure      403     {object}  apierror.Problem  "Permission denied"
+// @Failure      404     {object}  apierror.Problem  "Webhook not found"
+// @Failure      500     {object}  apierror.Problem  "Internal server error"
+// @Router       /webhooks/{id}/entregas [get]
+func ListarEntregasWebhook(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdmin(w, r, "Failed to retrieve deliveries")
+	if !ok {
+		return
+	}
+	id, ok := webhookDaEmpresa(w, r, solicitante, "Failed to retrieve deliveries")
+	if !ok {
+		return
+	}
+
+	query := `SELECT id, webhook_id, evento_id, tipo, payload, status, tentativas, proxima_tentativa_em,
+			ultima_tentativa_em, ultimo_status_http, ultimo_erro, criado_em, entregue_em
+		FROM webhook_entregas WHERE webhook_id = $1`
+	args := []interface{}{id}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	query += " ORDER BY criado_em DESC, id DESC LIMIT " + strconv.Itoa(maxEntregasListadas)
+
+	rows, err := database.DB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error querying webhook deliveries", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retrieve deliveries")
+		return
+	}
+	defer rows.Close()
+
+	entregas := []models.EntregaWebhook{}
+	for rows.Next() {
+		var e models.EntregaWebhook
+		var proxima, ultima, entregue sql.NullTime
+		var statusHTTP sql.NullInt64
+		err := rows.Scan(&e.ID, &e.WebhookID, &e.EventoID, &e.Tipo, &e.Payload, &e.Status, &e.Tentativas, &proxima,
+			&ultima, &statusHTTP, &e.UltimoErro, &e.CriadoEm, &entregue)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning webhook delivery row", "error", err)
+			respondWithError(w, r, http.StatusInternalServerError, "Failed to process deliveries")
+			return
+		}
+		if e.Status == models.EntregaPendente && proxima.Valid {
+			e.ProximaTentativaEm = &proxima.Time
+		}
+		if ultima.Valid {
+			e.UltimaTentativaEm = &ultima.Time
+		}
+		if entregue.Valid {
+			e.EntregueEm = &entregue.Time
+		}
+		if statusHTTP.Valid {
+			s := int(statusHTTP.Int64)
+			e.UltimoStatusHTTP = &s
+		}
+		entregas = append(entregas, e)
+	}
+
+	respondWithJSON(w, http.StatusOK, entregas)
+}
+
+// ReenviarEntregaWebhook godoc
+// @Summary      Reenvia uma entrega que falhou
+// @Description  Devolve à fila uma entrega que esgotou as tentativas, zerando o contador. Restrito a administradores.
+// @Tags         Webhooks
+// @Security     ApiKeyAuth
+// @Param        id          path  int  true  "ID do webhook"
+// @Param        entrega_id  path  int  true  "ID da entrega"
+// @Success      202  {string}  string  "Accepted"
+// @Failure      403  {object}  apierror.Problem  "Permission denied"
+// @Failure      404  {object}  apierror.Problem  "Delivery not found"
+// @Failure      409  {object}  apierror.Problem  "Delivery has not failed"
+// @Failure      500  {object}  apierror.Problem  "Internal server error"
+// @Router       /webhooks/{id}/entregas/{entrega_id}/reenviar [post]
+func ReenviarEntregaWebhook(w http.ResponseWriter, r *http.Request) {
+	solicitante, ok := carregarAdmin(w, r, "Failed to retry delivery")
+	if !ok {
+		return
+	}
+	id, ok := webhookDaEmpresa(w, r, solicitante, "Failed to retry delivery")
+	if !ok {
+		return
+	}
+	entregaID, err := strconv.ParseInt(chi.URLParam(r, "entrega_id"), 10, 64)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	var status string
+	err = database.DB.QueryRowContext(r.Context(), "SELECT status FROM webhook_entregas WHERE id = $1 AND webhook_id = $2", entregaID, id).Scan(&status)
+	if err == sql.ErrNoRows {
+		respondWithError(w, r, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading webhook delivery", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retry delivery")
+		return
+	}
+	if status != models.EntregaFalhou {
+		respondWithProblem(w, r, http.StatusConflict, apierror.CodeDeliveryNotFailed, "Only failed deliveries can be retried")
+		return
+	}
+
+	_, err = database.DB.ExecContext(r.Context(),
+		"UPDATE webhook_entregas SET status = $1, tentativas = 0, proxima_tentativa_em = $2 WHERE id = $3 AND status = $4",
+		models.EntregaPendente, time.Now(), entregaID, models.EntregaFalhou,
+	)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error retrying webhook delivery", "error", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Failed to retry delivery")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
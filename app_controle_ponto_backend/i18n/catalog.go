@@ -0,0 +1,471 @@
+package i18n
+
+// catalog maps each English message to its translation, by locale. English needs no
+// entries. Arguments keep their order, so every translation must use the same verbs as the
+// English message.
+var catalog = map[string]map[string]string{
+	PortugueseBR: {
+		// Error titles
+		"Bad Request":                               "Requisição inválida",
+		"Unauthorized":                              "Não autorizado",
+		"Forbidden":                                 "Acesso negado",
+		"Not Found":                                 "Não encontrado",
+		"Method Not Allowed":                        "Método não permitido",
+		"Conflict":                                  "Conflito",
+		"Request Entity Too Large":                  "Requisição grande demais",
+		"Locked":                                    "Bloqueado",
+		"Too Many Requests":                         "Requisições demais",
+		"Internal Server Error":                     "Erro interno do servidor",
+		"Bad Gateway":                               "Falha em serviço externo",
+		"Validation failed":                         "Dados inválidos",
+		"Malformed request body":                    "Corpo da requisição malformado",
+		"Authentication required":                   "Autenticação necessária",
+		"Invalid or expired session":                "Sessão inválida ou expirada",
+		"Invalid credentials":                       "Email ou senha incorretos",
+		"Email already registered":                  "Email já cadastrado",
+		"Email not verified":                        "Email não verificado",
+		"Account temporarily locked":                "Conta bloqueada temporariamente",
+		"Too many login attempts":                   "Tentativas de login demais",
+		"Too many requests":                         "Requisições demais",
+		"Invalid or expired link":                   "Link inválido ou expirado",
+		"Invalid or expired two-factor challenge":   "Desafio de dois fatores inválido ou expirado",
+		"Invalid two-factor code":                   "Código de dois fatores inválido",
+		"Two-factor authentication required":        "Autenticação de dois fatores obrigatória",
+		"Two-factor authentication already enabled": "Autenticação de dois fatores já ativada",
+		"Two-factor enrollment not started":         "Cadastro de dois fatores não iniciado",
+		"No identity provider configured":           "Nenhum provedor de identidade configurado",
+		"Identity provider unavailable":             "Provedor de identidade indisponível",
+		"Identity provider discovery failed":        "Falha ao consultar o provedor de identidade",
+		"Email domain already in use":               "Domínio de email já em uso",
+		"Domain ownership not verified":             "Posse do domínio não verificada",
+		"Punch too close to another one":            "Ponto muito próximo de outro",
+		"Overlapping leave request":                 "Afastamento sobreposto",
+		"Leave request already decided":             "Afastamento já decidido",
+		"Adjustment request already decided":        "Ajuste de ponto já decidido",
+		"Attachment required":                       "Anexo obrigatório",
+		"Delivery did not fail":                     "A entrega não falhou",
+		"Report not ready":                          "Relatório não gerado",
+		"Idempotency key reused":                    "Chave de idempotência reutilizada",
+		"Request still in progress":                 "Requisição ainda em andamento",
+
+		// Field errors
+		"is required":                                             "é obrigatório",
+		"must not be empty":                                       "não pode ser vazio",
+		"must be of type %s, got %s":                              "deve ser do tipo %s, recebido %s",
+		"must have at most %d characters":                         "deve ter no máximo %d caracteres",
+		"must have at most %d items":                              "deve ter no máximo %d itens",
+		"must have at least 16 characters":                        "deve ter no mínimo 16 caracteres",
+		"must be a valid email address":                           "deve ser um endereço de email válido",
+		"must be a valid UUID":                                    "deve ser um UUID válido",
+		"is not a known field":                                    "não é um campo conhecido",
+		"must be one of: %s":                                      "deve ser um destes: %s",
+		"must be a date in the YYYY-MM-DD format":                 "deve ser uma data no formato AAAA-MM-DD",
+		"must be between %s and %s":                               "deve estar entre %s e %s",
+		"must use the HH:MM format":                               "deve usar o formato HH:MM",
+		"must not be negative":                                    "não pode ser negativo",
+		"must be positive":                                        "deve ser positivo",
+		"must not be before data_inicio":                          "não pode ser anterior a data_inicio",
+		"must not be before inicio":                               "não pode ser anterior a inicio",
+		"can't be combined with equipe":                           "não pode ser combinado com equipe",
+		"is not a user you manage":                                "não é um usuário que você gerencia",
+		"the period must be shorter than %d days":                 "o período deve ter menos de %d dias",
+		"must be a hex-encoded SHA-256 digest":                    "deve ser um hash SHA-256 em hexadecimal",
+		"must be an absolute http or https URL":                   "deve ser uma URL http ou https absoluta",
+		"must be an email domain, such as empresa.com.br":         "deve ser um domínio de email, como empresa.com.br",
+		"must be an absolute https URL without query or fragment": "deve ser uma URL https absoluta, sem query nem fragmento",
+		"must be an IANA time zone":                               "deve ser um fuso horário IANA",
+		"is not a leave type available to you":                    "não é um tipo de afastamento disponível para você",
+		"is not one of your pontos":                               "não é um dos seus pontos",
+		"was used recently; choose another one":                   "foi usada recentemente; escolha outra",
+		"must have at least %d characters":                        "deve ter no mínimo %d caracteres",
+		"must have at most %d bytes":                              "deve ter no máximo %d bytes",
+		"must not contain your name or email":                     "não pode conter seu nome ou email",
+		"is too easy to guess (strength %d of 4, minimum %d)":     "é fácil demais de adivinhar (força %d de 4, mínimo %d)",
+		"appeared in a data breach; choose another one":           "apareceu em um vazamento de dados; escolha outra",
+
+		// Error details
+		"Failed to retrieve leave types":                                      "Falha ao consultar os tipos de afastamento",
+		"Failed to process leave types":                                       "Falha ao processar os tipos de afastamento",
+		"There is already a pending or approved leave request in this period": "Já existe um afastamento pendente ou aprovado neste período",
+		"Failed to create leave request":                                      "Falha ao criar o afastamento",
+		"Only managers can list the team's leave requests":                    "Só gestores podem listar os afastamentos da equipe",
+		"You don't have permission to see this user's leave requests":         "Você não tem permissão para ver os afastamentos deste usuário",
+		"Failed to process leave requests":                                    "Falha ao processar os afastamentos",
+		"Failed to retrieve leave requests":                                   "Falha ao consultar os afastamentos",
+		"Leave request not found or you don't have permission to change it":   "Afastamento não encontrado ou você não tem permissão para alterá-lo",
+		"Attachments can only be added to pending leave requests":             "Só é possível anexar arquivos a afastamentos pendentes",
+		"Failed to add attachment":                                            "Falha ao adicionar o anexo",
+		"Leave request not found":                                             "Afastamento não encontrado",
+		"You don't have permission to decide on this leave request":           "Você não tem permissão para decidir este afastamento",
+		"Leave request is %s and can't be changed to %s":                      "O afastamento está %s e não pode passar a %s",
+		"Leave request was changed by another request":                        "O afastamento foi alterado por outra requisição",
+		"This leave type requires an attachment before approval":              "Este tipo de afastamento exige um anexo antes da aprovação",
+		"Failed to update leave request":                                      "Falha ao atualizar o afastamento",
+		"Failed to create adjustment request":                                 "Falha ao criar a solicitação de ajuste",
+		"Failed to retrieve adjustment requests":                              "Falha ao buscar as solicitações de ajuste",
+		"Failed to update adjustment request":                                 "Falha ao atualizar a solicitação de ajuste",
+		"Only managers can list the team's adjustment requests":               "Apenas gestores podem listar os ajustes da equipe",
+		"You don't have permission to see this user's adjustment requests":    "Você não tem permissão para ver os ajustes deste usuário",
+		"Adjustment request not found":                                        "Solicitação de ajuste não encontrada",
+		"You don't have permission to decide on this adjustment request":      "Você não tem permissão para decidir sobre este ajuste",
+		"Adjustment request is %s and can't be changed to %s":                 "O ajuste está %s e não pode passar para %s",
+		"Adjustment request was changed by another request":                   "O ajuste foi alterado por outra requisição",
+		"Failed to hash password":                                             "Falha ao processar a senha",
+		"Failed to create user":                                               "Falha ao criar o usuário",
+		"You don't have permission to see this user's time bank":              "Você não tem permissão para ver o banco de horas deste usuário",
+		"Failed to calculate time bank":                                       "Falha ao calcular o banco de horas",
+		"You don't have permission to unlock this user's login":               "Você não tem permissão para desbloquear o login deste usuário",
+		"User not found":                                               "Usuário não encontrado",
+		"Failed to unlock login":                                       "Falha ao desbloquear o login",
+		"Invalid or expired token":                                     "Token inválido ou expirado",
+		"Invalid password":                                             "Senha inválida",
+		"Failed to update language":                                    "Falha ao atualizar o idioma",
+		"Only administrators can change the 'empresa' rules":           "Só administradores podem alterar as regras da empresa",
+		"Failed to update 'empresa' rules":                             "Falha ao atualizar as regras da empresa",
+		"Failed to retrieve 'empresa' rules":                           "Falha ao consultar as regras da empresa",
+		"Only managers can see the team status":                        "Só gestores podem ver a situação da equipe",
+		"Failed to retrieve team status":                               "Falha ao consultar a situação da equipe",
+		"Invalid user ID":                                              "ID de usuário inválido",
+		"You don't have permission to change this user's schedule":     "Você não tem permissão para alterar o horário deste usuário",
+		"Failed to update schedule":                                    "Falha ao atualizar o horário",
+		"Failed to open event stream":                                  "Falha ao abrir o fluxo de eventos",
+		"Only managers can follow the event stream":                    "Só gestores podem acompanhar o fluxo de eventos",
+		"Only administrators can follow the whole 'empresa'":           "Só administradores podem acompanhar a empresa inteira",
+		"escopo must be one of: equipe, empresa":                       "escopo deve ser um destes: equipe, empresa",
+		"Invalid Last-Event-ID":                                        "Last-Event-ID inválido",
+		"Invalid or expired MFA challenge":                             "Desafio de dois fatores inválido ou expirado",
+		"Failed to retrieve MFA status":                                "Falha ao consultar a autenticação de dois fatores",
+		"MFA is mandatory for this user":                               "A autenticação de dois fatores é obrigatória para este usuário",
+		"Failed to disable TOTP":                                       "Falha ao desativar o TOTP",
+		"Failed to generate recovery codes":                            "Falha ao gerar os códigos de recuperação",
+		"Failed to start TOTP enrollment":                              "Falha ao iniciar o cadastro do TOTP",
+		"TOTP is already enabled":                                      "O TOTP já está ativado",
+		"TOTP enrollment not started":                                  "Cadastro do TOTP não iniciado",
+		"Failed to confirm TOTP enrollment":                            "Falha ao confirmar o cadastro do TOTP",
+		"Invalid code":                                                 "Código inválido",
+		"Internal server error":                                        "Erro interno do servidor",
+		"email or empresa_id is required":                              "email ou empresa_id é obrigatório",
+		"Failed to start login":                                        "Falha ao iniciar o login",
+		"Invalid or expired login session":                             "Sessão de login inválida ou expirada",
+		"Identity provider is no longer active":                        "O provedor de identidade não está mais ativo",
+		"Identity provider login failed":                               "O login no provedor de identidade falhou",
+		"User is not allowed to sign in with this provider":            "Usuário sem permissão para entrar por este provedor",
+		"Failed to create token":                                       "Falha ao gerar o token",
+		"Failed to complete login":                                     "Falha ao concluir o login",
+		"Invalid or expired link token":                                "Token de vínculo inválido ou expirado",
+		"Account already belongs to an empresa":                        "A conta já pertence a uma empresa",
+		"Failed to link account":                                       "Falha ao vincular a conta",
+		"Failed to save identity provider":                             "Falha ao salvar o provedor de identidade",
+		"Domain not configured in the identity provider":               "Domínio não configurado no provedor de identidade",
+		"Verification TXT record not found":                            "Registro TXT de verificação não encontrado",
+		"Failed to verify domain":                                      "Falha ao verificar o domínio",
+		"Email domain already used by another empresa":                 "Domínio de email já usado por outra empresa",
+		"Failed to retrieve identity provider":                         "Falha ao consultar o provedor de identidade",
+		"Failed to delete identity provider":                           "Falha ao excluir o provedor de identidade",
+		"Failed to load user profile":                                  "Falha ao carregar o perfil do usuário",
+		"Only administrators can manage the identity provider":         "Só administradores podem gerenciar o provedor de identidade",
+		"'Ponto' too close to the one registered at %s":                "Ponto muito próximo do registrado em %s",
+		"Failed to register 'ponto'":                                   "Falha ao registrar o ponto",
+		"Failed to retrieve 'pontos'":                                  "Falha ao consultar os pontos",
+		"Failed to process 'pontos' data":                              "Falha ao processar os pontos",
+		"Invalid date format. Use YYYY-MM-DD":                          "Formato de data inválido. Use AAAA-MM-DD",
+		"Failed to retrieve 'pontos' for calculation":                  "Falha ao consultar os pontos para o cálculo",
+		"Failed to process 'horarios' for calculation":                 "Falha ao processar os horários para o cálculo",
+		"Failed to retrieve 'afastamentos' for calculation":            "Falha ao consultar os afastamentos para o cálculo",
+		"'Ponto' not found or you don't have permission to update it":  "Ponto não encontrado ou você não tem permissão para alterá-lo",
+		"Failed to update 'ponto'":                                     "Falha ao atualizar o ponto",
+		"Invalid request body":                                         "Corpo da requisição inválido",
+		"'Ponto' not found or you don't have permission to delete it":  "Ponto não encontrado ou você não tem permissão para excluí-lo",
+		"Failed to delete 'ponto'":                                     "Falha ao excluir o ponto",
+		"Failed to synchronize 'pontos'":                               "Falha ao sincronizar os pontos",
+		"Only managers can list the team's 'violacoes'":                "Só gestores podem listar as violações da equipe",
+		"Invalid user_id":                                              "user_id inválido",
+		"You don't have permission to see this user's 'violacoes'":     "Você não tem permissão para ver as violações deste usuário",
+		"Failed to retrieve 'violacoes'":                               "Falha ao consultar as violações",
+		"Failed to process 'violacoes' data":                           "Falha ao processar as violações",
+		"Failed to create webhook":                                     "Falha ao criar o webhook",
+		"Failed to retrieve webhooks":                                  "Falha ao consultar os webhooks",
+		"Failed to process webhooks":                                   "Falha ao processar os webhooks",
+		"Failed to delete webhook":                                     "Falha ao excluir o webhook",
+		"Failed to test webhook":                                       "Falha ao testar o webhook",
+		"Failed to retrieve deliveries":                                "Falha ao consultar as entregas",
+		"Failed to process deliveries":                                 "Falha ao processar as entregas",
+		"Invalid ID format":                                            "Formato de ID inválido",
+		"Delivery not found":                                           "Entrega não encontrada",
+		"Only failed deliveries can be retried":                        "Só entregas que falharam podem ser reenviadas",
+		"Failed to retry delivery":                                     "Falha ao reenviar a entrega",
+		"Could not retrieve user ID from context":                      "Não foi possível identificar o usuário",
+		"Only administrators can manage webhooks":                      "Só administradores podem gerenciar webhooks",
+		"Webhook not found":                                            "Webhook não encontrado",
+		"Route not found":                                              "Rota não encontrada",
+		"Method not allowed":                                           "Método não permitido",
+		"Missing authorization header":                                 "Cabeçalho Authorization ausente",
+		"Invalid authorization header format":                          "Formato do cabeçalho Authorization inválido",
+		"Invalid token":                                                "Token inválido",
+		"Idempotency-Key is too long":                                  "Idempotency-Key longa demais",
+		"Failed to read request body":                                  "Falha ao ler o corpo da requisição",
+		"Request body too large":                                       "Corpo da requisição grande demais",
+		"Idempotency-Key was already used with a different request":    "A Idempotency-Key já foi usada com outra requisição",
+		"A request with this Idempotency-Key is still being processed": "Uma requisição com esta Idempotency-Key ainda está em andamento",
+		"Invalid 'inicio' date format. Use YYYY-MM-DD":                 "Formato de data de 'inicio' inválido. Use AAAA-MM-DD",
+		"Invalid 'fim' date format. Use YYYY-MM-DD":                    "Formato de data de 'fim' inválido. Use AAAA-MM-DD",
+		"'fim' must not be before 'inicio'":                            "'fim' não pode ser anterior a 'inicio'",
+		"Period too long":                                              "Período longo demais",
+		"Failed to request report":                                     "Falha ao solicitar o relatório",
+		"Only managers can export the team's reports":                  "Só gestores podem exportar os relatórios da equipe",
+		"You don't have permission to export this user's reports":      "Você não tem permissão para exportar os relatórios deste usuário",
+		"Report not found":                                             "Relatório não encontrado",
+		"Failed to retrieve report":                                    "Falha ao consultar o relatório",
+		"The report has not been generated":                            "O relatório ainda não foi gerado",
+		"Failed to import 'pontos'":                                    "Falha ao importar os pontos",
+		"Only managers can import 'pontos'":                            "Só gestores podem importar pontos",
+		"Import not found":                                             "Importação não encontrada",
+		"Failed to retrieve import":                                    "Falha ao consultar a importação",
+
+		// Messages
+		"User created successfully. Check your email to verify the account.": "Usuário criado. Verifique seu email para ativar a conta.",
+		"Ponto updated successfully":                                         "Ponto atualizado",
+
+		// Emails
+		"Confirm your email": "Confirme seu email",
+		"Confirm your email to start using Controle de Ponto.": "Confirme seu email para começar a usar o Controle de Ponto.",
+		"Password reset": "Redefinição de senha",
+		"We received a request to reset your password. If it wasn't you, ignore this email.": "Recebemos um pedido para redefinir sua senha. Se não foi você, ignore este email.",
+		"Hello, %s.":            "Olá, %s.",
+		"Open: %s":              "Acesse: %s",
+		"Token: %s":             "Token: %s",
+		"Valid until %s (UTC).": "Válido até %s (UTC).",
+	},
+	Spanish: {
+		// Error titles
+		"Bad Request":                               "Solicitud no válida",
+		"Unauthorized":                              "No autorizado",
+		"Forbidden":                                 "Acceso denegado",
+		"Not Found":                                 "No encontrado",
+		"Method Not Allowed":                        "Método no permitido",
+		"Conflict":                                  "Conflicto",
+		"Request Entity Too Large":                  "Solicitud demasiado grande",
+		"Locked":                                    "Bloqueado",
+		"Too Many Requests":                         "Demasiadas solicitudes",
+		"Internal Server Error":                     "Error interno del servidor",
+		"Bad Gateway":                               "Falla en un servicio externo",
+		"Validation failed":                         "Datos no válidos",
+		"Malformed request body":                    "Cuerpo de la solicitud mal formado",
+		"Authentication required":                   "Autenticación requerida",
+		"Invalid or expired session":                "Sesión no válida o caducada",
+		"Invalid credentials":                       "Email o contraseña incorrectos",
+		"Email already registered":                  "Email ya registrado",
+		"Email not verified":                        "Email no verificado",
+		"Account temporarily locked":                "Cuenta bloqueada temporalmente",
+		"Too many login attempts":                   "Demasiados intentos de inicio de sesión",
+		"Too many requests":                         "Demasiadas solicitudes",
+		"Invalid or expired link":                   "Enlace no válido o caducado",
+		"Invalid or expired two-factor challenge":   "Desafío de dos factores no válido o caducado",
+		"Invalid two-factor code":                   "Código de dos factores no válido",
+		"Two-factor authentication required":        "Autenticación de dos factores obligatoria",
+		"Two-factor authentication already enabled": "Autenticación de dos factores ya activada",
+		"Two-factor enrollment not started":         "Registro de dos factores no iniciado",
+		"No identity provider configured":           "Ningún proveedor de identidad configurado",
+		"Identity provider unavailable":             "Proveedor de identidad no disponible",
+		"Identity provider discovery failed":        "Error al consultar el proveedor de identidad",
+		"Email domain already in use":               "Dominio de email ya en uso",
+		"Domain ownership not verified":             "Propiedad del dominio no verificada",
+		"Punch too close to another one":            "Fichaje demasiado cercano a otro",
+		"Overlapping leave request":                 "Ausencia superpuesta",
+		"Leave request already decided":             "Ausencia ya resuelta",
+		"Adjustment request already decided":        "Ajuste de fichaje ya resuelto",
+		"Attachment required":                       "Adjunto obligatorio",
+		"Delivery did not fail":                     "La entrega no falló",
+		"Report not ready":                          "Informe no generado",
+		"Idempotency key reused":                    "Clave de idempotencia reutilizada",
+		"Request still in progress":                 "Solicitud aún en curso",
+
+		// Field errors
+		"is required":                                             "es obligatorio",
+		"must not be empty":                                       "no puede estar vacío",
+		"must be of type %s, got %s":                              "debe ser de tipo %s, se recibió %s",
+		"must have at most %d characters":                         "debe tener como máximo %d caracteres",
+		"must have at most %d items":                              "debe tener como máximo %d elementos",
+		"must have at least 16 characters":                        "debe tener al menos 16 caracteres",
+		"must be a valid email address":                           "debe ser una dirección de email válida",
+		"must be a valid UUID":                                    "debe ser un UUID válido",
+		"is not a known field":                                    "no es un campo conocido",
+		"must be one of: %s":                                      "debe ser uno de: %s",
+		"must be a date in the YYYY-MM-DD format":                 "debe ser una fecha en el formato AAAA-MM-DD",
+		"must be between %s and %s":                               "debe estar entre %s y %s",
+		"must use the HH:MM format":                               "debe usar el formato HH:MM",
+		"must not be negative":                                    "no puede ser negativo",
+		"must be positive":                                        "debe ser positivo",
+		"must not be before data_inicio":                          "no puede ser anterior a data_inicio",
+		"must not be before inicio":                               "no puede ser anterior a inicio",
+		"can't be combined with equipe":                           "no se puede combinar con equipe",
+		"is not a user you manage":                                "no es un usuario que gestionas",
+		"the period must be shorter than %d days":                 "el período debe tener menos de %d días",
+		"must be a hex-encoded SHA-256 digest":                    "debe ser un hash SHA-256 en hexadecimal",
+		"must be an absolute http or https URL":                   "debe ser una URL http o https absoluta",
+		"must be an email domain, such as empresa.com.br":         "debe ser un dominio de email, como empresa.com.br",
+		"must be an absolute https URL without query or fragment": "debe ser una URL https absoluta, sin query ni fragmento",
+		"must be an IANA time zone":                               "debe ser una zona horaria IANA",
+		"is not a leave type available to you":                    "no es un tipo de ausencia disponible para ti",
+		"is not one of your pontos":                               "no es uno de tus fichajes",
+		"was used recently; choose another one":                   "se usó recientemente; elige otra",
+		"must have at least %d characters":                        "debe tener al menos %d caracteres",
+		"must have at most %d bytes":                              "debe tener como máximo %d bytes",
+		"must not contain your name or email":                     "no puede contener tu nombre o email",
+		"is too easy to guess (strength %d of 4, minimum %d)":     "es demasiado fácil de adivinar (fuerza %d de 4, mínimo %d)",
+		"appeared in a data breach; choose another one":           "apareció en una filtración de datos; elige otra",
+
+		// Error details
+		"Failed to retrieve leave types":                                      "Error al consultar los tipos de ausencia",
+		"Failed to process leave types":                                       "Error al procesar los tipos de ausencia",
+		"There is already a pending or approved leave request in this period": "Ya existe una ausencia pendiente o aprobada en este período",
+		"Failed to create leave request":                                      "Error al crear la ausencia",
+		"Only managers can list the team's leave requests":                    "Solo los gestores pueden listar las ausencias del equipo",
+		"You don't have permission to see this user's leave requests":         "No tienes permiso para ver las ausencias de este usuario",
+		"Failed to process leave requests":                                    "Error al procesar las ausencias",
+		"Failed to retrieve leave requests":                                   "Error al consultar las ausencias",
+		"Leave request not found or you don't have permission to change it":   "Ausencia no encontrada o no tienes permiso para modificarla",
+		"Attachments can only be added to pending leave requests":             "Solo se pueden adjuntar archivos a ausencias pendientes",
+		"Failed to add attachment":                                            "Error al agregar el adjunto",
+		"Leave request not found":                                             "Ausencia no encontrada",
+		"You don't have permission to decide on this leave request":           "No tienes permiso para resolver esta ausencia",
+		"Leave request is %s and can't be changed to %s":                      "La ausencia está %s y no puede pasar a %s",
+		"Leave request was changed by another request":                        "La ausencia fue modificada por otra solicitud",
+		"This leave type requires an attachment before approval":              "Este tipo de ausencia requiere un adjunto antes de la aprobación",
+		"Failed to update leave request":                                      "Error al actualizar la ausencia",
+		"Failed to create adjustment request":                                 "Error al crear la solicitud de ajuste",
+		"Failed to retrieve adjustment requests":                              "Error al obtener las solicitudes de ajuste",
+		"Failed to update adjustment request":                                 "Error al actualizar la solicitud de ajuste",
+		"Only managers can list the team's adjustment requests":               "Solo los gestores pueden listar los ajustes del equipo",
+		"You don't have permission to see this user's adjustment requests":    "No tienes permiso para ver los ajustes de este usuario",
+		"Adjustment request not found":                                        "Solicitud de ajuste no encontrada",
+		"You don't have permission to decide on this adjustment request":      "No tienes permiso para decidir sobre este ajuste",
+		"Adjustment request is %s and can't be changed to %s":                 "El ajuste está %s y no puede pasar a %s",
+		"Adjustment request was changed by another request":                   "El ajuste fue modificado por otra solicitud",
+		"Failed to hash password":                                             "Error al procesar la contraseña",
+		"Failed to create user":                                               "Error al crear el usuario",
+		"You don't have permission to see this user's time bank":              "No tienes permiso para ver la bolsa de horas de este usuario",
+		"Failed to calculate time bank":                                       "Error al calcular la bolsa de horas",
+		"You don't have permission to unlock this user's login":               "No tienes permiso para desbloquear el inicio de sesión de este usuario",
+		"User not found":                                               "Usuario no encontrado",
+		"Failed to unlock login":                                       "Error al desbloquear el inicio de sesión",
+		"Invalid or expired token":                                     "Token no válido o caducado",
+		"Invalid password":                                             "Contraseña no válida",
+		"Failed to update language":                                    "Error al actualizar el idioma",
+		"Only administrators can change the 'empresa' rules":           "Solo los administradores pueden cambiar las reglas de la empresa",
+		"Failed to update 'empresa' rules":                             "Error al actualizar las reglas de la empresa",
+		"Failed to retrieve 'empresa' rules":                           "Error al consultar las reglas de la empresa",
+		"Only managers can see the team status":                        "Solo los gestores pueden ver el estado del equipo",
+		"Failed to retrieve team status":                               "Error al consultar el estado del equipo",
+		"Invalid user ID":                                              "ID de usuario no válido",
+		"You don't have permission to change this user's schedule":     "No tienes permiso para cambiar el horario de este usuario",
+		"Failed to update schedule":                                    "Error al actualizar el horario",
+		"Failed to open event stream":                                  "Error al abrir el flujo de eventos",
+		"Only managers can follow the event stream":                    "Solo los gestores pueden seguir el flujo de eventos",
+		"Only administrators can follow the whole 'empresa'":           "Solo los administradores pueden seguir toda la empresa",
+		"escopo must be one of: equipe, empresa":                       "escopo debe ser uno de: equipe, empresa",
+		"Invalid Last-Event-ID":                                        "Last-Event-ID no válido",
+		"Invalid or expired MFA challenge":                             "Desafío de dos factores no válido o caducado",
+		"Failed to retrieve MFA status":                                "Error al consultar la autenticación de dos factores",
+		"MFA is mandatory for this user":                               "La autenticación de dos factores es obligatoria para este usuario",
+		"Failed to disable TOTP":                                       "Error al desactivar el TOTP",
+		"Failed to generate recovery codes":                            "Error al generar los códigos de recuperación",
+		"Failed to start TOTP enrollment":                              "Error al iniciar el registro del TOTP",
+		"TOTP is already enabled":                                      "El TOTP ya está activado",
+		"TOTP enrollment not started":                                  "Registro del TOTP no iniciado",
+		"Failed to confirm TOTP enrollment":                            "Error al confirmar el registro del TOTP",
+		"Invalid code":                                                 "Código no válido",
+		"Internal server error":                                        "Error interno del servidor",
+		"email or empresa_id is required":                              "email o empresa_id es obligatorio",
+		"Failed to start login":                                        "Error al iniciar sesión",
+		"Invalid or expired login session":                             "Sesión de inicio no válida o caducada",
+		"Identity provider is no longer active":                        "El proveedor de identidad ya no está activo",
+		"Identity provider login failed":                               "Falló el inicio de sesión en el proveedor de identidad",
+		"User is not allowed to sign in with this provider":            "El usuario no puede iniciar sesión con este proveedor",
+		"Failed to create token":                                       "Error al generar el token",
+		"Failed to complete login":                                     "Error al completar el inicio de sesión",
+		"Invalid or expired link token":                                "Token de vinculación no válido o caducado",
+		"Account already belongs to an empresa":                        "La cuenta ya pertenece a una empresa",
+		"Failed to link account":                                       "Error al vincular la cuenta",
+		"Failed to save identity provider":                             "Error al guardar el proveedor de identidad",
+		"Domain not configured in the identity provider":               "Dominio no configurado en el proveedor de identidad",
+		"Verification TXT record not found":                            "Registro TXT de verificación no encontrado",
+		"Failed to verify domain":                                      "Error al verificar el dominio",
+		"Email domain already used by another empresa":                 "Dominio de email ya usado por otra empresa",
+		"Failed to retrieve identity provider":                         "Error al consultar el proveedor de identidad",
+		"Failed to delete identity provider":                           "Error al eliminar el proveedor de identidad",
+		"Failed to load user profile":                                  "Error al cargar el perfil del usuario",
+		"Only administrators can manage the identity provider":         "Solo los administradores pueden gestionar el proveedor de identidad",
+		"'Ponto' too close to the one registered at %s":                "Fichaje demasiado cercano al registrado a las %s",
+		"Failed to register 'ponto'":                                   "Error al registrar el fichaje",
+		"Failed to retrieve 'pontos'":                                  "Error al consultar los fichajes",
+		"Failed to process 'pontos' data":                              "Error al procesar los fichajes",
+		"Invalid date format. Use YYYY-MM-DD":                          "Formato de fecha no válido. Usa AAAA-MM-DD",
+		"Failed to retrieve 'pontos' for calculation":                  "Error al consultar los fichajes para el cálculo",
+		"Failed to process 'horarios' for calculation":                 "Error al procesar los horarios para el cálculo",
+		"Failed to retrieve 'afastamentos' for calculation":            "Error al consultar las ausencias para el cálculo",
+		"'Ponto' not found or you don't have permission to update it":  "Fichaje no encontrado o no tienes permiso para modificarlo",
+		"Failed to update 'ponto'":                                     "Error al actualizar el fichaje",
+		"Invalid request body":                                         "Cuerpo de la solicitud no válido",
+		"'Ponto' not found or you don't have permission to delete it":  "Fichaje no encontrado o no tienes permiso para eliminarlo",
+		"Failed to delete 'ponto'":                                     "Error al eliminar el fichaje",
+		"Failed to synchronize 'pontos'":                               "Error al sincronizar los fichajes",
+		"Only managers can list the team's 'violacoes'":                "Solo los gestores pueden listar las infracciones del equipo",
+		"Invalid user_id":                                              "user_id no válido",
+		"You don't have permission to see this user's 'violacoes'":     "No tienes permiso para ver las infracciones de este usuario",
+		"Failed to retrieve 'violacoes'":                               "Error al consultar las infracciones",
+		"Failed to process 'violacoes' data":                           "Error al procesar las infracciones",
+		"Failed to create webhook":                                     "Error al crear el webhook",
+		"Failed to retrieve webhooks":                                  "Error al consultar los webhooks",
+		"Failed to process webhooks":                                   "Error al procesar los webhooks",
+		"Failed to delete webhook":                                     "Error al eliminar el webhook",
+		"Failed to test webhook":                                       "Error al probar el webhook",
+		"Failed to retrieve deliveries":                                "Error al consultar las entregas",
+		"Failed to process deliveries":                                 "Error al procesar las entregas",
+		"Invalid ID format":                                            "Formato de ID no válido",
+		"Delivery not found":                                           "Entrega no encontrada",
+		"Only failed deliveries can be retried":                        "Solo se pueden reintentar las entregas fallidas",
+		"Failed to retry delivery":                                     "Error al reintentar la entrega",
+		"Could not retrieve user ID from context":                      "No se pudo identificar al usuario",
+		"Only administrators can manage webhooks":                      "Solo los administradores pueden gestionar webhooks",
+		"Webhook not found":                                            "Webhook no encontrado",
+		"Route not found":                                              "Ruta no encontrada",
+		"Method not allowed":                                           "Método no permitido",
+		"Missing authorization header":                                 "Falta el encabezado Authorization",
+		"Invalid authorization header format":                          "Formato del encabezado Authorization no válido",
+		"Invalid token":                                                "Token no válido",
+		"Idempotency-Key is too long":                                  "Idempotency-Key demasiado larga",
+		"Failed to read request body":                                  "Error al leer el cuerpo de la solicitud",
+		"Request body too large":                                       "Cuerpo de la solicitud demasiado grande",
+		"Idempotency-Key was already used with a different request":    "La Idempotency-Key ya se usó con otra solicitud",
+		"A request with this Idempotency-Key is still being processed": "Una solicitud con esta Idempotency-Key aún se está procesando",
+		"Invalid 'inicio' date format. Use YYYY-MM-DD":                 "Formato de fecha de 'inicio' no válido. Usa AAAA-MM-DD",
+		"Invalid 'fim' date format. Use YYYY-MM-DD":                    "Formato de fecha de 'fim' no válido. Usa AAAA-MM-DD",
+		"'fim' must not be before 'inicio'":                            "'fim' no puede ser anterior a 'inicio'",
+		"Period too long":                                              "Período demasiado largo",
+		"Failed to request report":                                     "Error al solicitar el informe",
+		"Only managers can export the team's reports":                  "Solo los gestores pueden exportar los informes del equipo",
+		"You don't have permission to export this user's reports":      "No tienes permiso para exportar los informes de este usuario",
+		"Report not found":                                             "Informe no encontrado",
+		"Failed to retrieve report":                                    "Error al consultar el informe",
+		"The report has not been generated":                            "El informe aún no se ha generado",
+		"Failed to import 'pontos'":                                    "Error al importar los fichajes",
+		"Only managers can import 'pontos'":                            "Solo los gestores pueden importar fichajes",
+		"Import not found":                                             "Importación no encontrada",
+		"Failed to retrieve import":                                    "Error al consultar la importación",
+
+		// Messages
+		"User created successfully. Check your email to verify the account.": "Usuario creado. Revisa tu email para activar la cuenta.",
+		"Ponto updated successfully":                                         "Fichaje actualizado",
+
+		// Emails
+		"Confirm your email": "Confirma tu email",
+		"Confirm your email to start using Controle de Ponto.": "Confirma tu email para empezar a usar Controle de Ponto.",
+		"Password reset": "Restablecimiento de contraseña",
+		"We received a request to reset your password. If it wasn't you, ignore this email.": "Recibimos una solicitud para restablecer tu contraseña. Si no fuiste tú, ignora este email.",
+		"Hello, %s.":            "Hola, %s.",
+		"Open: %s":              "Accede: %s",
+		"Token: %s":             "Token: %s",
+		"Valid until %s (UTC).": "Válido hasta %s (UTC).",
+	},
+}
@@ -0,0 +1,147 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// messageArgs maps the functions that show a message to the client to the position of the
+// message among their arguments. v is the usual name of a validation.Validator.
+var messageArgs = map[string]int{
+	"v.Check":            3,
+	"v.Add":              2,
+	"respondWithError":   3,
+	"respondWithProblem": 4,
+	"falharLoginOIDC":    4,
+	"carregarAdmin":      2,
+	"webhookDaEmpresa":   3,
+	"apierror.Write":     4,
+	"i18n.T":             1,
+}
+
+// sourceMessages returns the literal messages passed to the functions in messageArgs by the
+// packages in dirs, and the values of apierror's titles.
+func sourceMessages(t *testing.T, dirs ...string) map[string]token.Position {
+	t.Helper()
+	fset := token.NewFileSet()
+	messages := map[string]token.Position{}
+	add := func(expr ast.Expr) {
+		if lit, ok := expr.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil && s != "" {
+				messages[s] = fset.Position(lit.Pos())
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			f, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.CallExpr:
+					name := ""
+					switch fn := n.Fun.(type) {
+					case *ast.Ident:
+						name = fn.Name
+					case *ast.SelectorExpr:
+						if pkg, ok := fn.X.(*ast.Ident); ok {
+							name = pkg.Name + "." + fn.Sel.Name
+						}
+					}
+					if i, ok := messageArgs[name]; ok && i < len(n.Args) {
+						add(n.Args[i])
+					}
+				case *ast.ValueSpec:
+					if len(n.Names) == 1 && n.Names[0].Name == "titles" && len(n.Values) == 1 {
+						if m, ok := n.Values[0].(*ast.CompositeLit); ok {
+							for _, e := range m.Elts {
+								add(e.(*ast.KeyValueExpr).Value)
+							}
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+	return messages
+}
+
+func TestCatalogCoversMessages(t *testing.T) {
+	messages := sourceMessages(t, "..", "../handlers", "../middleware", "../apierror", "../validation", "../password")
+	if len(messages) < 100 {
+		t.Fatalf("found only %d messages; did the source layout change?", len(messages))
+	}
+
+	for _, locale := range Supported {
+		if locale == English {
+			continue
+		}
+		var missing []string
+		for msg, pos := range messages {
+			if _, ok := catalog[locale][msg]; !ok {
+				missing = append(missing, pos.String()+": "+strconv.Quote(msg))
+			}
+		}
+		sort.Strings(missing)
+		for _, m := range missing {
+			t.Errorf("%s: no translation for %s", locale, m)
+		}
+	}
+}
+
+func TestCatalogVerbs(t *testing.T) {
+	verbs := func(s string) []string {
+		var v []string
+		for i := 0; i < len(s)-1; i++ {
+			if s[i] == '%' {
+				v = append(v, s[i:i+2])
+				i++
+			}
+		}
+		return v
+	}
+	for locale, messages := range catalog {
+		for english, translated := range messages {
+			if strings.Join(verbs(english), " ") != strings.Join(verbs(translated), " ") {
+				t.Errorf("%s: %q has other verbs than %q", locale, translated, english)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		locale, format string
+		args           []interface{}
+		want           string
+	}{
+		{PortugueseBR, "Invalid credentials", nil, "Email ou senha incorretos"},
+		{Spanish, "Hello, %s.", []interface{}{"Ana"}, "Hola, Ana."},
+		{English, "Hello, %s.", []interface{}{"Ana"}, "Hello, Ana."},
+		{PortugueseBR, "not in the catalog", nil, "not in the catalog"},
+		// A message with no arguments is not formatted, so a literal % survives
+		{PortugueseBR, "100% done", nil, "100% done"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.locale, tt.format, tt.args...); got != tt.want {
+			t.Errorf("T(%s, %q) = %q, want %q", tt.locale, tt.format, got, tt.want)
+		}
+	}
+}
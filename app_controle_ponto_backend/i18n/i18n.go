@@ -0,0 +1,158 @@
+// Package i18n translates the messages shown to users and formats dates and numbers for
+// their language. Messages are written in English in the code, formatted as in fmt.Sprintf,
+// and the English text is the key of the translations in the catalog.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported locales.
+const (
+	PortugueseBR = "pt-BR"
+	English      = "en"
+	Spanish      = "es"
+)
+
+// Supported lists every locale with a catalog.
+var Supported = []string{PortugueseBR, English, Spanish}
+
+// Default is the locale used when the client states no supported language. It is set in
+// main.
+var Default = PortugueseBR
+
+// IsSupported reports whether locale is one of Supported.
+func IsSupported(locale string) bool {
+	return slices.Contains(Supported, locale)
+}
+
+// Match returns the supported locale for a language tag such as "pt-PT" or "es_419",
+// matching on the language alone, or "" if there is none.
+func Match(tag string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	for _, locale := range Supported {
+		if strings.EqualFold(locale[:2], lang) {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Negotiate returns the supported locale the Accept-Language header prefers, or "" if it
+// names none.
+func Negotiate(acceptLanguage string) string {
+	type option struct {
+		tag string
+		q   float64
+	}
+	var options []option
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			options = append(options, option{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].q > options[j].q })
+
+	for _, o := range options {
+		if locale := Match(o.tag); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+type contextKey struct{}
+
+// WithLocale returns a copy of ctx carrying locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// FromContext returns the locale in ctx, or Default.
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(contextKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return Default
+}
+
+// T translates the English message format into locale and formats it with args. Messages
+// missing from the catalog are formatted in English.
+func T(locale, format string, args ...interface{}) string {
+	if translated, ok := catalog[locale][format]; ok {
+		format = translated
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+type formats struct {
+	date, dateTime     string
+	decimal, thousands string
+}
+
+var localeFormats = map[string]formats{
+	PortugueseBR: {date: "02/01/2006", dateTime: "02/01/2006 15:04", decimal: ",", thousands: "."},
+	English:      {date: "01/02/2006", dateTime: "01/02/2006 3:04 PM", decimal: ".", thousands: ","},
+	Spanish:      {date: "02/01/2006", dateTime: "02/01/2006 15:04", decimal: ",", thousands: "."},
+}
+
+func formatsFor(locale string) formats {
+	if f, ok := localeFormats[locale]; ok {
+		return f
+	}
+	return localeFormats[Default]
+}
+
+// FormatDate writes the date of t as usual in locale.
+func FormatDate(locale string, t time.Time) string {
+	return t.Format(formatsFor(locale).date)
+}
+
+// FormatDateTime writes the date and time of t, to the minute, as usual in locale.
+func FormatDateTime(locale string, t time.Time) string {
+	return t.Format(formatsFor(locale).dateTime)
+}
+
+// FormatNumber writes v with decimals digits after the separator and grouped thousands,
+// as usual in locale.
+func FormatNumber(locale string, v float64, decimals int) string {
+	f := formatsFor(locale)
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteByte('-')
+	}
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(f.thousands)
+		}
+		b.WriteRune(d)
+	}
+	if fracPart != "" {
+		b.WriteString(f.decimal)
+		b.WriteString(fracPart)
+	}
+	return b.String()
+}
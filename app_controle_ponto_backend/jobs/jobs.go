@@ -0,0 +1,312 @@
+// Package jobs implementa uma fila de tarefas em segundo plano guardada no banco de dados.
+// As tarefas são enfileiradas, de preferência na mesma transação da alteração que as origina,
+// e executadas por workers que as reservam por um tempo de visibilidade. Uma tarefa cujo
+// worker caiu volta a ficar disponível quando a reserva expira.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"controle-ponto-api/backoff"
+	"controle-ponto-api/tracing"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Status de uma tarefa.
+const (
+	StatusPendente   = "pendente"
+	StatusExecutando = "executando"
+	StatusConcluido  = "concluido"
+	StatusFalhou     = "falhou"
+)
+
+// Job é uma tarefa reservada para execução.
+type Job struct {
+	ID      int64
+	Tipo    string
+	Payload json.RawMessage
+	// Tentativa é o número da execução atual, a partir de 1.
+	Tentativa int
+}
+
+// Decodificar lê o payload da tarefa em v.
+func (j Job) Decodificar(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler executa uma tarefa. Um erro agenda uma nova tentativa.
+type Handler func(ctx context.Context, job Job) error
+
+// Opcoes ajustam o enfileiramento de uma tarefa.
+type Opcoes struct {
+	// ExecutarEm adia a tarefa; o padrão é executar assim que possível.
+	ExecutarEm time.Time
+	// ChaveUnica impede duas tarefas pendentes do mesmo tipo com a mesma chave.
+	ChaveUnica string
+	// MaxTentativas é o número de execuções antes de a tarefa ser marcada como falhou (padrão 5).
+	MaxTentativas int
+}
+
+// Executor é satisfeito por *sql.DB e *sql.Tx.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enfileirar grava uma tarefa. Com ChaveUnica, uma tarefa pendente igual torna a chamada
+// um no-op. Passe a transação da alteração para que a tarefa só exista se ela for confirmada.
+func Enfileirar(ctx context.Context, q Executor, tipo string, payload interface{}, opcoes Opcoes) error {
+	corpo, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if opcoes.MaxTentativas <= 0 {
+		opcoes.MaxTentativas = 5
+	}
+	var executarEm interface{}
+	if !opcoes.ExecutarEm.IsZero() {
+		executarEm = opcoes.ExecutarEm
+	}
+	var chave sql.NullString
+	if opcoes.ChaveUnica != "" {
+		chave = sql.NullString{String: opcoes.ChaveUnica, Valid: true}
+	}
+
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO jobs (tipo, payload, chave_unica, max_tentativas, executar_em)
+		VALUES ($1, $2, $3, $4, COALESCE($5::timestamptz, NOW()))
+		ON CONFLICT (tipo, chave_unica) WHERE status = 'pendente' AND chave_unica IS NOT NULL DO NOTHING`,
+		tipo, string(corpo), chave, opcoes.MaxTentativas, executarEm,
+	)
+	return err
+}
+
+// Runner reserva e executa as tarefas dos tipos registrados.
+type Runner struct {
+	DB *sql.DB
+	// Workers é o número de tarefas executadas em paralelo.
+	Workers int
+	// Intervalo entre as verificações da fila quando ela está vazia.
+	Intervalo time.Duration
+	// Visibilidade é por quanto tempo uma tarefa fica reservada; a reserva é renovada
+	// enquanto a tarefa executa.
+	Visibilidade time.Duration
+	// EsperaBase é a espera antes da segunda tentativa; dobra a cada falha até EsperaMaxima.
+	EsperaBase   time.Duration
+	EsperaMaxima time.Duration
+
+	handlers map[string]Handler
+}
+
+// NovoRunner cria um runner com os valores padrão.
+func NovoRunner(db *sql.DB) *Runner {
+	return &Runner{
+		DB:           db,
+		Workers:      4,
+		Intervalo:    time.Second,
+		Visibilidade: 5 * time.Minute,
+		EsperaBase:   10 * time.Second,
+		EsperaMaxima: time.Hour,
+		handlers:     make(map[string]Handler),
+	}
+}
+
+// Registrar associa um handler a um tipo de tarefa. Deve ser chamado antes de Executar.
+func (r *Runner) Registrar(tipo string, h Handler) {
+	r.handlers[tipo] = h
+}
+
+// Executar inicia os workers e bloqueia até ctx ser cancelado e as tarefas em andamento
+// terminarem. Uma tarefa interrompida pelo cancelamento volta para a fila sem contar a tentativa.
+func (r *Runner) Executar(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.worker(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) worker(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, ok, err := r.reservar(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Error leasing job", "error", err)
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-time.After(r.Intervalo):
+			}
+			continue
+		}
+		r.processar(ctx, job)
+	}
+}
+
+// reservar marca a próxima tarefa disponível como em execução. Tarefas em execução com a
+// reserva vencida pertencem a um worker que caiu e também são retomadas.
+func (r *Runner) reservar(ctx context.Context) (Job, bool, error) {
+	tipos := make([]string, 0, len(r.handlers))
+	for tipo := range r.handlers {
+		tipos = append(tipos, tipo)
+	}
+
+	var job Job
+	err := r.DB.QueryRowContext(ctx,
+		`UPDATE jobs SET status = $1, tentativas = tentativas + 1, reservado_ate = NOW() + make_interval(secs => $2)
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE tipo = ANY($3) AND (
+				(status = $4 AND executar_em <= NOW()) OR (status = $1 AND reservado_ate < NOW())
+			)
+			ORDER BY executar_em
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, tipo, payload, tentativas`,
+		StatusExecutando, r.Visibilidade.Seconds(), pq.Array(tipos), StatusPendente,
+	).Scan(&job.ID, &job.Tipo, &job.Payload, &job.Tentativa)
+	if err == sql.ErrNoRows {
+		return job, false, nil
+	}
+	if err != nil {
+		return job, false, err
+	}
+	return job, true, nil
+}
+
+// processar executa a tarefa renovando a reserva e grava o resultado. Cada execução é um
+// trace próprio, com as consultas do handler como filhas.
+func (r *Runner) processar(ctx context.Context, job Job) {
+	ctx, span := tracing.Start(ctx, "job "+job.Tipo,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.Int64("job.id", job.ID), attribute.Int("job.attempt", job.Tentativa)),
+	)
+	defer span.End()
+
+	feito := make(chan struct{})
+	go r.renovarReserva(ctx, job.ID, feito)
+
+	err := r.executar(ctx, job)
+	close(feito)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	// The result is recorded even when shutdown has cancelled ctx
+	resultadoCtx := context.WithoutCancel(ctx)
+
+	switch {
+	case err == nil:
+		err = r.finalizar(resultadoCtx, job, "status = $3, concluido_em = NOW(), ultimo_erro = ''", StatusConcluido)
+	case ctx.Err() != nil:
+		// Interrupted by shutdown: put it back without counting the attempt
+		err = r.finalizar(resultadoCtx, job, "status = $3, tentativas = tentativas - 1", StatusPendente)
+	default:
+		slog.ErrorContext(ctx, "Job failed", "job_id", job.ID, "tipo", job.Tipo, "attempt", job.Tentativa, "error", err)
+		err = r.finalizar(resultadoCtx, job,
+			`status = CASE WHEN tentativas >= max_tentativas THEN $3 ELSE $4 END,
+			executar_em = NOW() + make_interval(secs => $5), ultimo_erro = $6`,
+			StatusFalhou, StatusPendente, backoff.Espera(job.Tentativa, r.EsperaBase, r.EsperaMaxima).Seconds(), err.Error(),
+		)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error recording job result", "job_id", job.ID, "error", err)
+	}
+}
+
+// finalizar grava o resultado da execução, desde que a reserva ainda seja deste worker.
+// Se a tarefa voltaria para a fila mas já existe outra pendente com a mesma chave única,
+// ela é concluída, pois a pendente fará o mesmo trabalho.
+func (r *Runner) finalizar(ctx context.Context, job Job, set string, args ...interface{}) error {
+	_, err := r.DB.ExecContext(ctx,
+		"UPDATE jobs SET "+set+", reservado_ate = NULL WHERE id = $1 AND tentativas = $2 AND status = '"+StatusExecutando+"'",
+		append([]interface{}{job.ID, job.Tentativa}, args...)...,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		_, err = r.DB.ExecContext(ctx,
+			"UPDATE jobs SET status = $1, concluido_em = NOW(), reservado_ate = NULL, ultimo_erro = $2 WHERE id = $3",
+			StatusConcluido, "superseded by a pending job with the same key", job.ID,
+		)
+	}
+	return err
+}
+
+// executar chama o handler, convertendo um panic em erro.
+func (r *Runner) executar(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	h, ok := r.handlers[job.Tipo]
+	if !ok {
+		return errors.New("no handler registered for job type " + job.Tipo)
+	}
+	return h(ctx, job)
+}
+
+// renovarReserva estende a reserva da tarefa até feito ser fechado ou ctx ser cancelado.
+// Cada renovação tem até a próxima para terminar, para que uma conexão travada não
+// segure o worker no desligamento.
+func (r *Runner) renovarReserva(ctx context.Context, id int64, feito <-chan struct{}) {
+	intervalo := r.Visibilidade / 2
+	ticker := time.NewTicker(intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-feito:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			renovacaoCtx, cancel := context.WithTimeout(ctx, intervalo)
+			_, err := r.DB.ExecContext(renovacaoCtx,
+				"UPDATE jobs SET reservado_ate = NOW() + make_interval(secs => $1) WHERE id = $2 AND status = $3",
+				r.Visibilidade.Seconds(), id, StatusExecutando,
+			)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Error extending job lease", "job_id", id, "error", err)
+			}
+		}
+	}
+}
+
+// ContarPendentes retorna quantas tarefas aguardam execução, por tipo. Tarefas adiadas
+// também contam, pois já estão na fila.
+func ContarPendentes(ctx context.Context, db *sql.DB) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT tipo, COUNT(*) FROM jobs WHERE status = $1 GROUP BY tipo", StatusPendente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contagem := make(map[string]int)
+	for rows.Next() {
+		var tipo string
+		var n int
+		if err := rows.Scan(&tipo, &n); err != nil {
+			return nil, err
+		}
+		contagem[tipo] = n
+	}
+	return contagem, rows.Err()
+}
@@ -0,0 +1,245 @@
+package jobs
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+const tipoTeste = "teste.executar"
+
+// esperaEntre aceita a espera, em segundos, agendada para a próxima tentativa.
+type esperaEntre struct{ min, max time.Duration }
+
+func (e esperaEntre) Match(v driver.Value) bool {
+	s, ok := v.(float64)
+	d := time.Duration(s * float64(time.Second))
+	return ok && d >= e.min && d <= e.max
+}
+
+// runnerTeste cria um runner sobre um sqlmock com o handler dado registrado para tipoTeste.
+// As expectativas são conferidas ao fim do teste.
+func runnerTeste(t *testing.T, h Handler) (*Runner, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	r := NovoRunner(db)
+	r.Workers = 1
+	r.Intervalo = 10 * time.Millisecond
+	r.Registrar(tipoTeste, h)
+	return r, mock
+}
+
+// esperarReserva faz a próxima reserva devolver a tarefa 9 na tentativa informada.
+func esperarReserva(mock sqlmock.Sqlmock, tentativa int) {
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE jobs SET status = $1, tentativas = tentativas + 1")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tipo", "payload", "tentativas"}).AddRow(9, tipoTeste, []byte(`{}`), tentativa))
+}
+
+func TestReservar(t *testing.T) {
+	r, mock := runnerTeste(t, func(context.Context, Job) error { return nil })
+
+	// Pending jobs that are due and running jobs whose lease expired, without waiting
+	// for the rows other workers hold
+	mock.ExpectQuery(regexp.QuoteMeta("(status = $4 AND executar_em <= NOW()) OR (status = $1 AND reservado_ate < NOW())")+`[\s\S]*`+regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).
+		WithArgs(StatusExecutando, r.Visibilidade.Seconds(), sqlmock.AnyArg(), StatusPendente).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tipo", "payload", "tentativas"}).AddRow(9, tipoTeste, []byte(`{"a":1}`), 2))
+	mock.ExpectQuery(regexp.QuoteMeta("FOR UPDATE SKIP LOCKED")).WillReturnRows(sqlmock.NewRows([]string{"id", "tipo", "payload", "tentativas"}))
+
+	job, ok, err := r.reservar(t.Context())
+	if err != nil || !ok {
+		t.Fatalf("reservar() = %v, %v", ok, err)
+	}
+	// A job picked up again after its lease expired carries on counting attempts
+	if job.ID != 9 || job.Tipo != tipoTeste || string(job.Payload) != `{"a":1}` || job.Tentativa != 2 {
+		t.Errorf("job = %+v", job)
+	}
+
+	if _, ok, err := r.reservar(t.Context()); err != nil || ok {
+		t.Errorf("reservar() on an empty queue = %v, %v, want false", ok, err)
+	}
+}
+
+func TestProcessar(t *testing.T) {
+	falha := errors.New("smtp unavailable")
+
+	tests := []struct {
+		name      string
+		tentativa int
+		erro      error
+		esperar   func(r *Runner, mock sqlmock.Sqlmock)
+	}{
+		{
+			name:      "done",
+			tentativa: 1,
+			esperar: func(r *Runner, mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("concluido_em = NOW(), ultimo_erro = ''")).
+					WithArgs(9, 1, StatusConcluido).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:      "first failure waits EsperaBase",
+			tentativa: 1,
+			erro:      falha,
+			esperar: func(r *Runner, mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("executar_em = NOW() + make_interval(secs => $5)")).
+					WithArgs(9, 1, StatusFalhou, StatusPendente, esperaEntre{r.EsperaBase, r.EsperaBase * 11 / 10}, falha.Error()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:      "the wait doubles on each failure",
+			tentativa: 3,
+			erro:      falha,
+			esperar: func(r *Runner, mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("executar_em = NOW() + make_interval(secs => $5)")).
+					WithArgs(9, 3, StatusFalhou, StatusPendente, esperaEntre{4 * r.EsperaBase, 4 * r.EsperaBase * 11 / 10}, falha.Error()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			// Back in the queue while another job with the same key is pending: it's done by that one
+			name:      "superseded",
+			tentativa: 1,
+			erro:      falha,
+			esperar: func(r *Runner, mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("executar_em = NOW() + make_interval(secs => $5)")).
+					WillReturnError(&pq.Error{Code: "23505"})
+				mock.ExpectExec(regexp.QuoteMeta("UPDATE jobs SET status = $1, concluido_em = NOW()")).
+					WithArgs(StatusConcluido, "superseded by a pending job with the same key", 9).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mock := runnerTeste(t, func(ctx context.Context, job Job) error { return tt.erro })
+			tt.esperar(r, mock)
+
+			r.processar(t.Context(), Job{ID: 9, Tipo: tipoTeste, Payload: []byte(`{}`), Tentativa: tt.tentativa})
+		})
+	}
+}
+
+func TestProcessarPanic(t *testing.T) {
+	r, mock := runnerTeste(t, func(context.Context, Job) error { panic("boom") })
+	mock.ExpectExec(regexp.QuoteMeta("executar_em = NOW() + make_interval(secs => $5)")).
+		WithArgs(9, 1, StatusFalhou, StatusPendente, sqlmock.AnyArg(), "panic: boom").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	r.processar(t.Context(), Job{ID: 9, Tipo: tipoTeste, Tentativa: 1})
+}
+
+func TestExecutarDesligamento(t *testing.T) {
+	iniciou := make(chan struct{})
+	r, mock := runnerTeste(t, func(ctx context.Context, job Job) error {
+		close(iniciou)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	esperarReserva(mock, 2)
+	// Interrupted by the shutdown: back in the queue, and the attempt doesn't count
+	mock.ExpectExec(regexp.QuoteMeta("tentativas = tentativas - 1")).
+		WithArgs(9, 2, StatusPendente).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	parou := make(chan struct{})
+	go func() {
+		r.Executar(ctx)
+		close(parou)
+	}()
+
+	<-iniciou
+	cancel()
+	select {
+	case <-parou:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Executar didn't return after the shutdown")
+	}
+}
+
+// aguardarChamadas espera o sqlmock receber todas as chamadas esperadas, mesmo as que
+// ainda não retornaram.
+func aguardarChamadas(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	for prazo := time.Now().Add(5 * time.Second); mock.ExpectationsWereMet() != nil; {
+		if time.Now().After(prazo) {
+			t.Fatal(mock.ExpectationsWereMet())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRenovarReserva(t *testing.T) {
+	renovacao := regexp.QuoteMeta("UPDATE jobs SET reservado_ate = NOW() + make_interval(secs => $1)")
+
+	t.Run("renews until done", func(t *testing.T) {
+		r, mock := runnerTeste(t, nil)
+		r.Visibilidade = time.Second
+		mock.ExpectExec(renovacao).WithArgs(r.Visibilidade.Seconds(), 9, StatusExecutando).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		feito := make(chan struct{})
+		parou := make(chan struct{})
+		go func() {
+			r.renovarReserva(t.Context(), 9, feito)
+			close(parou)
+		}()
+		aguardarChamadas(t, mock)
+		close(feito)
+		<-parou
+	})
+
+	t.Run("a stuck renewal times out before the next one", func(t *testing.T) {
+		r, mock := runnerTeste(t, nil)
+		r.Visibilidade = 40 * time.Millisecond
+		mock.ExpectExec(renovacao).WillDelayFor(time.Hour).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(renovacao).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		feito := make(chan struct{})
+		parou := make(chan struct{})
+		go func() {
+			r.renovarReserva(t.Context(), 9, feito)
+			close(parou)
+		}()
+		aguardarChamadas(t, mock)
+		close(feito)
+		<-parou
+	})
+
+	t.Run("a stuck renewal stops on shutdown", func(t *testing.T) {
+		r, mock := runnerTeste(t, nil)
+		r.Visibilidade = 400 * time.Millisecond
+		mock.ExpectExec(renovacao).WillDelayFor(time.Hour).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ctx, cancel := context.WithCancel(t.Context())
+		parou := make(chan struct{})
+		go func() {
+			r.renovarReserva(ctx, 9, make(chan struct{}))
+			close(parou)
+		}()
+		aguardarChamadas(t, mock)
+		cancel()
+		// Well before the renewal's own timeout, half the visibility
+		select {
+		case <-parou:
+		case <-time.After(r.Visibilidade / 4):
+			t.Fatal("renovarReserva didn't return after ctx was cancelled")
+		}
+	})
+}
@@ -0,0 +1,252 @@
+// Package jornada reúne os cálculos feitos sobre os pontos de um dia de trabalho.
+package jornada
+
+import (
+	"controle-ponto-api/models"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FormatoData é o formato usado para identificar um dia nas rotas e nos relatórios.
+const FormatoData = "2006-01-02"
+
+// Tipos de inconsistência encontrados nos pontos de um dia.
+const (
+	InconsistenciaQuantidadeImpar = "quantidade_impar"
+	InconsistenciaPontosProximos  = "pontos_proximos"
+	InconsistenciaJornadaExcedida = "jornada_excedida"
+)
+
+// Periodo é um par entrada/saída.
+type Periodo struct {
+	Entrada models.Ponto
+	Saida   models.Ponto
+}
+
+// Duracao retorna o tempo trabalhado no período.
+func (p Periodo) Duracao() time.Duration {
+	return p.Saida.Horario.Sub(p.Entrada.Horario)
+}
+
+// Regras são os limites usados para detectar inconsistências.
+type Regras struct {
+	IntervaloMinimo time.Duration
+	JornadaMaxima   time.Duration
+}
+
+// RegrasDaEmpresa converte a configuração da empresa em Regras.
+func RegrasDaEmpresa(e models.Empresa) Regras {
+	return Regras{
+		IntervaloMinimo: time.Duration(e.IntervaloMinimoSegundos) * time.Second,
+		JornadaMaxima:   time.Duration(e.JornadaMaximaMinutos) * time.Minute,
+	}
+}
+
+// Inconsistencia descreve um problema nos pontos de um dia.
+type Inconsistencia struct {
+	Data      string   `json:"data"`
+	Tipo      string   `json:"tipo"`
+	Descricao string   `json:"descricao"`
+	PontoIDs  []string `json:"ponto_ids,omitempty"`
+}
+
+// Dia identifica o dia ao qual um horário pertence, contado no fuso informado.
+func Dia(horario time.Time, fuso *time.Location) string {
+	return horario.In(fuso).Format(FormatoData)
+}
+
+// Parear agrupa os pontos, já ordenados por horário, em pares entrada/saída.
+// Um último ponto sem par é devolvido separadamente.
+func Parear(pontos []models.Ponto) ([]Periodo, *models.Ponto) {
+	periodos := make([]Periodo, 0, len(pontos)/2)
+	for i := 0; i+1 < len(pontos); i += 2 {
+		periodos = append(periodos, Periodo{Entrada: pontos[i], Saida: pontos[i+1]})
+	}
+
+	if len(pontos)%2 != 0 {
+		ultimo := pontos[len(pontos)-1]
+		return periodos, &ultimo
+	}
+	return periodos, nil
+}
+
+// TotalTrabalhado soma a duração dos pares entrada/saída, ignorando um ponto sem par.
+func TotalTrabalhado(pontos []models.Ponto) time.Duration {
+	periodos, _ := Parear(pontos)
+
+	var total time.Duration
+	for _, p := range periodos {
+		total += p.Duracao()
+	}
+	return total
+}
+
+// AgruparPorDia separa os pontos por dia, contado no fuso informado, mantendo a ordem
+// original em cada dia. Os dias são devolvidos em ordem crescente.
+func AgruparPorDia(pontos []models.Ponto, fuso *time.Location) ([]string, map[string][]models.Ponto) {
+	porDia := make(map[string][]models.Ponto)
+	for _, p := range pontos {
+		dia := Dia(p.Horario, fuso)
+		porDia[dia] = append(porDia[dia], p)
+	}
+
+	dias := make([]string, 0, len(porDia))
+	for dia := range porDia {
+		dias = append(dias, dia)
+	}
+	sort.Strings(dias)
+	return dias, porDia
+}
+
+// Inconsistencias verifica os pontos de um dia, ordenados por horário,
+// em busca de quantidade ímpar, pontos próximos demais e jornada excedida.
+func Inconsistencias(data string, pontos []models.Ponto, regras Regras) []Inconsistencia {
+	var encontradas []Inconsistencia
+
+	if len(pontos)%2 != 0 {
+		encontradas = append(encontradas, Inconsistencia{
+			Data:      data,
+			Tipo:      InconsistenciaQuantidadeImpar,
+			Descricao: fmt.Sprintf("%d pontos registrados; falta uma entrada ou saída", len(pontos)),
+			PontoIDs:  idsDosPontos(pontos),
+		})
+	}
+
+	for i := 1; i < len(pontos); i++ {
+		diferenca := pontos[i].Horario.Sub(pontos[i-1].Horario)
+		if diferenca < regras.IntervaloMinimo {
+			encontradas = append(encontradas, Inconsistencia{
+				Data:      data,
+				Tipo:      InconsistenciaPontosProximos,
+				Descricao: fmt.Sprintf("pontos com apenas %s de diferença (mínimo %s)", diferenca.Round(time.Second), regras.IntervaloMinimo),
+				PontoIDs:  []string{pontos[i-1].ID, pontos[i].ID},
+			})
+		}
+	}
+
+	if total := TotalTrabalhado(pontos); regras.JornadaMaxima > 0 && total > regras.JornadaMaxima {
+		encontradas = append(encontradas, Inconsistencia{
+			Data:      data,
+			Tipo:      InconsistenciaJornadaExcedida,
+			Descricao: fmt.Sprintf("jornada de %s excede o máximo de %s", total.Round(time.Minute), regras.JornadaMaxima),
+			PontoIDs:  idsDosPontos(pontos),
+		})
+	}
+
+	return encontradas
+}
+
+func idsDosPontos(pontos []models.Ponto) []string {
+	ids := make([]string, len(pontos))
+	for i, p := range pontos {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+// Abono é o tempo creditado em um dia coberto por um afastamento aprovado.
+type Abono struct {
+	// Tipo é o código do tipo de afastamento (ferias, atestado, folga...).
+	Tipo      string
+	Creditado time.Duration
+}
+
+// Apuracao é o fechamento de um dia: tempo trabalhado, jornada esperada, abono e saldo.
+type Apuracao struct {
+	Data              string `json:"data"`
+	TrabalhadoMinutos int    `json:"trabalhado_minutos"`
+	EsperadoMinutos   int    `json:"esperado_minutos"`
+	AbonadoMinutos    int    `json:"abonado_minutos"`
+	// SaldoMinutos é positivo para horas extras e negativo para horas faltantes.
+	SaldoMinutos int    `json:"saldo_minutos"`
+	Afastamento  string `json:"afastamento,omitempty"`
+}
+
+// JornadaEsperada retorna a carga diária de segunda a sexta-feira e zero nos fins de semana.
+func JornadaEsperada(dia time.Time, cargaDiaria time.Duration) time.Duration {
+	switch dia.Weekday() {
+	case time.Saturday, time.Sunday:
+		return 0
+	default:
+		return cargaDiaria
+	}
+}
+
+// Apurar fecha um dia. Em dias de afastamento aprovado, o tempo creditado pelo tipo
+// de afastamento é abonado, limitado ao que faltou para completar a jornada esperada.
+func Apurar(dia time.Time, pontos []models.Ponto, cargaDiaria time.Duration, abono *Abono) Apuracao {
+	trabalhado := TotalTrabalhado(pontos)
+	esperado := JornadaEsperada(dia, cargaDiaria)
+
+	var abonado time.Duration
+	apuracao := Apuracao{Data: dia.Format(FormatoData)}
+	if abono != nil {
+		apuracao.Afastamento = abono.Tipo
+		abonado = min(abono.Creditado, max(esperado-trabalhado, 0))
+	}
+
+	apuracao.TrabalhadoMinutos = int(trabalhado.Minutes())
+	apuracao.EsperadoMinutos = int(esperado.Minutes())
+	apuracao.AbonadoMinutos = int(abonado.Minutes())
+	apuracao.SaldoMinutos = apuracao.TrabalhadoMinutos + apuracao.AbonadoMinutos - apuracao.EsperadoMinutos
+	return apuracao
+}
+
+// Situação de presença de um usuário no dia.
+const (
+	PresencaTrabalhando = "trabalhando"
+	PresencaEmIntervalo = "em_intervalo"
+	PresencaAusente     = "ausente"
+	PresencaNaoIniciou  = "nao_iniciou"
+	// PresencaEncerrado é a de quem ainda não tem pontos no dia e encerrou a jornada
+	// anterior com uma saída.
+	PresencaEncerrado = "encerrado"
+)
+
+// Presenca é a situação do usuário em um instante, derivada dos pontos do dia.
+type Presenca struct {
+	Situacao   string
+	Trabalhado time.Duration
+	// Atraso é o tempo entre a entrada prevista e o primeiro ponto do dia,
+	// preenchido apenas quando ultrapassa a tolerância.
+	Atraso time.Duration
+}
+
+// CalcularPresenca deriva a situação atual a partir dos pontos do dia, ordenados por horário.
+// Com quantidade ímpar de pontos o usuário está trabalhando desde o último ponto; com quantidade
+// par, está em intervalo. Sem pontos, é considerado ausente se estiver afastado ou se a entrada
+// prevista mais a tolerância já tiver passado; senão, está encerrado se os pontos do dia
+// anterior terminarem em uma saída. entradaPrevista é nil quando não há escala.
+func CalcularPresenca(pontos, anteriores []models.Ponto, agora time.Time, entradaPrevista *time.Time, tolerancia time.Duration, afastado bool) Presenca {
+	var p Presenca
+	p.Trabalhado = TotalTrabalhado(pontos)
+
+	if len(pontos) == 0 {
+		switch {
+		case afastado || (entradaPrevista != nil && agora.After(entradaPrevista.Add(tolerancia))):
+			p.Situacao = PresencaAusente
+		case len(anteriores) > 0 && len(anteriores)%2 == 0:
+			p.Situacao = PresencaEncerrado
+		default:
+			p.Situacao = PresencaNaoIniciou
+		}
+		return p
+	}
+
+	if _, aberto := Parear(pontos); aberto != nil {
+		p.Situacao = PresencaTrabalhando
+		if agora.After(aberto.Horario) {
+			p.Trabalhado += agora.Sub(aberto.Horario)
+		}
+	} else {
+		p.Situacao = PresencaEmIntervalo
+	}
+
+	if entradaPrevista != nil {
+		if atraso := pontos[0].Horario.Sub(*entradaPrevista); atraso > tolerancia {
+			p.Atraso = atraso
+		}
+	}
+	return p
+}
@@ -0,0 +1,136 @@
+package jornada
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"controle-ponto-api/models"
+)
+
+// carregarFuso carrega um fuso IANA ou interrompe o teste.
+func carregarFuso(t *testing.T, nome string) *time.Location {
+	t.Helper()
+	fuso, err := time.LoadLocation(nome)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fuso
+}
+
+func TestAgruparPorDia(t *testing.T) {
+	// 08:00 and 22:30 in São Paulo on March 1st; the second is already March 2nd in UTC
+	pontos := []models.Ponto{
+		{ID: "1", Horario: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{ID: "2", Horario: time.Date(2024, 3, 2, 1, 30, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		fuso *time.Location
+		want map[string][]string
+	}{
+		{carregarFuso(t, "America/Sao_Paulo"), map[string][]string{"2024-03-01": {"1", "2"}}},
+		{time.UTC, map[string][]string{"2024-03-01": {"1"}, "2024-03-02": {"2"}}},
+		{carregarFuso(t, "Asia/Tokyo"), map[string][]string{"2024-03-01": {"1"}, "2024-03-02": {"2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fuso.String(), func(t *testing.T) {
+			dias, porDia := AgruparPorDia(pontos, tt.fuso)
+			got := map[string][]string{}
+			for _, dia := range dias {
+				got[dia] = idsDosPontos(porDia[dia])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AgruparPorDia() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalcularPresenca(t *testing.T) {
+	dia := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	em := func(hora, minuto int) models.Ponto {
+		return models.Ponto{Horario: dia.Add(time.Duration(hora)*time.Hour + time.Duration(minuto)*time.Minute)}
+	}
+	ontem := func(hora int) models.Ponto {
+		return models.Ponto{Horario: dia.Add(time.Duration(hora-24) * time.Hour)}
+	}
+	prevista := dia.Add(8 * time.Hour)
+
+	tests := []struct {
+		name       string
+		pontos     []models.Ponto
+		anteriores []models.Ponto
+		agora      time.Time
+		prevista   *time.Time
+		afastado   bool
+		want       Presenca
+	}{
+		{
+			name:   "working since the entry",
+			pontos: []models.Ponto{em(8, 0)},
+			agora:  dia.Add(10 * time.Hour),
+			want:   Presenca{Situacao: PresencaTrabalhando, Trabalhado: 2 * time.Hour},
+		},
+		{
+			name:   "on a break",
+			pontos: []models.Ponto{em(8, 0), em(12, 0)},
+			agora:  dia.Add(12*time.Hour + 30*time.Minute),
+			want:   Presenca{Situacao: PresencaEmIntervalo, Trabalhado: 4 * time.Hour},
+		},
+		{
+			name:     "late entry",
+			pontos:   []models.Ponto{em(8, 30)},
+			agora:    dia.Add(9 * time.Hour),
+			prevista: &prevista,
+			want:     Presenca{Situacao: PresencaTrabalhando, Trabalhado: 30 * time.Minute, Atraso: 30 * time.Minute},
+		},
+		{
+			name:     "entry within the tolerance",
+			pontos:   []models.Ponto{em(8, 5)},
+			agora:    dia.Add(9 * time.Hour),
+			prevista: &prevista,
+			want:     Presenca{Situacao: PresencaTrabalhando, Trabalhado: 55 * time.Minute},
+		},
+		{
+			name:  "no pontos yet",
+			agora: dia.Add(7 * time.Hour),
+			want:  Presenca{Situacao: PresencaNaoIniciou},
+		},
+		{
+			name:       "previous day ended with an exit",
+			anteriores: []models.Ponto{ontem(8), ontem(12), ontem(13), ontem(17)},
+			agora:      dia.Add(7 * time.Hour),
+			want:       Presenca{Situacao: PresencaEncerrado},
+		},
+		{
+			// An entry with no exit leaves the previous jornada open
+			name:       "previous day ended with an entry",
+			anteriores: []models.Ponto{ontem(8), ontem(12), ontem(13)},
+			agora:      dia.Add(7 * time.Hour),
+			want:       Presenca{Situacao: PresencaNaoIniciou},
+		},
+		{
+			name:       "late after the previous day ended",
+			anteriores: []models.Ponto{ontem(8), ontem(17)},
+			agora:      dia.Add(9 * time.Hour),
+			prevista:   &prevista,
+			want:       Presenca{Situacao: PresencaAusente},
+		},
+		{
+			name:       "on leave",
+			anteriores: []models.Ponto{ontem(8), ontem(17)},
+			agora:      dia.Add(7 * time.Hour),
+			afastado:   true,
+			want:       Presenca{Situacao: PresencaAusente},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CalcularPresenca(tt.pontos, tt.anteriores, tt.agora, tt.prevista, 10*time.Minute, tt.afastado)
+			if got != tt.want {
+				t.Errorf("CalcularPresenca() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,14 +11,31 @@ package main
 // @description "Bearer token"
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	_ "time/tzdata" // empresas pick their time zone; the image may lack zoneinfo
 
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/auth"
+	"controle-ponto-api/config"
 	"controle-ponto-api/database"
 	_ "controle-ponto-api/docs" // docs is generated by Swag CLI
+	"controle-ponto-api/eventos"
 	"controle-ponto-api/handlers"
+	"controle-ponto-api/i18n"
+	"controle-ponto-api/jobs"
+	"controle-ponto-api/logging"
+	"controle-ponto-api/mailer"
+	"controle-ponto-api/metrics"
 	"controle-ponto-api/middleware"
+	"controle-ponto-api/password"
+	"controle-ponto-api/tracing"
+	"controle-ponto-api/webhooks"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -26,28 +43,128 @@ import (
 )
 
 func main() {
-	fmt.Println("Starting Ponto Control API...")
+	// Until the configuration is loaded, logs use the default format and level
+	logger, _ := logging.New(os.Stderr, logging.FormatJSON, "info")
+	slog.SetDefault(logger)
+	slog.Info("Starting Ponto Control API")
 
-	// Load environment variables (e.g., from a .env file) would be a good addition here
-	// For now, it relies on system-set env vars
+	// Defaults < YAML file (-config or CONFIG_FILE) < environment variables < flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("Invalid configuration", err)
+	}
+	logger, err = logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		fatal("Invalid log configuration", err)
+	}
+	slog.SetDefault(logger)
+
+	tokens, err := newTokenIssuer(cfg.JWT)
+	if err != nil {
+		fatal("Error configuring token keys", err)
+	}
+	handlers.Tokens = tokens
+	// Validate has already checked the list
+	trustedProxies, _ := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	handlers.OIDCCallbackURL = cfg.OIDC.CallbackURL
+	handlers.OIDCFrontendURL = cfg.OIDC.FrontendURL
+	handlers.Mailer = newMailer(cfg.Mail)
+	handlers.AppURL = cfg.Mail.AppURL
+	i18n.Default = cfg.Server.DefaultLocale
+	politica, err := newPasswordPolicy(cfg.Password)
+	if err != nil {
+		fatal("Error loading breached password list", err)
+	}
+	handlers.PoliticaSenha = politica
+	handlers.HistoricoSenhas = cfg.Password.HistorySize
+	handlers.LimitesLogin = handlers.LimitesTentativasLogin{
+		MaxFalhas:       cfg.Login.MaxFailures,
+		DuracaoBloqueio: cfg.Login.LockoutDuration,
+		JanelaFalhas:    cfg.Login.FailureWindow,
+		MaxFalhasIP:     cfg.Login.IPMaxFailures,
+		AtrasoBase:      cfg.Login.DelayBase,
+		AtrasoMax:       cfg.Login.DelayMax,
+	}
 
-	if err := database.InitDB(); err != nil {
-		log.Fatalf("Error initializing database: %v", err)
+	// Set up before the database so its statements are traced
+	shutdownTracing, err := tracing.New(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("Error configuring tracing", err)
 	}
-	defer database.DB.Close()
+
+	if err := database.InitDB(cfg.Database.URL); err != nil {
+		fatal("Error initializing database", err)
+	}
+
+	metrics.RegisterDB(database.DB)
+	metrics.RegisterQueueDepth(func(ctx context.Context) (map[string]int, error) {
+		return jobs.ContarPendentes(ctx, database.DB)
+	})
+
+	idempotencyStore := middleware.NewMemoryIdempotencyStore()
+
+	// Background workers stop when workersCtx is cancelled, after the HTTP server has drained
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	despachante := webhooks.NovoDespachante(database.DB)
+	jobRunner := jobs.NovoRunner(database.DB)
+	jobRunner.Registrar(handlers.JobReavaliarViolacoes, handlers.ReavaliarViolacoesJob)
+	jobRunner.Registrar(handlers.JobEnviarTokenEmail, handlers.EnviarTokenEmailJob)
+	jobRunner.Registrar(handlers.JobRecalcularBancoDeHoras, handlers.RecalcularBancoDeHorasJob)
+	jobRunner.Registrar(handlers.JobGerarRelatorio, handlers.GerarRelatorioJob)
+	jobRunner.Registrar(handlers.JobImportarPontos, handlers.ImportarPontosJob)
+
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		despachante.Executar(workersCtx)
+	}()
+	go func() {
+		defer workers.Done()
+		jobRunner.Executar(workersCtx)
+	}()
 
 	r := chi.NewRouter()
 
+	// Every request gets an ID, logged with each of its records and reported in error responses
+	r.Use(middleware.RequestID)
+
+	// Client IPs (login attempt limits, rate limits and the access log) come from X-Forwarded-For only behind a trusted proxy
+	r.Use(middleware.ClientIP(trustedProxies))
+
+	// Spans continue the caller's W3C traceparent; the access log then carries the trace ID
+	r.Use(middleware.Tracing)
+	r.Use(middleware.AccessLog(logger))
+	r.Use(middleware.Metrics)
+	r.Use(middleware.Locale)
+
 	// CORS Middleware
 	r.Use(cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"}, // Allow all for dev
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedOrigins: cfg.Server.CORSOrigins,
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Accept-Language", "Authorization", "Content-Type", "X-CSRF-Token", "Last-Event-ID", middleware.IdempotencyKeyHeader, middleware.RequestIDHeader, "traceparent", "tracestate"},
+		ExposedHeaders: []string{
+			"Link", "Retry-After", middleware.IdempotentReplayedHeader, middleware.RequestIDHeader,
+			middleware.RateLimitLimitHeader, middleware.RateLimitRemainingHeader, middleware.RateLimitResetHeader, middleware.RateLimitPolicyHeader,
+		},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}).Handler)
 
+	// Unknown routes and methods answer with the same problem body as the handlers
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusNotFound, "", "Route not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, "", "Method not allowed")
+	})
+
 	// Swagger UI
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
 
@@ -56,24 +173,276 @@ func main() {
 		w.Write([]byte("API is live!"))
 	})
 
+	// Prometheus scrape endpoint
+	if cfg.Metrics.Enabled {
+		r.Method(http.MethodGet, "/metrics", metrics.Handler(cfg.Metrics.Token))
+	}
+
+	// Public keys other services use to verify our tokens
+	r.Get("/.well-known/jwks.json", handlers.JWKS)
+
+	// Quotas per route group: public routes count per client IP, the others per user
+	rateLimitStore := middleware.NewMemoryRateLimitStore()
+	rateLimit := func(name string, rule config.RateLimitRule) func(http.Handler) http.Handler {
+		return middleware.RateLimiter(rateLimitStore, middleware.RateLimit{Name: name, Requests: rule.Requests, Per: rule.Per})
+	}
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
 		// Public auth routes
-		r.Post("/register", handlers.Register)
-		r.Post("/login", handlers.Login)
+		r.Group(func(r chi.Router) {
+			r.Use(rateLimit("auth", cfg.RateLimit.Auth))
+
+			r.Post("/register", handlers.Register)
+			r.Post("/login", handlers.Login)
+			r.Post("/login/mfa", handlers.VerificarLoginMFA)
+			r.Post("/login/mfa/cadastro", handlers.IniciarCadastroMFALogin)
+			r.Post("/login/mfa/cadastro/confirmar", handlers.ConfirmarCadastroMFALogin)
+			r.Post("/email/verificacao", handlers.SolicitarVerificacaoEmail)
+			r.Post("/email/verificar", handlers.VerificarEmail)
+			r.Post("/senha/esqueci", handlers.SolicitarRedefinicaoSenha)
+			r.Post("/senha/redefinir", handlers.RedefinirSenha)
+			r.Get("/auth/oidc/iniciar", handlers.IniciarLoginOIDC)
+			r.Get("/auth/oidc/callback", handlers.OIDCCallback)
+		})
 
 		// Protected routes
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.JwtAuthentication)
+			r.Use(middleware.JwtAuthentication(tokens, handlers.VersaoTokenDoUsuario))
+			// Without a usable Accept-Language, answer in the language the user chose
+			r.Use(middleware.UserLocale(handlers.IdiomaDoUsuario))
+
+			// Reports
+			r.Group(func(r chi.Router) {
+				r.Use(rateLimit("reports", cfg.RateLimit.Reports))
+
+				r.Get("/pontos/inconsistencias", handlers.ListarInconsistencias)
+				r.Get("/pontos/{data}/total-horas", handlers.CalcularHorasTrabalhadas)
+				r.Get("/violacoes", handlers.ListarViolacoes)
+				r.Get("/banco-horas", handlers.ConsultarBancoDeHoras)
+				r.Get("/equipe/status", handlers.StatusEquipe)
+				r.Get("/relatorios/{id}", handlers.ObterExportacaoRelatorio)
+				r.Get("/relatorios/{id}/arquivo", handlers.BaixarExportacaoRelatorio)
+
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL))
+
+					r.Post("/relatorios", handlers.SolicitarExportacaoRelatorio)
+				})
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(rateLimit("pontos", cfg.RateLimit.Pontos))
+
+				r.Get("/pontos/{data}", handlers.ListarPontosPorData)
+				r.Get("/pontos/importacoes/{id}", handlers.ObterImportacaoPontos)
+
+				// Mutating routes replay the first response for a repeated Idempotency-Key
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.Idempotency(idempotencyStore, cfg.Idempotency.TTL))
+
+					r.Post("/pontos", handlers.RegistrarPonto)
+					r.Post("/pontos/sync", handlers.SincronizarPontos)
+					r.Post("/pontos/importacoes", handlers.SolicitarImportacaoPontos)
+					r.Put("/pontos/{id}", handlers.AtualizarPonto)
+					r.Delete("/pontos/{id}", handlers.DeletarPonto)
+				})
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(rateLimit("default", cfg.RateLimit.Default))
+
+				r.Put("/conta/idioma", handlers.AtualizarIdioma)
+				r.Get("/mfa", handlers.ObterStatusMFA)
+				r.Post("/mfa/totp", handlers.IniciarCadastroTOTP)
+				r.Post("/mfa/totp/confirmar", handlers.ConfirmarCadastroTOTP)
+				r.Delete("/mfa/totp", handlers.DesativarTOTP)
+				r.Post("/mfa/codigos-recuperacao", handlers.RegerarCodigosRecuperacao)
+				r.Get("/empresa/regras", handlers.ObterRegrasEmpresa)
+				r.Put("/empresa/regras", handlers.AtualizarRegrasEmpresa)
+				r.Get("/empresa/provedor-identidade", handlers.ObterProvedorIdentidade)
+				r.Put("/empresa/provedor-identidade", handlers.ConfigurarProvedorIdentidade)
+				r.Delete("/empresa/provedor-identidade", handlers.RemoverProvedorIdentidade)
+				r.Post("/empresa/provedor-identidade/dominios/{dominio}/verificar", handlers.VerificarDominioProvedor)
+				r.Post("/auth/oidc/vincular", handlers.VincularContaOIDC)
+				r.Put("/equipe/membros/{id}/escala", handlers.AtualizarEscala)
+				r.Delete("/equipe/membros/{id}/bloqueio-login", handlers.DesbloquearLogin)
+				r.Get("/eventos/stream", handlers.StreamEventos)
 
-			r.Post("/pontos", handlers.RegistrarPonto)
-			r.Get("/pontos/{data}", handlers.ListarPontosPorData)
-			r.Get("/pontos/{data}/total-horas", handlers.CalcularHorasTrabalhadas)
-			r.Put("/pontos/{id}", handlers.AtualizarPonto)
-			r.Delete("/pontos/{id}", handlers.DeletarPonto)
+				r.Get("/webhooks", handlers.ListarWebhooks)
+				r.Post("/webhooks", handlers.CriarWebhook)
+				r.Delete("/webhooks/{id}", handlers.RemoverWebhook)
+				r.Post("/webhooks/{id}/testar", handlers.TestarWebhook)
+				r.Get("/webhooks/{id}/entregas", handlers.ListarEntregasWebhook)
+				r.Post("/webhooks/{id}/entregas/{entrega_id}/reenviar", handlers.ReenviarEntregaWebhook)
+
+				r.Get("/afastamentos/tipos", handlers.ListarTiposAfastamento)
+				r.Get("/afastamentos", handlers.ListarAfastamentos)
+				r.Post("/afastamentos", handlers.SolicitarAfastamento)
+				r.Post("/afastamentos/{id}/anexos", handlers.AdicionarAnexoAfastamento)
+				r.Post("/afastamentos/{id}/aprovar", handlers.AprovarAfastamento)
+				r.Post("/afastamentos/{id}/rejeitar", handlers.RejeitarAfastamento)
+				r.Post("/afastamentos/{id}/cancelar", handlers.CancelarAfastamento)
+
+				r.Get("/ajustes", handlers.ListarAjustes)
+				r.Post("/ajustes", handlers.SolicitarAjuste)
+				r.Post("/ajustes/{id}/aprovar", handlers.AprovarAjuste)
+				r.Post("/ajustes/{id}/rejeitar", handlers.RejeitarAjuste)
+			})
 		})
 	})
 
-	fmt.Println("Server listening on port 8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr(),
+		Handler:           r,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+	// Event streams never become idle, so they are closed for Shutdown to finish
+	srv.RegisterOnShutdown(eventos.Padrao.Encerrar)
+
+	sinal, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server listening", "port", cfg.Server.Port, "env", cfg.Env)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		slog.Error("HTTP server error", "error", err)
+		exitCode = 1
+	case <-sinal.Done():
+		slog.Info("Shutdown signal received, draining in-flight requests")
+	}
+	stopSignals()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Error draining HTTP server", "error", err)
+		exitCode = 1
+	}
+
+	stopWorkers()
+	if !waitWithContext(ctx, &workers) {
+		slog.Warn("Background workers did not stop before the shutdown deadline; leased jobs will be retried")
+		exitCode = 1
+	}
+
+	// Spans still buffered are sent before exiting
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+
+	// The database is closed last, after every request and worker that uses it has finished
+	if err := database.DB.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+	slog.Info("Server stopped")
+	os.Exit(exitCode)
+}
+
+// fatal logs the error that keeps the server from starting and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// waitWithContext waits for wg, returning false if ctx is done first.
+func waitWithContext(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// newTokenIssuer signs with the private key file when one is configured, otherwise with the
+// current secret. Every other configured secret and key file only validates tokens, so keys
+// can be rotated without logging everyone out. Key IDs are derived from the key material,
+// so tokens keep validating across restarts and instances.
+func newTokenIssuer(cfg config.JWTConfig) (*auth.Issuer, error) {
+	var keys []*auth.Key
+	var signingKeyID string
+
+	if cfg.SigningKeyFile != "" {
+		k, err := auth.LoadKeyFile(cfg.SigningKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		signingKeyID = k.ID
+	}
+	for _, path := range cfg.VerificationKeyFiles {
+		k, err := auth.LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	secrets := cfg.PreviousSecrets
+	if cfg.Secret != "" {
+		secrets = append([]string{cfg.Secret}, secrets...)
+	}
+	for _, secret := range secrets {
+		k, err := auth.NewHMACKey(auth.HMACKeyID(secret), []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if signingKeyID == "" && cfg.Secret != "" {
+		signingKeyID = auth.HMACKeyID(cfg.Secret)
+	}
+
+	return auth.NewIssuer(auth.Options{
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		TTL:      cfg.TTL,
+		Leeway:   cfg.Leeway,
+	}, signingKeyID, keys...)
+}
+
+// newPasswordPolicy returns the policy for new passwords, loading the breached password
+// list when one is configured.
+func newPasswordPolicy(cfg config.PasswordConfig) (*password.Policy, error) {
+	policy := &password.Policy{MinLength: cfg.MinLength, MinScore: cfg.MinScore}
+	if cfg.BreachedList != "" {
+		list, err := password.LoadBreached(cfg.BreachedList)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = list
+	}
+	return policy, nil
+}
+
+// newMailer returns the Sender for the configured driver.
+func newMailer(cfg config.MailConfig) mailer.Sender {
+	if cfg.Driver == config.MailDriverSMTP {
+		return &mailer.SMTPSender{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+		}
+	}
+	return &mailer.LogSender{Dir: cfg.Dir, From: cfg.From}
 }
@@ -0,0 +1,139 @@
+// Package metrics defines the Prometheus metrics of the API and serves them for scraping.
+// Metrics live in the default registry, next to the Go runtime and process collectors.
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "controle_ponto"
+
+// Origins of registered pontos, for PontosRegistered.
+const (
+	OriginOnline = "online"
+	OriginSync   = "sync"
+	OriginImport = "import"
+)
+
+// Reasons of failed logins, for LoginFailures.
+const (
+	LoginInvalidCredentials = "invalid_credentials"
+	LoginLocked             = "locked"
+	LoginThrottled          = "throttled"
+	LoginInvalidMFACode     = "invalid_mfa_code"
+)
+
+// Outcomes of webhook delivery attempts, for WebhookDeliveries.
+const (
+	WebhookDelivered = "delivered"
+	WebhookRetrying  = "retrying"
+	WebhookFailed    = "failed"
+)
+
+var (
+	// HTTPRequests counts finished requests by method, chi route pattern and status.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes request latency by method, chi route pattern and status.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	// PontosRegistered counts new pontos by origin: online or synchronized from the app.
+	PontosRegistered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pontos_registered_total",
+		Help:      "Pontos registered, by origin (online or sync).",
+	}, []string{"origin"})
+
+	// LoginFailures counts failed logins by reason.
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Failed logins, by reason (invalid_credentials, invalid_mfa_code, locked or throttled).",
+	}, []string{"reason"})
+
+	// WebhookDeliveries counts webhook delivery attempts by outcome.
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts, by outcome (delivered, retrying or failed).",
+	}, []string{"outcome"})
+)
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// QueueDepthFunc returns the number of pending background jobs by type.
+type QueueDepthFunc func(ctx context.Context) (map[string]int, error)
+
+// RegisterQueueDepth exports the background job queue depth, calling depth on each scrape.
+func RegisterQueueDepth(depth QueueDepthFunc) {
+	prometheus.MustRegister(&queueCollector{depth: depth})
+}
+
+var queueDepthDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "jobs", "queue_depth"),
+	"Background jobs waiting to run, by type.",
+	[]string{"tipo"}, nil,
+)
+
+type queueCollector struct {
+	depth QueueDepthFunc
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := c.depth(ctx)
+	if err != nil {
+		slog.Error("Error counting queued jobs", "error", err)
+		ch <- prometheus.NewInvalidMetric(queueDepthDesc, err)
+		return
+	}
+	for tipo, n := range counts {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), tipo)
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format. With a non-empty token,
+// scrapers must send it as a Bearer token.
+func Handler(token string) http.Handler {
+	h := promhttp.Handler()
+	if token == "" {
+		return h
+	}
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
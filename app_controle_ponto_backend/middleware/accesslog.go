@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+type accessLogKey struct{}
+
+// accessLogEntry collects what inner handlers learn about the request, such as the user
+// that JwtAuthentication identified.
+type accessLogEntry struct {
+	userID int64
+}
+
+// setAccessLogUser records the authenticated user in the access log of the request.
+func setAccessLogUser(ctx context.Context, userID int64) {
+	if e, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		e.userID = userID
+	}
+}
+
+// AccessLog logs one record per request when it finishes: method, route pattern, path,
+// status, response size, latency, client IP and the authenticated user, if any. Server
+// errors are logged at error level. It must run after RequestID so the record carries the
+// request ID, and after ClientIP.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &accessLogEntry{}
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := ""
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("ip", RemoteIP(r)),
+			}
+			if entry.userID != 0 {
+				attrs = append(attrs, slog.Int64("user_id", entry.userID))
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
@@ -2,62 +2,59 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"controle-ponto-api/apierror"
+	"controle-ponto-api/auth"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET"))
-
-type Claims struct {
-	UserID int64 `json:"user_id"`
-	jwt.RegisteredClaims
-}
-
 // ContextKey is a custom type to avoid collisions in context keys.
 type ContextKey string
 
 const UserIDKey ContextKey = "user_id"
 
-func JwtAuthentication(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		bearerToken := strings.Split(authHeader, " ")
-		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			http.Error(w, "Invalid authorization header format", http.StatusUnauthorized)
-			return
-		}
-
-		tokenStr := bearerToken[1]
-		claims := &Claims{}
-
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtKey, nil
-		})
+// JwtAuthentication requires a Bearer token accepted by tokens and puts its user ID in
+// the request context. tokenVersion returns the user's current token version; tokens
+// issued with an older one were revoked and are refused.
+func JwtAuthentication(tokens *auth.Issuer, tokenVersion func(ctx context.Context, userID int64) (int64, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeTokenMissing, "Missing authorization header")
+				return
+			}
 
-		if err != nil {
-			if err == jwt.ErrSignatureInvalid {
-				http.Error(w, "Invalid token signature", http.StatusUnauthorized)
+			bearerToken := strings.Split(authHeader, " ")
+			if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeTokenInvalid, "Invalid authorization header format")
 				return
 			}
-			http.Error(w, "Invalid token", http.StatusBadRequest)
-			return
-		}
-
-		if !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		// Add user_id to the context of the request
-		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+
+			claims, err := tokens.Validate(bearerToken[1])
+			if err != nil {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeTokenInvalid, "Invalid token")
+				return
+			}
+			version, err := tokenVersion(r.Context(), claims.UserID)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && version != claims.Version) {
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeTokenInvalid, "Invalid token")
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error checking token version", "error", err)
+				apierror.Write(w, r, http.StatusInternalServerError, "", "Internal server error")
+				return
+			}
+
+			// Add user_id to the context of the request
+			setAccessLogUser(r.Context(), claims.UserID)
+			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"controle-ponto-api/auth"
+)
+
+func TestJwtAuthenticationTokenVersion(t *testing.T) {
+	key, err := auth.NewHMACKey("test", []byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := auth.NewIssuer(auth.Options{Issuer: "controle-ponto", Audience: "controle-ponto-api", TTL: time.Hour}, "test", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := tokens.Issue(5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		version    int64
+		versionErr error
+		want       int
+	}{
+		{"current version", 2, nil, http.StatusOK},
+		{"revoked by a password reset", 3, nil, http.StatusUnauthorized},
+		{"deleted user", 0, sql.ErrNoRows, http.StatusUnauthorized},
+		{"lookup error", 0, errors.New("db down"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := func(ctx context.Context, userID int64) (int64, error) {
+				if userID != 5 {
+					t.Errorf("version lookup for user %d, want 5", userID)
+				}
+				return tt.version, tt.versionErr
+			}
+			h := JwtAuthentication(tokens, version)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if id, _ := r.Context().Value(UserIDKey).(int64); id != 5 {
+					t.Errorf("user ID in context = %d, want 5", id)
+				}
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/api/pontos", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
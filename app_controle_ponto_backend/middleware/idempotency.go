@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"controle-ponto-api/apierror"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// IdempotencyRecord is the stored state of a request made with an Idempotency-Key.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore keeps the responses of idempotent requests.
+// Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Reserve marks the key as in progress. If the key already exists, the
+	// existing record is returned and reserved is false.
+	Reserve(key, fingerprint string, ttl time.Duration) (existing *IdempotencyRecord, reserved bool)
+	// Complete stores the final response for a reserved key.
+	Complete(key string, record IdempotencyRecord, ttl time.Duration)
+	// Release drops a reserved key so the request can be retried.
+	Release(key string)
+}
+
+type memoryIdempotencyEntry struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	lastSweep time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]memoryIdempotencyEntry)}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	if entry, ok := s.entries[key]; ok && now.Before(entry.expiresAt) {
+		record := entry.record
+		return &record, false
+	}
+
+	s.entries[key] = memoryIdempotencyEntry{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(ttl),
+	}
+	return nil, true
+}
+
+func (s *MemoryIdempotencyStore) Complete(key string, record IdempotencyRecord, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record.Completed = true
+	s.entries[key] = memoryIdempotencyEntry{record: record, expiresAt: time.Now().Add(ttl)}
+}
+
+func (s *MemoryIdempotencyStore) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+}
+
+// sweep removes expired entries at most once a minute. Must be called with s.mu held.
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Idempotency honors the Idempotency-Key header on POST, PUT and DELETE requests.
+// The first response for a key is kept for ttl and replayed for identical retries;
+// reusing a key with a different request returns 409 Conflict.
+// Keys are scoped by the authenticated user, so it must run after JwtAuthentication.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !isIdempotentMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				apierror.Write(w, r, http.StatusBadRequest, "", "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, "", "Failed to read request body")
+				return
+			}
+			if len(body) > maxIdempotentRequestBytes {
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, "", "Request body too large")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			userID, _ := r.Context().Value(UserIDKey).(int64)
+			scopedKey := fmt.Sprintf("%d:%s", userID, key)
+			fingerprint := requestFingerprint(r, body)
+
+			existing, reserved := store.Reserve(scopedKey, fingerprint, ttl)
+			if !reserved {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					apierror.Write(w, r, http.StatusConflict, apierror.CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+				case !existing.Completed:
+					apierror.Write(w, r, http.StatusConflict, apierror.CodeIdempotencyKeyInProgress, "A request with this Idempotency-Key is still being processed")
+				default:
+					replayResponse(w, existing)
+				}
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					store.Release(scopedKey)
+				}
+			}()
+
+			// Headers already set belong to the middlewares around this one and to this request only
+			outerHeader := w.Header().Clone()
+			next.ServeHTTP(rec, r)
+
+			// Server errors are not cached so the client can retry them.
+			if rec.statusCode >= http.StatusInternalServerError {
+				return
+			}
+
+			store.Complete(scopedKey, IdempotencyRecord{
+				Fingerprint: fingerprint,
+				StatusCode:  rec.statusCode,
+				Header:      handlerHeader(outerHeader, w.Header()),
+				Body:        rec.body.Bytes(),
+			}, ttl)
+			completed = true
+		})
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodDelete
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// handlerHeader returns the headers the handler added or changed. The others, such as
+// X-Request-ID and RateLimit-*, were set for the first request and would be stale in a replay.
+func handlerHeader(outer, final http.Header) http.Header {
+	header := http.Header{}
+	for name, values := range final {
+		if !slices.Equal(outer[name], values) {
+			header[name] = slices.Clone(values)
+		}
+	}
+	return header
+}
+
+func replayResponse(w http.ResponseWriter, record *IdempotencyRecord) {
+	for name, values := range record.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.statusCode = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"controle-ponto-api/apierror"
+)
+
+// servidorIdempotente monta o Idempotency entre um middleware que, como os de request ID e
+// rate limit, marca cada requisição com o seu número, e o handler dado.
+func servidorIdempotente(handler http.HandlerFunc) http.Handler {
+	var requisicoes atomic.Int64
+	h := Idempotency(NewMemoryIdempotencyStore(), time.Hour)(handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := strconv.FormatInt(requisicoes.Add(1), 10)
+		w.Header().Set(RequestIDHeader, "req-"+n)
+		w.Header().Set(RateLimitRemainingHeader, n)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), UserIDKey, int64(7))))
+	})
+}
+
+// requisicaoIdempotente faz um POST em /api/pontos com a chave e o corpo dados.
+func requisicaoIdempotente(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/pontos", strings.NewReader(body))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	var chamadas atomic.Int64
+	h := servidorIdempotente(func(w http.ResponseWriter, r *http.Request) {
+		n := chamadas.Add(1)
+		w.Header().Set("Location", "/api/pontos/"+strconv.FormatInt(n, 10))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": ` + strconv.FormatInt(n, 10) + `}`))
+	})
+
+	primeira := requisicaoIdempotente(h, "chave-1", `{"horario": "2024-03-04T08:00:00Z"}`)
+	repetida := requisicaoIdempotente(h, "chave-1", `{"horario": "2024-03-04T08:00:00Z"}`)
+
+	if chamadas.Load() != 1 {
+		t.Errorf("handler called %d times, want 1", chamadas.Load())
+	}
+	if repetida.Code != primeira.Code || repetida.Body.String() != primeira.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", repetida.Code, repetida.Body, primeira.Code, primeira.Body)
+	}
+	if got := repetida.Header().Get(IdempotentReplayedHeader); got != "true" {
+		t.Errorf("%s = %q, want true", IdempotentReplayedHeader, got)
+	}
+	if got := repetida.Header().Get("Location"); got != "/api/pontos/1" {
+		t.Errorf("Location = %q, want the one the handler set", got)
+	}
+	// The headers of the middlewares around it describe the replay, not the first request
+	if got := repetida.Header().Get(RequestIDHeader); got != "req-2" {
+		t.Errorf("%s = %q, want req-2", RequestIDHeader, got)
+	}
+	if got := repetida.Header().Get(RateLimitRemainingHeader); got != "2" {
+		t.Errorf("%s = %q, want 2", RateLimitRemainingHeader, got)
+	}
+}
+
+func TestIdempotencyConflicts(t *testing.T) {
+	t.Run("different payload", func(t *testing.T) {
+		h := servidorIdempotente(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+		requisicaoIdempotente(h, "chave-1", `{"horario": "2024-03-04T08:00:00Z"}`)
+		rec := requisicaoIdempotente(h, "chave-1", `{"horario": "2024-03-04T12:00:00Z"}`)
+
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), apierror.CodeIdempotencyKeyReused) {
+			t.Errorf("response = %d %s, want 409 %s", rec.Code, rec.Body, apierror.CodeIdempotencyKeyReused)
+		}
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		iniciou := make(chan struct{})
+		liberar := make(chan struct{})
+		h := servidorIdempotente(func(w http.ResponseWriter, r *http.Request) {
+			close(iniciou)
+			<-liberar
+			w.WriteHeader(http.StatusCreated)
+		})
+		primeira := make(chan *httptest.ResponseRecorder)
+		go func() {
+			primeira <- requisicaoIdempotente(h, "chave-1", `{}`)
+		}()
+		<-iniciou
+
+		rec := requisicaoIdempotente(h, "chave-1", `{}`)
+		close(liberar)
+
+		if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), apierror.CodeIdempotencyKeyInProgress) {
+			t.Errorf("response = %d %s, want 409 %s", rec.Code, rec.Body, apierror.CodeIdempotencyKeyInProgress)
+		}
+		if got := (<-primeira).Code; got != http.StatusCreated {
+			t.Errorf("first request status = %d, want %d", got, http.StatusCreated)
+		}
+	})
+}
+
+func TestIdempotencyServerErrorNotCached(t *testing.T) {
+	var chamadas atomic.Int64
+	h := servidorIdempotente(func(w http.ResponseWriter, r *http.Request) {
+		if chamadas.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	if rec := requisicaoIdempotente(h, "chave-1", `{}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("first status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	rec := requisicaoIdempotente(h, "chave-1", `{}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry = %d replayed %q, want a new %d", rec.Code, rec.Header().Get(IdempotentReplayedHeader), http.StatusCreated)
+	}
+	if chamadas.Load() != 2 {
+		t.Errorf("handler called %d times, want 2", chamadas.Load())
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"controle-ponto-api/i18n"
+)
+
+type localeFromHeaderKey struct{}
+
+// Locale puts the locale negotiated from Accept-Language in the request context, where
+// i18n.FromContext finds it, and reports it in Content-Language. Without a supported
+// language in the header, i18n.Default is used until UserLocale finds the user's own.
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := i18n.Negotiate(r.Header.Get("Accept-Language"))
+		ctx := r.Context()
+		if locale == "" {
+			locale = i18n.Default
+		} else {
+			ctx = context.WithValue(ctx, localeFromHeaderKey{}, true)
+		}
+		w.Header().Set("Content-Language", locale)
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(ctx, locale)))
+	})
+}
+
+// UserLocale answers authenticated requests whose Accept-Language names no supported
+// language in the language stored for the user, which lookup returns ("" if none). It must
+// run after Locale and JwtAuthentication. A lookup error keeps the default.
+func UserLocale(lookup func(ctx context.Context, userID int64) (string, error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := r.Context().Value(UserIDKey).(int64)
+			if fromHeader, _ := r.Context().Value(localeFromHeaderKey{}).(bool); fromHeader || !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			locale, err := lookup(r.Context(), userID)
+			if err != nil {
+				slog.WarnContext(r.Context(), "Error loading user locale", "error", err)
+			}
+			if err != nil || !i18n.IsSupported(locale) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Content-Language", locale)
+			next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"controle-ponto-api/i18n"
+)
+
+func TestUserLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		userID         int64
+		stored         string
+		lookupErr      error
+		want           string
+		wantLookup     bool
+	}{
+		{"header wins", "es-AR,es;q=0.9", 1, "en", nil, i18n.Spanish, false},
+		{"unsupported header uses the stored language", "fr-FR", 1, "en", nil, i18n.English, true},
+		{"no header uses the stored language", "", 1, "es", nil, i18n.Spanish, true},
+		{"no stored language", "", 1, "", nil, i18n.Default, true},
+		{"unsupported stored language", "", 1, "fr", nil, i18n.Default, true},
+		{"lookup error", "", 1, "", errors.New("db down"), i18n.Default, true},
+		{"anonymous request", "", 0, "en", nil, i18n.Default, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			looked := false
+			lookup := func(ctx context.Context, userID int64) (string, error) {
+				looked = true
+				if userID != tt.userID {
+					t.Errorf("lookup for user %d, want %d", userID, tt.userID)
+				}
+				return tt.stored, tt.lookupErr
+			}
+
+			var got string
+			h := Locale(authenticated(tt.userID, UserLocale(lookup)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = i18n.FromContext(r.Context())
+			}))))
+			r := httptest.NewRequest(http.MethodGet, "/api/pontos", nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if got != tt.want {
+				t.Errorf("locale = %q, want %q", got, tt.want)
+			}
+			if cl := rec.Header().Get("Content-Language"); cl != tt.want {
+				t.Errorf("Content-Language = %q, want %q", cl, tt.want)
+			}
+			if looked != tt.wantLookup {
+				t.Errorf("lookup called = %v, want %v", looked, tt.wantLookup)
+			}
+		})
+	}
+}
+
+// authenticated stands in for JwtAuthentication; a zero userID leaves the request anonymous.
+func authenticated(userID int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if userID != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), UserIDKey, userID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"controle-ponto-api/metrics"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// Metrics records the count and latency of every request by method, chi route pattern and
+// status. Requests that match no route are grouped as "unmatched", so unknown paths can't
+// grow the number of series.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+			// A miss inside a sub-router still leaves its mount pattern, such as "/api/*"
+			if route != "" && (status == http.StatusNotFound || status == http.StatusMethodNotAllowed) && !routeExists(rctx, r) {
+				route = ""
+			}
+		}
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := []string{r.Method, route, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeExists reports whether the router that served r has a route for its method and path.
+func routeExists(rctx *chi.Context, r *http.Request) bool {
+	if rctx.Routes == nil {
+		return false
+	}
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+	return rctx.Routes.Match(chi.NewRouteContext(), r.Method, path)
+}
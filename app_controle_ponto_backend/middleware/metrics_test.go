@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"controle-ponto-api/metrics"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsRouteLabels(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Metrics)
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/pontos/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if chi.URLParam(r, "id") == "0" {
+				http.NotFound(w, r)
+			}
+		})
+		r.Delete("/pontos/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		route  string
+		status string
+	}{
+		{"path parameter", http.MethodGet, "/api/pontos/42", "/api/pontos/{id}", "200"},
+		{"other method", http.MethodDelete, "/api/pontos/7", "/api/pontos/{id}", "204"},
+		{"resource not found", http.MethodGet, "/api/pontos/0", "/api/pontos/{id}", "404"},
+		{"unknown path", http.MethodGet, "/nao-existe/123", "unmatched", "404"},
+		{"unknown path in a sub-router", http.MethodGet, "/api/nao-existe/123", "unmatched", "404"},
+		{"method not allowed", http.MethodPost, "/api/pontos/42", "unmatched", "405"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The collectors are global, so the test compares the series before and after
+			counter := metrics.HTTPRequests.WithLabelValues(tt.method, tt.route, tt.status)
+			before := testutil.ToFloat64(counter)
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got := testutil.ToFloat64(counter) - before; got != 1 {
+				t.Errorf("%s %s: http_requests_total{route=%q, status=%q} grew by %v, want 1", tt.method, tt.path, tt.route, tt.status, got)
+			}
+			// The raw path must never become a label
+			if got := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(tt.method, tt.path, tt.status)); got != 0 {
+				t.Errorf("http_requests_total{route=%q} = %v, want 0", tt.path, got)
+			}
+		})
+	}
+}
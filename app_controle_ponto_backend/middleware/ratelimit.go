@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"controle-ponto-api/apierror"
+)
+
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+	RateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+// RateLimit is a token bucket quota: a client may burst up to Requests requests and
+// regains them at a steady pace over Per.
+type RateLimit struct {
+	// Name separates the buckets of different route groups.
+	Name     string
+	Requests int
+	Per      time.Duration
+}
+
+// RateLimitResult is the state of a bucket after a request was counted.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, when it wasn't.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the token buckets. A store shared between instances (such as Redis)
+// lets every replica enforce the same quota. Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take spends one token from the bucket for key, which starts full.
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// maxRateLimitBuckets bounds the memory of a MemoryRateLimitStore. Past it, the least
+// recently used bucket is dropped.
+const maxRateLimitBuckets = 100_000
+
+type tokenBucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// MemoryRateLimitStore is an in-memory RateLimitStore, local to one instance. It keeps at
+// most maxRateLimitBuckets buckets and drops the idle ones, which have refilled.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// lru orders the buckets from the most to the least recently used.
+	lru        *list.List
+	maxBuckets int
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:    make(map[string]*list.Element),
+		lru:        list.New(),
+		maxBuckets: maxRateLimitBuckets,
+		now:        time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	perToken := limit.Per.Seconds() / capacity
+
+	var b *tokenBucket
+	if e, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(e)
+		b = e.Value.(*tokenBucket)
+	} else {
+		if len(s.buckets) >= s.maxBuckets {
+			s.remove(s.lru.Back())
+		}
+		b = &tokenBucket{key: key, tokens: capacity, updated: now}
+		s.buckets[key] = s.lru.PushFront(b)
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()/perToken)
+	b.updated = now
+
+	result := RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) * perToken)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) * perToken)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops buckets that have refilled, which behave like new ones, at most once a
+// minute. Must be called with s.mu held.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for e := s.lru.Back(); e != nil; {
+		prev := e.Prev()
+		if !now.Before(e.Value.(*tokenBucket).full) {
+			s.remove(e)
+		}
+		e = prev
+	}
+}
+
+// remove drops the bucket of e. Must be called with s.mu held.
+func (s *MemoryRateLimitStore) remove(e *list.Element) {
+	delete(s.buckets, s.lru.Remove(e).(*tokenBucket).key)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimiter enforces limit per client: the authenticated user when it runs after
+// JwtAuthentication, otherwise the client IP. Clients over the quota get 429 Too Many
+// Requests with Retry-After; every response carries the RateLimit headers. A limit with
+// zero Requests disables it. If the store fails, requests are let through.
+func RateLimiter(store RateLimitStore, limit RateLimit) func(http.Handler) http.Handler {
+	if limit.Requests <= 0 || limit.Per <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(math.Ceil(limit.Per.Seconds())))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := store.Take(r.Context(), rateLimitKey(r, limit.Name), limit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Rate limit store error", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set(RateLimitLimitHeader, strconv.Itoa(limit.Requests))
+			h.Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			h.Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(result.Reset)))
+			h.Set(RateLimitPolicyHeader, policy)
+			if !result.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies the client, by the IP found by ClientIP when unauthenticated.
+func rateLimitKey(r *http.Request, name string) string {
+	if userID, ok := r.Context().Value(UserIDKey).(int64); ok {
+		return fmt.Sprintf("%s:user:%d", name, userID)
+	}
+	return name + ":ip:" + RemoteIP(r)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is the clock of a MemoryRateLimitStore under test.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*MemoryRateLimitStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewMemoryRateLimitStore()
+	s.now = clock.now
+	return s, clock
+}
+
+func TestMemoryRateLimitStoreTake(t *testing.T) {
+	limit := RateLimit{Name: "test", Requests: 3, Per: 3 * time.Second}
+
+	type step struct {
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"burst up to the limit", []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, time.Second},
+		}},
+		{"refill one token per period share", []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{time.Second, true, 0, 0},
+			{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+			{500 * time.Millisecond, true, 0, 0},
+		}},
+		{"refill never exceeds the burst", []step{
+			{0, true, 2, 0},
+			{time.Hour, true, 2, 0},
+			{0, true, 1, 0},
+		}},
+		{"denied requests don't spend tokens", []step{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, time.Second},
+			{0, false, 0, time.Second},
+			{time.Second, true, 0, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newTestStore()
+			for i, st := range tt.steps {
+				clock.advance(st.advance)
+				got, err := s.Take(context.Background(), "k", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Allowed != st.allowed || got.Remaining != st.remaining || got.RetryAfter != st.retryAfter {
+					t.Errorf("step %d: got allowed=%v remaining=%d retry_after=%v, want %v %d %v",
+						i, got.Allowed, got.Remaining, got.RetryAfter, st.allowed, st.remaining, st.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreEviction(t *testing.T) {
+	limit := RateLimit{Requests: 2, Per: time.Minute}
+	ctx := context.Background()
+
+	t.Run("least recently used bucket goes past the cap", func(t *testing.T) {
+		s, _ := newTestStore()
+		s.maxBuckets = 2
+		s.Take(ctx, "a", limit)
+		s.Take(ctx, "b", limit)
+		s.Take(ctx, "a", limit)
+		s.Take(ctx, "c", limit)
+		if len(s.buckets) != 2 || s.lru.Len() != 2 {
+			t.Fatalf("%d buckets, %d in the list; want 2", len(s.buckets), s.lru.Len())
+		}
+		if _, ok := s.buckets["b"]; ok {
+			t.Error("b, the least recently used, was kept")
+		}
+		if _, ok := s.buckets["a"]; !ok {
+			t.Error("a was evicted")
+		}
+	})
+
+	t.Run("idle buckets are swept", func(t *testing.T) {
+		s, clock := newTestStore()
+		s.Take(ctx, "idle", limit)
+		clock.advance(30 * time.Second)
+		s.Take(ctx, "busy", limit)
+		s.Take(ctx, "busy", limit)
+		// idle refilled 30s after its request; busy needs a full minute
+		clock.advance(45 * time.Second)
+		s.Take(ctx, "other", limit)
+		if _, ok := s.buckets["idle"]; ok {
+			t.Error("idle bucket was kept")
+		}
+		if _, ok := s.buckets["busy"]; !ok {
+			t.Error("busy bucket was swept before refilling")
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	limit := RateLimit{Name: "auth", Requests: 2, Per: 10 * time.Second}
+	s, clock := newTestStore()
+	h := ClientIP(nil)(RateLimiter(s, limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		remoteAddr string
+		status     int
+		remaining  string
+		retryAfter string
+	}{
+		{"first request", 0, "198.51.100.1:1000", http.StatusNoContent, "1", ""},
+		{"second request", 0, "198.51.100.1:1001", http.StatusNoContent, "0", ""},
+		{"over the quota", 0, "198.51.100.1:1002", http.StatusTooManyRequests, "0", "5"},
+		{"another client", 0, "198.51.100.2:1000", http.StatusNoContent, "1", ""},
+		{"after part of the wait", 3 * time.Second, "198.51.100.1:1003", http.StatusTooManyRequests, "0", "2"},
+		{"after the wait", 2 * time.Second, "198.51.100.1:1004", http.StatusNoContent, "0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.advance(tt.advance)
+			r := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if got := rec.Header().Get(RateLimitRemainingHeader); got != tt.remaining {
+				t.Errorf("%s = %q, want %q", RateLimitRemainingHeader, got, tt.remaining)
+			}
+			if got := rec.Header().Get(RateLimitLimitHeader); got != "2" {
+				t.Errorf("%s = %q, want 2", RateLimitLimitHeader, got)
+			}
+			if got := rec.Header().Get(RateLimitPolicyHeader); got != "2;w=10" {
+				t.Errorf("%s = %q, want 2;w=10", RateLimitPolicyHeader, got)
+			}
+		})
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	s, _ := newTestStore()
+	h := RateLimiter(s, RateLimit{Requests: 0, Per: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusNoContent || rec.Header().Get(RateLimitLimitHeader) != "" {
+			t.Fatalf("request %d limited: status %d", i, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Status de uma solicitação de afastamento.
+const (
+	AfastamentoPendente  = "pendente"
+	AfastamentoAprovado  = "aprovado"
+	AfastamentoRejeitado = "rejeitado"
+	AfastamentoCancelado = "cancelado"
+)
+
+// TipoAfastamento representa um tipo de ausência justificada (férias, atestado, folga...).
+type TipoAfastamento struct {
+	ID        int64  `json:"id"`
+	EmpresaID *int64 `json:"empresa_id,omitempty"`
+	Codigo    string `json:"codigo"`
+	Nome      string `json:"nome"`
+	// MinutosCreditados é o tempo abonado em cada dia do afastamento, limitado à jornada esperada.
+	MinutosCreditados int  `json:"minutos_creditados"`
+	ExigeAnexo        bool `json:"exige_anexo"`
+}
+
+// Afastamento representa uma solicitação de afastamento de um usuário.
+type Afastamento struct {
+	ID                int64              `json:"id"`
+	UserID            int64              `json:"user_id"`
+	TipoID            int64              `json:"tipo_id"`
+	Tipo              *TipoAfastamento   `json:"tipo,omitempty"`
+	DataInicio        string             `json:"data_inicio"`
+	DataFim           string             `json:"data_fim"`
+	Motivo            string             `json:"motivo"`
+	Status            string             `json:"status"`
+	DecididoPor       *int64             `json:"decidido_por,omitempty"`
+	DecididoEm        *time.Time         `json:"decidido_em,omitempty"`
+	ObservacaoDecisao string             `json:"observacao_decisao,omitempty"`
+	CriadoEm          time.Time          `json:"criado_em"`
+	Anexos            []AnexoAfastamento `json:"anexos"`
+}
+
+// AnexoAfastamento contém os metadados de um documento anexado a um afastamento.
+// O arquivo em si fica no armazenamento indicado pela URL.
+type AnexoAfastamento struct {
+	ID            int64     `json:"id"`
+	AfastamentoID int64     `json:"afastamento_id"`
+	NomeArquivo   string    `json:"nome_arquivo"`
+	TipoConteudo  string    `json:"tipo_conteudo"`
+	TamanhoBytes  int64     `json:"tamanho_bytes"`
+	SHA256        string    `json:"sha256"`
+	URL           string    `json:"url"`
+	CriadoEm      time.Time `json:"criado_em"`
+}
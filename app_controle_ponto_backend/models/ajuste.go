@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Status de uma solicitação de ajuste de ponto.
+const (
+	AjustePendente  = "pendente"
+	AjusteAprovado  = "aprovado"
+	AjusteRejeitado = "rejeitado"
+)
+
+// AjustePonto é o pedido de correção de um ponto, ou de inclusão de um ponto esquecido,
+// que só é aplicado quando o gestor o aprova.
+type AjustePonto struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// PontoID é o ponto corrigido; nulo quando o ajuste inclui um ponto que faltou.
+	PontoID *int64 `json:"ponto_id,omitempty"`
+	// Horario é o horário pedido para o ponto.
+	Horario           time.Time  `json:"horario"`
+	Motivo            string     `json:"motivo"`
+	Status            string     `json:"status"`
+	DecididoPor       *int64     `json:"decidido_por,omitempty"`
+	DecididoEm        *time.Time `json:"decidido_em,omitempty"`
+	ObservacaoDecisao string     `json:"observacao_decisao,omitempty"`
+	CriadoEm          time.Time  `json:"criado_em"`
+}
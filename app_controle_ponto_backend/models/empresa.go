@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Políticas aplicadas quando um ponto é registrado muito perto de outro.
+const (
+	PoliticaDuplicidadeRejeitar  = "rejeitar"
+	PoliticaDuplicidadeMesclar   = "mesclar"
+	PoliticaDuplicidadeSinalizar = "sinalizar"
+)
+
+// Empresa representa uma empresa e as regras de ponto configuradas para ela.
+type Empresa struct {
+	ID   int64  `json:"id"`
+	Nome string `json:"nome"`
+	// IntervaloMinimoSegundos é o intervalo mínimo entre dois pontos do mesmo usuário.
+	IntervaloMinimoSegundos int `json:"intervalo_minimo_segundos"`
+	// PoliticaDuplicidade define o que fazer com pontos mais próximos que o intervalo mínimo.
+	PoliticaDuplicidade string `json:"politica_duplicidade"`
+	// JornadaMaximaMinutos é a duração máxima esperada para a jornada de um dia.
+	JornadaMaximaMinutos int `json:"jornada_maxima_minutos"`
+	// InterjornadaMinimaMinutos é o descanso mínimo entre duas jornadas.
+	InterjornadaMinimaMinutos int `json:"interjornada_minima_minutos"`
+	// IntrajornadaLimiteMinutos é a jornada a partir da qual se exige IntrajornadaMinimaMinutos de intervalo.
+	IntrajornadaLimiteMinutos int `json:"intrajornada_limite_minutos"`
+	IntrajornadaMinimaMinutos int `json:"intrajornada_minima_minutos"`
+	// IntrajornadaCurtaLimiteMinutos é a jornada a partir da qual se exige IntrajornadaCurtaMinimaMinutos de intervalo.
+	IntrajornadaCurtaLimiteMinutos int `json:"intrajornada_curta_limite_minutos"`
+	IntrajornadaCurtaMinimaMinutos int `json:"intrajornada_curta_minima_minutos"`
+	// CargaHorariaDiariaMinutos é a jornada esperada de segunda a sexta-feira, base do saldo de horas.
+	CargaHorariaDiariaMinutos int `json:"carga_horaria_diaria_minutos"`
+	// ToleranciaAtrasoMinutos é o tempo após a entrada prevista a partir do qual o ponto conta como atraso.
+	ToleranciaAtrasoMinutos int `json:"tolerancia_atraso_minutos"`
+	// MFAObrigatoriaPapeis são os papéis que precisam de segundo fator (TOTP) para entrar.
+	MFAObrigatoriaPapeis []string `json:"mfa_obrigatoria_papeis"`
+	// FusoHorario é o fuso IANA (ex.: America/Sao_Paulo) em que os dias de trabalho são contados.
+	FusoHorario string `json:"fuso_horario"`
+}
+
+// FusoHorarioPadrao é o fuso das empresas que não configuraram outro.
+const FusoHorarioPadrao = "America/Sao_Paulo"
+
+// Fuso retorna o fuso horário da empresa, ou UTC se ele não puder ser carregado.
+func (e Empresa) Fuso() *time.Location {
+	fuso, err := time.LoadLocation(e.FusoHorario)
+	if err != nil {
+		return time.UTC
+	}
+	return fuso
+}
+
+// EmpresaPadrao contém as regras usadas para usuários sem empresa vinculada.
+var EmpresaPadrao = Empresa{
+	IntervaloMinimoSegundos: 60,
+	PoliticaDuplicidade:     PoliticaDuplicidadeRejeitar,
+	JornadaMaximaMinutos:    600,
+	// Limites dos artigos 66 e 71 da CLT
+	InterjornadaMinimaMinutos:      660,
+	IntrajornadaLimiteMinutos:      360,
+	IntrajornadaMinimaMinutos:      60,
+	IntrajornadaCurtaLimiteMinutos: 240,
+	IntrajornadaCurtaMinimaMinutos: 15,
+	CargaHorariaDiariaMinutos:      480,
+	ToleranciaAtrasoMinutos:        10,
+	MFAObrigatoriaPapeis:           []string{},
+	FusoHorario:                    FusoHorarioPadrao,
+}
@@ -7,4 +7,15 @@ type Ponto struct {
 	ID      string    `json:"id"`
 	UserID  int64     `json:"user_id"`
 	Horario time.Time `json:"horario"`
+	// ClientID é o UUID gerado pelo aplicativo para pontos capturados offline.
+	ClientID *string `json:"client_id,omitempty"`
+	// HorarioDispositivo é o horário informado pelo relógio do aparelho.
+	HorarioDispositivo *time.Time `json:"horario_dispositivo,omitempty"`
+	// RecebidoEm é o horário em que o servidor recebeu o registro.
+	RecebidoEm *time.Time `json:"recebido_em,omitempty"`
+	// DerivaSegundos é a diferença estimada entre o relógio do aparelho e o do servidor.
+	DerivaSegundos *int64 `json:"deriva_segundos,omitempty"`
+	DerivaSuspeita bool   `json:"deriva_suspeita,omitempty"`
+	// DuplicidadeSuspeita indica que o ponto foi registrado muito perto de outro.
+	DuplicidadeSuspeita bool `json:"duplicidade_suspeita,omitempty"`
 }
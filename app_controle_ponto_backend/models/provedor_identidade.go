@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// ProvedorIdentidade é o provedor OpenID Connect em que os funcionários da empresa fazem login.
+type ProvedorIdentidade struct {
+	ID        int64  `json:"id"`
+	EmpresaID int64  `json:"empresa_id"`
+	Issuer    string `json:"issuer"`
+	ClientID  string `json:"client_id"`
+	// Dominios são os domínios de email atendidos pelo provedor. Só usuários com email
+	// nesses domínios são aceitos, e o login é direcionado ao provedor pelo domínio, depois
+	// que a empresa comprovar a posse dele (veja Verificacoes).
+	Dominios []string `json:"dominios"`
+	// Verificacoes traz, para cada domínio, o registro DNS TXT que comprova a posse.
+	Verificacoes []DominioVerificacao `json:"verificacoes"`
+	Ativo        bool                 `json:"ativo"`
+	// PossuiClientSecret indica se há um client secret gravado; ele nunca é devolvido.
+	PossuiClientSecret bool      `json:"possui_client_secret"`
+	CriadoEm           time.Time `json:"criado_em"`
+	AtualizadoEm       time.Time `json:"atualizado_em"`
+}
+
+// DominioVerificacao é o desafio DNS de um domínio do provedor: a empresa publica um
+// registro TXT Nome com o Valor e pede a verificação.
+type DominioVerificacao struct {
+	Dominio      string     `json:"dominio" example:"empresa.com.br"`
+	Nome         string     `json:"nome" example:"_controle-ponto.empresa.com.br"`
+	Valor        string     `json:"valor" example:"controle-ponto-verificacao=3q2-7wEjPk..."`
+	VerificadoEm *time.Time `json:"verificado_em,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Tipos de relatório que podem ser exportados.
+const (
+	RelatorioBancoHoras      = "banco_horas"
+	RelatorioInconsistencias = "inconsistencias"
+)
+
+// Status de uma exportação de relatório.
+const (
+	ExportacaoPendente  = "pendente"
+	ExportacaoConcluida = "concluida"
+	ExportacaoFalhou    = "falhou"
+)
+
+// ExportacaoRelatorio é um relatório gerado em segundo plano. O arquivo CSV pode ser
+// baixado quando o status é concluida.
+type ExportacaoRelatorio struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Tipo   string `json:"tipo"`
+	Inicio string `json:"inicio"`
+	Fim    string `json:"fim"`
+	// UsuarioIDs são os usuários incluídos no relatório, resolvidos no pedido.
+	UsuarioIDs  []int64    `json:"usuario_ids"`
+	Status      string     `json:"status"`
+	CriadoEm    time.Time  `json:"criado_em"`
+	ConcluidoEm *time.Time `json:"concluido_em,omitempty"`
+}
@@ -1,8 +1,20 @@
 package models
 
+// Papéis de usuário.
+const (
+	PapelFuncionario = "funcionario"
+	PapelGestor      = "gestor"
+	PapelAdmin       = "admin"
+)
+
 type User struct {
-	ID       int64  `json:"id"`
-	Nome     string `json:"nome"`
-	Email    string `json:"email"`
-	Password string `json:"password,omitempty"` // omitempty so it's not sent in responses
+	ID        int64  `json:"id"`
+	Nome      string `json:"nome"`
+	Email     string `json:"email"`
+	Password  string `json:"password,omitempty"` // omitempty so it's not sent in responses
+	Papel     string `json:"papel,omitempty"`
+	EmpresaID *int64 `json:"empresa_id,omitempty"`
+	GestorID  *int64 `json:"gestor_id,omitempty"`
+	// Idioma é o idioma dos emails do usuário (pt-BR, en ou es).
+	Idioma string `json:"idioma,omitempty"`
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Status de uma entrega de webhook.
+const (
+	EntregaPendente = "pendente"
+	EntregaEntregue = "entregue"
+	// EntregaFalhou indica que as tentativas se esgotaram (dead letter).
+	EntregaFalhou = "falhou"
+)
+
+// Webhook representa um endpoint externo que recebe os eventos da empresa.
+type Webhook struct {
+	ID        int64    `json:"id"`
+	EmpresaID int64    `json:"empresa_id"`
+	URL       string   `json:"url"`
+	Eventos   []string `json:"eventos"`
+	Ativo     bool     `json:"ativo"`
+	// Segredo assina as entregas e só é devolvido na criação do webhook.
+	Segredo  string    `json:"segredo,omitempty"`
+	CriadoEm time.Time `json:"criado_em"`
+}
+
+// EntregaWebhook é o registro de entrega de um evento a um webhook.
+type EntregaWebhook struct {
+	ID                 int64           `json:"id"`
+	WebhookID          int64           `json:"webhook_id"`
+	EventoID           string          `json:"evento_id"`
+	Tipo               string          `json:"tipo"`
+	Payload            json.RawMessage `json:"payload" swaggertype:"object"`
+	Status             string          `json:"status"`
+	Tentativas         int             `json:"tentativas"`
+	ProximaTentativaEm *time.Time      `json:"proxima_tentativa_em,omitempty"`
+	UltimaTentativaEm  *time.Time      `json:"ultima_tentativa_em,omitempty"`
+	UltimoStatusHTTP   *int            `json:"ultimo_status_http,omitempty"`
+	UltimoErro         string          `json:"ultimo_erro,omitempty"`
+	CriadoEm           time.Time       `json:"criado_em"`
+	EntregueEm         *time.Time      `json:"entregue_em,omitempty"`
+}
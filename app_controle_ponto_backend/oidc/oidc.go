@@ -0,0 +1,338 @@
+// Package oidc is a minimal OpenID Connect relying party: provider discovery, the
+// authorization code flow with PKCE and ID token validation against the provider's JWKS.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"controle-ponto-api/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryTTL is how long a discovery document and its JWKS are reused.
+const discoveryTTL = time.Hour
+
+// jwksRefreshInterval limits how often an unknown kid makes the JWKS be fetched again.
+const jwksRefreshInterval = time.Minute
+
+// maxResponseSize bounds what is read from the provider.
+const maxResponseSize = 1 << 20
+
+// ErrInvalidIDToken is returned, wrapped, for every ID token that fails validation.
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// Provider identifies a client registration at an identity provider.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+}
+
+// Discovery is the part of the provider's /.well-known/openid-configuration that is used.
+type Discovery struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// IDClaims are the ID token claims used to identify the user.
+type IDClaims struct {
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+	AZP           string `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// Client talks to identity providers, caching their discovery documents and keys.
+type Client struct {
+	HTTP *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*providerCache
+}
+
+type providerCache struct {
+	discovery   *Discovery
+	fetchedAt   time.Time
+	keys        map[string]interface{}
+	keysFetched time.Time
+}
+
+// NewClient creates a Client with a bounded HTTP timeout.
+func NewClient() *Client {
+	return &Client{
+		HTTP:      &http.Client{Timeout: 10 * time.Second},
+		providers: make(map[string]*providerCache),
+	}
+}
+
+// Discover returns the provider's discovery document, fetching it when not cached.
+func (c *Client) Discover(ctx context.Context, issuer string) (*Discovery, error) {
+	c.mu.Lock()
+	cached := c.providers[issuer]
+	c.mu.Unlock()
+	if cached != nil && time.Since(cached.fetchedAt) < discoveryTTL {
+		return cached.discovery, nil
+	}
+
+	var d Discovery
+	if err := c.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("error fetching discovery document: %w", err)
+	}
+	// OpenID Connect Discovery 1.0, section 4.3
+	if d.Issuer != issuer {
+		return nil, fmt.Errorf("discovery document issuer %q does not match %q", d.Issuer, issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing required endpoints")
+	}
+	if len(d.CodeChallengeMethodsSupported) > 0 && !contains(d.CodeChallengeMethodsSupported, "S256") {
+		return nil, errors.New("provider does not support PKCE with S256")
+	}
+
+	c.mu.Lock()
+	c.providers[issuer] = &providerCache{discovery: &d, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return &d, nil
+}
+
+// ValidateIssuer requires an absolute https URL. Plain http is accepted only for loopback
+// hosts, so a local mock provider can be used in development.
+func ValidateIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("issuer must be an absolute URL without query or fragment")
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return nil
+		}
+	}
+	return errors.New("issuer must use https")
+}
+
+// NewPKCE returns a code verifier and its S256 challenge (RFC 7636).
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns n random bytes, base64url encoded, for state, nonce and verifiers.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthCodeURL builds the authorization request the user's browser is redirected to.
+func AuthCodeURL(d *Discovery, p Provider, redirectURI, state, nonce, challenge string) string {
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"openid email profile"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems the authorization code and returns the validated ID token claims.
+func (c *Client) Exchange(ctx context.Context, p Provider, redirectURI, code, verifier, nonce string) (*IDClaims, error) {
+	d, err := c.Discover(ctx, p.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret == "" {
+		// Public client
+		form.Set("client_id", p.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		// client_secret_basic; RFC 6749 section 2.3.1 requires form-encoding both parts
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("token endpoint returned status %d and an invalid body", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned status %d: %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return c.VerifyIDToken(ctx, p, token.IDToken, nonce)
+}
+
+// VerifyIDToken checks the ID token signature against the provider's JWKS and its iss,
+// aud, azp, exp, iat and nonce claims.
+func (c *Client) VerifyIDToken(ctx context.Context, p Provider, raw, nonce string) (*IDClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	claims := &IDClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return c.key(ctx, p.Issuer, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AZP != p.ClientID {
+		return nil, fmt.Errorf("%w: azp does not match the client", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// key returns the provider key with the given kid, refetching the JWKS when the kid is
+// unknown because the provider may have rotated its keys.
+func (c *Client) key(ctx context.Context, issuer, kid string) (interface{}, error) {
+	d, err := c.Discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	cached := c.providers[issuer]
+	keys, fetched := cached.keys, cached.keysFetched
+	c.mu.Unlock()
+
+	if k, ok := lookupKey(keys, kid); ok {
+		return k, nil
+	}
+	if time.Since(fetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	var set auth.JWKS
+	if err := c.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("error fetching provider keys: %w", err)
+	}
+	keys = make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if pub, err := jwk.PublicKey(); err == nil {
+			keys[jwk.Kid] = pub
+		}
+	}
+
+	c.mu.Lock()
+	cached.keys, cached.keysFetched = keys, time.Now()
+	c.mu.Unlock()
+
+	if k, ok := lookupKey(keys, kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// lookupKey finds the key by kid. Providers with a single key may omit the kid.
+func lookupKey(keys map[string]interface{}, kid string) (interface{}, bool) {
+	if k, ok := keys[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", endpoint, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+func contains(values []string, v string) bool {
+	for _, item := range values {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
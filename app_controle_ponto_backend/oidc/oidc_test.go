@@ -0,0 +1,120 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"controle-ponto-api/oidc"
+	"controle-ponto-api/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURI = "https://app.example/api/auth/oidc/callback"
+
+// login runs the authorization step against p and returns the code with the verifier and
+// nonce the relying party kept for it.
+func login(t *testing.T, c *oidc.Client, p *oidctest.Provider) (code, verifier, nonce string) {
+	t.Helper()
+	d, err := c.Discover(context.Background(), p.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := oidc.RandomString(32)
+	nonce, _ = oidc.RandomString(32)
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, gotState := p.Authorize(oidc.AuthCodeURL(d, p.Config(), redirectURI, state, nonce, challenge))
+	if gotState != state {
+		t.Fatalf("state = %q, want %q", gotState, state)
+	}
+	return code, verifier, nonce
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name string
+		// verifier and nonce replace the ones of the login when set
+		verifier, nonce string
+		claims          func(*oidc.IDClaims)
+		wantIDTokenErr  bool
+		wantErr         bool
+	}{
+		{name: "valid login"},
+		{name: "wrong PKCE verifier", verifier: "not-the-verifier", wantErr: true},
+		{name: "nonce of another login", nonce: "other-nonce", wantIDTokenErr: true},
+		{name: "token without nonce", claims: func(c *oidc.IDClaims) { c.Nonce = "" }, wantIDTokenErr: true},
+		{name: "token for another client", claims: func(c *oidc.IDClaims) { c.Audience = jwt.ClaimStrings{"other"} }, wantIDTokenErr: true},
+		{name: "token from another issuer", claims: func(c *oidc.IDClaims) { c.Issuer = "https://evil.example" }, wantIDTokenErr: true},
+		{name: "expired token", claims: func(c *oidc.IDClaims) {
+			c.IssuedAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Minute))
+		}, wantIDTokenErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := oidctest.NewProvider(t)
+			p.Claims = tt.claims
+			c := oidc.NewClient()
+
+			code, verifier, nonce := login(t, c, p)
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			claims, err := c.Exchange(context.Background(), p.Config(), redirectURI, code, verifier, nonce)
+			switch {
+			case tt.wantIDTokenErr:
+				if !errors.Is(err, oidc.ErrInvalidIDToken) {
+					t.Fatalf("Exchange error = %v, want ErrInvalidIDToken", err)
+				}
+			case tt.wantErr:
+				if err == nil {
+					t.Fatal("Exchange succeeded")
+				}
+			case err != nil:
+				t.Fatalf("Exchange: %v", err)
+			case claims.Email != p.Email || claims.EmailVerified == nil || !*claims.EmailVerified:
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestExchangeSingleUseCode(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	c := oidc.NewClient()
+	code, verifier, nonce := login(t, c, p)
+
+	if _, err := c.Exchange(context.Background(), p.Config(), redirectURI, code, verifier, nonce); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Exchange(context.Background(), p.Config(), redirectURI, code, verifier, nonce); err == nil {
+		t.Error("code redeemed twice")
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidc.Discovery{
+			Issuer:                "https://other.example",
+			AuthorizationEndpoint: "https://other.example/authorize",
+			TokenEndpoint:         "https://other.example/token",
+			JWKSURI:               "https://other.example/jwks",
+		})
+	}))
+	defer srv.Close()
+
+	if _, err := oidc.NewClient().Discover(context.Background(), srv.URL); err == nil {
+		t.Error("discovery document of another issuer accepted")
+	}
+}
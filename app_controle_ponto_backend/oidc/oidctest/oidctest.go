@@ -0,0 +1,166 @@
+// Package oidctest provides an in-process OpenID Connect provider for tests: discovery,
+// token and JWKS endpoints, with the authorization step played by the test itself.
+package oidctest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"controle-ponto-api/auth"
+	"controle-ponto-api/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Provider is a running identity provider. Its token endpoint only redeems codes returned
+// by Authorize, once, with the verifier of their PKCE challenge and the same redirect URI.
+type Provider struct {
+	*httptest.Server
+	ClientID string
+	// Email, Name and EmailVerified go into the ID tokens.
+	Email         string
+	Name          string
+	EmailVerified bool
+	// Claims, when set, changes the claims of the next ID tokens before they are signed.
+	Claims func(*oidc.IDClaims)
+
+	t   testing.TB
+	key ed25519.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+type authorization struct {
+	challenge, nonce, redirectURI string
+}
+
+// NewProvider starts a provider that is closed when the test ends.
+func NewProvider(t testing.TB) *Provider {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{
+		ClientID:      "controle-ponto",
+		Email:         "ana@empresa.com.br",
+		Name:          "Ana",
+		EmailVerified: true,
+		t:             t,
+		key:           priv,
+		kid:           "k1",
+		codes:         map[string]authorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Config returns the client registration of the relying party at the provider.
+func (p *Provider) Config() oidc.Provider {
+	return oidc.Provider{Issuer: p.URL, ClientID: p.ClientID}
+}
+
+// Authorize plays the user consenting at the provider: it checks the authorization request
+// and returns the code the browser brings back to the callback, with the request's state.
+func (p *Provider) Authorize(authURL string) (code, state string) {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("client_id") != p.ClientID || q.Get("response_type") != "code" {
+		p.t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		p.t.Fatalf("authorization request without S256 PKCE: %s", authURL)
+	}
+	code, err = oidc.RandomString(16)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.codes[code] = authorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri")}
+	p.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(oidc.Discovery{
+		Issuer:                        p.URL,
+		AuthorizationEndpoint:         p.URL + "/authorize",
+		TokenEndpoint:                 p.URL + "/token",
+		JWKSURI:                       p.URL + "/jwks",
+		CodeChallengeMethodsSupported: []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	key, err := auth.NewEd25519Key(p.kid, nil, p.key.Public().(ed25519.PublicKey))
+	if err != nil {
+		p.t.Error(err)
+	}
+	jwk, _ := key.JWK()
+	json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{jwk}})
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		p.t.Error(err)
+	}
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	a, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != a.challenge || r.PostForm.Get("redirect_uri") != a.redirectURI {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	verified := p.EmailVerified
+	now := time.Now()
+	claims := &oidc.IDClaims{
+		Email:         p.Email,
+		EmailVerified: &verified,
+		Name:          p.Name,
+		Nonce:         a.nonce,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.URL,
+			Subject:   p.Email,
+			Audience:  jwt.ClaimStrings{p.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+		},
+	}
+	if p.Claims != nil {
+		p.Claims(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = p.kid
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
@@ -0,0 +1,99 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// prefixLength is the length of the SHA-1 hash prefix in the k-anonymity range format.
+const prefixLength = 5
+
+// BreachedList is a local copy of breached password hashes in the format of the Have I Been
+// Pwned range API, so passwords never leave the server. It is either:
+//
+//   - a directory with one file per 5-character hash prefix, named PREFIX.txt and holding
+//     "SUFFIX:COUNT" lines, as written by the official downloader. Files are read on demand
+//     and lines with a count of 0 (padding) are ignored.
+//   - a single file with one "HASH" or "HASH:COUNT" line per password, loaded into memory.
+//
+// Hashes are uppercase hex SHA-1.
+type BreachedList struct {
+	dir    string
+	hashes map[string]struct{}
+}
+
+// LoadBreached opens the list at path, which may be a directory or a file.
+func LoadBreached(path string) (*BreachedList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &BreachedList{dir: path}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	hashes := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		hash, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" || strings.HasPrefix(hash, "#") || isPadding(count) {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: expected a SHA-1 hash", path, line)
+		}
+		hashes[strings.ToUpper(hash)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &BreachedList{hashes: hashes}, nil
+}
+
+// Contains reports whether the password is in the list.
+func (l *BreachedList) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if l.hashes != nil {
+		_, ok := l.hashes[hash]
+		return ok, nil
+	}
+
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+	f, err := os.Open(filepath.Join(l.dir, prefix+".txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(s, suffix) && !isPadding(count) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// isPadding reports whether count marks a padding line. Range responses fetched with the
+// Add-Padding header carry random suffixes with a count of 0, which were never breached.
+func isPadding(count string) bool {
+	return strings.TrimSpace(count) == "0"
+}
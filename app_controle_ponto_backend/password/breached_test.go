@@ -0,0 +1,92 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hashSHA1 devolve o SHA-1 da senha em hexadecimal maiúsculo, como na lista.
+func hashSHA1(senha string) string {
+	sum := sha1.Sum([]byte(senha))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// listaEmDiretorio grava arquivos PREFIXO.txt como os do downloader oficial, com o conteúdo
+// das respostas da API de faixas: linhas "SUFIXO:CONTAGEM" separadas por CRLF.
+func listaEmDiretorio(t *testing.T, faixas map[string][]string) *BreachedList {
+	t.Helper()
+	dir := t.TempDir()
+	for prefixo, linhas := range faixas {
+		if err := os.WriteFile(filepath.Join(dir, prefixo+".txt"), []byte(strings.Join(linhas, "\r\n")), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := LoadBreached(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestBreachedListDirectory(t *testing.T) {
+	vazada := hashSHA1("senha-vazada")
+	minuscula := hashSHA1("outra-vazada")
+	preenchimento := hashSHA1("so-preenchimento")
+	list := listaEmDiretorio(t, map[string][]string{
+		vazada[:5]:        {"0018A45C4D1DEF81644B54AB7F969B88D65:3", vazada[5:] + ":412", "00D4F6E8FA6EECAD2A3AA415EEC418D38EC:2"},
+		minuscula[:5]:     {strings.ToLower(minuscula[5:]) + ":7"},
+		preenchimento[:5]: {preenchimento[5:] + ":0"},
+	})
+
+	tests := []struct {
+		name     string
+		password string
+		found    bool
+	}{
+		{"suffix in its prefix file", "senha-vazada", true},
+		{"lower case suffix", "outra-vazada", true},
+		// Padding lines are random suffixes that were never breached
+		{"padding line", "so-preenchimento", false},
+		{"no file for the prefix", "nunca-vazou", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := list.Contains(tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != tt.found {
+				t.Errorf("Contains(%q) = %v, want %v", tt.password, found, tt.found)
+			}
+		})
+	}
+}
+
+func TestBreachedListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vazadas.txt")
+	conteudo := "# hashes de teste\n" + hashSHA1("senha-vazada") + ":412\n" + strings.ToLower(hashSHA1("outra-vazada")) + "\n"
+	if err := os.WriteFile(path, []byte(conteudo), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := LoadBreached(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for password, want := range map[string]bool{"senha-vazada": true, "outra-vazada": true, "nunca-vazou": false} {
+		if found, err := list.Contains(password); err != nil || found != want {
+			t.Errorf("Contains(%q) = %v, %v; want %v", password, found, err, want)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("5BAA6:12\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBreached(path); err == nil {
+		t.Error("LoadBreached() accepted a line that is not a SHA-1 hash")
+	}
+}
@@ -0,0 +1,100 @@
+// Package password decides whether a new password is acceptable: minimum length, an
+// estimated strength score, the user's own details and a list of breached passwords.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBytes is the longest password bcrypt can hash.
+const MaxBytes = 72
+
+// Policy holds the rules a new password must follow.
+type Policy struct {
+	MinLength int
+	// MinScore is the minimum Strength score, from 0 (too guessable) to 4 (very unguessable).
+	MinScore int
+	// Breached, when set, rejects passwords found in known breaches.
+	Breached *BreachedList
+}
+
+// ErrWeak is returned, wrapped in a *RejectedError, for every rejected password.
+var ErrWeak = errors.New("password rejected")
+
+// RejectedError says why a password was rejected. Reason is an English format for Args,
+// worded to follow "password" ("must have at least %d characters"), so it can be shown to
+// the user as is or translated.
+type RejectedError struct {
+	Reason string
+	Args   []interface{}
+}
+
+func rejected(reason string, args ...interface{}) error {
+	return &RejectedError{Reason: reason, Args: args}
+}
+
+func (e *RejectedError) Error() string {
+	return ErrWeak.Error() + ": password " + fmt.Sprintf(e.Reason, e.Args...)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return ErrWeak
+}
+
+// Check returns nil if the password follows the policy. userInputs are details of the user,
+// such as name and email, which must not make up the password.
+func (p *Policy) Check(password string, userInputs ...string) error {
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		return rejected("must have at least %d characters", p.MinLength)
+	}
+	if len(password) > MaxBytes {
+		return rejected("must have at most %d bytes", MaxBytes)
+	}
+
+	inputs := expandInputs(userInputs)
+	lower := strings.ToLower(password)
+	for _, in := range inputs {
+		if len(in) >= 4 && strings.Contains(lower, in) {
+			return rejected("must not contain your name or email")
+		}
+	}
+	if score := Strength(password, inputs...); score < p.MinScore {
+		return rejected("is too easy to guess (strength %d of 4, minimum %d)", score, p.MinScore)
+	}
+
+	if p.Breached != nil {
+		found, err := p.Breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("error checking breached passwords: %w", err)
+		}
+		if found {
+			return rejected("appeared in a data breach; choose another one")
+		}
+	}
+	return nil
+}
+
+// expandInputs splits names and email local parts into the lowercase words a user would
+// reuse. Email domains are left out; they are shared by the whole empresa.
+func expandInputs(userInputs []string) []string {
+	var out []string
+	for _, in := range userInputs {
+		in = strings.ToLower(strings.TrimSpace(in))
+		if at := strings.IndexByte(in, '@'); at >= 0 {
+			in = in[:at]
+		}
+		if in == "" {
+			continue
+		}
+		out = append(out, in)
+		for _, f := range strings.FieldsFunc(in, func(r rune) bool { return strings.ContainsRune(" .-_+", r) }) {
+			if f != in {
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}
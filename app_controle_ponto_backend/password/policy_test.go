@@ -0,0 +1,48 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	policy := &Policy{
+		MinLength: 8,
+		MinScore:  3,
+		Breached:  listaEmDiretorio(t, map[string][]string{hashSHA1("uma senha bem longa")[:5]: {hashSHA1("uma senha bem longa")[5:] + ":9"}}),
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		// reason is the RejectedError.Reason, empty when the password is accepted
+		reason string
+	}{
+		{name: "accepted", password: "k8#Lm2!qZp"},
+		{name: "too short", password: "k8#Lm2", reason: "must have at least %d characters"},
+		{name: "longer than bcrypt hashes", password: strings.Repeat("k8#Lm2!qZp", 8), reason: "must have at most %d bytes"},
+		{name: "contains the name", password: "Silva#k8Lm2", reason: "must not contain your name or email"},
+		{name: "contains the email local part", password: "k8#ana.silva", reason: "must not contain your name or email"},
+		{name: "too easy to guess", password: "Flamengo2024", reason: "is too easy to guess (strength %d of 4, minimum %d)"},
+		{name: "breached", password: "uma senha bem longa", reason: "appeared in a data breach; choose another one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password, "Ana Silva", "ana.silva@empresa.com.br")
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Check() error = %v", err)
+				}
+				return
+			}
+			var recusa *RejectedError
+			if !errors.As(err, &recusa) || !errors.Is(err, ErrWeak) {
+				t.Fatalf("Check() error = %v, want a RejectedError", err)
+			}
+			if recusa.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", recusa.Reason, tt.reason)
+			}
+		})
+	}
+}
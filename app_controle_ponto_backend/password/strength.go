@@ -0,0 +1,165 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonWords are frequent password words in rough order of popularity, in English and
+// Portuguese. A word's guess count is its position in the list.
+var commonWords = []string{
+	"senha", "password", "qwerty", "brasil", "admin", "iloveyou", "teamo", "mudar", "abc", "amor",
+	"dragon", "monkey", "letmein", "welcome", "master", "football", "futebol", "flamengo",
+	"corinthians", "palmeiras", "santos", "gremio", "vasco", "cruzeiro", "sunshine", "princess",
+	"shadow", "superman", "batman", "jesus", "deus", "cristo", "familia", "trustno1", "login",
+	"teste", "test", "usuario", "user", "root", "changeme", "trocar", "segredo", "secret",
+	"ponto", "empresa", "trabalho", "gatinho", "gatinha", "princesa", "estrela", "felicidade",
+	"saudade", "baseball", "hello", "freedom", "whatever", "charlie", "michael", "daniel",
+	"gabriel", "lucas", "pedro", "maria", "ana", "joao", "jose", "carlos", "paulo", "marcos",
+	"rafael", "bruno", "juliana", "fernanda", "amanda", "camila", "beatriz", "mariana",
+	"janeiro", "fevereiro", "marco", "abril", "maio", "junho", "julho", "agosto", "setembro",
+	"outubro", "novembro", "dezembro", "verao", "inverno",
+}
+
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890", "qazwsxedc"}
+
+var leet = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// Strength estimates how hard the password is to guess, from 0 (too guessable) to 4 (very
+// unguessable), in the spirit of zxcvbn: the password is covered by the cheapest sequence of
+// patterns (dictionary words, userInputs, repeats, sequences, keyboard runs, years and
+// brute-forced characters) and the score follows the estimated number of guesses.
+func Strength(password string, userInputs ...string) int {
+	guesses := log10Guesses(password, userInputs)
+	switch {
+	case guesses < 3:
+		return 0
+	case guesses < 6:
+		return 1
+	case guesses < 8:
+		return 2
+	case guesses < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// log10Guesses returns log10 of the estimated guesses, computed by dynamic programming over
+// the patterns that end at each position.
+func log10Guesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+	unleet := []rune(leet.Replace(string(lower)))
+	n := len(runes)
+	if len(unleet) != n {
+		unleet = lower
+	}
+
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = math.Inf(1)
+	}
+	relax := func(start, end int, log10 float64) {
+		if v := best[start] + log10; v < best[end] {
+			best[end] = v
+		}
+	}
+
+	for end := 1; end <= n; end++ {
+		// Brute force: one character of its class
+		relax(end-1, end, math.Log10(cardinality(runes[end-1])))
+
+		for start := 0; start <= end-3; start++ {
+			l := end - start
+			word := string(lower[start:end])
+			variations := 1.0
+			if string(runes[start:end]) != word {
+				variations *= 2
+			}
+
+			for rank, w := range commonWords {
+				if w == word {
+					relax(start, end, math.Log10(float64(rank+1)*variations))
+				} else if w == string(unleet[start:end]) {
+					relax(start, end, math.Log10(float64(rank+1)*variations*2))
+				}
+			}
+			for _, in := range userInputs {
+				if in == word || in == string(unleet[start:end]) {
+					relax(start, end, math.Log10(2*variations))
+				}
+			}
+			if isRepeat(lower[start:end]) {
+				relax(start, end, math.Log10(cardinality(runes[start])*float64(l)))
+			}
+			if isSequence(lower[start:end]) {
+				relax(start, end, math.Log10(26*float64(l)))
+			}
+			if l >= 4 && isKeyboardRun(word) {
+				relax(start, end, math.Log10(50*float64(l)))
+			}
+			if l == 4 && isYear(word) {
+				relax(start, end, math.Log10(120))
+			}
+		}
+	}
+	return best[n]
+}
+
+func cardinality(r rune) float64 {
+	switch {
+	case r >= '0' && r <= '9':
+		return 10
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		return 26
+	case r < unicode.MaxASCII:
+		return 33
+	default:
+		return 100
+	}
+}
+
+func isRepeat(s []rune) bool {
+	for _, r := range s[1:] {
+		if r != s[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func isSequence(s []rune) bool {
+	delta := s[1] - s[0]
+	if delta != 1 && delta != -1 {
+		return false
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i]-s[i-1] != delta {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyboardRun(s string) bool {
+	for _, row := range keyboardRows {
+		if strings.Contains(row, s) || strings.Contains(reverse(row), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func isYear(s string) bool {
+	return (strings.HasPrefix(s, "19") || strings.HasPrefix(s, "20")) && strings.Trim(s, "0123456789") == ""
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
@@ -0,0 +1,37 @@
+package password
+
+import "testing"
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		password   string
+		userInputs []string
+		score      int
+	}{
+		{password: "senha", score: 0},
+		{password: "s3nh4", score: 0},
+		{password: "senha123", score: 0},
+		{password: "12345678", score: 0},
+		{password: "aaaaaaaaaa", score: 0},
+		{password: "abcdefghij", score: 0},
+		{password: "qwertyuiop", score: 0},
+		{password: "Fl4meng0", score: 0},
+		{password: "Flamengo2024", score: 1},
+		{password: "maria1990", score: 1},
+		{password: "zxcvbnm123", score: 1},
+		{password: "anasilva", score: 3},
+		// The same password is trivial for the user it describes
+		{password: "anasilva", userInputs: []string{"ana", "silva"}, score: 0},
+		{password: "Ana.Silva", userInputs: []string{"ana", "silva"}, score: 0},
+		{password: "k8#Lm2!qZp", score: 4},
+		{password: "uma senha bem longa", score: 4},
+		{password: "correct horse battery staple", score: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := Strength(tt.password, tt.userInputs...); got != tt.score {
+				t.Errorf("Strength(%q, %q) = %d, want %d", tt.password, tt.userInputs, got, tt.score)
+			}
+		})
+	}
+}
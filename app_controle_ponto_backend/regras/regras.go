@@ -0,0 +1,238 @@
+// Package regras avalia as jornadas de cada dia contra as regras da CLT (interjornada e
+// intrajornada) ou as variações configuradas pela empresa.
+package regras
+
+import (
+	"controle-ponto-api/jornada"
+	"controle-ponto-api/models"
+	"fmt"
+	"time"
+)
+
+// Tipos de violação registrados pelo motor de regras.
+const (
+	ViolacaoInterjornada = "interjornada"
+	ViolacaoIntrajornada = "intrajornada"
+)
+
+// Config define os limites usados pelas regras.
+type Config struct {
+	// InterjornadaMinima é o descanso mínimo entre o fim de uma jornada e o início da seguinte.
+	InterjornadaMinima time.Duration
+	// IntrajornadaLimite é a duração de jornada a partir da qual é exigido IntrajornadaMinima de intervalo.
+	IntrajornadaLimite time.Duration
+	IntrajornadaMinima time.Duration
+	// IntrajornadaCurtaLimite é a duração de jornada a partir da qual é exigido IntrajornadaCurtaMinima de intervalo.
+	IntrajornadaCurtaLimite time.Duration
+	IntrajornadaCurtaMinima time.Duration
+	// IntrajornadaMaxima é o intervalo mais longo dentro de uma jornada; uma pausa maior
+	// depois de uma saída encerra a jornada.
+	IntrajornadaMaxima time.Duration
+}
+
+// ConfigCLT contém os limites dos artigos 66 e 71 da CLT.
+var ConfigCLT = Config{
+	InterjornadaMinima:      11 * time.Hour,
+	IntrajornadaLimite:      6 * time.Hour,
+	IntrajornadaMinima:      time.Hour,
+	IntrajornadaCurtaLimite: 4 * time.Hour,
+	IntrajornadaCurtaMinima: 15 * time.Minute,
+	IntrajornadaMaxima:      2 * time.Hour,
+}
+
+// ConfigDaEmpresa converte a configuração da empresa em Config.
+func ConfigDaEmpresa(e models.Empresa) Config {
+	return Config{
+		InterjornadaMinima:      time.Duration(e.InterjornadaMinimaMinutos) * time.Minute,
+		IntrajornadaLimite:      time.Duration(e.IntrajornadaLimiteMinutos) * time.Minute,
+		IntrajornadaMinima:      time.Duration(e.IntrajornadaMinimaMinutos) * time.Minute,
+		IntrajornadaCurtaLimite: time.Duration(e.IntrajornadaCurtaLimiteMinutos) * time.Minute,
+		IntrajornadaCurtaMinima: time.Duration(e.IntrajornadaCurtaMinimaMinutos) * time.Minute,
+		IntrajornadaMaxima:      ConfigCLT.IntrajornadaMaxima,
+	}
+}
+
+// Violacao é o descumprimento de uma regra em um dia.
+type Violacao struct {
+	ID             int64     `json:"id,omitempty"`
+	UserID         int64     `json:"user_id"`
+	Data           string    `json:"data"`
+	Tipo           string    `json:"tipo"`
+	Descricao      string    `json:"descricao"`
+	ApuradoMinutos int       `json:"apurado_minutos"`
+	ExigidoMinutos int       `json:"exigido_minutos"`
+	DetectadaEm    time.Time `json:"detectada_em,omitempty"`
+}
+
+// Dia reúne os dados necessários para avaliar um dia de um usuário.
+type Dia struct {
+	UserID int64
+	Data   string
+	// Fuso é o fuso em que Data é contada.
+	Fuso *time.Location
+	// Pontos são os pontos em torno do dia, ordenados por horário. Precisam cobrir a jornada
+	// anterior à primeira do dia e o fim das jornadas iniciadas nele, que pode passar da
+	// meia-noite.
+	Pontos []models.Ponto
+}
+
+// Jornadas são as jornadas de um dia avaliadas pelas regras.
+type Jornadas struct {
+	UserID int64
+	Data   string
+	// DoDia são as jornadas iniciadas no dia, em ordem, cada uma com os seus pontos.
+	DoDia [][]models.Ponto
+	// Anterior é a jornada que precede a primeira do dia, ou nil se não houver.
+	Anterior []models.Ponto
+}
+
+// Regra é uma verificação aplicada às jornadas de um dia.
+type Regra interface {
+	Avaliar(j Jornadas) []Violacao
+}
+
+// Motor aplica um conjunto de regras aos dias de trabalho.
+type Motor struct {
+	cfg    Config
+	regras []Regra
+}
+
+// NovoMotor cria um motor com as regras de interjornada e intrajornada.
+func NovoMotor(cfg Config) *Motor {
+	return &Motor{cfg: cfg, regras: []Regra{Interjornada{cfg}, Intrajornada{cfg}}}
+}
+
+// Avaliar retorna as violações de todas as regras nas jornadas iniciadas no dia. Uma jornada
+// que atravessa a meia-noite pertence ao dia em que começou.
+func (m *Motor) Avaliar(dia Dia) []Violacao {
+	violacoes := []Violacao{}
+	j := Jornadas{UserID: dia.UserID, Data: dia.Data}
+	for _, pontos := range separarJornadas(dia.Pontos, m.cfg) {
+		switch inicio := jornada.Dia(pontos[0].Horario, dia.Fuso); {
+		case inicio < dia.Data:
+			j.Anterior = pontos
+		case inicio == dia.Data:
+			j.DoDia = append(j.DoDia, pontos)
+		}
+	}
+	if len(j.DoDia) == 0 {
+		return violacoes
+	}
+	for _, regra := range m.regras {
+		violacoes = append(violacoes, regra.Avaliar(j)...)
+	}
+	return violacoes
+}
+
+// separarJornadas divide os pontos, ordenados por horário, em jornadas. Os pontos são
+// pareados como em jornada.Parear, e a jornada termina quando a pausa depois de uma saída
+// passa de IntrajornadaMaxima, ou quando uma entrada fica InterjornadaMinima sem saída,
+// sinal de que a saída não foi registrada.
+func separarJornadas(pontos []models.Ponto, cfg Config) [][]models.Ponto {
+	var jornadas [][]models.Ponto
+	var atual []models.Ponto
+	for _, p := range pontos {
+		if n := len(atual); n > 0 {
+			pausa := p.Horario.Sub(atual[n-1].Horario)
+			depoisDeSaida := n%2 == 0
+			if (depoisDeSaida && pausa > cfg.IntrajornadaMaxima) ||
+				(!depoisDeSaida && cfg.InterjornadaMinima > 0 && pausa >= cfg.InterjornadaMinima) {
+				jornadas = append(jornadas, atual)
+				atual = nil
+			}
+		}
+		atual = append(atual, p)
+	}
+	if len(atual) > 0 {
+		jornadas = append(jornadas, atual)
+	}
+	return jornadas
+}
+
+// Interjornada verifica o descanso entre cada jornada do dia e a anterior. Havendo mais de
+// uma falta no dia, é registrado o menor descanso.
+type Interjornada struct {
+	Config Config
+}
+
+func (r Interjornada) Avaliar(j Jornadas) []Violacao {
+	if r.Config.InterjornadaMinima <= 0 {
+		return nil
+	}
+
+	menor := r.Config.InterjornadaMinima
+	anterior := j.Anterior
+	for _, atual := range j.DoDia {
+		if anterior != nil {
+			menor = min(menor, atual[0].Horario.Sub(anterior[len(anterior)-1].Horario))
+		}
+		anterior = atual
+	}
+	if menor >= r.Config.InterjornadaMinima {
+		return nil
+	}
+
+	return []Violacao{{
+		UserID:         j.UserID,
+		Data:           j.Data,
+		Tipo:           ViolacaoInterjornada,
+		Descricao:      fmt.Sprintf("descanso de %s entre jornadas, abaixo do mínimo de %s", formatar(menor), formatar(r.Config.InterjornadaMinima)),
+		ApuradoMinutos: int(menor.Minutes()),
+		ExigidoMinutos: int(r.Config.InterjornadaMinima.Minutes()),
+	}}
+}
+
+// Intrajornada verifica o intervalo para repouso e alimentação dentro de cada jornada do dia.
+// Como a CLT não admite o fracionamento do intervalo mínimo, é considerado o maior
+// intervalo entre uma saída e a entrada seguinte. Havendo mais de uma falta no dia, é
+// registrada a maior.
+type Intrajornada struct {
+	Config Config
+}
+
+func (r Intrajornada) Avaliar(j Jornadas) []Violacao {
+	var pior *Violacao
+	for _, pontos := range j.DoDia {
+		trabalhado := jornada.TotalTrabalhado(pontos)
+
+		var exigido time.Duration
+		switch {
+		case r.Config.IntrajornadaLimite > 0 && trabalhado > r.Config.IntrajornadaLimite:
+			exigido = r.Config.IntrajornadaMinima
+		case r.Config.IntrajornadaCurtaLimite > 0 && trabalhado > r.Config.IntrajornadaCurtaLimite:
+			exigido = r.Config.IntrajornadaCurtaMinima
+		}
+		if exigido <= 0 {
+			continue
+		}
+
+		periodos, _ := jornada.Parear(pontos)
+		var maiorIntervalo time.Duration
+		for i := 1; i < len(periodos); i++ {
+			maiorIntervalo = max(maiorIntervalo, periodos[i].Entrada.Horario.Sub(periodos[i-1].Saida.Horario))
+		}
+		if maiorIntervalo >= exigido {
+			continue
+		}
+
+		if pior == nil || exigido-maiorIntervalo > time.Duration(pior.ExigidoMinutos-pior.ApuradoMinutos)*time.Minute {
+			pior = &Violacao{
+				UserID:         j.UserID,
+				Data:           j.Data,
+				Tipo:           ViolacaoIntrajornada,
+				Descricao:      fmt.Sprintf("intervalo de %s em jornada de %s, abaixo do mínimo de %s", formatar(maiorIntervalo), formatar(trabalhado), formatar(exigido)),
+				ApuradoMinutos: int(maiorIntervalo.Minutes()),
+				ExigidoMinutos: int(exigido.Minutes()),
+			}
+		}
+	}
+	if pior == nil {
+		return nil
+	}
+	return []Violacao{*pior}
+}
+
+func formatar(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
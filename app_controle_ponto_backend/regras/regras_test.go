@@ -0,0 +1,169 @@
+package regras
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"controle-ponto-api/models"
+)
+
+const hora = 60
+
+// pontosEm cria pontos nos minutos informados, contados a partir da meia-noite (UTC) de
+// 4 de março de 2024; valores negativos caem na véspera.
+func pontosEm(minutos ...int) []models.Ponto {
+	meiaNoite := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	pontos := make([]models.Ponto, len(minutos))
+	for i, m := range minutos {
+		pontos[i] = models.Ponto{ID: strconv.Itoa(i + 1), UserID: 7, Horario: meiaNoite.Add(time.Duration(m) * time.Minute)}
+	}
+	return pontos
+}
+
+func TestMotorAvaliar(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		pontos []models.Ponto
+		// want lista as violações como tipo:minutos apurados
+		want []string
+	}{
+		{
+			name:   "rest of exactly 11 hours",
+			pontos: pontosEm(-11*hora, -2*hora, 9*hora, 13*hora),
+		},
+		{
+			name:   "rest under 11 hours",
+			pontos: pontosEm(-11*hora, -1*hora, 8*hora, 12*hora),
+			want:   []string{"interjornada:540"},
+		},
+		{
+			name:   "1 hour break after more than 6 hours",
+			pontos: pontosEm(8*hora, 12*hora, 13*hora, 17*hora),
+		},
+		{
+			name:   "short break after more than 6 hours",
+			pontos: pontosEm(8*hora, 12*hora, 12*hora+30, 16*hora),
+			want:   []string{"intrajornada:30"},
+		},
+		{
+			name:   "15 minute break after more than 4 hours",
+			pontos: pontosEm(8*hora, 10*hora, 10*hora+15, 12*hora+30),
+		},
+		{
+			name:   "short break after more than 4 hours",
+			pontos: pontosEm(8*hora, 10*hora, 10*hora+10, 12*hora+30),
+			want:   []string{"intrajornada:10"},
+		},
+		{
+			name:   "no break needed for exactly 4 hours",
+			pontos: pontosEm(8*hora, 12*hora),
+		},
+		{
+			name:   "no break after more than 4 hours",
+			pontos: pontosEm(8*hora, 12*hora+30),
+			want:   []string{"intrajornada:0"},
+		},
+		{
+			// A pause longer than 2 hours ends the jornada, so it isn't a break
+			name:   "two jornadas in a day",
+			pontos: pontosEm(6*hora, 10*hora, 13*hora, 17*hora),
+			want:   []string{"interjornada:180"},
+		},
+		{
+			name:   "both rules violated",
+			pontos: pontosEm(-10*hora, -3*hora, 5*hora, 9*hora, 9*hora+30, 13*hora),
+			want:   []string{"interjornada:480", "intrajornada:30"},
+		},
+		{
+			// 22:00 to 06:00 with a break from 02:00 to 03:00, on both nights
+			name:   "overnight shifts",
+			pontos: pontosEm(-2*hora, 2*hora, 3*hora, 6*hora, 22*hora, 26*hora, 27*hora, 30*hora),
+		},
+		{
+			name:   "overnight shift with a short break",
+			pontos: pontosEm(22*hora, 26*hora, 26*hora+15, 30*hora),
+			want:   []string{"intrajornada:15"},
+		},
+		{
+			name:   "short rest after an overnight shift",
+			pontos: pontosEm(-2*hora, 2*hora, 3*hora, 6*hora, 14*hora, 18*hora),
+			want:   []string{"interjornada:480"},
+		},
+		{
+			// The shift belongs to the day it started
+			name:   "day where an overnight shift ends",
+			data:   "2024-03-05",
+			pontos: pontosEm(22*hora, 26*hora, 27*hora, 30*hora),
+		},
+		{
+			name:   "missing exit the day before",
+			pontos: pontosEm(-16*hora, 8*hora, 12*hora, 13*hora, 17*hora),
+		},
+		{
+			// Left open for 12 hours, the entrada isn't paired with the next day's
+			name:   "missing exit the night before",
+			pontos: pontosEm(-4*hora, 8*hora, 12*hora),
+		},
+		{
+			name:   "missing exit at the end of the day",
+			pontos: pontosEm(8*hora, 12*hora, 12*hora+10),
+		},
+		{
+			name:   "no pontos in the day",
+			pontos: pontosEm(-11*hora, -2*hora),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			if data == "" {
+				data = "2024-03-04"
+			}
+			violacoes := NovoMotor(ConfigCLT).Avaliar(Dia{UserID: 7, Data: data, Fuso: time.UTC, Pontos: tt.pontos})
+
+			var got []string
+			for _, v := range violacoes {
+				if v.UserID != 7 || v.Data != data {
+					t.Errorf("violation %+v is not for user 7 on %s", v, data)
+				}
+				got = append(got, v.Tipo+":"+strconv.Itoa(v.ApuradoMinutos))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Avaliar() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSepararJornadas(t *testing.T) {
+	tests := []struct {
+		name   string
+		pontos []models.Ponto
+		// want lista os IDs dos pontos de cada jornada
+		want [][]string
+	}{
+		{"break of exactly 2 hours", pontosEm(8*hora, 12*hora, 14*hora, 18*hora), [][]string{{"1", "2", "3", "4"}}},
+		{"pause over 2 hours", pontosEm(8*hora, 12*hora, 14*hora+1, 18*hora), [][]string{{"1", "2"}, {"3", "4"}}},
+		{"overnight", pontosEm(22*hora, 26*hora, 27*hora, 30*hora), [][]string{{"1", "2", "3", "4"}}},
+		{"open entrada under 11 hours", pontosEm(8*hora, 18*hora+59), [][]string{{"1", "2"}}},
+		{"open entrada for 11 hours", pontosEm(8*hora, 19*hora, 23*hora), [][]string{{"1"}, {"2", "3"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+			for _, jornada := range separarJornadas(tt.pontos, ConfigCLT) {
+				var ids []string
+				for _, p := range jornada {
+					ids = append(ids, p.ID)
+				}
+				got = append(got, ids)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("separarJornadas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
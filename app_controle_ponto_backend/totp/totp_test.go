@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// segredoRFC é o segredo SHA-1 do Apêndice B da RFC 6238, codificado como nas URIs.
+var segredoRFC = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238(t *testing.T) {
+	// Appendix B lists 8 digit codes; a 6 digit code is the same value mod 10^6
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		t.Run(time.Unix(tt.unix, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			got, err := Code(segredoRFC, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.code {
+				t.Errorf("Code() = %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestCodeSecretEncoding(t *testing.T) {
+	want, _ := Code(segredoRFC, 1)
+	// Secrets copied from an authenticator app may be in lower case
+	if got, err := Code(strings.ToLower(segredoRFC), 1); err != nil || got != want {
+		t.Errorf("Code() with a lower case secret = %s, %v; want %s", got, err, want)
+	}
+	if _, err := Code("não é base32", 1); err == nil {
+		t.Error("Code() with an invalid secret returned no error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	code := func(step int64) string {
+		c, err := Code(segredoRFC, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastUsed int64
+		step     int64
+		ok       bool
+	}{
+		{name: "current step", code: code(current), step: current, ok: true},
+		{name: "previous step within skew", code: code(current - 1), step: current - 1, ok: true},
+		{name: "next step within skew", code: code(current + 1), step: current + 1, ok: true},
+		{name: "two steps behind", code: code(current - 2)},
+		{name: "two steps ahead", code: code(current + 2)},
+		{name: "typed with spaces", code: code(current)[:3] + " " + code(current)[3:], step: current, ok: true},
+		{name: "wrong length", code: code(current)[:5]},
+		{name: "replayed code", code: code(current), lastUsed: current},
+		// A code already used can't be followed by an older one still inside the window
+		{name: "older than the last used", code: code(current - 1), lastUsed: current},
+		{name: "newer than the last used", code: code(current + 1), lastUsed: current, step: current + 1, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(segredoRFC, tt.code, now, tt.lastUsed)
+			if ok != tt.ok || step != tt.step {
+				t.Errorf("Validate(%q) = %d, %v; want %d, %v", tt.code, step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := Code(segredoRFC, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastUsed, ok := Validate(segredoRFC, code, now, 0)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+	// Still inside the window, but the stored step stops the same code being used twice
+	if _, ok := Validate(segredoRFC, code, now.Add(Period/2), lastUsed); ok {
+		t.Error("replayed code accepted")
+	}
+}
@@ -0,0 +1,97 @@
+// Package tracing sets up OpenTelemetry distributed tracing. Trace context travels in the
+// W3C traceparent and tracestate headers, and finished spans are exported over OTLP to a
+// collector or printed to stdout.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters accepted by New.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// InstrumentationName names the tracer of the API's own spans.
+const InstrumentationName = "controle-ponto-api"
+
+// Options configures New.
+type Options struct {
+	// Exporter is "otlp", "stdout" or "none", which keeps spans from being recorded while
+	// still propagating the trace context of incoming requests.
+	Exporter string
+	// Endpoint is the OTLP/HTTP collector URL, such as http://localhost:4318. When empty the
+	// exporter reads OTEL_EXPORTER_OTLP_ENDPOINT, falling back to https://localhost:4318.
+	Endpoint string
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded, from 0 to 1. Traces started by a
+	// caller follow the caller's sampling decision.
+	SampleRatio float64
+}
+
+// New installs the global propagator and tracer provider. The returned function flushes
+// pending spans and must be called before the process exits.
+func New(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(opts.Exporter) {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var o []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			o = append(o, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, o...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("error building trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span of the API, child of the span in ctx, if any.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, opts...)
+}
+
+// Valid reports whether exporter is accepted by New.
+func Valid(exporter string) bool {
+	switch strings.ToLower(exporter) {
+	case ExporterNone, ExporterOTLP, ExporterStdout:
+		return true
+	}
+	return false
+}
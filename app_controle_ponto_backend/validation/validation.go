@@ -0,0 +1,182 @@
+// Package validation decodes request bodies and checks their fields, collecting every
+// problem as a FieldError so clients can show each one next to its input.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Codes of FieldError, stable for clients to branch on.
+const (
+	CodeRequired    = "required"
+	CodeInvalid     = "invalid"
+	CodeInvalidType = "invalid_type"
+	CodeTooLong     = "too_long"
+	CodeOutOfRange  = "out_of_range"
+	CodeNotAllowed  = "not_allowed"
+)
+
+// FieldError is a problem with one field of the request. Field uses the JSON name, with
+// dots and indexes for nested values ("pontos[2].client_id").
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// format and args produced Message, kept so it can be translated
+	format string
+	args   []interface{}
+}
+
+func newFieldError(field, code, format string, args ...interface{}) FieldError {
+	message := format
+	if len(args) > 0 {
+		message = fmt.Sprintf(format, args...)
+	}
+	return FieldError{Field: field, Code: code, Message: message, format: format, args: args}
+}
+
+// Errors is every FieldError of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, f := range e {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Localize returns a copy of e with each Message rewritten by translate, which receives
+// the English format and arguments the message was built from.
+func (e Errors) Localize(translate func(format string, args ...interface{}) string) Errors {
+	localized := make(Errors, len(e))
+	for i, f := range e {
+		localized[i] = f
+		if f.format != "" {
+			localized[i].Message = translate(f.format, f.args...)
+		}
+	}
+	return localized
+}
+
+// ErrInvalidJSON is returned, wrapped, when the body is not valid JSON.
+var ErrInvalidJSON = errors.New("invalid JSON")
+
+// DecodeJSON decodes the body into dst. A field dst doesn't have or a value of the wrong
+// type comes back as Errors naming the field; any other problem, including data after the
+// JSON value, wraps ErrInvalidJSON.
+func DecodeJSON(body io.Reader, dst interface{}) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if err == nil {
+		if _, err := dec.Token(); err != io.EOF {
+			return fmt.Errorf("%w: unexpected data after the JSON value", ErrInvalidJSON)
+		}
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Errors{newFieldError(typeErr.Field, CodeInvalidType, "must be of type %s, got %s", jsonType(typeErr.Type.Kind().String()), typeErr.Value)}
+	}
+	// encoding/json has no error type for unknown fields, only this message
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if field, err := strconv.Unquote(name); err == nil {
+			return Errors{newFieldError(field, CodeNotAllowed, "is not a known field")}
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+}
+
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "string":
+		return "string"
+	case kind == "bool":
+		return "boolean"
+	case kind == "slice", kind == "array":
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// Validator collects the FieldErrors of a request. The zero value is ready to use.
+type Validator struct {
+	errs Errors
+}
+
+// Add records a problem with field. The message is written in English, formatted as in
+// fmt.Sprintf, and translated by Errors.Localize.
+func (v *Validator) Add(field, code, format string, args ...interface{}) {
+	v.errs = append(v.errs, newFieldError(field, code, format, args...))
+}
+
+// Check records the problem unless ok.
+func (v *Validator) Check(ok bool, field, code, format string, args ...interface{}) bool {
+	if !ok {
+		v.Add(field, code, format, args...)
+	}
+	return ok
+}
+
+// Has reports whether field already has a problem, to skip checks that depend on it.
+func (v *Validator) Has(field string) bool {
+	return slices.ContainsFunc(v.errs, func(f FieldError) bool { return f.Field == field })
+}
+
+// Required checks that value is not blank.
+func (v *Validator) Required(field, value string) bool {
+	return v.Check(strings.TrimSpace(value) != "", field, CodeRequired, "is required")
+}
+
+// MaxLength checks that value has at most max characters.
+func (v *Validator) MaxLength(field, value string, max int) bool {
+	return v.Check(utf8.RuneCountInString(value) <= max, field, CodeTooLong, "must have at most %d characters", max)
+}
+
+// Email checks that value is a bare email address, without a display name.
+func (v *Validator) Email(field, value string) bool {
+	addr, err := mail.ParseAddress(value)
+	return v.Check(err == nil && addr.Address == strings.TrimSpace(value), field, CodeInvalid, "must be a valid email address")
+}
+
+// OneOf checks that value is one of allowed.
+func (v *Validator) OneOf(field, value string, allowed ...string) bool {
+	return v.Check(slices.Contains(allowed, value), field, CodeNotAllowed, "must be one of: %s", strings.Join(allowed, ", "))
+}
+
+// Date checks that value is a YYYY-MM-DD date and returns it.
+func (v *Validator) Date(field, value string) (time.Time, bool) {
+	t, err := time.Parse(time.DateOnly, value)
+	return t, v.Check(err == nil, field, CodeInvalid, "must be a date in the YYYY-MM-DD format")
+}
+
+// TimeBetween checks that t is set and within [min, max].
+func (v *Validator) TimeBetween(field string, t, min, max time.Time) bool {
+	if !v.Check(!t.IsZero(), field, CodeRequired, "is required") {
+		return false
+	}
+	return v.Check(!t.Before(min) && !t.After(max), field, CodeOutOfRange,
+		"must be between %s and %s", min.UTC().Format(time.RFC3339), max.UTC().Format(time.RFC3339))
+}
+
+// Err returns the collected Errors, or nil if there are none.
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// codigos devolve o código de cada erro de err, na ordem, ou nil se não houver erros.
+func codigos(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var campos Errors
+	if !errors.As(err, &campos) {
+		t.Fatalf("error %v is not Errors", err)
+	}
+	var got []string
+	for _, f := range campos {
+		got = append(got, f.Field+":"+f.Code)
+	}
+	return got
+}
+
+func TestValidator(t *testing.T) {
+	minimo := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	maximo := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		check func(v *Validator) bool
+		want  []string
+	}{
+		{"required", func(v *Validator) bool { return v.Required("nome", "Ana") }, nil},
+		{"required blank", func(v *Validator) bool { return v.Required("nome", "  \t") }, []string{"nome:required"}},
+		{"max length counts runes", func(v *Validator) bool { return v.MaxLength("nome", "João", 4) }, nil},
+		{"too long", func(v *Validator) bool { return v.MaxLength("nome", "Joana", 4) }, []string{"nome:too_long"}},
+		{"email", func(v *Validator) bool { return v.Email("email", "ana@exemplo.com") }, nil},
+		{"email without domain", func(v *Validator) bool { return v.Email("email", "ana@") }, []string{"email:invalid"}},
+		{"email with display name", func(v *Validator) bool { return v.Email("email", "Ana <ana@exemplo.com>") }, []string{"email:invalid"}},
+		{"one of", func(v *Validator) bool { return v.OneOf("papel", "gestor", "funcionario", "gestor") }, nil},
+		{"not one of", func(v *Validator) bool { return v.OneOf("papel", "root", "funcionario", "gestor") }, []string{"papel:not_allowed"}},
+		{"date", func(v *Validator) bool { _, ok := v.Date("inicio", "2024-02-29"); return ok }, nil},
+		{"date that doesn't exist", func(v *Validator) bool { _, ok := v.Date("inicio", "2023-02-29"); return ok }, []string{"inicio:invalid"}},
+		{"date in another format", func(v *Validator) bool { _, ok := v.Date("inicio", "04/03/2024"); return ok }, []string{"inicio:invalid"}},
+		{"time at the lower bound", func(v *Validator) bool { return v.TimeBetween("horario", minimo, minimo, maximo) }, nil},
+		{"time at the upper bound", func(v *Validator) bool { return v.TimeBetween("horario", maximo, minimo, maximo) }, nil},
+		{"zero time", func(v *Validator) bool { return v.TimeBetween("horario", time.Time{}, minimo, maximo) }, []string{"horario:required"}},
+		{"time before", func(v *Validator) bool { return v.TimeBetween("horario", time.Unix(0, 0), minimo, maximo) }, []string{"horario:out_of_range"}},
+		{"time after", func(v *Validator) bool { return v.TimeBetween("horario", maximo.Add(time.Second), minimo, maximo) }, []string{"horario:out_of_range"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Validator
+			ok := tt.check(&v)
+			if ok != (tt.want == nil) {
+				t.Errorf("check returned %v, want %v", ok, tt.want == nil)
+			}
+			if got := codigos(t, v.Err()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeBetweenMessage(t *testing.T) {
+	sp := time.FixedZone("BRT", -3*60*60)
+	var v Validator
+	v.TimeBetween("horario", time.Date(1999, 1, 1, 0, 0, 0, 0, sp), time.Date(2000, 1, 1, 0, 0, 0, 0, sp), time.Date(2024, 1, 1, 0, 0, 0, 0, sp))
+
+	// The bounds are shown in UTC, whatever their zone
+	want := "must be between 2000-01-01T03:00:00Z and 2024-01-01T03:00:00Z"
+	if got := v.errs[0].Message; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type destino struct {
+		Nome  string `json:"nome"`
+		Idade int    `json:"idade"`
+	}
+
+	tests := []struct {
+		name string
+		body string
+		// fields são os erros de campo esperados; invalid, se o corpo não é JSON válido
+		fields  []string
+		invalid bool
+	}{
+		{name: "valid", body: `{"nome": "Ana", "idade": 30}`},
+		{name: "trailing whitespace", body: "{\"nome\": \"Ana\"}\n"},
+		{name: "wrong type", body: `{"nome": "Ana", "idade": "trinta"}`, fields: []string{"idade:invalid_type"}},
+		{name: "unknown field", body: `{"nome": "Ana", "admin": true}`, fields: []string{"admin:not_allowed"}},
+		{name: "trailing data", body: `{"nome": "Ana"} {"nome": "Bia"}`, invalid: true},
+		{name: "trailing garbage", body: `{"nome": "Ana"}}`, invalid: true},
+		{name: "malformed", body: `{"nome": "Ana"`, invalid: true},
+		{name: "empty", body: ``, invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst destino
+			err := DecodeJSON(strings.NewReader(tt.body), &dst)
+
+			if tt.invalid {
+				if !errors.Is(err, ErrInvalidJSON) {
+					t.Errorf("DecodeJSON() = %v, want ErrInvalidJSON", err)
+				}
+				return
+			}
+			if got := codigos(t, err); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("DecodeJSON() errors = %v, want %v", got, tt.fields)
+			}
+			if err == nil && dst.Nome != "Ana" {
+				t.Errorf("nome = %q, want Ana", dst.Nome)
+			}
+		})
+	}
+}
+
+func TestErrorsLocalize(t *testing.T) {
+	var v Validator
+	v.MaxLength("nome", "Joana", 4)
+	v.Add("papel", CodeNotAllowed, "custom")
+	campos := v.Err().(Errors)
+
+	localized := campos.Localize(func(format string, args ...interface{}) string {
+		return "[pt] " + fmt.Sprintf(format, args...)
+	})
+
+	if localized[0].Message != "[pt] must have at most 4 characters" || localized[1].Message != "[pt] custom" {
+		t.Errorf("Localize() = %+v", localized)
+	}
+	// The original keeps its English messages
+	if campos[0].Message != "must have at most 4 characters" {
+		t.Errorf("original message = %q, want it unchanged", campos[0].Message)
+	}
+}
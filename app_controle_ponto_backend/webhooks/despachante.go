@@ -0,0 +1,227 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"controle-ponto-api/backoff"
+	"controle-ponto-api/metrics"
+	"controle-ponto-api/models"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"controle-ponto-api/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Despachante entrega as entregas pendentes da outbox. Várias instâncias podem rodar ao
+// mesmo tempo: cada lote é reservado com FOR UPDATE SKIP LOCKED.
+type Despachante struct {
+	DB      *sql.DB
+	Cliente *http.Client
+	// Intervalo entre as verificações da outbox.
+	Intervalo time.Duration
+	// Lote é o número máximo de entregas reservadas por verificação.
+	Lote int
+	// MaxTentativas é o número de tentativas antes de a entrega ser marcada como falhou.
+	MaxTentativas int
+	// EsperaBase é a espera antes da segunda tentativa; dobra a cada falha até EsperaMaxima.
+	EsperaBase   time.Duration
+	EsperaMaxima time.Duration
+}
+
+// NovoDespachante cria um despachante com os valores padrão.
+func NovoDespachante(db *sql.DB) *Despachante {
+	return &Despachante{
+		DB:            db,
+		Cliente:       &http.Client{Timeout: 10 * time.Second},
+		Intervalo:     5 * time.Second,
+		Lote:          20,
+		MaxTentativas: 8,
+		EsperaBase:    30 * time.Second,
+		EsperaMaxima:  6 * time.Hour,
+	}
+}
+
+// entrega é uma entrega reservada, com o destino e o segredo do webhook.
+type entrega struct {
+	ID         int64
+	EventoID   string
+	Tipo       string
+	Payload    []byte
+	Tentativas int
+	URL        string
+	Segredo    string
+}
+
+// Executar verifica a outbox periodicamente até ctx ser cancelado.
+func (d *Despachante) Executar(ctx context.Context) {
+	ticker := time.NewTicker(d.Intervalo)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := d.ProcessarPendentes(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Error delivering webhooks", "error", err)
+			}
+			// A full batch suggests more are waiting
+			if err != nil || n < d.Lote {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessarPendentes reserva e entrega um lote de entregas vencidas, retornando quantas processou.
+func (d *Despachante) ProcessarPendentes(ctx context.Context) (int, error) {
+	// The lease outlives the HTTP timeout, so a crashed dispatcher's batch is retried later
+	reserva := d.Cliente.Timeout + time.Minute
+
+	rows, err := d.DB.QueryContext(ctx,
+		`WITH reservadas AS (
+			UPDATE webhook_entregas SET proxima_tentativa_em = NOW() + make_interval(secs => $3)
+			WHERE id IN (
+				SELECT id FROM webhook_entregas
+				WHERE status = $1 AND proxima_tentativa_em <= NOW()
+				ORDER BY proxima_tentativa_em
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, webhook_id, evento_id, tipo, payload, tentativas
+		)
+		SELECT r.id, r.evento_id, r.tipo, r.payload, r.tentativas, w.url, w.segredo
+		FROM reservadas r JOIN webhooks w ON w.id = r.webhook_id`,
+		models.EntregaPendente, d.Lote, reserva.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var lote []entrega
+	for rows.Next() {
+		var e entrega
+		if err := rows.Scan(&e.ID, &e.EventoID, &e.Tipo, &e.Payload, &e.Tentativas, &e.URL, &e.Segredo); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		lote = append(lote, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, e := range lote {
+		if err := d.processar(ctx, e); err != nil {
+			return len(lote), err
+		}
+	}
+	return len(lote), nil
+}
+
+// processar entrega e registra uma entrega, num trace próprio.
+func (d *Despachante) processar(ctx context.Context, e entrega) error {
+	ctx, span := tracing.Start(ctx, "webhook "+e.Tipo,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.Int64("webhook.entrega_id", e.ID), attribute.String("webhook.evento_id", e.EventoID)),
+	)
+	defer span.End()
+
+	statusHTTP, errEnvio := d.enviar(ctx, e)
+	if ctx.Err() != nil {
+		// Interrupted by shutdown: the lease expires and the delivery is retried
+		return ctx.Err()
+	}
+	if errEnvio != nil {
+		span.SetStatus(codes.Error, errEnvio.Error())
+	}
+	// A delivery already sent is recorded even when shutdown cancels ctx
+	return d.registrar(context.WithoutCancel(ctx), e, statusHTTP, errEnvio)
+}
+
+// enviar faz o POST assinado. Respostas fora da faixa 2xx são tratadas como falha.
+func (d *Despachante) enviar(ctx context.Context, e entrega) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(e.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "controle-ponto-webhooks/1.0")
+	req.Header.Set(CabecalhoEvento, e.Tipo)
+	req.Header.Set(CabecalhoEventoID, e.EventoID)
+	req.Header.Set(CabecalhoTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(CabecalhoAssinatura, Assinar(e.Segredo, timestamp, e.Payload))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := d.Cliente.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		trecho, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(trecho))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// registrar grava o resultado da tentativa, agendando a próxima ou encerrando a entrega.
+func (d *Despachante) registrar(ctx context.Context, e entrega, statusHTTP int, errEnvio error) error {
+	var status sql.NullInt64
+	if statusHTTP != 0 {
+		status = sql.NullInt64{Int64: int64(statusHTTP), Valid: true}
+	}
+	tentativas := e.Tentativas + 1
+
+	if errEnvio == nil {
+		_, err := d.DB.ExecContext(ctx,
+			`UPDATE webhook_entregas SET status = $1, tentativas = $2, ultimo_status_http = $3, ultimo_erro = '',
+				ultima_tentativa_em = NOW(), entregue_em = NOW()
+			WHERE id = $4`,
+			models.EntregaEntregue, tentativas, status, e.ID,
+		)
+		if err == nil {
+			metrics.WebhookDeliveries.WithLabelValues(metrics.WebhookDelivered).Inc()
+		}
+		return err
+	}
+
+	novoStatus := models.EntregaPendente
+	if tentativas >= d.MaxTentativas {
+		novoStatus = models.EntregaFalhou
+	}
+	_, err := d.DB.ExecContext(ctx,
+		`UPDATE webhook_entregas SET status = $1, tentativas = $2, ultimo_status_http = $3, ultimo_erro = $4,
+			ultima_tentativa_em = NOW(), proxima_tentativa_em = NOW() + make_interval(secs => $5)
+		WHERE id = $6`,
+		novoStatus, tentativas, status, errEnvio.Error(), backoff.Espera(tentativas, d.EsperaBase, d.EsperaMaxima).Seconds(), e.ID,
+	)
+	if err == nil {
+		resultado := metrics.WebhookRetrying
+		if novoStatus == models.EntregaFalhou {
+			resultado = metrics.WebhookFailed
+		}
+		metrics.WebhookDeliveries.WithLabelValues(resultado).Inc()
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package webhooks_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"controle-ponto-api/eventos"
+	"controle-ponto-api/models"
+	"controle-ponto-api/webhooks"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	segredoTeste = "segredo-do-receptor-de-teste"
+	payloadTeste = `{"id":"4b1f0a9e-0000-4000-8000-000000000001","tipo":"ajuste.aprovado","dados":{}}`
+)
+
+// receptor é o servidor de um webhook: confere a assinatura de cada entrega como um
+// cliente faria e responde com os status de respostas, em ordem.
+type receptor struct {
+	*httptest.Server
+	t         *testing.T
+	mu        sync.Mutex
+	respostas []int
+	recebidas int
+}
+
+func novoReceptor(t *testing.T, respostas ...int) *receptor {
+	rc := &receptor{t: t, respostas: respostas}
+	rc.Server = httptest.NewServer(http.HandlerFunc(rc.receber))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receptor) receber(w http.ResponseWriter, r *http.Request) {
+	corpo, _ := io.ReadAll(r.Body)
+	timestamp := r.Header.Get(webhooks.CabecalhoTimestamp)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		rc.t.Errorf("timestamp header %q: %v", timestamp, err)
+	}
+
+	// Computed by hand rather than with webhooks.Assinar, so the test pins the scheme
+	mac := hmac.New(sha256.New, []byte(segredoTeste))
+	mac.Write([]byte(timestamp + "." + string(corpo)))
+	esperada := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(r.Header.Get(webhooks.CabecalhoAssinatura)), []byte(esperada)) {
+		rc.t.Errorf("signature = %q, want %q", r.Header.Get(webhooks.CabecalhoAssinatura), esperada)
+	}
+	if string(corpo) != payloadTeste {
+		rc.t.Errorf("body = %s, want %s", corpo, payloadTeste)
+	}
+	if got := r.Header.Get(webhooks.CabecalhoEvento); got != eventos.TipoAjusteAprovado {
+		rc.t.Errorf("event header = %q, want %q", got, eventos.TipoAjusteAprovado)
+	}
+
+	rc.mu.Lock()
+	status := rc.respostas[rc.recebidas]
+	rc.recebidas++
+	rc.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+// esperaEntre aceita a espera, em segundos, agendada para a próxima tentativa.
+type esperaEntre struct{ min, max time.Duration }
+
+func (e esperaEntre) Match(v driver.Value) bool {
+	s, ok := v.(float64)
+	d := time.Duration(s * float64(time.Second))
+	return ok && d >= e.min && d <= e.max
+}
+
+// esperarReserva faz a próxima reserva da outbox devolver a entrega com tentativas já feitas.
+func esperarReserva(mock sqlmock.Sqlmock, url string, tentativas int) {
+	mock.ExpectQuery(regexp.QuoteMeta("WITH reservadas AS")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "evento_id", "tipo", "payload", "tentativas", "url", "segredo"}).
+			AddRow(11, "4b1f0a9e-0000-4000-8000-000000000001", eventos.TipoAjusteAprovado, []byte(payloadTeste), tentativas, url, segredoTeste))
+}
+
+func TestDespachanteAssinaturaENovasTentativas(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rc := novoReceptor(t, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusOK)
+	d := webhooks.NovoDespachante(db)
+	d.MaxTentativas = 5
+
+	// First failure: retried after EsperaBase, plus up to 10%
+	esperarReserva(mock, rc.URL, 0)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_entregas SET status = $1")).
+		WithArgs(models.EntregaPendente, 1, int64(http.StatusInternalServerError), sqlmock.AnyArg(), esperaEntre{d.EsperaBase, d.EsperaBase * 11 / 10}, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Second failure: the wait doubles
+	esperarReserva(mock, rc.URL, 1)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_entregas SET status = $1")).
+		WithArgs(models.EntregaPendente, 2, int64(http.StatusServiceUnavailable), sqlmock.AnyArg(), esperaEntre{2 * d.EsperaBase, 2 * d.EsperaBase * 11 / 10}, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Third attempt is delivered
+	esperarReserva(mock, rc.URL, 2)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_entregas SET status = $1")+`.*`+regexp.QuoteMeta("entregue_em = NOW()")).
+		WithArgs(models.EntregaEntregue, 3, int64(http.StatusOK), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	for i := 0; i < 3; i++ {
+		if n, err := d.ProcessarPendentes(t.Context()); err != nil || n != 1 {
+			t.Fatalf("attempt %d: processed %d, error %v", i+1, n, err)
+		}
+	}
+	if rc.recebidas != 3 {
+		t.Errorf("receiver got %d deliveries, want 3", rc.recebidas)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDespachanteDesisteDepoisDeMaxTentativas(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rc := novoReceptor(t, http.StatusBadGateway)
+	d := webhooks.NovoDespachante(db)
+	d.MaxTentativas = 3
+
+	esperarReserva(mock, rc.URL, 2)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE webhook_entregas SET status = $1")).
+		WithArgs(models.EntregaFalhou, 3, int64(http.StatusBadGateway), sqlmock.AnyArg(), sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := d.ProcessarPendentes(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
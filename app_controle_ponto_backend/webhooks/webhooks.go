@@ -0,0 +1,112 @@
+// Package webhooks grava os eventos destinados aos webhooks das empresas em uma
+// tabela de saída (outbox) e os entrega com assinatura HMAC-SHA256 e novas tentativas.
+package webhooks
+
+import (
+	"context"
+	"controle-ponto-api/eventos"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Cabeçalhos enviados em cada entrega.
+const (
+	CabecalhoEvento     = "X-Webhook-Evento"
+	CabecalhoEventoID   = "X-Webhook-Id"
+	CabecalhoTimestamp  = "X-Webhook-Timestamp"
+	CabecalhoAssinatura = "X-Webhook-Assinatura"
+)
+
+// TipoTeste é o evento enviado pelo endpoint de teste de um webhook.
+const TipoTeste = "webhook.teste"
+
+// EventosSuportados são os tipos de evento que um webhook pode assinar.
+var EventosSuportados = []string{
+	eventos.TipoPontoCriado,
+	eventos.TipoPontoAlterado,
+	eventos.TipoPontoRemovido,
+	eventos.TipoAfastamentoSolicitado,
+	eventos.TipoAfastamentoAprovado,
+	eventos.TipoAfastamentoRejeitado,
+	eventos.TipoAfastamentoCancelado,
+	eventos.TipoAjusteSolicitado,
+	eventos.TipoAjusteAprovado,
+	eventos.TipoAjusteRejeitado,
+	TipoTeste,
+}
+
+// Envelope é o corpo JSON entregue aos webhooks.
+type Envelope struct {
+	ID         string      `json:"id"`
+	Tipo       string      `json:"tipo"`
+	OcorridoEm time.Time   `json:"ocorrido_em"`
+	Dados      interface{} `json:"dados"`
+}
+
+// Executor é satisfeito por *sql.DB e *sql.Tx.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enfileirar grava uma entrega pendente para cada webhook ativo da empresa do usuário
+// que assina o tipo do evento. Deve receber a transação que grava a alteração, para que
+// o evento só exista se a alteração for confirmada.
+func Enfileirar(ctx context.Context, q Executor, userID int64, tipo string, dados interface{}) error {
+	id := NovoID()
+	corpo, err := json.Marshal(Envelope{ID: id, Tipo: tipo, OcorridoEm: time.Now().UTC(), Dados: dados})
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO webhook_entregas (webhook_id, evento_id, tipo, payload)
+		SELECT w.id, $1::uuid, $2::text, $3::jsonb FROM webhooks w JOIN users u ON u.empresa_id = w.empresa_id
+		WHERE u.id = $4 AND w.ativo AND $2 = ANY(w.eventos)`,
+		id, tipo, string(corpo), userID,
+	)
+	return err
+}
+
+// EnfileirarTeste grava um evento de teste para um único webhook.
+func EnfileirarTeste(ctx context.Context, q Executor, webhookID int64) (string, error) {
+	id := NovoID()
+	corpo, err := json.Marshal(Envelope{ID: id, Tipo: TipoTeste, OcorridoEm: time.Now().UTC(), Dados: map[string]int64{"webhook_id": webhookID}})
+	if err != nil {
+		return "", err
+	}
+	_, err = q.ExecContext(ctx,
+		"INSERT INTO webhook_entregas (webhook_id, evento_id, tipo, payload) VALUES ($1, $2, $3, $4)",
+		webhookID, id, TipoTeste, string(corpo),
+	)
+	return id, err
+}
+
+// Assinar calcula a assinatura de uma entrega: HMAC-SHA256 de "<timestamp>.<corpo>"
+// com o segredo do webhook, em hexadecimal e prefixada por "sha256=".
+func Assinar(segredo string, timestamp int64, corpo []byte) string {
+	mac := hmac.New(sha256.New, []byte(segredo))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(corpo)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NovoSegredo gera um segredo aleatório para um webhook.
+func NovoSegredo() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// NovoID gera um UUID v4, usado pelos receptores para descartar entregas repetidas.
+func NovoID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}